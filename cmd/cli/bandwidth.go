@@ -0,0 +1,65 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/config"
+	"sda-filesystem/internal/logs"
+)
+
+const rateUsage = "in bytes per second, with an optional K, M or G suffix (0 means unlimited)"
+
+// parseRate converts a rate such as 500K, 10M or 1G into bytes per second
+func parseRate(input string) (int64, error) {
+	rate, err := config.ParseSize(strings.TrimSuffix(strings.TrimSpace(input), "/s"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate %q", input)
+	}
+
+	return rate, nil
+}
+
+// formatRate is the inverse of parseRate
+func formatRate(rate int64) string {
+	if rate == 0 {
+		return "unlimited"
+	}
+
+	return config.FormatSize(rate) + "/s"
+}
+
+// limitCommand handles the `limit [upload|download <rate>]` command.
+// Without arguments the current limits are logged.
+func limitCommand(input []string) error {
+	limits := api.GetBandwidthLimits()
+	if len(input) == 0 {
+		logs.Infof("Upload limit: %s, download limit: %s", formatRate(limits.Upload), formatRate(limits.Download))
+
+		return nil
+	}
+	if len(input) != 2 {
+		return errors.New("usage: limit [upload|download <rate>]")
+	}
+
+	rate, err := parseRate(input[1])
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(input[0]) {
+	case "upload":
+		limits.Upload = rate
+	case "download":
+		limits.Download = rate
+	default:
+		return fmt.Errorf("unknown direction %q, expected upload or download", input[0])
+	}
+	if err = api.SetBandwidthLimits(limits); err != nil {
+		return err
+	}
+	logs.Infof("Set %s limit to %s", strings.ToLower(input[0]), formatRate(rate))
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"testing"
+
+	"sda-filesystem/internal/api"
+)
+
+func TestParseRate(t *testing.T) {
+	var tests = []struct {
+		testname, input string
+		expected        int64
+	}{
+		{"OK_ZERO", "0", 0},
+		{"OK_BYTES", "1500", 1500},
+		{"OK_KILO", "500K", 500 << 10},
+		{"OK_MEGA", "10m", 10 << 20},
+		{"OK_GIGA", "1G/s", 1 << 30},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			rate, err := parseRate(tt.input)
+			if err != nil {
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			} else if rate != tt.expected {
+				t.Errorf("Function returned incorrect rate. Expected=%d, received=%d", tt.expected, rate)
+			}
+		})
+	}
+}
+
+func TestParseRate_Error(t *testing.T) {
+	var tests = []struct {
+		testname, input string
+	}{
+		{"FAIL_EMPTY", ""},
+		{"FAIL_UNIT", "M"},
+		{"FAIL_NEGATIVE", "-1K"},
+		{"FAIL_TEXT", "fast"},
+		{"FAIL_OVERFLOW", "9999999999999G"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			errStr := "invalid rate \"" + tt.input + "\""
+			if _, err := parseRate(tt.input); err == nil {
+				t.Error("Function did not return error")
+			} else if err.Error() != errStr {
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+			}
+		})
+	}
+}
+
+func TestFormatRate(t *testing.T) {
+	var tests = []struct {
+		rate     int64
+		expected string
+	}{
+		{0, "unlimited"},
+		{1500, "1500/s"},
+		{2048, "2K/s"},
+		{10 << 20, "10M/s"},
+		{1 << 30, "1G/s"},
+	}
+
+	for _, tt := range tests {
+		if received := formatRate(tt.rate); received != tt.expected {
+			t.Errorf("Function returned incorrect string. Expected=%s, received=%s", tt.expected, received)
+		}
+	}
+}
+
+func TestLimitCommand(t *testing.T) {
+	origLimits := api.GetBandwidthLimits()
+	defer func() { _ = api.SetBandwidthLimits(origLimits) }()
+
+	_ = api.SetBandwidthLimits(api.BandwidthLimits{})
+
+	if err := limitCommand([]string{"upload", "2M"}); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if err := limitCommand([]string{"Download", "512K"}); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if err := limitCommand([]string{}); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+
+	expected := api.BandwidthLimits{Upload: 2 << 20, Download: 512 << 10}
+	if received := api.GetBandwidthLimits(); received != expected {
+		t.Errorf("Limits were not set correctly\nExpected=%v\nReceived=%v", expected, received)
+	}
+}
+
+func TestLimitCommand_Error(t *testing.T) {
+	origLimits := api.GetBandwidthLimits()
+	defer func() { _ = api.SetBandwidthLimits(origLimits) }()
+
+	var tests = []struct {
+		testname, errStr string
+		input            []string
+	}{
+		{"FAIL_ARGS", "usage: limit [upload|download <rate>]", []string{"upload"}},
+		{"FAIL_RATE", "invalid rate \"lots\"", []string{"upload", "lots"}},
+		{"FAIL_DIRECTION", "unknown direction \"sideways\", expected upload or download", []string{"sideways", "1M"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			if err := limitCommand(tt.input); err == nil {
+				t.Error("Function did not return error")
+			} else if err.Error() != tt.errStr {
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+		})
+	}
+}
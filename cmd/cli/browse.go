@@ -0,0 +1,276 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"sda-filesystem/internal/airlock"
+	"sda-filesystem/internal/config"
+)
+
+// timeFormat is how modification times are shown in tables
+const timeFormat = "2006-01-02 15:04"
+
+var browsePaths []string
+var findFilter airlock.FindFilter
+
+func init() {
+	handlers["ls"] = handlerFuncs{setup: lsSetup, execute: lsHandler}
+	handlers["stat"] = handlerFuncs{setup: statSetup, execute: statHandler}
+	handlers["find"] = handlerFuncs{setup: findSetup, execute: findHandler}
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+
+	return t.Local().Format(timeFormat)
+}
+
+func lsSetup(args []string) (int, error) {
+	set := flag.NewFlagSet("ls", flag.ContinueOnError)
+	set.Usage = func() {
+		fmt.Fprintln(messages, "Usage of ls:")
+		fmt.Fprintln(messages, "  List repositories, buckets, folders and objects in SD Connect and SD Apply")
+		fmt.Fprintln(messages, "Examples:")
+		fmt.Fprintln(messages, " ", os.Args[0], "ls")
+		fmt.Fprintln(messages, " ", os.Args[0], "ls SD-Connect")
+		fmt.Fprintln(messages, " ", os.Args[0], "ls SD-Connect/testbucket/path/to/folder")
+	}
+
+	if err := set.Parse(args); err != nil {
+		return 2, nil
+	}
+	if set.NArg() > 1 {
+		set.Usage()
+
+		return 2, nil
+	}
+	if _, _, _, err := airlock.ParseStoragePath(set.Arg(0)); err != nil {
+		return 2, err
+	}
+	browsePaths = set.Args()
+
+	return 0, nil
+}
+
+func lsHandler() (int, error) {
+	path := ""
+	if len(browsePaths) > 0 {
+		path = browsePaths[0]
+	}
+	entries, err := airlock.ListPath(path)
+	if err != nil {
+		return 0, err
+	}
+	if jsonOutput {
+		setResult(entries)
+
+		return 0, nil
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tSIZE\tMODIFIED\tOWNER")
+	for _, entry := range entries {
+		name, size := entry.Name, formatBytes(entry.Size)
+		if entry.Dir {
+			name += "/"
+			if entry.Size == 0 {
+				size = "-"
+			}
+		}
+		owner := entry.Owner
+		if owner == "" {
+			owner = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", name, size, formatTime(entry.Modified), owner)
+	}
+
+	return 0, w.Flush()
+}
+
+func statSetup(args []string) (int, error) {
+	set := flag.NewFlagSet("stat", flag.ContinueOnError)
+	set.Usage = func() {
+		fmt.Fprintln(messages, "Usage of stat:")
+		fmt.Fprintln(messages, "  Show the size, modification time, owner and metadata of buckets, folders and objects")
+		fmt.Fprintln(messages, "Examples:")
+		fmt.Fprintln(messages, " ", os.Args[0], "stat SD-Connect/testbucket/path/to/file.c4gh")
+		fmt.Fprintln(messages, " ", os.Args[0], "stat SD-Apply/dataset SD-Apply/dataset/file.c4gh")
+	}
+
+	if err := set.Parse(args); err != nil {
+		return 2, nil
+	}
+	if set.NArg() < 1 {
+		set.Usage()
+
+		return 2, nil
+	}
+	for _, arg := range set.Args() {
+		if _, bucket, _, err := airlock.ParseStoragePath(arg); err != nil {
+			return 2, err
+		} else if bucket == "" {
+			return 2, fmt.Errorf("invalid path %q, expected format repository/bucket/path/to/object", arg)
+		}
+	}
+	browsePaths = set.Args()
+
+	return 0, nil
+}
+
+func statHandler() (int, error) {
+	infos := make([]airlock.EntryInfo, 0, len(browsePaths))
+	for _, path := range browsePaths {
+		info, err := airlock.StatPath(path)
+		if err != nil {
+			return 0, err
+		}
+		infos = append(infos, info)
+	}
+	if jsonOutput {
+		setResult(infos)
+
+		return 0, nil
+	}
+
+	for i, info := range infos {
+		if i > 0 {
+			fmt.Fprintln(stdout)
+		}
+		w := tabwriter.NewWriter(stdout, 0, 0, 1, ' ', 0)
+		fmt.Fprintf(w, "Path:\t%s\n", info.Path)
+		if info.Dir {
+			fmt.Fprintf(w, "Type:\tfolder\n")
+			fmt.Fprintf(w, "Objects:\t%d\n", info.Objects)
+		} else {
+			fmt.Fprintf(w, "Type:\tobject\n")
+		}
+		fmt.Fprintf(w, "Size:\t%s (%d bytes)\n", formatBytes(info.Size), info.Size)
+		fmt.Fprintf(w, "Modified:\t%s\n", formatTime(info.Modified))
+		if info.Owner != "" {
+			fmt.Fprintf(w, "Owner:\t%s\n", info.Owner)
+		}
+		if info.ID != "" {
+			fmt.Fprintf(w, "File ID:\t%s\n", info.ID)
+		}
+		if err := w.Flush(); err != nil {
+			return 0, err
+		}
+		if len(info.Metadata) == 0 {
+			continue
+		}
+
+		fmt.Fprintln(stdout, "Metadata:")
+		w = tabwriter.NewWriter(stdout, 0, 0, 1, ' ', 0)
+		for _, key := range slices.Sorted(maps.Keys(info.Metadata)) {
+			fmt.Fprintf(w, "  %s:\t%s\n", key, info.Metadata[key])
+		}
+		if err := w.Flush(); err != nil {
+			return 0, err
+		}
+	}
+
+	return 0, nil
+}
+
+func findSetup(args []string) (int, error) {
+	var minSize, maxSize, newer, older string
+	set := flag.NewFlagSet("find", flag.ContinueOnError)
+	set.StringVar(&findFilter.Name, "name", "", "Glob that the object name must match, or the whole path if the glob contains '/'")
+	set.StringVar(&minSize, "min-size", "", "Minimum size of the objects, with an optional K, M or G suffix")
+	set.StringVar(&maxSize, "max-size", "", "Maximum size of the objects, with an optional K, M or G suffix")
+	set.StringVar(&newer, "newer", "", "Find objects modified after this date (YYYY-MM-DD or RFC 3339)")
+	set.StringVar(&older, "older", "", "Find objects modified before this date (YYYY-MM-DD or RFC 3339)")
+	set.Usage = func() {
+		fmt.Fprintln(messages, "Usage of find:")
+		fmt.Fprintln(messages, "  Search for objects by name, size and modification time in SD Connect and SD Apply")
+		fmt.Fprintln(messages, "Examples:")
+		fmt.Fprintln(messages, " ", os.Args[0], "find -name='*.vcf.c4gh'")
+		fmt.Fprintln(messages, " ", os.Args[0], "find -min-size=1G -newer=2024-01-01 SD-Connect/testbucket")
+		fmt.Fprintln(messages, "Options:")
+		set.PrintDefaults()
+	}
+
+	if err := set.Parse(args); err != nil {
+		return 2, nil
+	}
+	if set.NArg() > 1 {
+		set.Usage()
+
+		return 2, nil
+	}
+	if _, _, _, err := airlock.ParseStoragePath(set.Arg(0)); err != nil {
+		return 2, err
+	}
+	browsePaths = set.Args()
+
+	var err error
+	for _, size := range []struct {
+		name, value string
+		target      *int64
+	}{{"min-size", minSize, &findFilter.MinSize}, {"max-size", maxSize, &findFilter.MaxSize}} {
+		if size.value == "" {
+			continue
+		}
+		if *size.target, err = config.ParseSize(size.value); err != nil {
+			return 2, fmt.Errorf("invalid value for flag -%s: %w", size.name, err)
+		}
+	}
+	for _, date := range []struct {
+		name, value string
+		target      *time.Time
+	}{{"newer", newer, &findFilter.Newer}, {"older", older, &findFilter.Older}} {
+		if date.value == "" {
+			continue
+		}
+		if *date.target, err = parseDate(date.value); err != nil {
+			return 2, fmt.Errorf("invalid value for flag -%s: %w", date.name, err)
+		}
+	}
+
+	return 0, nil
+}
+
+// parseDate accepts a date as YYYY-MM-DD in local time, or a timestamp in RFC 3339 format
+func parseDate(input string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, input, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, input)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", input)
+	}
+
+	return t, nil
+}
+
+func findHandler() (int, error) {
+	path := ""
+	if len(browsePaths) > 0 {
+		path = browsePaths[0]
+	}
+	entries, err := airlock.Find(path, findFilter)
+	if err != nil {
+		return 0, err
+	}
+	if jsonOutput {
+		setResult(entries)
+
+		return 0, nil
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "PATH\tSIZE\tMODIFIED")
+	for _, entry := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", entry.Path, formatBytes(entry.Size), formatTime(entry.Modified))
+	}
+
+	return 0, w.Flush()
+}
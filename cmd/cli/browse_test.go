@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"sda-filesystem/internal/airlock"
+	"sda-filesystem/internal/api"
+)
+
+func TestBrowseSetup(t *testing.T) {
+	newer := time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)
+	older := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		testname, args, errStr string
+		setup                  func([]string) (int, error)
+		code                   int
+		paths                  []string
+		filter                 airlock.FindFilter
+	}{
+		{"OK_LS_1", "", "", lsSetup, 0, []string{}, airlock.FindFilter{}},
+		{"OK_LS_2", "SD-Connect/bucket", "", lsSetup, 0, []string{"SD-Connect/bucket"}, airlock.FindFilter{}},
+		{"OK_STAT", "SD-Connect/bucket/file.c4gh SD-Apply/dataset", "", statSetup, 0, []string{"SD-Connect/bucket/file.c4gh", "SD-Apply/dataset"}, airlock.FindFilter{}},
+		{
+			"OK_FIND", "-name=*.c4gh -min-size=1K -max-size=2M -newer=2024-01-02 -older=2024-06-01T12:00:00Z SD-Apply", "",
+			findSetup, 0, []string{"SD-Apply"},
+			airlock.FindFilter{Name: "*.c4gh", MinSize: 1 << 10, MaxSize: 2 << 20, Newer: newer, Older: older},
+		},
+		{"FAIL_LS_ARGS", "SD-Connect SD-Apply", "", lsSetup, 2, nil, airlock.FindFilter{}},
+		{"FAIL_LS_REPOSITORY", "Allas", "unknown repository \"Allas\", expected one of SD-Connect, SD-Apply", lsSetup, 2, nil, airlock.FindFilter{}},
+		{"FAIL_STAT_NO_ARGS", "", "", statSetup, 2, nil, airlock.FindFilter{}},
+		{"FAIL_STAT_NO_BUCKET", "SD-Connect", "invalid path \"SD-Connect\", expected format repository/bucket/path/to/object", statSetup, 2, nil, airlock.FindFilter{}},
+		{"FAIL_FIND_SIZE", "-min-size=big", "invalid value for flag -min-size: invalid size \"big\"", findSetup, 2, []string{}, airlock.FindFilter{}},
+		{
+			"FAIL_FIND_DATE", "-older=yesterday", "invalid value for flag -older: invalid date \"yesterday\", expected YYYY-MM-DD or RFC 3339",
+			findSetup, 2, []string{}, airlock.FindFilter{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			t.Cleanup(func() {
+				browsePaths = nil
+				findFilter = airlock.FindFilter{}
+			})
+
+			code, err := runSetup(tt.setup, tt.args)
+
+			if code != tt.code {
+				t.Errorf("Received incorrect status code. Expected=%d, received=%d", tt.code, code)
+			}
+			switch {
+			case tt.errStr == "":
+				if err != nil {
+					t.Errorf("Returned unexpected err: %s", err.Error())
+				}
+			case err == nil:
+				t.Error("Function should have returned error")
+			case err.Error() != tt.errStr:
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+			if !reflect.DeepEqual(tt.paths, browsePaths) {
+				t.Errorf("Received incorrect paths. Expected=%v, received=%v", tt.paths, browsePaths)
+			}
+			if tt.code == 0 && !reflect.DeepEqual(findFilter, tt.filter) {
+				t.Errorf("Flags have incorrect values. Expected=%+v, received=%+v", tt.filter, findFilter)
+			}
+		})
+	}
+}
+
+func TestBrowseHandlers(t *testing.T) {
+	modified := time.Date(2024, 3, 5, 8, 0, 0, 0, time.Local)
+	entries := []airlock.Entry{
+		{Path: "SD-Connect/bucket/dir", Name: "dir", Dir: true, Size: 2048, Modified: &modified},
+		{Path: "SD-Connect/bucket/file.c4gh", Name: "file.c4gh", Size: 100, Owner: "project_2002"},
+	}
+	info := airlock.EntryInfo{
+		Entry:    airlock.Entry{Path: "SD-Connect/bucket/file.c4gh", Name: "file.c4gh", Size: 100, Modified: &modified},
+		Metadata: map[string]string{api.MetaMode: "0644", api.MetaChecksum: "abc"},
+	}
+
+	var tests = []struct {
+		testname, output string
+		handler          func() (int, error)
+	}{
+		{
+			"OK_LS", "NAME       SIZE     MODIFIED          OWNER\n" +
+				"dir/       2.0 KiB  2024-03-05 08:00  -\n" +
+				"file.c4gh  100 B    -                 project_2002\n",
+			lsHandler,
+		},
+		{
+			"OK_STAT", "Path:     SD-Connect/bucket/file.c4gh\nType:     object\nSize:     100 B (100 bytes)\nModified: 2024-03-05 08:00\n" +
+				"Metadata:\n  source-mode:   0644\n  source-sha256: abc\n",
+			statHandler,
+		},
+		{
+			"OK_FIND", "PATH                         SIZE     MODIFIED\n" +
+				"SD-Connect/bucket/dir        2.0 KiB  2024-03-05 08:00\n" +
+				"SD-Connect/bucket/file.c4gh  100 B    -\n",
+			findHandler,
+		},
+	}
+
+	origListPath := airlock.ListPath
+	origStatPath := airlock.StatPath
+	origFind := airlock.Find
+	origStdout := stdout
+	defer func() {
+		airlock.ListPath = origListPath
+		airlock.StatPath = origStatPath
+		airlock.Find = origFind
+		stdout = origStdout
+		browsePaths = nil
+		jsonOutput = false
+		results = commandResult{}
+	}()
+
+	airlock.ListPath = func(string) ([]airlock.Entry, error) {
+		return entries, nil
+	}
+	airlock.StatPath = func(string) (airlock.EntryInfo, error) {
+		return info, nil
+	}
+	airlock.Find = func(string, airlock.FindFilter) ([]airlock.Entry, error) {
+		return entries, nil
+	}
+	browsePaths = []string{"SD-Connect/bucket/file.c4gh"}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			stdout = buf
+
+			if _, err := tt.handler(); err != nil {
+				t.Fatalf("Returned unexpected err: %s", err.Error())
+			}
+			if buf.String() != tt.output {
+				t.Errorf("Incorrect output\nExpected=%q\nReceived=%q", tt.output, buf.String())
+			}
+		})
+	}
+
+	// With -output=json the handlers only set the results, which are printed once the subcommand has finished
+	jsonOutput = true
+	for _, tt := range []struct {
+		handler  func() (int, error)
+		expected any
+	}{{lsHandler, entries}, {statHandler, []airlock.EntryInfo{info}}, {findHandler, entries}} {
+		buf := &bytes.Buffer{}
+		stdout = buf
+		if _, err := tt.handler(); err != nil {
+			t.Fatalf("Returned unexpected err: %s", err.Error())
+		}
+		if buf.Len() > 0 {
+			t.Errorf("Handler should not print anything, received=%q", buf.String())
+		}
+		if !reflect.DeepEqual(results.Result, tt.expected) {
+			t.Errorf("Incorrect result\nExpected=%+v\nReceived=%+v", tt.expected, results.Result)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"sda-filesystem/internal/airlock"
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/config"
+)
+
+var catPaths []string
+var catOffset, catLength int64
+
+func init() {
+	handlers["cat"] = handlerFuncs{setup: catSetup, execute: catHandler}
+}
+
+func catSetup(args []string) (int, error) {
+	var byteRange string
+	set := flag.NewFlagSet("cat", flag.ContinueOnError)
+	set.StringVar(&byteRange, "range", "", "Bytes to print, given as START-END (END excluded), START- or -LENGTH, with optional K, M or G suffixes")
+	set.Usage = func() {
+		fmt.Fprintln(messages, "Usage of cat:")
+		fmt.Fprintln(messages, "  Print the decrypted content of objects in SD Connect or SD Apply without mounting Data Gateway")
+		fmt.Fprintln(messages, "Examples:")
+		fmt.Fprintln(messages, " ", os.Args[0], "cat SD-Connect/testbucket/path/to/file.txt.c4gh | head")
+		fmt.Fprintln(messages, " ", os.Args[0], "cat -range=0-1M SD-Apply/dataset/file.vcf.gz.c4gh | zcat")
+		fmt.Fprintln(messages, " ", os.Args[0], "cat -range=-100 SD-Connect/testbucket/file.csv.c4gh")
+		fmt.Fprintln(messages, "Options:")
+		set.PrintDefaults()
+	}
+
+	if err := set.Parse(args); err != nil {
+		return 2, nil
+	}
+	if set.NArg() < 1 {
+		set.Usage()
+
+		return 2, nil
+	}
+
+	if jsonOutput {
+		return 2, errors.New("cat writes the content of objects on stdout and does not support -output=json")
+	}
+
+	var err error
+	if catOffset, catLength, err = parseByteRange(byteRange); err != nil {
+		return 2, err
+	}
+	for _, arg := range set.Args() {
+		rep, bucket, object, err := airlock.ParseStoragePath(arg)
+		if err != nil {
+			return 2, err
+		}
+		if bucket == "" || object == "" || strings.HasSuffix(object, "/") {
+			return 2, fmt.Errorf("invalid path %q, expected format repository/bucket/path/to/object", arg)
+		}
+		if rep == api.SDConnect && !api.SDConnectEnabled() {
+			return 0, errors.New("you do not have SD Connect enabled")
+		}
+	}
+	catPaths = set.Args()
+
+	return 0, nil
+}
+
+// parseByteRange parses a range given as START-END, START- or -LENGTH into an offset and a length. END is exclusive,
+// so that 0-1M is the first MiB and 1M-2M the next one. A negative offset counts from the end of the object and
+// a negative length means the rest of the object.
+func parseByteRange(input string) (int64, int64, error) {
+	if input == "" {
+		return 0, -1, nil
+	}
+
+	invalid := fmt.Errorf("invalid range %q, expected START-END, START- or -LENGTH", input)
+	first, last, ok := strings.Cut(input, "-")
+	if !ok {
+		return 0, 0, invalid
+	}
+	if first == "" {
+		length, err := config.ParseSize(last)
+		if err != nil || length == 0 {
+			return 0, 0, invalid
+		}
+
+		return -length, -1, nil
+	}
+
+	start, err := config.ParseSize(first)
+	if err != nil {
+		return 0, 0, invalid
+	}
+	if last == "" {
+		return start, -1, nil
+	}
+	end, err := config.ParseSize(last)
+	if err != nil || end <= start {
+		return 0, 0, invalid
+	}
+
+	return start, end - start, nil
+}
+
+func catHandler() (int, error) {
+	defer api.DeleteWhitelistedKeys()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for _, path := range catPaths {
+		if err := airlock.Cat(ctx, stdout, path, catOffset, catLength); err != nil {
+			return 0, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	}
+
+	return 0, nil
+}
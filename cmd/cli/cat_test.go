@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"sda-filesystem/internal/airlock"
+	"sda-filesystem/internal/api"
+)
+
+func TestCatSetup(t *testing.T) {
+	var tests = []struct {
+		testname, args, errStr string
+		code                   int
+		enabled                bool
+		paths                  []string
+		offset, length         int64
+	}{
+		{"OK_1", "SD-Connect/bucket/file.txt.c4gh", "", 0, true, []string{"SD-Connect/bucket/file.txt.c4gh"}, 0, -1},
+		{"OK_2", "-range=10-20 SD-Apply/example.com/dataset/file.c4gh SD-Connect/bucket/a.c4gh", "", 0, true, []string{"SD-Apply/example.com/dataset/file.c4gh", "SD-Connect/bucket/a.c4gh"}, 10, 10},
+		{"OK_3", "-range=1K- SD-Apply/dataset/file.c4gh", "", 0, false, []string{"SD-Apply/dataset/file.c4gh"}, 1 << 10, -1},
+		{"OK_4", "-range=-100 SD-Connect/bucket/file.c4gh", "", 0, true, []string{"SD-Connect/bucket/file.c4gh"}, -100, -1},
+		{"FAIL_NO_ARGS", "", "", 2, true, nil, 0, 0},
+		{"FAIL_RANGE_1", "-range=20-10 SD-Connect/bucket/file.c4gh", "invalid range \"20-10\", expected START-END, START- or -LENGTH", 2, true, nil, 0, 0},
+		{"FAIL_RANGE_2", "-range=100 SD-Connect/bucket/file.c4gh", "invalid range \"100\", expected START-END, START- or -LENGTH", 2, true, nil, 0, 0},
+		{"FAIL_RANGE_3", "-range=- SD-Connect/bucket/file.c4gh", "invalid range \"-\", expected START-END, START- or -LENGTH", 2, true, nil, 0, 0},
+		{"FAIL_BUCKET", "SD-Connect/bucket", "invalid path \"SD-Connect/bucket\", expected format repository/bucket/path/to/object", 2, true, nil, 0, -1},
+		{"FAIL_FOLDER", "SD-Connect/bucket/dir/", "invalid path \"SD-Connect/bucket/dir/\", expected format repository/bucket/path/to/object", 2, true, nil, 0, -1},
+		{"FAIL_NOT_ENABLED", "SD-Connect/bucket/file.c4gh", "you do not have SD Connect enabled", 0, false, nil, 0, -1},
+	}
+
+	origSDConnectEnabled := api.SDConnectEnabled
+	defer func() {
+		api.SDConnectEnabled = origSDConnectEnabled
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			t.Cleanup(func() {
+				catPaths = nil
+				catOffset, catLength = 0, 0
+			})
+
+			api.SDConnectEnabled = func() bool {
+				return tt.enabled
+			}
+
+			code, err := runSetup(catSetup, tt.args)
+
+			if code != tt.code {
+				t.Errorf("Received incorrect status code. Expected=%d, received=%d", tt.code, code)
+			}
+			switch {
+			case tt.errStr == "":
+				if err != nil {
+					t.Errorf("Returned unexpected err: %s", err.Error())
+				}
+			case err == nil:
+				t.Error("Function should have returned error")
+			case err.Error() != tt.errStr:
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+			if !reflect.DeepEqual(tt.paths, catPaths) {
+				t.Errorf("Received incorrect paths. Expected=%v, received=%v", tt.paths, catPaths)
+			}
+			if catOffset != tt.offset || catLength != tt.length {
+				t.Errorf("Incorrect range. Expected=%d %d, received=%d %d", tt.offset, tt.length, catOffset, catLength)
+			}
+		})
+	}
+}
+
+func TestCatHandler(t *testing.T) {
+	origCat := airlock.Cat
+	origDeleteWhitelistedKeys := api.DeleteWhitelistedKeys
+	origStdout := stdout
+	defer func() {
+		airlock.Cat = origCat
+		api.DeleteWhitelistedKeys = origDeleteWhitelistedKeys
+		stdout = origStdout
+		catPaths = nil
+		catOffset, catLength = 0, 0
+	}()
+
+	api.DeleteWhitelistedKeys = func() {}
+	catPaths = []string{"SD-Connect/bucket/a.c4gh", "SD-Connect/bucket/b.c4gh"}
+	catOffset, catLength = 5, 10
+
+	buf := &bytes.Buffer{}
+	stdout = buf
+	airlock.Cat = func(_ context.Context, w io.Writer, p string, offset, length int64) error {
+		if offset != 5 || length != 10 {
+			t.Errorf("Incorrect range. Expected=5 10, received=%d %d", offset, length)
+		}
+		if p == "SD-Connect/bucket/b.c4gh" && buf.Len() == 0 {
+			return errExpected
+		}
+		_, err := w.Write([]byte(p + "\n"))
+
+		return err
+	}
+
+	if _, err := catHandler(); err != nil {
+		t.Fatalf("Returned unexpected err: %s", err.Error())
+	}
+	expected := "SD-Connect/bucket/a.c4gh\nSD-Connect/bucket/b.c4gh\n"
+	if buf.String() != expected {
+		t.Errorf("Incorrect output\nExpected=%q\nReceived=%q", expected, buf.String())
+	}
+
+	buf.Reset()
+	catPaths = []string{"SD-Connect/bucket/b.c4gh"}
+	errStr := "failed to read SD-Connect/bucket/b.c4gh: " + errExpected.Error()
+	if _, err := catHandler(); err == nil {
+		t.Error("Function should have returned error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+}
+
+func TestParseByteRange(t *testing.T) {
+	var tests = []struct {
+		input          string
+		offset, length int64
+	}{
+		{"", 0, -1},
+		{"0-1M", 0, 1 << 20},
+		{"1M-2M", 1 << 20, 1 << 20},
+		{"10-11", 10, 1},
+		{"1K-", 1 << 10, -1},
+		{"-100", -100, -1},
+	}
+
+	for _, tt := range tests {
+		offset, length, err := parseByteRange(tt.input)
+		if err != nil {
+			t.Errorf("Function returned unexpected error for %q: %s", tt.input, err.Error())
+		} else if offset != tt.offset || length != tt.length {
+			t.Errorf("Incorrect range for %q. Expected=%d %d, received=%d %d", tt.input, tt.offset, tt.length, offset, length)
+		}
+	}
+
+	errStr := "invalid range \"10-10\", expected START-END, START- or -LENGTH"
+	if _, _, err := parseByteRange("10-10"); err == nil || err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%v", errStr, err)
+	}
+}
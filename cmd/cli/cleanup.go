@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"sda-filesystem/internal/airlock"
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+)
+
+var cleanupBuckets []string
+var deleteOrphans bool
+
+func init() {
+	handlers["cleanup"] = handlerFuncs{setup: cleanupSetup, execute: cleanupHandler}
+}
+
+func cleanupSetup(args []string) (int, error) {
+	set := flag.NewFlagSet("cleanup", flag.ContinueOnError)
+	set.BoolVar(&deleteOrphans, "delete", false, "Delete the headers that were found")
+	set.Usage = func() {
+		fmt.Fprintln(messages, "Usage of cleanup:")
+		fmt.Fprintln(messages, "  Find headers in Vault whose objects do not exist in SD Connect, left behind by failed exports")
+
+		set.PrintDefaults()
+
+		fmt.Fprintln(messages, "Examples:")
+		fmt.Fprintln(messages, " ", os.Args[0], "cleanup testbucket")
+		fmt.Fprintln(messages, " ", os.Args[0], "cleanup -delete testbucket another-bucket")
+	}
+
+	if err := set.Parse(args); err != nil {
+		return 2, nil
+	}
+	if set.NArg() < 1 {
+		set.Usage()
+
+		return 2, nil
+	}
+
+	for _, arg := range set.Args() {
+		if arg == "" || strings.Contains(strings.Trim(arg, "/"), "/") {
+			return 2, fmt.Errorf("invalid bucket %q", arg)
+		}
+	}
+	cleanupBuckets = set.Args()
+
+	if !api.SDConnectEnabled() {
+		return 0, errors.New("you do not have SD Connect enabled")
+	}
+
+	return 0, nil
+}
+
+func cleanupHandler() (int, error) {
+	found, failed := 0, 0
+	for _, arg := range cleanupBuckets {
+		bucket := strings.Trim(arg, "/")
+		orphans, err := airlock.FindOrphanedHeaders(bucket)
+		if err != nil {
+			logs.Errorf("Could not check headers in bucket %s: %w", bucket, err)
+			addFileResult("", bucket, statusFailed, err)
+			failed++
+
+			continue
+		}
+
+		for _, object := range orphans {
+			found++
+			if !deleteOrphans {
+				logs.Infof("Header without object: %s/%s", bucket, object)
+				addFileResult("", bucket+"/"+object, statusFound, nil)
+
+				continue
+			}
+			if err := api.DeleteHeader(bucket, object); err != nil {
+				logs.Error(err)
+				addFileResult("", bucket+"/"+object, statusFailed, err)
+				failed++
+
+				continue
+			}
+			logs.Infof("Deleted header: %s/%s", bucket, object)
+			addFileResult("", bucket+"/"+object, statusDeleted, nil)
+		}
+	}
+
+	switch {
+	case failed > 0:
+		logs.Errorf("Cleanup finished with %d error(s)", failed)
+
+		return 1, nil
+	case found > 0 && !deleteOrphans:
+		logs.Infof("Found %d header(s) without objects, run with -delete to remove them", found)
+	default:
+		logs.Infof("Cleanup complete, %d header(s) without objects found", found)
+	}
+
+	return 0, nil
+}
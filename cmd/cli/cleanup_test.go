@@ -0,0 +1,148 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sda-filesystem/internal/airlock"
+	"sda-filesystem/internal/api"
+)
+
+func TestCleanupSetup(t *testing.T) {
+	var tests = []struct {
+		testname, args, errStr string
+		code                   int
+		enabled, delete        bool
+		buckets                []string
+	}{
+		{"OK_1", "bucket", "", 0, true, false, []string{"bucket"}},
+		{"OK_2", "-delete bucket /bucket-2/", "", 0, true, true, []string{"bucket", "/bucket-2/"}},
+		{"FAIL_NO_ARGS", "", "", 2, true, false, nil},
+		{"FAIL_BAD_FLAG", "-sync bucket", "", 2, true, false, nil},
+		{"FAIL_OBJECT", "bucket/file.c4gh", "invalid bucket \"bucket/file.c4gh\"", 2, true, false, nil},
+		{"FAIL_NOT_ENABLED", "bucket", "you do not have SD Connect enabled", 0, false, false, []string{"bucket"}},
+	}
+
+	origSDConnectEnabled := api.SDConnectEnabled
+	defer func() { api.SDConnectEnabled = origSDConnectEnabled }()
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			t.Cleanup(func() {
+				cleanupBuckets = nil
+				deleteOrphans = false
+			})
+
+			api.SDConnectEnabled = func() bool {
+				return tt.enabled
+			}
+
+			args := strings.Fields(tt.args)
+
+			// Ignore prints to stdout
+			null, _ := os.Open(os.DevNull)
+			sout, smessages := os.Stdout, messages
+			os.Stdout, messages = null, null
+
+			code, err := cleanupSetup(args)
+
+			os.Stdout, messages = sout, smessages
+			null.Close()
+
+			if code != tt.code {
+				t.Errorf("Received incorrect status code. Expected=%d, received=%d", tt.code, code)
+			}
+			switch {
+			case tt.errStr == "":
+				if err != nil {
+					t.Errorf("Returned unexpected err: %s", err.Error())
+				}
+			case err == nil:
+				t.Error("Function should have returned error")
+			case err.Error() != tt.errStr:
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+			if !reflect.DeepEqual(tt.buckets, cleanupBuckets) {
+				t.Errorf("Received incorrect buckets. Expected=%v, received=%v", tt.buckets, cleanupBuckets)
+			}
+			if deleteOrphans != tt.delete {
+				t.Errorf("Flag -delete has incorrect value. Expected=%t, received=%t", tt.delete, deleteOrphans)
+			}
+		})
+	}
+}
+
+func TestCleanupHandler(t *testing.T) {
+	var tests = []struct {
+		testname string
+		delete   bool
+		code     int
+		deleted  []string
+	}{
+		{"OK_LIST", false, 0, []string{}},
+		{"OK_DELETE", true, 0, []string{"bucket/dir/file.c4gh", "bucket/other.c4gh"}},
+	}
+
+	origFindOrphanedHeaders := airlock.FindOrphanedHeaders
+	origDeleteHeader := api.DeleteHeader
+	defer func() {
+		airlock.FindOrphanedHeaders = origFindOrphanedHeaders
+		api.DeleteHeader = origDeleteHeader
+		cleanupBuckets = nil
+		deleteOrphans = false
+	}()
+
+	airlock.FindOrphanedHeaders = func(bucket string) ([]string, error) {
+		switch bucket {
+		case "bucket":
+			return []string{"dir/file.c4gh", "other.c4gh"}, nil
+		case "empty":
+			return nil, nil
+		}
+
+		return nil, errExpected
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			deleted := []string{}
+			api.DeleteHeader = func(bucket, object string) error {
+				deleted = append(deleted, bucket+"/"+object)
+
+				return nil
+			}
+
+			cleanupBuckets = []string{"/bucket/", "empty"}
+			deleteOrphans = tt.delete
+			code, err := cleanupHandler()
+			if err != nil {
+				t.Errorf("Returned unexpected err: %s", err.Error())
+			}
+			if code != tt.code {
+				t.Errorf("Received incorrect status code. Expected=%d, received=%d", tt.code, code)
+			}
+			if !reflect.DeepEqual(tt.deleted, deleted) {
+				t.Errorf("Incorrect headers were deleted. Expected=%v, received=%v", tt.deleted, deleted)
+			}
+		})
+	}
+
+	deleteOrphans = true
+	api.DeleteHeader = func(bucket, object string) error {
+		if object == "other.c4gh" {
+			return errExpected
+		}
+
+		return nil
+	}
+	cleanupBuckets = []string{"bucket"}
+	if code, err := cleanupHandler(); err != nil || code != 1 {
+		t.Errorf("Function should have failed with code 1, received code %d and error %v", code, err)
+	}
+	cleanupBuckets = []string{"missing"}
+	if code, err := cleanupHandler(); err != nil || code != 1 {
+		t.Errorf("Function should have failed with code 1, received code %d and error %v", code, err)
+	}
+}
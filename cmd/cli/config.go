@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"slices"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/cache"
+	"sda-filesystem/internal/config"
+	"sda-filesystem/internal/logs"
+)
+
+var configPath, profileName string
+
+// settings contains the values read from the configuration file
+var settings = &config.Config{}
+
+// envSettings are the settings that are used for environment variables that are not set
+var envSettings = map[string]string{
+	"connection.proxy-url":       "PROXY_URL",
+	"connection.config-endpoint": "CONFIG_ENDPOINT",
+}
+
+// loadSettings reads the configuration file and applies the settings that are shared by all subcommands.
+// Flags given on the command line and environment variables take precedence over the file.
+func loadSettings() error {
+	var err error
+	if settings, err = config.Load(configPath, profileName); err != nil {
+		return err
+	}
+
+	if err = applySettings(flag.CommandLine, map[string]string{
+		"loglevel": "logging.level", "logformat": "logging.format", "logfile": "logging.file",
+	}); err != nil {
+		return err
+	}
+	if err = logs.SetFormat(logFormat); err != nil {
+		return err
+	}
+	if patterns, ok := settings.Strings("logging.redact"); ok {
+		for _, pattern := range patterns {
+			if err = logs.AddRedactPattern(pattern); err != nil {
+				return fmt.Errorf("invalid value for logging.redact in %s: %w", settings.Path(), err)
+			}
+		}
+	}
+	if logFile != "" {
+		maxSize, backups := int64(logs.DefaultMaxSize), int64(logs.DefaultBackups)
+		if size, ok := settings.Int("logging.max-size"); ok {
+			if err = logs.CheckMaxSize(size); err != nil {
+				return fmt.Errorf("invalid value for logging.max-size in %s: %w", settings.Path(), err)
+			}
+			maxSize = size
+		}
+		if n, ok := settings.Int("logging.backups"); ok {
+			backups = n
+		}
+		if err = logs.SetFile(logFile, maxSize, int(backups)); err != nil {
+			return err
+		}
+	}
+	for key, env := range envSettings {
+		if value, ok := settings.String(key); ok {
+			api.SetEnvDefault(env, value)
+		}
+	}
+	if size, ok := settings.Int("filesystem.cache-size"); ok {
+		if err = cache.SetMaxSize(size); err != nil {
+			return fmt.Errorf("invalid value for filesystem.cache-size in %s: %w", settings.Path(), err)
+		}
+	}
+
+	return nil
+}
+
+// logSettings tells which configuration file and profile are used. Called once the log level is known.
+func logSettings() {
+	if len(settings.Keys()) == 0 {
+		return
+	}
+	if settings.Profile() != "" {
+		logs.Debugf("Using configuration file %s with profile %s", settings.Path(), settings.Profile())
+	} else {
+		logs.Debugf("Using configuration file %s", settings.Path())
+	}
+}
+
+// applySettings gives the flags in `set` that were not given on the command line their values from
+// the configuration file. `keys` maps the names of the flags to the settings.
+func applySettings(set *flag.FlagSet, keys map[string]string) error {
+	given := make(map[string]bool)
+	set.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	for _, name := range slices.Sorted(maps.Keys(keys)) {
+		if given[name] {
+			continue
+		}
+		value, ok := settings.Value(keys[name])
+		if !ok {
+			continue
+		}
+		if err := set.Set(name, value); err != nil {
+			return fmt.Errorf("invalid value for %s in %s: %w", keys[name], settings.Path(), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sda-filesystem/internal/cache"
+	"sda-filesystem/internal/config"
+	"sda-filesystem/internal/logs"
+)
+
+// loadTestSettings writes `data` into a configuration file and loads it into `settings`
+func loadTestSettings(t *testing.T, data string) string {
+	t.Helper()
+
+	file := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatalf("Could not write configuration file: %s", err.Error())
+	}
+	var err error
+	if settings, err = config.Load(file, ""); err != nil {
+		t.Fatalf("Could not load configuration file: %s", err.Error())
+	}
+	t.Cleanup(func() {
+		settings = &config.Config{}
+	})
+
+	return file
+}
+
+func TestLoadSettings(t *testing.T) {
+	origLogLevel, origLogFormat, origLogFile := logLevel, logFormat, logFile
+	origCacheSize := cache.GetMaxSize()
+	defer func() {
+		logLevel, logFormat, logFile = origLogLevel, origLogFormat, origLogFile
+		_ = logs.SetFile("", 0, 0)
+		_ = logs.SetFormat("text")
+		_ = cache.SetMaxSize(origCacheSize)
+		settings = &config.Config{}
+		configPath, profileName = "", ""
+	}()
+
+	dir := t.TempDir()
+	file, logPath := filepath.Join(dir, "config.toml"), filepath.Join(dir, "gateway.log")
+	data := "[logging]\nlevel = \"info\"\nformat = \"json\"\nfile = \"" + logPath + "\"\nmax-size = \"1M\"\n" +
+		"[filesystem]\ncache-size = \"64M\"\n[profiles.debug.logging]\nlevel = \"debug\"\n"
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatalf("Could not write configuration file: %s", err.Error())
+	}
+	configPath, profileName = file, "debug"
+
+	if err := loadSettings(); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if logLevel != "debug" {
+		t.Errorf("Incorrect log level. Expected=debug, received=%s", logLevel)
+	}
+	if cache.GetMaxSize() != 64<<20 {
+		t.Errorf("Incorrect cache size. Expected=%d, received=%d", 64<<20, cache.GetMaxSize())
+	}
+	if logFormat != "json" || logFile != logPath {
+		t.Errorf("Incorrect log settings: format=%s, file=%s", logFormat, logFile)
+	}
+	if _, err := os.Stat(logPath); err != nil {
+		t.Errorf("Log file was not created: %s", err.Error())
+	}
+
+	if err := os.WriteFile(file, []byte("[filesystem]\ncache-size = \"1M\"\n"), 0o600); err != nil {
+		t.Fatalf("Could not write configuration file: %s", err.Error())
+	}
+	profileName = ""
+	errStr := fmt.Sprintf("invalid value for filesystem.cache-size in %s: cache size must be at least 32 MiB", file)
+	if err := loadSettings(); err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+
+	if err := os.WriteFile(file, []byte("[logging]\nfile = \""+logPath+"\"\nmax-size = 0\n"), 0o600); err != nil {
+		t.Fatalf("Could not write configuration file: %s", err.Error())
+	}
+	errStr = fmt.Sprintf("invalid value for logging.max-size in %s: maximum size of log file must be at least 1 MiB", file)
+	if err := loadSettings(); err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+}
+
+func TestApplySettings(t *testing.T) {
+	file := loadTestSettings(t, "[transfer]\nparallel = 8\ndownload-limit = \"2M\"\n[export]\nverify-decrypt = true\non-conflict = \"skip\"\n")
+
+	var parallel, changeRetries int
+	var downloadLimit, onConflict string
+	var decryptCheck bool
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	set.IntVar(&parallel, "parallel", 4, "")
+	set.IntVar(&changeRetries, "retry-changed", 0, "")
+	set.StringVar(&downloadLimit, "download-limit", "0", "")
+	set.StringVar(&onConflict, "on-conflict", "", "")
+	set.BoolVar(&decryptCheck, "verify-decrypt", false, "")
+	if err := set.Parse([]string{"-parallel=2"}); err != nil {
+		t.Fatalf("Parsing flags failed: %s", err.Error())
+	}
+
+	err := applySettings(set, map[string]string{
+		"parallel":       "transfer.parallel",
+		"download-limit": "transfer.download-limit",
+		"verify-decrypt": "export.verify-decrypt",
+	})
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	// Flags given on the command line take precedence, and flags without a setting keep their defaults
+	if parallel != 2 || downloadLimit != "2097152" || !decryptCheck || onConflict != "" {
+		t.Errorf("Flags have incorrect values: parallel=%d, download-limit=%s, verify-decrypt=%t, on-conflict=%q",
+			parallel, downloadLimit, decryptCheck, onConflict)
+	}
+
+	errStr := fmt.Sprintf("invalid value for export.on-conflict in %s: parse error", file)
+	err = applySettings(set, map[string]string{"retry-changed": "export.on-conflict"})
+	if err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+}
+
+func TestSelectedRepositories(t *testing.T) {
+	var tests = []struct {
+		testname, data, errStr string
+		sdapplyOnly            bool
+		expected               map[string]bool
+	}{
+		{"OK_NOT_SET", "", "", false, nil},
+		{"OK_SDAPPLY", "[filesystem]\nrepositories = [\"SD-Connect\"]", "", true, map[string]bool{"SD-Apply": true}},
+		{"OK_SETTING", "[filesystem]\nrepositories = [\"sd-connect\", \"SD-Apply\"]", "", false, map[string]bool{"SD-Connect": true, "SD-Apply": true}},
+		{"FAIL_EMPTY", "[filesystem]\nrepositories = []", "invalid value for filesystem.repositories in %s: no repositories", false, nil},
+		{
+			"FAIL_UNKNOWN", "[filesystem]\nrepositories = [\"Allas\"]",
+			"invalid value for filesystem.repositories in %s: unknown repository \"Allas\", expected one of SD-Connect, SD-Apply", false, nil,
+		},
+		{
+			"FAIL_BUCKET", "[filesystem]\nrepositories = [\"SD-Connect/bucket\"]",
+			"invalid value for filesystem.repositories in %s: invalid repository \"SD-Connect/bucket\"", false, nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			file := loadTestSettings(t, tt.data)
+
+			selected, err := selectedRepositories(tt.sdapplyOnly)
+			switch {
+			case tt.errStr == "":
+				if err != nil {
+					t.Errorf("Returned unexpected err: %s", err.Error())
+				}
+			case err == nil:
+				t.Error("Function should have returned error")
+			case err.Error() != fmt.Sprintf(tt.errStr, file):
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", fmt.Sprintf(tt.errStr, file), err.Error())
+			}
+			if !reflect.DeepEqual(selected, tt.expected) {
+				t.Errorf("Incorrect repositories. Expected=%v, received=%v", tt.expected, selected)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"time"
+)
+
+// ctlTimeout limits how long ctl waits for the daemon. Updating the filesystem can take a while.
+const ctlTimeout = 5 * time.Minute
+
+var ctlRequest controlRequest
+
+func init() {
+	handlers["ctl"] = handlerFuncs{setup: ctlSetup, execute: ctlHandler, local: true}
+}
+
+func ctlSetup(args []string) (int, error) {
+	set := flag.NewFlagSet("ctl", flag.ContinueOnError)
+	set.StringVar(&controlSocket, "socket", defaultSocket(), "Path to the control socket of 'import -daemon' or 'export -socket'")
+	set.Usage = func() {
+		fmt.Fprintf(set.Output(), "Usage: ctl [options] command [arguments]\n\n")
+		fmt.Fprintf(set.Output(), "Commands: update, clear <path>, status, stats, limit [upload|download <rate>], loglevel [level], bundle, unmount\n\n")
+		set.PrintDefaults()
+	}
+
+	if err := set.Parse(args); err != nil {
+		return 2, nil
+	}
+	if set.NArg() == 0 {
+		set.Usage()
+
+		return 2, nil
+	}
+
+	ctlRequest = controlRequest{Command: set.Arg(0), Args: set.Args()[1:]}
+
+	return 0, nil
+}
+
+func ctlHandler() (int, error) {
+	resp, err := sendControl(controlSocket, ctlRequest)
+	if err != nil {
+		return 1, err
+	}
+	if !resp.OK {
+		return 1, errors.New(resp.Message)
+	}
+	if jsonOutput {
+		setResult(resp)
+
+		return 0, nil
+	}
+
+	if resp.Message != "" {
+		fmt.Fprintln(stdout, resp.Message)
+	}
+	if resp.Data != nil {
+		data, err := json.MarshalIndent(resp.Data, "", "  ")
+		if err != nil {
+			return 1, fmt.Errorf("could not format response: %w", err)
+		}
+		fmt.Fprintln(stdout, string(data))
+	}
+
+	return 0, nil
+}
+
+// sendControl sends `req` to the daemon listening at `path` and returns its response
+func sendControl(path string, req controlRequest) (controlResponse, error) {
+	// Keep data as it was sent so that the order of its fields is preserved
+	var data json.RawMessage
+	resp := controlResponse{Data: &data}
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return resp, fmt.Errorf("could not connect to Data Gateway, is 'import -daemon' running?: %w", err)
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(ctlTimeout))
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return resp, fmt.Errorf("could not send command: %w", err)
+	}
+	if err = json.NewDecoder(conn).Decode(&resp); err != nil {
+		return resp, fmt.Errorf("could not read response: %w", err)
+	}
+	if len(data) == 0 {
+		resp.Data = nil
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/cache"
+)
+
+func TestCtlSetup(t *testing.T) {
+	var tests = []struct {
+		testname, args, socket string
+		code                   int
+		request                controlRequest
+	}{
+		{"OK_1", "status", defaultSocket(), 0, controlRequest{Command: "status", Args: []string{}}},
+		{"OK_2", "-socket=/run/dg.sock clear SD-Connect/project/bucket", "/run/dg.sock", 0, controlRequest{Command: "clear", Args: []string{"SD-Connect/project/bucket"}}},
+		{"FAIL_NO_ARGS", "", defaultSocket(), 2, controlRequest{}},
+		{"FAIL_BAD_FLAG", "-mount=/mnt status", defaultSocket(), 2, controlRequest{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			t.Cleanup(func() {
+				ctlRequest = controlRequest{}
+				controlSocket = ""
+			})
+
+			// Ignore prints to stdout and stderr
+			null, _ := os.Open(os.DevNull)
+			sout, serr, smessages := os.Stdout, os.Stderr, messages
+			os.Stdout, os.Stderr, messages = null, null, null
+
+			code, err := ctlSetup(strings.Fields(tt.args))
+
+			os.Stdout, os.Stderr, messages = sout, serr, smessages
+			null.Close()
+
+			switch {
+			case err != nil:
+				t.Errorf("Returned unexpected err: %s", err.Error())
+			case code != tt.code:
+				t.Errorf("Received incorrect status code. Expected=%d, received=%d", tt.code, code)
+			case tt.code == 0 && controlSocket != tt.socket:
+				t.Errorf("Incorrect socket. Expected=%s, received=%s", tt.socket, controlSocket)
+			case !reflect.DeepEqual(ctlRequest, tt.request):
+				t.Errorf("Incorrect request\nExpected=%+v\nReceived=%+v", tt.request, ctlRequest)
+			}
+		})
+	}
+}
+
+func TestCtlHandler(t *testing.T) {
+	var tests = []struct {
+		testname, output, errStr string
+		request                  controlRequest
+	}{
+		{"OK_1", "Log level is info\n", "", controlRequest{Command: "loglevel"}},
+		{"OK_2", "{\n  \"cache\": {\n    \"hits\": 0,\n    \"misses\": 0,\n    \"items\": 0,\n    \"bytes\": 0\n  },\n  \"limits\": {\n    \"upload\": 0,\n    \"download\": 0\n  },\n  \"uptime\": \"0s\"\n}\n", "", controlRequest{Command: "stats"}},
+		{"FAIL_COMMAND", "", "unknown command \"reboot\"", controlRequest{Command: "reboot"}},
+	}
+
+	origCacheStats := api.GetCacheStats
+	origOutput := stdout
+	defer func() {
+		api.GetCacheStats = origCacheStats
+		stdout = origOutput
+		controlSocket = ""
+		ctlRequest = controlRequest{}
+	}()
+
+	api.GetCacheStats = func() cache.Stats {
+		return cache.Stats{}
+	}
+	controlSocket = filepath.Join(t.TempDir(), "control.sock")
+	server, err := listenControl(controlSocket)
+	if err != nil {
+		t.Fatalf("Failed to create control socket: %s", err.Error())
+	}
+	defer server.close()
+	go server.serve()
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			stdout = buf
+			ctlRequest = tt.request
+
+			code, err := ctlHandler()
+
+			switch {
+			case tt.errStr != "":
+				if err == nil {
+					t.Errorf("Function did not return error")
+				} else if err.Error() != tt.errStr {
+					t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+				} else if code != 1 {
+					t.Errorf("Received incorrect status code. Expected=1, received=%d", code)
+				}
+			case err != nil:
+				t.Errorf("Returned unexpected err: %s", err.Error())
+			case buf.String() != tt.output:
+				t.Errorf("Received incorrect output\nExpected=%s\nReceived=%s", tt.output, buf.String())
+			}
+		})
+	}
+}
+
+func TestCtlHandler_NoDaemon(t *testing.T) {
+	origSocket := controlSocket
+	defer func() { controlSocket = origSocket }()
+
+	controlSocket = filepath.Join(t.TempDir(), "missing.sock")
+	code, err := ctlHandler()
+
+	errStr := "could not connect to Data Gateway, is 'import -daemon' running?"
+	if err == nil {
+		t.Errorf("Function did not return error")
+	} else if !strings.HasPrefix(err.Error(), errStr) {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+	if code != 1 {
+		t.Errorf("Received incorrect status code. Expected=1, received=%d", code)
+	}
+}
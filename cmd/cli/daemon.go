@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sda-filesystem/certs"
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/cache"
+	"sda-filesystem/internal/filesystem"
+	"sda-filesystem/internal/logs"
+	"sda-filesystem/internal/support"
+)
+
+// controlReadTimeout is how long the daemon waits for a client to send its request
+const controlReadTimeout = 10 * time.Second
+
+var logLevels = []string{"trace", "debug", "info", "warning", "error"}
+
+// These are variables so that they can be mocked in tests
+var filesOpen = filesystem.FilesOpen
+var updateFilesystem = filesystem.UpdateFilesystem
+var clearPath = filesystem.ClearPath
+var unmountFilesystem = filesystem.UnmountFilesystem
+
+// controlRequest is a single command sent to the control socket
+type controlRequest struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// controlResponse is the answer of the daemon to a controlRequest
+type controlResponse struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message,omitempty"`
+	Data    any    `json:"data,omitempty"`
+}
+
+type daemonStatus struct {
+	Mount        string   `json:"mount"`
+	Project      string   `json:"project"`
+	Repositories []string `json:"repositories"`
+	Version      string   `json:"version"`
+	LogLevel     string   `json:"loglevel"`
+	FilesOpen    bool     `json:"files_open"`
+	PID          int      `json:"pid"`
+	Uptime       string   `json:"uptime"`
+}
+
+type daemonStats struct {
+	Cache  cache.Stats         `json:"cache"`
+	Limits api.BandwidthLimits `json:"limits"`
+	Uptime string              `json:"uptime"`
+}
+
+// exportCommands are the control commands accepted during 'export -socket'
+var exportCommands = []string{"limit", "loglevel"}
+
+// controlServer listens for commands on a Unix socket while Data Gateway is mounted or exporting files
+type controlServer struct {
+	listener net.Listener
+	started  time.Time
+	commands []string   // Commands that are accepted, all of them if empty
+	mu       sync.Mutex // Commands are applied one at a time
+	wg       sync.WaitGroup
+}
+
+// defaultSocket returns the path of the control socket used when -socket is not given
+func defaultSocket() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "data-gateway.sock")
+	}
+
+	return filepath.Join(os.TempDir(), fmt.Sprintf("data-gateway-%d.sock", os.Getuid()))
+}
+
+// listenControl creates the control socket at `path`. A socket left behind by
+// a daemon that is no longer running is replaced.
+func listenControl(path string) (*controlServer, error) {
+	if _, err := os.Stat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+
+			return nil, fmt.Errorf("control socket %s is already in use", path)
+		}
+		if err = os.Remove(path); err != nil {
+			return nil, fmt.Errorf("could not remove stale control socket: %w", err)
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("could not create control socket: %w", err)
+	}
+	if err = os.Chmod(path, 0o600); err != nil {
+		listener.Close()
+
+		return nil, fmt.Errorf("could not restrict access to control socket: %w", err)
+	}
+
+	return &controlServer{listener: listener, started: time.Now()}, nil
+}
+
+// serve accepts connections until the server is closed
+func (s *controlServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				logs.Errorf("Control socket stopped accepting commands: %w", err)
+			}
+
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// close removes the socket and waits for the ongoing commands to finish
+func (s *controlServer) close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+// handle reads one request from `conn` and writes the response
+func (s *controlServer) handle(conn net.Conn) {
+	defer conn.Close()
+
+	var req controlRequest
+	_ = conn.SetReadDeadline(time.Now().Add(controlReadTimeout))
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		s.respond(conn, controlResponse{Message: fmt.Sprintf("invalid request: %s", err.Error())})
+
+		return
+	}
+
+	s.mu.Lock()
+	data, message, err := s.apply(strings.ToLower(req.Command), req.Args)
+	s.mu.Unlock()
+
+	if err != nil {
+		logs.Error(err)
+		s.respond(conn, controlResponse{Message: err.Error()})
+
+		return
+	}
+	s.respond(conn, controlResponse{OK: true, Message: message, Data: data})
+}
+
+func (s *controlServer) respond(conn net.Conn, resp controlResponse) {
+	if err := json.NewEncoder(conn).Encode(resp); err != nil {
+		logs.Warningf("Could not respond to control command: %w", err)
+	}
+}
+
+// apply executes a control command and returns the data and message sent back to the client
+func (s *controlServer) apply(command string, args []string) (any, string, error) {
+	logs.Debugf("Received control command %q", strings.TrimSpace(command+" "+strings.Join(args, " ")))
+	if len(s.commands) > 0 && !slices.Contains(s.commands, command) {
+		return nil, "", fmt.Errorf("command %q is not available, possible commands are {%s}", command, strings.Join(s.commands, ","))
+	}
+
+	switch command {
+	case "update":
+		if filesOpen() {
+			return nil, "", errors.New("you have files in use which prevents updating Data Gateway")
+		}
+		updateFilesystem()
+
+		return nil, "Data Gateway updated", nil
+	case "clear":
+		if len(args) != 1 {
+			return nil, "", errors.New("usage: clear <path>")
+		}
+		path := filepath.Clean(args[0])
+		if err := clearPath(path); err != nil {
+			return nil, "", err
+		}
+
+		return nil, fmt.Sprintf("Cleared %s from cache", path), nil
+	case "status":
+		repositories := []string{}
+		for _, rep := range api.GetRepositories() {
+			repositories = append(repositories, rep.ForPath())
+		}
+
+		return daemonStatus{
+			Mount:        mount,
+			Project:      api.GetProjectName(),
+			Repositories: repositories,
+			Version:      api.GetVersion(),
+			LogLevel:     logs.GetLevel().String(),
+			FilesOpen:    filesOpen(),
+			PID:          os.Getpid(),
+			Uptime:       s.uptime(),
+		}, "", nil
+	case "stats":
+		return daemonStats{
+			Cache:  api.GetCacheStats(),
+			Limits: api.GetBandwidthLimits(),
+			Uptime: s.uptime(),
+		}, "", nil
+	case "bundle":
+		return support.Collect(settings, mount, true, certs.Files), "", nil
+	case "limit":
+		if err := limitCommand(args); err != nil {
+			return nil, "", err
+		}
+		limits := api.GetBandwidthLimits()
+
+		return limits, fmt.Sprintf("Upload limit: %s, download limit: %s", formatRate(limits.Upload), formatRate(limits.Download)), nil
+	case "loglevel":
+		if len(args) > 1 {
+			return nil, "", errors.New("usage: loglevel [trace|debug|info|warning|error]")
+		}
+		if len(args) == 1 {
+			level := strings.ToLower(args[0])
+			if !slices.Contains(logLevels, level) {
+				return nil, "", fmt.Errorf("unknown log level %q, possible values are {%s}", args[0], strings.Join(logLevels, ","))
+			}
+			logs.SetLevel(level)
+		}
+
+		return nil, fmt.Sprintf("Log level is %s", logs.GetLevel().String()), nil
+	case "unmount":
+		logs.Info("Shutting down Data Gateway")
+		if err := unmountFilesystem(); err != nil {
+			return nil, "", err
+		}
+
+		return nil, "Data Gateway unmounted", nil
+	default:
+		return nil, "", fmt.Errorf("unknown command %q", command)
+	}
+}
+
+func (s *controlServer) uptime() string {
+	return time.Since(s.started).Round(time.Second).String()
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/config"
+	"sda-filesystem/internal/logs"
+	"sda-filesystem/internal/support"
+)
+
+func TestDefaultSocket(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	if socket := defaultSocket(); socket != "/run/user/1000/data-gateway.sock" {
+		t.Errorf("Received incorrect socket path. Expected=/run/user/1000/data-gateway.sock, received=%s", socket)
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	if socket := defaultSocket(); filepath.Dir(socket) != filepath.Clean(os.TempDir()) || !strings.HasPrefix(filepath.Base(socket), "data-gateway-") {
+		t.Errorf("Received incorrect socket path %s", socket)
+	}
+}
+
+func TestListenControl(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "control.sock")
+
+	// Leftover file from a daemon that is no longer running
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("Failed to create file: %s", err.Error())
+	}
+
+	server, err := listenControl(path)
+	if err != nil {
+		t.Fatalf("Returned unexpected err: %s", err.Error())
+	}
+
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		t.Errorf("Control socket was not created: %s", err.Error())
+	case info.Mode()&os.ModeSocket == 0:
+		t.Errorf("File %s is not a socket", path)
+	case info.Mode().Perm() != 0o600:
+		t.Errorf("Control socket has incorrect permissions. Expected=0600, received=%04o", info.Mode().Perm())
+	}
+
+	errStr := "control socket " + path + " is already in use"
+	if _, err = listenControl(path); err == nil {
+		t.Errorf("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+
+	server.close()
+	if _, err = os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Control socket was not removed when server was closed")
+	}
+}
+
+func TestControlServer_Apply(t *testing.T) {
+	var tests = []struct {
+		testname, command, message, errStr string
+		args                               []string
+		open                               bool
+		clearErr, unmountErr               error
+		calls                              []string
+		data                               any
+	}{
+		{"OK_UPDATE", "update", "Data Gateway updated", "", nil, false, nil, nil, []string{"update"}, nil},
+		{"OK_CLEAR", "clear", "Cleared SD-Connect/project/bucket from cache", "", []string{"SD-Connect/project/bucket/"}, false, nil, nil, []string{"clear SD-Connect/project/bucket"}, nil},
+		{"OK_LOGLEVEL_1", "loglevel", "Log level is info", "", nil, false, nil, nil, nil, nil},
+		{"OK_LOGLEVEL_2", "loglevel", "Log level is debug", "", []string{"DEBUG"}, false, nil, nil, nil, nil},
+		{"OK_LIMIT", "limit", "Upload limit: 1M/s, download limit: unlimited", "", []string{"upload", "1M"}, false, nil, nil, nil, api.BandwidthLimits{Upload: 1 << 20}},
+		{"OK_STATUS", "status", "", "", nil, true, nil, nil, nil, daemonStatus{
+			Mount: "/mnt/dg", Project: "project_2001", Repositories: []string{"SD-Connect", "SD-Apply"},
+			Version: api.GetVersion(), LogLevel: "info", FilesOpen: true, PID: os.Getpid(), Uptime: "0s",
+		}},
+		{"OK_BUNDLE", "bundle", "", "", nil, false, nil, nil, nil, support.Bundle{Info: support.Info{Mount: "/mnt/dg", Mounted: true}}},
+		{"OK_UNMOUNT", "unmount", "Data Gateway unmounted", "", nil, false, nil, nil, []string{"unmount"}, nil},
+		{"FAIL_UPDATE", "update", "", "you have files in use which prevents updating Data Gateway", nil, true, nil, nil, nil, nil},
+		{"FAIL_CLEAR_ARGS", "clear", "", "usage: clear <path>", nil, false, nil, nil, nil, nil},
+		{"FAIL_CLEAR", "clear", "", errExpected.Error(), []string{"path"}, false, errExpected, nil, []string{"clear path"}, nil},
+		{"FAIL_LOGLEVEL", "loglevel", "", "unknown log level \"verbose\", possible values are {trace,debug,info,warning,error}", []string{"verbose"}, false, nil, nil, nil, nil},
+		{"FAIL_LIMIT", "limit", "", "unknown direction \"sideways\", expected upload or download", []string{"sideways", "1M"}, false, nil, nil, nil, nil},
+		{"FAIL_UNMOUNT", "unmount", "", errExpected.Error(), nil, false, nil, errExpected, []string{"unmount"}, nil},
+		{"FAIL_UNKNOWN", "reboot", "", "unknown command \"reboot\"", nil, false, nil, nil, nil, nil},
+	}
+
+	origFilesOpen := filesOpen
+	origUpdateFilesystem := updateFilesystem
+	origClearPath := clearPath
+	origUnmountFilesystem := unmountFilesystem
+	origGetRepositories := api.GetRepositories
+	origGetProjectName := api.GetProjectName
+	origLimits := api.GetBandwidthLimits()
+	origMount := mount
+	origCollect := support.Collect
+
+	defer func() {
+		filesOpen = origFilesOpen
+		updateFilesystem = origUpdateFilesystem
+		clearPath = origClearPath
+		unmountFilesystem = origUnmountFilesystem
+		api.GetRepositories = origGetRepositories
+		api.GetProjectName = origGetProjectName
+		_ = api.SetBandwidthLimits(origLimits)
+		mount = origMount
+		support.Collect = origCollect
+	}()
+
+	support.Collect = func(_ *config.Config, mount string, mounted bool, _ api.FileReader) support.Bundle {
+		return support.Bundle{Info: support.Info{Mount: mount, Mounted: mounted}}
+	}
+	api.GetRepositories = func() []api.Repo {
+		return []api.Repo{api.SDConnect, api.SDApply}
+	}
+	api.GetProjectName = func() string {
+		return "project_2001"
+	}
+	mount = "/mnt/dg"
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			t.Cleanup(func() {
+				logs.SetLevel("info")
+				_ = api.SetBandwidthLimits(api.BandwidthLimits{})
+			})
+
+			var calls []string
+			filesOpen = func() bool {
+				return tt.open
+			}
+			updateFilesystem = func() {
+				calls = append(calls, "update")
+			}
+			clearPath = func(path string) error {
+				calls = append(calls, "clear "+path)
+
+				return tt.clearErr
+			}
+			unmountFilesystem = func() error {
+				calls = append(calls, "unmount")
+
+				return tt.unmountErr
+			}
+
+			server := &controlServer{started: time.Now()}
+			data, message, err := server.apply(tt.command, tt.args)
+
+			switch {
+			case tt.errStr != "":
+				if err == nil {
+					t.Errorf("Function did not return error")
+				} else if err.Error() != tt.errStr {
+					t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+				}
+			case err != nil:
+				t.Errorf("Returned unexpected err: %s", err.Error())
+			case message != tt.message:
+				t.Errorf("Received incorrect message\nExpected=%s\nReceived=%s", tt.message, message)
+			case !reflect.DeepEqual(data, tt.data):
+				t.Errorf("Received incorrect data\nExpected=%+v\nReceived=%+v", tt.data, data)
+			}
+			if !reflect.DeepEqual(calls, tt.calls) {
+				t.Errorf("Incorrect calls to filesystem. Expected=%v, received=%v", tt.calls, calls)
+			}
+		})
+	}
+}
+
+func TestControlServer_Handle(t *testing.T) {
+	var tests = []struct {
+		testname, request string
+		response          controlResponse
+	}{
+		{"OK", `{"command":"loglevel"}`, controlResponse{OK: true, Message: "Log level is info"}},
+		{"FAIL_COMMAND", `{"command":"reboot"}`, controlResponse{Message: "unknown command \"reboot\""}},
+		{"FAIL_JSON", `reboot`, controlResponse{Message: "invalid request: invalid character 'r' looking for beginning of value"}},
+	}
+
+	server := &controlServer{started: time.Now()}
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			client, conn := net.Pipe()
+			go server.handle(conn)
+
+			if _, err := client.Write([]byte(tt.request + "\n")); err != nil {
+				t.Fatalf("Failed to send request: %s", err.Error())
+			}
+
+			var resp controlResponse
+			if err := json.NewDecoder(client).Decode(&resp); err != nil {
+				t.Fatalf("Failed to read response: %s", err.Error())
+			}
+			client.Close()
+
+			if !reflect.DeepEqual(resp, tt.response) {
+				t.Errorf("Received incorrect response\nExpected=%+v\nReceived=%+v", tt.response, resp)
+			}
+		})
+	}
+}
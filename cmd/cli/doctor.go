@@ -0,0 +1,255 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"sda-filesystem/certs"
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/mountpoint"
+)
+
+// Statuses of the checks done by doctor
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+	checkSkip = "skip"
+)
+
+// certificateWarning is how long before the client certificate expires doctor starts to warn about it
+const certificateWarning = 30 * 24 * time.Hour
+
+// check is the outcome of a single prerequisite checked by doctor
+type check struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+	Hint    string `json:"hint,omitempty"` // How to fix the problem
+}
+
+func init() {
+	handlers["doctor"] = handlerFuncs{setup: doctorSetup, execute: doctorHandler, local: true}
+}
+
+func doctorSetup(args []string) (int, error) {
+	set := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	set.StringVar(&mount, "mount", "", "Path to the Data Gateway mount point that is checked (default from the configuration file or ~/Projects)")
+	set.Usage = func() {
+		fmt.Fprintln(messages, "Usage of doctor:")
+		fmt.Fprintln(messages, "  Check the prerequisites of Data Gateway one by one and tell how to fix the ones that fail")
+		fmt.Fprintln(messages, "Examples:")
+		fmt.Fprintln(messages, " ", os.Args[0], "doctor")
+		fmt.Fprintln(messages, " ", os.Args[0], "-output=json doctor -mount=/path/to/mount")
+		fmt.Fprintln(messages, "Options:")
+		set.PrintDefaults()
+	}
+
+	if err := set.Parse(args); err != nil {
+		return 2, nil
+	}
+	if set.NArg() > 0 {
+		set.Usage()
+
+		return 2, nil
+	}
+
+	return 0, applySettings(set, map[string]string{"mount": "filesystem.mount"})
+}
+
+func doctorHandler() (int, error) {
+	var checks []check
+	add := func(name, status, message, hint string) bool {
+		checks = append(checks, check{Name: name, Status: status, Message: message, Hint: hint})
+
+		return status != checkFail
+	}
+	skip := func(names ...string) {
+		for _, name := range names {
+			add(name, checkSkip, "an earlier check failed", "")
+		}
+	}
+
+	if settings.Path() != "" && len(settings.Keys()) > 0 {
+		message := settings.Path()
+		if settings.Profile() != "" {
+			message += fmt.Sprintf(" (profile %s)", settings.Profile())
+		}
+		add("Configuration file", checkPass, message, "")
+	} else {
+		add("Configuration file", checkPass, "not used", "")
+	}
+
+	envOK := add(checkEnvironment())
+	proxy, configURL, err := api.GetURLs()
+
+	profileOK := false
+	switch {
+	case !envOK:
+		skip("Proxy", "configuration.json", "Client certificate", "Profile")
+	case err != nil:
+		add("Proxy", checkFail, err.Error(), "Check the value of OVERRIDE_PROXY_URL")
+		skip("configuration.json", "Client certificate", "Profile")
+	case !add(checkProxy(proxy)):
+		skip("configuration.json", "Client certificate", "Profile")
+	case !add(checkConfiguration(configURL)):
+		add(checkCertificate(proxy))
+		skip("Profile")
+	default:
+		add(checkCertificate(proxy))
+		profileOK = add(checkProfile())
+	}
+
+	if err := mountpoint.CheckFUSE(); err != nil {
+		add("FUSE", checkFail, err.Error(), "Install FUSE 3 (e.g. 'sudo apt install fuse3') or macFUSE on macOS")
+	} else {
+		add("FUSE", checkPass, "installed", "")
+	}
+	add(checkMountPoint())
+	if profileOK {
+		add(checkClamAV())
+	} else {
+		add("ClamAV", checkSkip, "project type is not known without the profile", "")
+	}
+
+	return reportChecks(checks)
+}
+
+// checkEnvironment checks the environment variables that api.Setup requires
+func checkEnvironment() (string, string, string, string) {
+	names := []string{"SDS_ACCESS_TOKEN", "PROXY_URL"}
+	if api.Port == "" {
+		names = append(names, "CONFIG_ENDPOINT")
+	}
+
+	var problems []string
+	for _, name := range names {
+		if _, err := api.GetEnv(name, name != "SDS_ACCESS_TOKEN"); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return "Environment variables", checkFail, strings.Join(problems, "; "),
+			"Run Data Gateway in SD Desktop, where these variables are set, or set them in the environment or the configuration file"
+	}
+
+	return "Environment variables", checkPass, strings.Join(names, ", ") + " are set", ""
+}
+
+func checkProxy(proxy string) (string, string, string, string) {
+	if err := api.PingProxy(proxy); err != nil {
+		return "Proxy", checkFail, err.Error(), "Check your network connection and that PROXY_URL is correct"
+	}
+
+	return "Proxy", checkPass, proxy + " is reachable", ""
+}
+
+func checkConfiguration(configURL string) (string, string, string, string) {
+	if err := api.CheckConfiguration(configURL); err != nil {
+		return "configuration.json", checkFail, err.Error(),
+			"Check that CONFIG_ENDPOINT is correct. If it is, this version of Data Gateway may be too old, fetch a newer one"
+	}
+
+	return "configuration.json", checkPass, "contains all the required endpoints", ""
+}
+
+func checkCertificate(proxy string) (string, string, string, string) {
+	expiry, err := api.CertificateExpiry(certs.Files, proxy)
+	switch {
+	case errors.Is(err, api.ErrNoCertificate):
+		return "Client certificate", checkWarn, err.Error() + ", exporting files is not possible",
+			"Fetch a Data Gateway that is built for this environment"
+	case err != nil:
+		return "Client certificate", checkFail, err.Error(), "Fetch a newer Data Gateway"
+	case !expiry.After(time.Now()):
+		return "Client certificate", checkFail, "expired at " + expiry.Local().Format(timeFormat), "Fetch a newer Data Gateway"
+	case time.Until(expiry) < certificateWarning:
+		return "Client certificate", checkWarn, "expires at " + expiry.Local().Format(timeFormat), "Fetch a newer Data Gateway soon"
+	}
+
+	return "Client certificate", checkPass, "valid until " + expiry.Local().Format(timeFormat), ""
+}
+
+func checkProfile() (string, string, string, string) {
+	if err := api.Setup(certs.Files); err != nil {
+		return "Profile", checkFail, err.Error(), "Run doctor with -loglevel=debug for more details"
+	}
+	access, err := api.GetProfileCLI()
+	if err != nil {
+		return "Profile", checkFail, err.Error(), "Your SD Desktop session may have expired, log in to SD Desktop again"
+	}
+
+	message := fmt.Sprintf("user %s in project %s", api.GetUsername(), api.GetProjectName())
+	if !access {
+		message += ", password is needed to access SD Connect and SD Apply"
+	}
+	if !api.SDConnectEnabled() {
+		message += ", SD Connect is not enabled"
+	}
+
+	return "Profile", checkPass, message, ""
+}
+
+func checkMountPoint() (string, string, string, string) {
+	hint := "Use an empty folder that you can write to, given with -mount or in the configuration file"
+	if mount == "" {
+		defaultMount, err := mountpoint.DefaultMountPoint()
+		if err != nil {
+			return "Mount point", checkFail, err.Error(), hint
+		}
+		mount = defaultMount
+	} else if err := mountpoint.CheckMountPoint(filepath.Clean(mount)); err != nil {
+		return "Mount point", checkFail, err.Error(), hint
+	}
+
+	return "Mount point", checkPass, filepath.Clean(mount) + " can be used", ""
+}
+
+func checkClamAV() (string, string, string, string) {
+	if api.GetProjectType() != "findata" {
+		return "ClamAV", checkSkip, "only needed in Findata projects", ""
+	}
+	if err := api.PingClamAV(); err != nil {
+		return "ClamAV", checkFail, err.Error(), "Check that CLAMAV_SOCKET is set and the ClamAV daemon is running"
+	}
+
+	return "ClamAV", checkPass, "responds on CLAMAV_SOCKET", ""
+}
+
+// reportChecks prints the outcome of the checks. The exit code is 1 if any of them failed.
+func reportChecks(checks []check) (int, error) {
+	code := 0
+	counts := make(map[string]int)
+	for _, c := range checks {
+		counts[c.Status]++
+		if c.Status == checkFail {
+			code = 1
+		}
+	}
+	if jsonOutput {
+		setResult(checks)
+
+		return code, nil
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	for _, c := range checks {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", strings.ToUpper(c.Status), c.Name, c.Message)
+		if c.Hint != "" {
+			fmt.Fprintf(w, "\t\tHint: %s\n", c.Hint)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(stdout, "\n%d passed, %d warnings, %d failed, %d skipped\n",
+		counts[checkPass], counts[checkWarn], counts[checkFail], counts[checkSkip])
+
+	return code, nil
+}
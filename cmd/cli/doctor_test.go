@@ -0,0 +1,220 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/mountpoint"
+)
+
+func TestDoctorSetup(t *testing.T) {
+	var tests = []struct {
+		testname, args, mount string
+		code                  int
+	}{
+		{"OK_DEFAULT", "", "", 0},
+		{"OK_MOUNT", "-mount=/tmp/gateway", "/tmp/gateway", 0},
+		{"FAIL_ARGS", "extra", "", 2},
+		{"FAIL_FLAG", "-sdapply", "", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			t.Cleanup(func() { mount = "" })
+
+			code, err := runSetup(doctorSetup, tt.args)
+			if err != nil {
+				t.Errorf("Returned unexpected err: %s", err.Error())
+			}
+			if code != tt.code {
+				t.Errorf("Received incorrect status code. Expected=%d, received=%d", tt.code, code)
+			}
+			if mount != tt.mount {
+				t.Errorf("Incorrect mount point. Expected=%s, received=%s", tt.mount, mount)
+			}
+		})
+	}
+}
+
+func TestDoctorHandler(t *testing.T) {
+	var tests = []struct {
+		testname                            string
+		envErr, proxyErr, configErr         error
+		certErr, setupErr, fuseErr, clamErr error
+		expiry                              time.Time
+		projectType                         string
+		code                                int
+		statuses                            []string
+	}{
+		{
+			"OK", nil, nil, nil, nil, nil, nil, nil, time.Now().Add(365 * 24 * time.Hour), "default", 0,
+			[]string{checkPass, checkPass, checkPass, checkPass, checkPass, checkPass, checkPass, checkPass, checkSkip},
+		},
+		{
+			"OK_FINDATA_WARNINGS", nil, nil, nil, fmt.Errorf("%w for host proxy", api.ErrNoCertificate), nil, nil, nil, time.Time{}, "findata", 0,
+			[]string{checkPass, checkPass, checkPass, checkPass, checkWarn, checkPass, checkPass, checkPass, checkPass},
+		},
+		{
+			"FAIL_ENV", errExpected, nil, nil, nil, nil, nil, nil, time.Now().Add(time.Hour), "default", 1,
+			[]string{checkPass, checkFail, checkSkip, checkSkip, checkSkip, checkSkip, checkPass, checkPass, checkSkip},
+		},
+		{
+			"FAIL_PROXY", nil, errExpected, nil, nil, nil, errExpected, nil, time.Now().Add(time.Hour), "default", 1,
+			[]string{checkPass, checkPass, checkFail, checkSkip, checkSkip, checkSkip, checkFail, checkPass, checkSkip},
+		},
+		{
+			"FAIL_CONFIG", nil, nil, errExpected, nil, nil, nil, nil, time.Now().Add(-time.Hour), "default", 1,
+			[]string{checkPass, checkPass, checkPass, checkFail, checkFail, checkSkip, checkPass, checkPass, checkSkip},
+		},
+		{
+			"FAIL_PROFILE", nil, nil, nil, nil, errExpected, nil, nil, time.Now().Add(24 * time.Hour), "findata", 1,
+			[]string{checkPass, checkPass, checkPass, checkPass, checkWarn, checkFail, checkPass, checkPass, checkSkip},
+		},
+		{
+			"FAIL_CLAMAV", nil, nil, nil, nil, nil, nil, errExpected, time.Now().Add(365 * 24 * time.Hour), "findata", 1,
+			[]string{checkPass, checkPass, checkPass, checkPass, checkPass, checkPass, checkPass, checkPass, checkFail},
+		},
+	}
+
+	origGetEnv := api.GetEnv
+	origGetURLs := api.GetURLs
+	origPingProxy := api.PingProxy
+	origCheckConfiguration := api.CheckConfiguration
+	origCertificateExpiry := api.CertificateExpiry
+	origSetup := api.Setup
+	origGetProfileCLI := api.GetProfileCLI
+	origGetProjectName := api.GetProjectName
+	origGetProjectType := api.GetProjectType
+	origSDConnectEnabled := api.SDConnectEnabled
+	origPingClamAV := api.PingClamAV
+	origCheckFUSE := mountpoint.CheckFUSE
+	origDefaultMountPoint := mountpoint.DefaultMountPoint
+	origStdout := stdout
+	defer func() {
+		api.GetEnv = origGetEnv
+		api.GetURLs = origGetURLs
+		api.PingProxy = origPingProxy
+		api.CheckConfiguration = origCheckConfiguration
+		api.CertificateExpiry = origCertificateExpiry
+		api.Setup = origSetup
+		api.GetProfileCLI = origGetProfileCLI
+		api.GetProjectName = origGetProjectName
+		api.GetProjectType = origGetProjectType
+		api.SDConnectEnabled = origSDConnectEnabled
+		api.PingClamAV = origPingClamAV
+		mountpoint.CheckFUSE = origCheckFUSE
+		mountpoint.DefaultMountPoint = origDefaultMountPoint
+		stdout = origStdout
+		jsonOutput = false
+		results = commandResult{}
+		mount = ""
+	}()
+
+	api.GetURLs = func() (string, string, error) {
+		return "https://proxy.example.com", "https://proxy.example.com/configuration.json", nil
+	}
+	api.GetProfileCLI = func() (bool, error) {
+		return true, nil
+	}
+	api.GetProjectName = func() string {
+		return "project_2001"
+	}
+	api.SDConnectEnabled = func() bool {
+		return true
+	}
+	mountpoint.DefaultMountPoint = func() (string, error) {
+		return "/home/user/Projects", nil
+	}
+	jsonOutput = true
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			results, mount = commandResult{}, ""
+			api.GetEnv = func(name string, verifyURL bool) (string, error) {
+				return "value", tt.envErr
+			}
+			api.PingProxy = func(string) error {
+				return tt.proxyErr
+			}
+			api.CheckConfiguration = func(string) error {
+				return tt.configErr
+			}
+			api.CertificateExpiry = func(api.FileReader, string) (time.Time, error) {
+				return tt.expiry, tt.certErr
+			}
+			api.Setup = func(api.FileReader) error {
+				return tt.setupErr
+			}
+			api.GetProjectType = func() string {
+				return tt.projectType
+			}
+			api.PingClamAV = func() error {
+				return tt.clamErr
+			}
+			mountpoint.CheckFUSE = func() error {
+				return tt.fuseErr
+			}
+
+			code, err := doctorHandler()
+			if err != nil {
+				t.Fatalf("Returned unexpected err: %s", err.Error())
+			}
+			if code != tt.code {
+				t.Errorf("Received incorrect status code. Expected=%d, received=%d", tt.code, code)
+			}
+			checks, ok := results.Result.([]check)
+			if !ok {
+				t.Fatalf("Result has incorrect type %T", results.Result)
+			}
+			statuses := make([]string, len(checks))
+			for i := range checks {
+				statuses[i] = checks[i].Status
+				if checks[i].Status == checkFail && checks[i].Hint == "" {
+					t.Errorf("Failed check %s has no hint", checks[i].Name)
+				}
+			}
+			if !reflect.DeepEqual(statuses, tt.statuses) {
+				t.Errorf("Incorrect statuses\nExpected=%v\nReceived=%v", tt.statuses, statuses)
+			}
+		})
+	}
+}
+
+func TestReportChecks(t *testing.T) {
+	origStdout := stdout
+	defer func() { stdout = origStdout }()
+
+	buf := &bytes.Buffer{}
+	stdout = buf
+	checks := []check{
+		{Name: "Proxy", Status: checkPass, Message: "https://proxy.example.com is reachable"},
+		{Name: "FUSE", Status: checkFail, Message: errors.New("command fusermount3 not found").Error(), Hint: "Install FUSE 3"},
+		{Name: "ClamAV", Status: checkSkip, Message: "only needed in Findata projects"},
+	}
+
+	code, err := reportChecks(checks)
+	if err != nil {
+		t.Fatalf("Returned unexpected err: %s", err.Error())
+	}
+	if code != 1 {
+		t.Errorf("Received incorrect status code. Expected=1, received=%d", code)
+	}
+	expected := strings.Join([]string{
+		"PASS  Proxy   https://proxy.example.com is reachable",
+		"FAIL  FUSE    command fusermount3 not found",
+		"              Hint: Install FUSE 3",
+		"SKIP  ClamAV  only needed in Findata projects",
+		"",
+		"1 passed, 0 warnings, 1 failed, 1 skipped",
+		"",
+	}, "\n")
+	if buf.String() != expected {
+		t.Errorf("Incorrect output\nExpected=%q\nReceived=%q", expected, buf.String())
+	}
+}
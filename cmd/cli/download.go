@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"sda-filesystem/internal/airlock"
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+
+	"golang.org/x/term"
+)
+
+var downloadSources []string
+var downloadDir string
+var downloadParallel int
+var downloadOverwrite bool
+
+func init() {
+	handlers["download"] = handlerFuncs{setup: downloadSetup, execute: downloadHandler}
+}
+
+func downloadSetup(args []string) (int, error) {
+	var downloadLimit string
+	set := flag.NewFlagSet("download", flag.ContinueOnError)
+	set.StringVar(&downloadDir, "dir", ".", "Folder into which the objects are downloaded")
+	set.IntVar(&downloadParallel, "parallel", 4, "Number of objects that are downloaded at once")
+	set.BoolVar(&downloadOverwrite, "overwrite", false, "Replace existing files that do not match their objects")
+	set.StringVar(&downloadLimit, "download-limit", "0", "Maximum download rate "+rateUsage)
+	set.Usage = func() {
+		fmt.Fprintln(messages, "Usage of download:")
+		fmt.Fprintln(messages, "  Download and decrypt objects from SD Connect or SD Apply without mounting Data Gateway")
+		fmt.Fprintln(messages, "Examples:")
+		fmt.Fprintln(messages, " ", os.Args[0], "download SD-Connect/testbucket")
+		fmt.Fprintln(messages, " ", os.Args[0], "download -dir=data SD-Connect/testbucket/path/to/folder SD-Apply/dataset/file.c4gh")
+		fmt.Fprintln(messages, "Options:")
+		set.PrintDefaults()
+	}
+
+	if err := set.Parse(args); err != nil {
+		return 2, nil
+	}
+	if err := applySettings(set, map[string]string{
+		"parallel":       "transfer.parallel",
+		"download-limit": "transfer.download-limit",
+	}); err != nil {
+		return 2, err
+	}
+	if set.NArg() < 1 {
+		set.Usage()
+
+		return 2, nil
+	}
+	if downloadParallel < 1 {
+		return 2, errors.New("flag -parallel must be at least 1")
+	}
+
+	rate, err := parseRate(downloadLimit)
+	if err != nil {
+		return 2, fmt.Errorf("invalid download limit: %w", err)
+	}
+	limits := api.GetBandwidthLimits()
+	limits.Download = rate
+	if err = api.SetBandwidthLimits(limits); err != nil {
+		return 2, err
+	}
+
+	for _, arg := range set.Args() {
+		rep, _, _, err := airlock.ParseDownloadSource(arg)
+		if err != nil {
+			return 2, err
+		}
+		if rep == api.SDConnect && !api.SDConnectEnabled() {
+			return 0, errors.New("you do not have SD Connect enabled")
+		}
+	}
+	downloadSources = set.Args()
+	downloadDir = filepath.Clean(downloadDir)
+
+	return 0, nil
+}
+
+func downloadHandler() (int, error) {
+	defer api.DeleteWhitelistedKeys()
+
+	var objects []airlock.DownloadObject
+	for _, source := range downloadSources {
+		rep, bucket, prefix, _ := airlock.ParseDownloadSource(source)
+		found, err := airlock.ResolveDownload(rep, bucket, prefix, downloadDir)
+		if err != nil {
+			return 0, fmt.Errorf("failed to find objects for %s: %w", source, err)
+		}
+		objects = append(objects, found...)
+	}
+	logs.Infof("Downloading %d object(s) to %s", len(objects), downloadDir)
+
+	display := newProgressDisplay(os.Stderr, term.IsTerminal(int(os.Stderr.Fd())))
+	airlock.SetProgressFunc(display.update)
+	logs.SetOutput(display)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	errs, err := airlock.Download(ctx, objects, downloadParallel, downloadOverwrite)
+	stop()
+	display.close()
+	logs.SetOutput(os.Stderr)
+	airlock.SetProgressFunc(nil)
+
+	done := 0
+	for i := range objects {
+		object := objects[i].StoragePath()
+		switch {
+		case i < len(errs) && errors.Is(errs[i], context.Canceled):
+			addFileResult(objects[i].Path, object, statusCancelled, nil)
+		case i < len(errs) && errs[i] != nil:
+			addFileResult(objects[i].Path, object, statusFailed, errs[i])
+		default:
+			done++
+			addFileResult(objects[i].Path, object, statusDownloaded, nil)
+		}
+	}
+
+	if errors.Is(err, context.Canceled) {
+		logs.Warningf("Download cancelled, %d of %d object(s) were downloaded. Run the command again to continue", done, len(objects))
+
+		return 1, nil
+	}
+	if err != nil {
+		logs.Error(err)
+
+		return 1, nil
+	}
+	logs.Info("Download(s) complete")
+
+	return 0, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sda-filesystem/internal/airlock"
+	"sda-filesystem/internal/api"
+)
+
+func TestDownloadSetup(t *testing.T) {
+	var tests = []struct {
+		testname, args, errStr, dir string
+		code, parallel              int
+		enabled, overwrite          bool
+		sources                     []string
+		limit                       int64
+	}{
+		{"OK_1", "SD-Connect/bucket", "", ".", 0, 4, true, false, []string{"SD-Connect/bucket"}, 0},
+		{"OK_2", "-dir=data/ -parallel=8 -overwrite -download-limit=1M SD-Connect/bucket/dir SD-Apply/dataset", "", "data", 0, 8, true, true, []string{"SD-Connect/bucket/dir", "SD-Apply/dataset"}, 1 << 20},
+		{"OK_3", "SD-Apply/dataset/file.c4gh", "", ".", 0, 4, false, false, []string{"SD-Apply/dataset/file.c4gh"}, 0},
+		{"FAIL_NO_ARGS", "", "", ".", 2, 4, true, false, nil, 0},
+		{"FAIL_BAD_FLAG", "-sync SD-Connect/bucket", "", ".", 2, 4, true, false, nil, 0},
+		{"FAIL_PARALLEL", "-parallel=0 SD-Connect/bucket", "flag -parallel must be at least 1", ".", 2, 0, true, false, nil, 0},
+		{"FAIL_LIMIT", "-download-limit=fast SD-Connect/bucket", "invalid download limit: invalid rate \"fast\"", ".", 2, 4, true, false, nil, 0},
+		{
+			"FAIL_SOURCE", "SD-Connect/bucket bucket/file", "invalid source \"bucket/file\", expected format repository/bucket/path/to/prefix, where repository is one of SD-Connect, SD-Apply",
+			".", 2, 4, true, false, nil, 0,
+		},
+		{"FAIL_NOT_ENABLED", "SD-Connect/bucket", "you do not have SD Connect enabled", ".", 0, 4, false, false, nil, 0},
+	}
+
+	origSDConnectEnabled := api.SDConnectEnabled
+	origLimits := api.GetBandwidthLimits()
+	defer func() {
+		api.SDConnectEnabled = origSDConnectEnabled
+		_ = api.SetBandwidthLimits(origLimits)
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			t.Cleanup(func() {
+				downloadSources = nil
+				downloadDir = ""
+				downloadParallel = 0
+				downloadOverwrite = false
+				_ = api.SetBandwidthLimits(api.BandwidthLimits{})
+			})
+
+			api.SDConnectEnabled = func() bool {
+				return tt.enabled
+			}
+
+			// Ignore prints to stdout
+			null, _ := os.Open(os.DevNull)
+			sout, smessages := os.Stdout, messages
+			os.Stdout, messages = null, null
+
+			code, err := downloadSetup(strings.Fields(tt.args))
+
+			os.Stdout, messages = sout, smessages
+			null.Close()
+
+			if code != tt.code {
+				t.Errorf("Received incorrect status code. Expected=%d, received=%d", tt.code, code)
+			}
+			switch {
+			case tt.errStr == "":
+				if err != nil {
+					t.Errorf("Returned unexpected err: %s", err.Error())
+				}
+			case err == nil:
+				t.Error("Function should have returned error")
+			case err.Error() != tt.errStr:
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+			if !reflect.DeepEqual(tt.sources, downloadSources) {
+				t.Errorf("Received incorrect sources. Expected=%v, received=%v", tt.sources, downloadSources)
+			}
+			if tt.sources == nil {
+				return
+			}
+			if downloadDir != tt.dir || downloadParallel != tt.parallel || downloadOverwrite != tt.overwrite {
+				t.Errorf("Flags have incorrect values. Expected=%s %d %t, received=%s %d %t",
+					tt.dir, tt.parallel, tt.overwrite, downloadDir, downloadParallel, downloadOverwrite)
+			}
+			if limit := api.GetBandwidthLimits().Download; limit != tt.limit {
+				t.Errorf("Incorrect download limit. Expected=%d, received=%d", tt.limit, limit)
+			}
+		})
+	}
+}
+
+func TestDownloadHandler(t *testing.T) {
+	var tests = []struct {
+		testname, status string
+		code             int
+		errStr           string
+		resolve          error
+		download         error
+	}{
+		{"OK", statusDownloaded, 0, "", nil, nil},
+		{"FAIL_DOWNLOAD", statusFailed, 1, "", nil, errExpected},
+		{"FAIL_CANCELLED", statusCancelled, 1, "", nil, context.Canceled},
+		{"FAIL_RESOLVE", "", 0, "failed to find objects for SD-Apply/dataset: " + errExpected.Error(), errExpected, nil},
+	}
+
+	origResolveDownload := airlock.ResolveDownload
+	origDownload := airlock.Download
+	origDeleteWhitelistedKeys := api.DeleteWhitelistedKeys
+	defer func() {
+		airlock.ResolveDownload = origResolveDownload
+		airlock.Download = origDownload
+		api.DeleteWhitelistedKeys = origDeleteWhitelistedKeys
+		downloadSources = nil
+		downloadDir = ""
+		downloadParallel = 0
+	}()
+
+	api.DeleteWhitelistedKeys = func() {}
+	downloadSources = []string{"SD-Connect/bucket/dir", "SD-Apply/dataset"}
+	downloadDir = "data"
+	downloadParallel = 3
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			results = commandResult{}
+			t.Cleanup(func() {
+				results = commandResult{}
+			})
+
+			airlock.ResolveDownload = func(rep api.Repo, bucket, prefix, dir string) ([]airlock.DownloadObject, error) {
+				if dir != "data" {
+					t.Errorf("Incorrect destination. Expected=data, received=%s", dir)
+				}
+				if rep == api.SDApply {
+					return []airlock.DownloadObject{{Repository: rep, Bucket: bucket, Object: "file.c4gh"}}, tt.resolve
+				}
+
+				return []airlock.DownloadObject{{Repository: rep, Bucket: bucket, Object: prefix + "/file.c4gh"}}, nil
+			}
+			var received []airlock.DownloadObject
+			airlock.Download = func(_ context.Context, objects []airlock.DownloadObject, parallel int, overwrite bool) ([]error, error) {
+				received = objects
+				if parallel != 3 {
+					t.Errorf("Incorrect number of workers. Expected=3, received=%d", parallel)
+				}
+
+				errs := make([]error, len(objects))
+				errs[len(objects)-1] = tt.download
+
+				return errs, tt.download
+			}
+
+			code, err := downloadHandler()
+
+			switch {
+			case tt.errStr == "":
+				if err != nil {
+					t.Errorf("Returned unexpected err: %s", err.Error())
+				}
+			case err == nil:
+				t.Error("Function should have returned error")
+			case err.Error() != tt.errStr:
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+			if code != tt.code {
+				t.Errorf("Received incorrect status code. Expected=%d, received=%d", tt.code, code)
+			}
+
+			expected := []airlock.DownloadObject{
+				{Repository: api.SDConnect, Bucket: "bucket", Object: "dir/file.c4gh"},
+				{Repository: api.SDApply, Bucket: "dataset", Object: "file.c4gh"},
+			}
+			if tt.resolve != nil {
+				expected = nil
+			}
+			if !reflect.DeepEqual(received, expected) {
+				t.Errorf("Incorrect objects were downloaded\nExpected=%v\nReceived=%v", expected, received)
+			}
+			if tt.status == "" {
+				return
+			}
+			if len(results.Files) != 2 || results.Files[0].Status != statusDownloaded || results.Files[1].Status != tt.status {
+				t.Errorf("Incorrect results for objects. Expected statuses %s and %s, received=%+v", statusDownloaded, tt.status, results.Files)
+			}
+		})
+	}
+}
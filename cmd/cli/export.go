@@ -1,39 +1,57 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"net/mail"
 	"os"
+	"os/signal"
 	"slices"
+	"syscall"
 
 	"sda-filesystem/internal/airlock"
 	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/config"
 	"sda-filesystem/internal/logs"
+
+	"golang.org/x/term"
 )
 
 var exportPrefix string
 var selection []string
 
 var override bool
+var syncMode bool
+var deleteRemoved bool
+var archiveMode bool
+var compressArchive bool
+var conflictPolicy airlock.ConflictPolicy
+var dryRun bool
+var exportSocket string
 var metadata = make(map[string]string)
 
 func init() {
 	handlers["export"] = handlerFuncs{setup: exportSetup, execute: exportHandler}
 }
 
+// isFlag tells whether `arg` is a flag argument. A single '-' stands for standard input.
+func isFlag(arg string) bool {
+	return arg[0] == '-' && arg != airlock.StdinFile
+}
+
 // flagSortFunc sorts non-flag arguments (the ones with '-') first.
 // This is so that users are able to give non-flag arguments after
 // listing the bucket and files/folders.
 func flagSortFunc(arg1, arg2 string) int {
-	if arg1[0] == '-' && arg2[0] == '-' {
+	if isFlag(arg1) && isFlag(arg2) {
 		return 0
 	}
-	if arg1[0] != '-' && arg2[0] != '-' {
+	if !isFlag(arg1) && !isFlag(arg2) {
 		return 0
 	}
-	if arg1[0] == '-' {
+	if isFlag(arg1) {
 		return -1
 	}
 
@@ -69,39 +87,157 @@ func exportSetup(args []string) (int, error) {
 	}
 
 	var email, journalNumber string
+	limits := airlock.GetUploadLimits()
+	var decryptCheck bool
+	var changeRetries int
+	var followSymlinks, symlinkObjects bool
+	var uploadLimit, memoryBudget, privateKey, onConflict string
 	set := flag.NewFlagSet("export", flag.ContinueOnError)
 	set.BoolVar(&override, "override", false, "Forcibly override data in SD Connect")
+	set.StringVar(&onConflict, "on-conflict", "", "What to do when an object already exists: skip, overwrite, rename or fail (asked by default)")
+	set.BoolVar(&syncMode, "sync", false, "Upload only files that are new or have changed since they were last exported")
+	set.BoolVar(&deleteRemoved, "delete", false, "With -sync, delete objects whose files no longer exist locally")
 	set.StringVar(&email, "email", aaiEmail, "Your email (for Findata projects)")
 	set.StringVar(&journalNumber, "journal-number", "", "Journal number (for Findata projects)")
+	set.IntVar(&limits.PartConcurrency, "part-concurrency", limits.PartConcurrency, "Number of parts of a single file uploaded at the same time")
+	set.StringVar(&memoryBudget, "memory-budget", config.FormatSize(limits.MemoryBudget), "Memory available for buffering file parts during upload, in bytes with an optional K, M or G suffix")
+	set.StringVar(&uploadLimit, "upload-limit", "0", "Maximum upload rate "+rateUsage+". With -socket, it can be changed during the export with 'ctl limit'")
+	set.StringVar(&exportSocket, "socket", "", "Listen for 'ctl limit' and 'ctl loglevel' commands at this socket during the export")
+	set.BoolVar(&archiveMode, "archive", false, "Upload the given folder as a single tar archive object")
+	set.BoolVar(&compressArchive, "compress", false, "With -archive, compress the archive with zstd. Compressed archives cannot be browsed in the filesystem")
+	set.BoolVar(&decryptCheck, "verify-decrypt", false, "After each upload, also decrypt the first and last block of the object")
+	set.IntVar(&changeRetries, "retry-changed", 0, "Number of times a file that is modified during its upload is uploaded again")
+	set.BoolVar(&followSymlinks, "follow-symlinks", false, "Export the files and folders that symbolic links point to")
+	set.BoolVar(&symlinkObjects, "symlink-objects", false, "Export symbolic links as small objects that contain the target of the link")
+	set.BoolVar(&dryRun, "dry-run", false, "List the files that would be exported and their objects without uploading anything")
+	set.StringVar(&privateKey, "private-key", "", "Your crypt4gh private key, used for re-encrypting the headers of files that are already encrypted")
 
 	set.Usage = func() {
-		fmt.Println("Usage of export:")
+		fmt.Fprintln(messages, "Usage of export:")
 
 		set.PrintDefaults()
 
-		fmt.Println("Examples:")
-		fmt.Println(" ", os.Args[0], "export testbucket path/to/file/or/folder")
-		fmt.Println(" ", os.Args[0], "export -override testbucket/subfolder path/to/file/or/folder path/to/another/file")
+		fmt.Fprintln(messages, "Examples:")
+		fmt.Fprintln(messages, " ", os.Args[0], "export testbucket path/to/file/or/folder")
+		fmt.Fprintln(messages, " ", os.Args[0], "export -override testbucket/subfolder path/to/file/or/folder path/to/another/file")
+		fmt.Fprintln(messages, " ", os.Args[0], "export -on-conflict=rename testbucket path/to/folder")
+		fmt.Fprintln(messages, " ", os.Args[0], "export -sync -delete testbucket path/to/folder")
+		fmt.Fprintln(messages, " ", os.Args[0], "export -archive testbucket path/to/folder")
+		fmt.Fprintln(messages, " ", os.Args[0], "export -private-key path/to/key.sec testbucket path/to/file.c4gh")
+		fmt.Fprintln(messages, " ", os.Args[0], "export testbucket/path/to/object.c4gh -")
+		fmt.Fprintln(messages, " ", os.Args[0], "export -dry-run -follow-symlinks testbucket path/to/folder")
 	}
 
 	args = refineArgs(args, "email")
 	args = refineArgs(args, "journal-number")
+	args = refineArgs(args, "part-concurrency")
+	args = refineArgs(args, "memory-budget")
+	args = refineArgs(args, "upload-limit")
+	args = refineArgs(args, "private-key")
+	args = refineArgs(args, "on-conflict")
+	args = refineArgs(args, "retry-changed")
+	args = refineArgs(args, "socket")
 
 	// We want the non-flag arguments to be first
 	slices.SortStableFunc(args, flagSortFunc)
 	if err := set.Parse(args); err != nil {
 		return 2, nil
 	}
+	exportSettings := map[string]string{
+		"verify-decrypt":   "export.verify-decrypt",
+		"retry-changed":    "export.retry-changed",
+		"part-concurrency": "export.part-concurrency",
+		"memory-budget":    "export.memory-budget",
+		"upload-limit":     "transfer.upload-limit",
+	}
+	// A default conflict policy does not apply when -override or -sync decides what happens to existing objects
+	if !override && !syncMode {
+		exportSettings["on-conflict"] = "export.on-conflict"
+	}
+	if err := applySettings(set, exportSettings); err != nil {
+		return 2, err
+	}
 
-	args = slices.DeleteFunc(args, func(arg string) bool {
-		return arg[0] == '-'
-	})
+	args = slices.DeleteFunc(args, isFlag)
 
 	if len(args) < 2 {
 		set.Usage()
 
 		return 2, nil
 	}
+	if deleteRemoved && !syncMode {
+		return 2, errors.New("flag -delete can only be used with -sync")
+	}
+	if compressArchive && !archiveMode {
+		return 2, errors.New("flag -compress can only be used with -archive")
+	}
+	if archiveMode && syncMode {
+		return 2, errors.New("flags -archive and -sync cannot be used together")
+	}
+	if archiveMode && len(args) != 2 {
+		return 2, errors.New("flag -archive requires exactly one file or folder")
+	}
+	if onConflict != "" {
+		if override || syncMode {
+			return 2, errors.New("flag -on-conflict cannot be used with -override or -sync")
+		}
+		policy, err := airlock.ParseConflictPolicy(onConflict)
+		if err != nil {
+			return 2, err
+		}
+		conflictPolicy = policy
+	}
+	switch {
+	case followSymlinks && symlinkObjects:
+		return 2, errors.New("flags -follow-symlinks and -symlink-objects cannot be used together")
+	case (followSymlinks || symlinkObjects) && archiveMode:
+		return 2, errors.New("flags -follow-symlinks and -symlink-objects cannot be used with -archive")
+	case followSymlinks:
+		airlock.SetSymlinkMode(airlock.SymlinkFollow)
+	case symlinkObjects:
+		airlock.SetSymlinkMode(airlock.SymlinkObject)
+	}
+	if slices.Contains(args[1:], airlock.StdinFile) {
+		if archiveMode || syncMode {
+			return 2, errors.New("standard input cannot be exported with -archive or -sync")
+		}
+		if len(args) != 2 {
+			return 2, errors.New("standard input cannot be exported together with other files")
+		}
+		// The user cannot be asked about existing objects when standard input contains the data
+		if conflictPolicy == "" && !override {
+			conflictPolicy = airlock.ConflictFail
+		}
+	}
+	budget, err := config.ParseSize(memoryBudget)
+	if err != nil {
+		return 2, fmt.Errorf("invalid memory budget: %w", err)
+	}
+	limits.MemoryBudget = budget
+	if err := airlock.SetUploadLimits(limits); err != nil {
+		return 2, err
+	}
+	rate, err := parseRate(uploadLimit)
+	if err != nil {
+		return 2, fmt.Errorf("invalid upload limit: %w", err)
+	}
+	bandwidth := api.GetBandwidthLimits()
+	bandwidth.Upload = rate
+	if err = api.SetBandwidthLimits(bandwidth); err != nil {
+		return 2, err
+	}
+
+	airlock.SetDecryptCheck(decryptCheck)
+	if changeRetries < 0 {
+		return 2, errors.New("flag -retry-changed cannot be negative")
+	}
+	airlock.SetChangeRetries(changeRetries)
+
+	if privateKey != "" {
+		if err := loadPrivateKey(privateKey); err != nil {
+			return 2, err
+		}
+	}
 
 	exportPrefix = args[0]
 	selection = args[1:]
@@ -129,28 +265,330 @@ func exportSetup(args []string) (int, error) {
 	return 0, nil
 }
 
+// loadPrivateKey reads the user's crypt4gh private key. The passphrase of the key is taken from the
+// environment variable C4GH_PASSPHRASE, or asked from the user if the key cannot be read without one.
+func loadPrivateKey(filename string) error {
+	passphrase, ok := os.LookupEnv("C4GH_PASSPHRASE")
+	if ok {
+		logs.Info("Using passphrase from environment variable C4GH_PASSPHRASE")
+
+		return airlock.SetPrivateKey(filename, []byte(passphrase))
+	}
+	if err := airlock.SetPrivateKey(filename, nil); err == nil {
+		return nil
+	}
+
+	passphrase, err := askForPassphrase()
+	if err != nil {
+		return err
+	}
+
+	return airlock.SetPrivateKey(filename, []byte(passphrase))
+}
+
+var askForPassphrase = func() (string, error) {
+	fmt.Fprint(messages, "Enter passphrase for private key: ")
+	passphrase, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Fprintln(messages)
+	if err != nil {
+		return "", fmt.Errorf("could not read passphrase: %w", err)
+	}
+
+	return string(passphrase), nil
+}
+
 func exportHandler() (int, error) {
+	if archiveMode {
+		return exportArchive()
+	}
+
 	set, err := airlock.WalkDirs(selection, nil, exportPrefix)
 	if err != nil {
 		return 0, fmt.Errorf("failed to select files for export: %w", err)
 	}
+	if dryRun {
+		setResult(set)
+		logPlan(set)
+
+		return 0, nil
+	}
 
 	created, err := airlock.ValidateBucket(set.Bucket)
 	if err != nil {
 		return 0, fmt.Errorf("cannot use bucket %s: %w", set.Bucket, err)
 	}
 
-	if !created && !override {
-		if err := airlock.CheckObjectExistences(&set, os.Stdin); err != nil {
+	report := airlock.SyncReport{New: set.Files}
+	var conflicts []airlock.ConflictResult
+	switch {
+	case syncMode && !created:
+		report, err = airlock.SyncSet(&set, selection, exportPrefix)
+		if err != nil {
+			return 0, err
+		}
+	case !created && conflictPolicy != "":
+		conflicts, err = airlock.ResolveConflicts(&set, conflictPolicy)
+		if err != nil {
+			return 0, err
+		}
+	case !created && !override:
+		if err := airlock.CheckObjectExistences(&set, os.Stdin, messages); err != nil {
+			return 0, err
+		}
+	}
+	// The result lists the objects that are written, once conflicts and sync have decided them
+	setResult(set)
+
+	if len(set.Objects) > 0 {
+		closeControl, err := listenExportControl()
+		if err != nil {
+			return 0, err
+		}
+		defer closeControl()
+		display := newProgressDisplay(os.Stderr, term.IsTerminal(int(os.Stderr.Fd())))
+		airlock.SetProgressFunc(display.update)
+		logs.SetOutput(display)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		completed, err := airlock.Upload(ctx, set, metadata)
+		stop()
+		display.close()
+		logs.SetOutput(os.Stderr)
+		airlock.SetProgressFunc(nil)
+		recordUploads(set, completed, err)
+		if errors.Is(err, context.Canceled) {
+			logs.Warningf("Export cancelled, %d of %d file(s) were uploaded", len(completed), len(set.Files))
+			for _, file := range completed {
+				logs.Infof("Uploaded: %s", file)
+			}
+
+			return 1, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		logs.Info("Upload(s) complete")
+	}
+
+	var failed map[string]error
+	if syncMode {
+		if deleteRemoved && len(report.Removed) > 0 {
+			failed = airlock.DeleteObjects(set.Bucket, report.Removed)
+		}
+		logSyncReport(report, set.Bucket, failed)
+	}
+	if conflicts != nil {
+		logConflicts(conflicts)
+	}
+	if len(failed) > 0 {
+		return 0, fmt.Errorf("failed to delete %d object(s)", len(failed))
+	}
+
+	return 0, nil
+}
+
+// listenExportControl creates the control socket given with -socket, through which the bandwidth limits can be
+// changed while files are uploaded. It returns a function that closes the socket.
+func listenExportControl() (func(), error) {
+	if exportSocket == "" {
+		return func() {}, nil
+	}
+	server, err := listenControl(exportSocket)
+	if err != nil {
+		return nil, err
+	}
+	server.commands = exportCommands
+	logs.Infof("Listening for commands at %s", exportSocket)
+	go server.serve()
+
+	return server.close, nil
+}
+
+// logPlan lists the files that would be exported, and the objects they would be uploaded as
+func logPlan(set airlock.UploadSet) {
+	links := 0
+	for i := range set.Files {
+		addFileResult(set.Files[i], set.Bucket+"/"+set.Objects[i], statusPlanned, nil)
+		target, err := os.Readlink(set.Files[i])
+		switch {
+		case err != nil:
+			logs.Infof("Would export: %s -> %s/%s", set.Files[i], set.Bucket, set.Objects[i])
+		case airlock.GetSymlinkMode() == airlock.SymlinkObject:
+			links++
+			logs.Infof("Would export symbolic link: %s (link to %s) -> %s/%s", set.Files[i], target, set.Bucket, set.Objects[i])
+		default:
+			links++
+			logs.Infof("Would export through symbolic link: %s (link to %s) -> %s/%s", set.Files[i], target, set.Bucket, set.Objects[i])
+		}
+	}
+	logs.Infof("Dry run: %d file(s), %d of them symbolic links, would be exported to bucket %s", len(set.Files), links, set.Bucket)
+}
+
+// recordUploads adds the outcome of each file in `set` to the results of the subcommand
+func recordUploads(set airlock.UploadSet, completed []string, err error) {
+	uploaded := make(map[string]bool, len(completed))
+	for _, file := range completed {
+		uploaded[file] = true
+	}
+	status := statusFailed
+	if errors.Is(err, context.Canceled) {
+		status = statusCancelled
+	}
+	for i := range set.Files {
+		if uploaded[set.Files[i]] {
+			addFileResult(set.Files[i], set.Bucket+"/"+set.Objects[i], statusUploaded, nil)
+		} else {
+			addFileResult(set.Files[i], set.Bucket+"/"+set.Objects[i], status, nil)
+		}
+	}
+}
+
+// setFileAction records in the results of the subcommand why `file` was uploaded
+func setFileAction(file, action string) {
+	for i := range results.Files {
+		if results.Files[i].File == file {
+			results.Files[i].Action = action
+		}
+	}
+}
+
+// logConflicts summarises what happened to each file when objects already existed
+func logConflicts(conflicts []airlock.ConflictResult) {
+	counts := make(map[string]int)
+	for _, result := range conflicts {
+		counts[result.Action]++
+		setFileAction(result.File, result.Action)
+		switch result.Action {
+		case airlock.ActionNew:
+			logs.Debugf("New: %s", result.File)
+		case airlock.ActionSkipped:
+			logs.Infof("Skipped: %s", result.File)
+			addFileResult(result.File, "", statusSkipped, nil)
+		case airlock.ActionOverwritten:
+			logs.Infof("Overwritten: %s -> %s", result.File, result.Object)
+		case airlock.ActionRenamed:
+			logs.Infof("Renamed: %s -> %s", result.File, result.Object)
+		}
+	}
+
+	logs.Infof("Export complete: %d new, %d skipped, %d overwritten, %d renamed",
+		counts[airlock.ActionNew], counts[airlock.ActionSkipped],
+		counts[airlock.ActionOverwritten], counts[airlock.ActionRenamed])
+}
+
+// logSyncReport summarises what a sync export did to each file.
+// `failed` contains the objects that could not be deleted.
+func logSyncReport(report airlock.SyncReport, bucket string, failed map[string]error) {
+	for _, file := range report.New {
+		logs.Infof("New: %s", file)
+		setFileAction(file, "new")
+	}
+	for _, file := range report.Changed {
+		logs.Infof("Changed: %s", file)
+		setFileAction(file, "changed")
+	}
+	for _, file := range report.Unchanged {
+		logs.Debugf("Unchanged: %s", file)
+		addFileResult(file, "", statusUnchanged, nil)
+	}
+
+	action, status := "Not deleted (use -delete)", statusRemoved
+	if deleteRemoved {
+		action, status = "Deleted", statusDeleted
+	}
+	for _, object := range report.Removed {
+		if err := failed[object]; err != nil {
+			logs.Infof("Failed to delete: %s", object)
+			addFileResult("", bucket+"/"+object, statusFailed, err)
+
+			continue
+		}
+		logs.Infof("%s: %s", action, object)
+		addFileResult("", bucket+"/"+object, status, nil)
+	}
+
+	logs.Infof("Sync complete: %d new, %d changed, %d unchanged, %d removed locally",
+		len(report.New), len(report.Changed), len(report.Unchanged), len(report.Removed))
+}
+
+// logArchivePlan lists the files that would be added to the archive, and the object the archive would be uploaded as
+func logArchivePlan(archive airlock.Archive) {
+	for i := range archive.Files {
+		logs.Infof("Would archive: %s -> %s", archive.Files[i], archive.Members[i])
+	}
+	addFileResult(selection[0], archive.Bucket+"/"+archive.Object, statusPlanned, nil)
+	logs.Infof("Dry run: %d file(s) would be exported as archive %s/%s with index %s",
+		len(archive.Files), archive.Bucket, archive.Object, api.ArchiveIndexObject(archive.Object))
+}
+
+// exportArchive uploads the selected folder as a single archive object
+func exportArchive() (int, error) {
+	archive, err := airlock.WalkArchive(selection[0], exportPrefix, compressArchive)
+	if err != nil {
+		return 0, fmt.Errorf("failed to select files for archive: %w", err)
+	}
+	set := airlock.UploadSet{
+		Bucket:  archive.Bucket,
+		Files:   []string{selection[0]},
+		Objects: []string{archive.Object},
+		Exists:  []bool{false},
+	}
+	if dryRun {
+		setResult(set)
+		logArchivePlan(archive)
+
+		return 0, nil
+	}
+
+	created, err := airlock.ValidateBucket(archive.Bucket)
+	if err != nil {
+		return 0, fmt.Errorf("cannot use bucket %s: %w", archive.Bucket, err)
+	}
+	var conflicts []airlock.ConflictResult
+	switch {
+	case !created && conflictPolicy != "":
+		conflicts, err = airlock.ResolveArchiveConflicts(&set, conflictPolicy)
+		if err != nil {
 			return 0, err
 		}
+	case !created && !override:
+		if err := airlock.CheckObjectExistences(&set, os.Stdin, messages); err != nil {
+			return 0, err
+		}
+	}
+	setResult(set)
+	if len(set.Objects) == 0 {
+		logConflicts(conflicts)
+
+		return 0, nil
 	}
+	archive.Object = set.Objects[0]
 
-	if err := airlock.Upload(set, metadata); err != nil {
+	closeControl, err := listenExportControl()
+	if err != nil {
 		return 0, err
 	}
+	defer closeControl()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err = airlock.UploadArchive(ctx, archive)
+	var completed []string
+	if err == nil {
+		completed = set.Files
+	}
+	recordUploads(set, completed, err)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			logs.Warningf("Export cancelled, archive %s was not uploaded", archive.Object)
+
+			return 1, nil
+		}
 
-	logs.Info("Upload(s) complete")
+		return 0, err
+	}
+	logs.Info("Upload complete")
+	if conflicts != nil {
+		logConflicts(conflicts)
+	}
 
 	return 0, nil
 }
@@ -1,13 +1,20 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
+	"slices"
 	"strings"
 	"testing"
 
 	"sda-filesystem/internal/airlock"
 	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+
+	"github.com/neicnordic/crypt4gh/keys"
 )
 
 func TestExportSetup(t *testing.T) {
@@ -73,10 +80,82 @@ func TestExportSetup(t *testing.T) {
 			[]string{"test-file", "test-file-2", "test-file-3"},
 			false, true, map[string]string{"author_email": "maija.meikalainen@gmail.com", "journal_number": "123"},
 		},
+		{
+			"OK_8",
+			"-sync test-bucket-5 test-dir -delete",
+			"test-bucket-5", "",
+			[]string{"test-dir"},
+			false, false, make(map[string]string),
+		},
+		{
+			"OK_9",
+			"-part-concurrency=8 test-bucket-6 test-file -memory-budget=4G",
+			"test-bucket-6", "",
+			[]string{"test-file"},
+			false, false, make(map[string]string),
+		},
+		{
+			"OK_10",
+			"test-bucket-7 -upload-limit 10M test-file",
+			"test-bucket-7", "",
+			[]string{"test-file"},
+			false, false, make(map[string]string),
+		},
+		{
+			"OK_11",
+			"-archive test-bucket-8 test-dir -compress",
+			"test-bucket-8", "",
+			[]string{"test-dir"},
+			false, false, make(map[string]string),
+		},
+		{
+			"OK_12",
+			"-on-conflict rename test-bucket-9 test-dir",
+			"test-bucket-9", "",
+			[]string{"test-dir"},
+			false, false, make(map[string]string),
+		},
+		{
+			"OK_13",
+			"-verify-decrypt test-bucket-10 test-file",
+			"test-bucket-10", "",
+			[]string{"test-file"},
+			false, false, make(map[string]string),
+		},
+		{
+			"OK_14",
+			"test-bucket-11/object.c4gh - -verify-decrypt",
+			"test-bucket-11/object.c4gh", "",
+			[]string{"-"},
+			false, false, make(map[string]string),
+		},
+		{
+			"OK_15",
+			"test-bucket-12 test-file -retry-changed 2",
+			"test-bucket-12", "",
+			[]string{"test-file"},
+			false, false, make(map[string]string),
+		},
+		{
+			"OK_16",
+			"-follow-symlinks -dry-run test-bucket-13 test-dir",
+			"test-bucket-13", "",
+			[]string{"test-dir"},
+			false, false, make(map[string]string),
+		},
+		{
+			"OK_17",
+			"test-bucket-14 test-dir -symlink-objects",
+			"test-bucket-14", "",
+			[]string{"test-dir"},
+			false, false, make(map[string]string),
+		},
 	}
 
 	origExportPossible := airlock.ExportPossible
 	origFindataUpload := api.FindataUpload
+	origLimits := airlock.GetUploadLimits()
+	origBandwidth := api.GetBandwidthLimits()
 	origGetUserEmail := api.GetUserEmail
 	defer func() {
 		airlock.ExportPossible = origExportPossible
@@ -92,8 +171,16 @@ func TestExportSetup(t *testing.T) {
 		t.Run(tt.testname, func(t *testing.T) {
 			t.Cleanup(func() {
 				exportPrefix, selection = "", []string{}
-				override = false
+				override, syncMode, deleteRemoved = false, false, false
+				archiveMode, compressArchive = false, false
+				conflictPolicy = ""
+				airlock.SetDecryptCheck(false)
+				airlock.SetChangeRetries(0)
+				airlock.SetSymlinkMode("")
+				dryRun = false
 				metadata = make(map[string]string)
+				_ = airlock.SetUploadLimits(origLimits)
+				_ = api.SetBandwidthLimits(origBandwidth)
 			})
 
 			api.FindataUpload = func() bool {
@@ -105,12 +192,12 @@ func TestExportSetup(t *testing.T) {
 
 			// Ignore prints to stdout
 			null, _ := os.Open(os.DevNull)
-			sout := os.Stdout
-			os.Stdout = null
+			sout, smessages := os.Stdout, messages
+			os.Stdout, messages = null, null
 
 			code, err := exportSetup(strings.Split(tt.args, " "))
 
-			os.Stdout = sout
+			os.Stdout, messages = sout, smessages
 			null.Close()
 
 			switch {
@@ -126,6 +213,18 @@ func TestExportSetup(t *testing.T) {
 				t.Errorf("Received incorrect override value. Expected=%t, received=%t", tt.override, override)
 			case !reflect.DeepEqual(tt.metadata, metadata):
 				t.Errorf("Received incorrect metadata\nExpected=%v\nReceived=%v", tt.metadata, metadata)
+			case tt.testname == "OK_16" && (airlock.GetSymlinkMode() != airlock.SymlinkFollow || !dryRun):
+				t.Errorf("Symbolic links are not followed in a dry run. Received mode=%s, dry run=%t", airlock.GetSymlinkMode(), dryRun)
+			case tt.testname == "OK_17" && airlock.GetSymlinkMode() != airlock.SymlinkObject:
+				t.Errorf("Received incorrect symbolic link mode. Expected=%s, received=%s", airlock.SymlinkObject, airlock.GetSymlinkMode())
+			case tt.testname == "OK_14" && conflictPolicy != airlock.ConflictFail:
+				t.Errorf("Received incorrect conflict policy. Expected=%s, received=%s", airlock.ConflictFail, conflictPolicy)
+			case tt.testname == "OK_12" && conflictPolicy != airlock.ConflictRename:
+				t.Errorf("Received incorrect conflict policy. Expected=%s, received=%s", airlock.ConflictRename, conflictPolicy)
+			case tt.testname == "OK_11" && (!archiveMode || !compressArchive):
+				t.Errorf("Archive flags were not set. Received archive=%t, compress=%t", archiveMode, compressArchive)
+			case tt.testname == "OK_10" && api.GetBandwidthLimits().Upload != 10<<20:
+				t.Errorf("Upload limit was not set. Expected=%d, received=%d", 10<<20, api.GetBandwidthLimits().Upload)
 			}
 		})
 	}
@@ -167,17 +266,116 @@ func TestExportSetup_Error(t *testing.T) {
 			"test-bucket test-file test-folder", "you are not allowed to export files",
 			0, false, false,
 		},
+		{
+			"FAIL_DELETE",
+			"-delete test-bucket test-folder", "flag -delete can only be used with -sync",
+			2, true, false,
+		},
+		{
+			"FAIL_COMPRESS",
+			"-compress test-bucket test-folder", "flag -compress can only be used with -archive",
+			2, true, false,
+		},
+		{
+			"FAIL_ARCHIVE_SYNC",
+			"-archive -sync test-bucket test-folder", "flags -archive and -sync cannot be used together",
+			2, true, false,
+		},
+		{
+			"FAIL_ARCHIVE_SELECTION",
+			"-archive test-bucket test-folder test-file", "flag -archive requires exactly one file or folder",
+			2, true, false,
+		},
+		{
+			"FAIL_CONFLICT_OVERRIDE",
+			"-override -on-conflict=skip test-bucket test-folder", "flag -on-conflict cannot be used with -override or -sync",
+			2, true, false,
+		},
+		{
+			"FAIL_CONFLICT_POLICY",
+			"-on-conflict=ask test-bucket test-folder",
+			"invalid conflict policy \"ask\", should be one of skip, overwrite, rename or fail",
+			2, true, false,
+		},
+		{
+			"FAIL_STDIN_ARCHIVE",
+			"-archive test-bucket/object -", "standard input cannot be exported with -archive or -sync",
+			2, true, false,
+		},
+		{
+			"FAIL_STDIN_SELECTION",
+			"test-bucket/object - test-file", "standard input cannot be exported together with other files",
+			2, true, false,
+		},
+		{
+			"FAIL_SYMLINK_FLAGS",
+			"-follow-symlinks -symlink-objects test-bucket test-folder",
+			"flags -follow-symlinks and -symlink-objects cannot be used together",
+			2, true, false,
+		},
+		{
+			"FAIL_SYMLINK_ARCHIVE",
+			"-archive -follow-symlinks test-bucket test-folder",
+			"flags -follow-symlinks and -symlink-objects cannot be used with -archive",
+			2, true, false,
+		},
+		{
+			"FAIL_RETRY_CHANGED",
+			"-retry-changed=-1 test-bucket test-folder", "flag -retry-changed cannot be negative",
+			2, true, false,
+		},
+		{
+			"FAIL_CONCURRENCY",
+			"-part-concurrency=0 test-bucket test-folder", "part concurrency must be at least 1",
+			2, true, false,
+		},
+		{
+			"FAIL_MEMORY",
+			"test-bucket test-folder -memory-budget 64M", "memory budget must be at least 128 MiB",
+			2, true, false,
+		},
+		{
+			"FAIL_MEMORY_SIZE",
+			"test-bucket test-folder -memory-budget=1TB", "invalid memory budget: invalid size \"1TB\"",
+			2, true, false,
+		},
+		{
+			"FAIL_UPLOAD_LIMIT",
+			"-upload-limit=-5 test-bucket test-folder", "invalid upload limit: invalid rate \"-5\"",
+			2, true, false,
+		},
+		{
+			"FAIL_PRIVATE_KEY",
+			"-private-key /nonexistent/key.sec test-bucket test-folder",
+			"failed to open private key: open /nonexistent/key.sec: no such file or directory",
+			2, true, false,
+		},
 	}
 
 	origExportPossible := airlock.ExportPossible
 	origFindataUpload := api.FindataUpload
+	origLimits := airlock.GetUploadLimits()
+	origBandwidth := api.GetBandwidthLimits()
 	defer func() {
 		airlock.ExportPossible = origExportPossible
 		api.FindataUpload = origFindataUpload
 	}()
+	t.Setenv("C4GH_PASSPHRASE", "")
 
 	for _, tt := range tests {
 		t.Run(tt.testname, func(t *testing.T) {
+			t.Cleanup(func() {
+				override, syncMode, deleteRemoved = false, false, false
+				archiveMode, compressArchive = false, false
+				conflictPolicy = ""
+				airlock.SetDecryptCheck(false)
+				airlock.SetChangeRetries(0)
+				airlock.SetSymlinkMode("")
+				dryRun = false
+				_ = airlock.SetUploadLimits(origLimits)
+				_ = api.SetBandwidthLimits(origBandwidth)
+			})
+
 			airlock.ExportPossible = func() bool {
 				return tt.export
 			}
@@ -187,12 +385,12 @@ func TestExportSetup_Error(t *testing.T) {
 
 			// Ignore prints to stdout
 			null, _ := os.Open(os.DevNull)
-			sout := os.Stdout
-			os.Stdout = null
+			sout, smessages := os.Stdout, messages
+			os.Stdout, messages = null, null
 
 			code, err := exportSetup(strings.Split(tt.args, " "))
 
-			os.Stdout = sout
+			os.Stdout, messages = sout, smessages
 			null.Close()
 
 			if code != tt.code {
@@ -212,3 +410,262 @@ func TestExportSetup_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestExportHandler_DryRun(t *testing.T) {
+	defer func() {
+		logs.SetSignal(func(string, []string) {})
+		exportPrefix, selection = "", []string{}
+		dryRun = false
+		airlock.SetSymlinkMode("")
+	}()
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(tmpDir+"/file.txt", []byte("content"), 0600); err != nil {
+		t.Fatalf("Failed to create file: %s", err.Error())
+	}
+	if err := os.Symlink(tmpDir+"/file.txt", tmpDir+"/link.txt"); err != nil {
+		t.Fatalf("Failed to create symbolic link: %s", err.Error())
+	}
+
+	var logged []string
+	logs.SetSignal(func(level string, messages []string) {
+		logged = append(logged, messages...)
+	})
+
+	// Bucket would be created if files were exported
+	exportPrefix, selection, dryRun = "test-bucket", []string{tmpDir + "/file.txt", tmpDir + "/link.txt"}, true
+	airlock.SetSymlinkMode(airlock.SymlinkObject)
+	if code, err := exportHandler(); err != nil || code != 0 {
+		t.Fatalf("Function should have succeeded, received code %d and error %v", code, err)
+	}
+
+	for _, line := range []string{
+		"Would export: " + tmpDir + "/file.txt -> test-bucket/file.txt.c4gh",
+		"Would export symbolic link: " + tmpDir + "/link.txt (link to " + tmpDir + "/file.txt) -> test-bucket/link.txt.c4gh",
+		"Dry run: 2 file(s), 1 of them symbolic links, would be exported to bucket test-bucket",
+	} {
+		if !slices.Contains(logged, line) {
+			t.Errorf("Plan did not contain line %q\nReceived=%q", line, logged)
+		}
+	}
+}
+
+func TestExportHandler_ArchiveDryRun(t *testing.T) {
+	origUploadArchive := airlock.UploadArchive
+	defer func() {
+		airlock.UploadArchive = origUploadArchive
+		logs.SetSignal(func(string, []string) {})
+		exportPrefix, selection = "", []string{}
+		dryRun, archiveMode, compressArchive = false, false, false
+		results = commandResult{}
+	}()
+
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(tmpDir+"/data/sub", 0755); err != nil {
+		t.Fatalf("Failed to create folder: %s", err.Error())
+	}
+	for _, name := range []string{"/data/file.txt", "/data/sub/other.txt"} {
+		if err := os.WriteFile(tmpDir+name, []byte("content"), 0600); err != nil {
+			t.Fatalf("Failed to create file: %s", err.Error())
+		}
+	}
+
+	airlock.UploadArchive = func(ctx context.Context, archive airlock.Archive) error {
+		t.Errorf("Archive %s should not have been uploaded", archive.Object)
+
+		return nil
+	}
+	var logged []string
+	logs.SetSignal(func(level string, messages []string) {
+		logged = append(logged, messages...)
+	})
+
+	// Bucket would be created if the archive was exported
+	results = commandResult{}
+	exportPrefix, selection = "test-bucket", []string{tmpDir + "/data"}
+	dryRun, archiveMode, compressArchive = true, true, true
+	if code, err := exportHandler(); err != nil || code != 0 {
+		t.Fatalf("Function should have succeeded, received code %d and error %v", code, err)
+	}
+
+	for _, line := range []string{
+		"Would archive: " + tmpDir + "/data/file.txt -> data/file.txt",
+		"Would archive: " + tmpDir + "/data/sub/other.txt -> data/sub/other.txt",
+		"Dry run: 2 file(s) would be exported as archive test-bucket/data.tar.zst.c4gh with index data.tar.zst.index.json.c4gh",
+	} {
+		if !slices.Contains(logged, line) {
+			t.Errorf("Plan did not contain line %q\nReceived=%q", line, logged)
+		}
+	}
+	expected := []fileResult{{File: tmpDir + "/data", Object: "test-bucket/data.tar.zst.c4gh", Status: statusPlanned}}
+	if !reflect.DeepEqual(results.Files, expected) {
+		t.Errorf("Incorrect results\nExpected=%+v\nReceived=%+v", expected, results.Files)
+	}
+}
+
+func TestExportHandler_ResultAfterConflicts(t *testing.T) {
+	origBucketExists := api.BucketExists
+	origResolveConflicts := airlock.ResolveConflicts
+	defer func() {
+		api.BucketExists = origBucketExists
+		airlock.ResolveConflicts = origResolveConflicts
+		logs.SetSignal(func(string, []string) {})
+		exportPrefix, selection = "", []string{}
+		conflictPolicy = ""
+		results = commandResult{}
+	}()
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(tmpDir+"/file.txt", []byte("content"), 0600); err != nil {
+		t.Fatalf("Failed to create file: %s", err.Error())
+	}
+
+	api.BucketExists = func(rep api.Repo, bucket string) (bool, error) {
+		return true, nil
+	}
+	airlock.ResolveConflicts = func(set *airlock.UploadSet, policy airlock.ConflictPolicy) ([]airlock.ConflictResult, error) {
+		conflicts := []airlock.ConflictResult{{File: set.Files[0], Action: airlock.ActionSkipped}}
+		set.Files, set.Objects, set.Exists = nil, nil, nil
+
+		return conflicts, nil
+	}
+	logs.SetSignal(func(string, []string) {})
+
+	results = commandResult{}
+	exportPrefix, selection, conflictPolicy = "test-bucket", []string{tmpDir + "/file.txt"}, airlock.ConflictSkip
+	if code, err := exportHandler(); err != nil || code != 0 {
+		t.Fatalf("Function should have succeeded, received code %d and error %v", code, err)
+	}
+
+	set, ok := results.Result.(airlock.UploadSet)
+	if !ok {
+		t.Fatalf("Result has incorrect type %T", results.Result)
+	}
+	if len(set.Files) != 0 || len(set.Objects) != 0 {
+		t.Errorf("Result should not list skipped files\nReceived=%+v", set)
+	}
+}
+
+func TestRecordUploads(t *testing.T) {
+	defer func() {
+		results = commandResult{}
+		deleteRemoved = false
+	}()
+
+	results = commandResult{}
+	set := airlock.UploadSet{
+		Bucket:  "bucket",
+		Files:   []string{"a.txt", "b.txt", "c.txt"},
+		Objects: []string{"a.txt.c4gh", "b.txt.c4gh", "c (1).txt.c4gh"},
+	}
+	recordUploads(set, []string{"a.txt", "c.txt"}, errExpected)
+	logConflicts([]airlock.ConflictResult{
+		{File: "a.txt", Object: "a.txt.c4gh", Action: airlock.ActionNew},
+		{File: "c.txt", Object: "c (1).txt.c4gh", Action: airlock.ActionRenamed},
+		{File: "d.txt", Action: airlock.ActionSkipped},
+	})
+	deleteRemoved = true
+	logSyncReport(airlock.SyncReport{Unchanged: []string{"e.txt"}, Removed: []string{"f.txt.c4gh", "g.txt.c4gh"}},
+		"bucket", map[string]error{"g.txt.c4gh": errExpected})
+
+	expected := []fileResult{
+		{File: "a.txt", Object: "bucket/a.txt.c4gh", Status: statusUploaded, Action: airlock.ActionNew},
+		{File: "b.txt", Object: "bucket/b.txt.c4gh", Status: statusFailed},
+		{File: "c.txt", Object: "bucket/c (1).txt.c4gh", Status: statusUploaded, Action: airlock.ActionRenamed},
+		{File: "d.txt", Status: statusSkipped},
+		{File: "e.txt", Status: statusUnchanged},
+		{Object: "bucket/f.txt.c4gh", Status: statusDeleted},
+		{Object: "bucket/g.txt.c4gh", Status: statusFailed, Error: []string{errExpected.Error()}},
+	}
+	if !reflect.DeepEqual(results.Files, expected) {
+		t.Errorf("Incorrect results\nExpected=%+v\nReceived=%+v", expected, results.Files)
+	}
+
+	results = commandResult{}
+	recordUploads(set, nil, fmt.Errorf("upload cancelled: %w", context.Canceled))
+	for _, result := range results.Files {
+		if result.Status != statusCancelled {
+			t.Errorf("File %s should have been cancelled, received status %s", result.File, result.Status)
+		}
+	}
+}
+
+func TestLoadPrivateKey(t *testing.T) {
+	origAskForPassphrase := askForPassphrase
+	defer func() {
+		askForPassphrase = origAskForPassphrase
+		_ = airlock.SetPrivateKey("", nil)
+	}()
+
+	_, privateKey, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	filename := t.TempDir() + "/key.sec"
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatalf("Failed to create file: %s", err.Error())
+	}
+	if err = keys.WriteCrypt4GHX25519PrivateKey(file, privateKey, []byte("secret")); err != nil {
+		t.Fatalf("Failed to write private key: %s", err.Error())
+	}
+	file.Close()
+
+	asked := 0
+	askForPassphrase = func() (string, error) {
+		asked++
+
+		return "secret", nil
+	}
+	if err = loadPrivateKey(filename); err != nil {
+		t.Errorf("Function returned unexpected error: %s", err.Error())
+	}
+	if asked != 1 {
+		t.Errorf("Passphrase should have been asked once, was asked %d time(s)", asked)
+	}
+
+	t.Setenv("C4GH_PASSPHRASE", "secret")
+	if err = loadPrivateKey(filename); err != nil {
+		t.Errorf("Function returned unexpected error: %s", err.Error())
+	}
+	if asked != 1 {
+		t.Error("Passphrase should have been taken from environment")
+	}
+}
+
+func TestListenExportControl(t *testing.T) {
+	origSocket := exportSocket
+	origLimits := api.GetBandwidthLimits()
+	defer func() {
+		exportSocket = origSocket
+		_ = api.SetBandwidthLimits(origLimits)
+	}()
+
+	exportSocket = ""
+	closeControl, err := listenExportControl()
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	closeControl()
+
+	exportSocket = filepath.Join(t.TempDir(), "export.sock")
+	closeControl, err = listenExportControl()
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	defer closeControl()
+
+	resp, err := sendControl(exportSocket, controlRequest{Command: "limit", Args: []string{"upload", "2M"}})
+	if err != nil || !resp.OK {
+		t.Fatalf("Limit could not be changed: %v %+v", err, resp)
+	}
+	if limits := api.GetBandwidthLimits(); limits.Upload != 2<<20 {
+		t.Errorf("Upload limit was not changed. Expected=%d, received=%d", 2<<20, limits.Upload)
+	}
+
+	resp, err = sendControl(exportSocket, controlRequest{Command: "unmount"})
+	errStr := "command \"unmount\" is not available, possible commands are {limit,loglevel}"
+	if err != nil || resp.OK || resp.Message != errStr {
+		t.Errorf("Received incorrect response\nExpected=%s\nReceived=%+v (%v)", errStr, resp, err)
+	}
+}
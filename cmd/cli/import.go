@@ -3,12 +3,15 @@ package main
 import (
 	"bufio"
 	"flag"
+	"fmt"
 	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"syscall"
 
+	"sda-filesystem/internal/airlock"
 	"sda-filesystem/internal/api"
 	"sda-filesystem/internal/filesystem"
 	"sda-filesystem/internal/logs"
@@ -16,20 +19,48 @@ import (
 )
 
 var mount string
+var daemon bool
+var controlSocket string
 
 func init() {
 	handlers["import"] = handlerFuncs{setup: importSetup, execute: importHandler}
 }
 
 func importSetup(args []string) (int, error) {
-	var sdapplyOnly bool
+	var sdapplyOnly, browseArchives bool
+	var downloadLimit string
 	set := flag.NewFlagSet("import", flag.ContinueOnError)
 	set.StringVar(&mount, "mount", "", "Path to Data Gateway mount point")
 	set.BoolVar(&sdapplyOnly, "sdapply", false, "Connect only to SD Apply")
+	set.BoolVar(&browseArchives, "browse-archives", false, "Show archives exported with 'export -archive' as folders")
+	set.StringVar(&downloadLimit, "download-limit", "0", "Maximum download rate "+rateUsage)
+	set.BoolVar(&daemon, "daemon", false, "Do not read commands from standard input, listen for 'ctl' commands on a Unix socket instead")
+	set.StringVar(&controlSocket, "socket", defaultSocket(), "Path to the control socket used with -daemon")
 
 	if err := set.Parse(args); err != nil {
 		return 2, nil
 	}
+	if err := applySettings(set, map[string]string{
+		"mount":           "filesystem.mount",
+		"browse-archives": "filesystem.browse-archives",
+		"download-limit":  "transfer.download-limit",
+	}); err != nil {
+		return 2, err
+	}
+	selected, err := selectedRepositories(sdapplyOnly)
+	if err != nil {
+		return 2, err
+	}
+
+	rate, err := parseRate(downloadLimit)
+	if err != nil {
+		return 2, fmt.Errorf("invalid download limit: %w", err)
+	}
+	limits := api.GetBandwidthLimits()
+	limits.Download = rate
+	if err = api.SetBandwidthLimits(limits); err != nil {
+		return 2, err
+	}
 
 	if mount == "" {
 		defaultMount, err := mountpoint.DefaultMountPoint()
@@ -43,17 +74,48 @@ func importSetup(args []string) (int, error) {
 
 	mount = filepath.Clean(mount)
 
-	if !sdapplyOnly && !api.SDConnectEnabled() {
+	if (selected == nil || selected[api.SDConnect.ForPath()]) && !api.SDConnectEnabled() {
 		logs.Warningf("You do not have SD Connect enabled")
 	}
 
-	if sdapplyOnly {
-		api.SetRepositories(map[string]bool{api.SDApply.ForPath(): true})
+	filesystem.SetBrowseArchives(browseArchives)
+
+	if selected != nil {
+		api.SetRepositories(selected)
 	}
 
 	return 0, nil
 }
 
+// selectedRepositories returns the repositories chosen with -sdapply or in the configuration file,
+// or nil if all available repositories are used
+func selectedRepositories(sdapplyOnly bool) (map[string]bool, error) {
+	if sdapplyOnly {
+		return map[string]bool{api.SDApply.ForPath(): true}, nil
+	}
+	names, ok := settings.Strings("filesystem.repositories")
+	if !ok {
+		return nil, nil
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("invalid value for filesystem.repositories in %s: no repositories", settings.Path())
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range names {
+		rep, bucket, _, err := airlock.ParseStoragePath(name)
+		if err == nil && (rep == "" || bucket != "") {
+			err = fmt.Errorf("invalid repository %q", name)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for filesystem.repositories in %s: %w", settings.Path(), err)
+		}
+		selected[rep.ForPath()] = true
+	}
+
+	return selected, nil
+}
+
 // userInput reads user's input from io.Reader and sends it into a channel
 func userInput(r io.Reader, ch chan<- []string) {
 	scanner := bufio.NewScanner(r)
@@ -92,22 +154,40 @@ func applyCommand(ch <-chan []string) {
 			} else {
 				logs.Errorf("Cannot clear cache without path")
 			}
+		case "limit":
+			if err := limitCommand(input[1:]); err != nil {
+				logs.Error(err)
+			}
 		}
 	}
 }
 
 func importHandler() (int, error) {
+	var server *controlServer
+	if daemon {
+		var err error
+		if server, err = listenControl(controlSocket); err != nil {
+			return 1, err
+		}
+		defer server.close()
+		logs.Infof("Listening for commands at %s", controlSocket)
+	}
+
 	var wait = make(chan any)
 	var cmd = make(chan []string)
 	go func() {
 		<-wait // Wait for fuse to be ready
 		go filesystem.WaitForUpdateSignal(cmd)
-		go userInput(os.Stdin, cmd)
+		if server != nil {
+			go server.serve()
+		} else {
+			go userInput(os.Stdin, cmd)
+		}
 		go applyCommand(cmd)
 	}()
 
 	s := make(chan os.Signal, 1)
-	signal.Notify(s, os.Interrupt)
+	signal.Notify(s, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		for range s {
 			logs.Info("Shutting down Data Gateway")
@@ -88,6 +88,10 @@ func TestImportSetup_Error(t *testing.T) {
 			"FAIL_BAD_ARG", "-money=euro", "",
 			true, 2, nil, nil,
 		},
+		{
+			"FAIL_DOWNLOAD_LIMIT", "-download-limit=fast", "invalid download limit: invalid rate \"fast\"",
+			true, 2, nil, nil,
+		},
 	}
 
 	origDefaultMountPoint := mountpoint.DefaultMountPoint
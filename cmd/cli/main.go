@@ -12,18 +12,20 @@ import (
 
 	"sda-filesystem/certs"
 	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/config"
 	"sda-filesystem/internal/logs"
 
 	"golang.org/x/term"
 )
 
-var logLevel string
+var logLevel, logFormat, logFile string
 
 var handlers = map[string]handlerFuncs{}
 
 type handlerFuncs struct {
 	setup   func([]string) (int, error)
 	execute func() (int, error)
+	local   bool // Subcommand does not need access to SD Connect or SD Apply
 }
 
 type loginReader interface {
@@ -54,9 +56,9 @@ func (r *stdinReader) restoreState() error {
 }
 
 var askForPassword = func(lr loginReader) (string, error) {
-	fmt.Print("Enter password: ")
+	fmt.Fprint(messages, "Enter password: ")
 	password, err := lr.readPassword()
-	fmt.Println()
+	fmt.Fprintln(messages)
 	if err != nil {
 		return "", fmt.Errorf("could not read password: %w", err)
 	}
@@ -84,7 +86,7 @@ var login = func(lr loginReader) error {
 	defer func() { signal.Stop(signalChan) }()
 	go func() {
 		<-signalChan
-		fmt.Println("")
+		fmt.Fprintln(messages, "")
 		if err = lr.restoreState(); err != nil {
 			logs.Warningf("Could not restore terminal to original state: %w", err)
 		}
@@ -112,18 +114,33 @@ var login = func(lr loginReader) error {
 
 func init() {
 	flag.Usage = func() {
-		fmt.Printf("Usage: %s [arguments] [subcommand] [subcommand arguments]\n", os.Args[0])
+		fmt.Fprintf(messages, "Usage: %s [arguments] [subcommand] [subcommand arguments]\n", os.Args[0])
 
 		flag.PrintDefaults()
 
-		fmt.Printf("\nRun %s [subcommand] -help to learn more about subcommands\n", os.Args[0])
-		fmt.Println("\nAvailable subcommands:")
-		fmt.Println("import: Setup a filesystem that has access to files in SD Connect and SD Apply")
-		fmt.Println("export: Upload files and folders from VM to SD Connect")
-		fmt.Println()
+		fmt.Fprintf(messages, "\nRun %s [subcommand] -help to learn more about subcommands\n", os.Args[0])
+		fmt.Fprintln(messages, "\nAvailable subcommands:")
+		fmt.Fprintln(messages, "import: Setup a filesystem that has access to files in SD Connect and SD Apply")
+		fmt.Fprintln(messages, "export: Upload files and folders from VM to SD Connect")
+		fmt.Fprintln(messages, "verify: Check that exported objects in SD Connect match the checksums of the original files")
+		fmt.Fprintln(messages, "cleanup: Find and remove headers in Vault that were left behind by failed exports")
+		fmt.Fprintln(messages, "download: Download and decrypt objects from SD Connect or SD Apply without mounting Data Gateway")
+		fmt.Fprintln(messages, "ls: List repositories, buckets, folders and objects in SD Connect and SD Apply")
+		fmt.Fprintln(messages, "stat: Show the size, modification time, owner and metadata of buckets, folders and objects")
+		fmt.Fprintln(messages, "find: Search for objects by name, size and modification time")
+		fmt.Fprintln(messages, "cat: Print the decrypted content of objects in SD Connect or SD Apply")
+		fmt.Fprintln(messages, "ctl: Send a command to Data Gateway running with 'import -daemon'")
+		fmt.Fprintln(messages, "doctor: Check the prerequisites of Data Gateway and tell how to fix the ones that fail")
+		fmt.Fprintln(messages, "support-bundle: Package logs, version, configuration and state of Data Gateway for a support ticket")
+		fmt.Fprintln(messages)
 	}
 
 	flag.StringVar(&logLevel, "loglevel", "info", "Logging level. Possible values: {trace,debug,info,warning,error}")
+	flag.StringVar(&logFormat, "logformat", "text", "Format of the logs. Possible values: {text,json}")
+	flag.StringVar(&logFile, "logfile", "", "Write the logs also to this file, which is rotated once it grows larger than logging.max-size in the configuration file, 10M by default")
+	flag.StringVar(&outputFormat, "output", "text", "Format of the results printed on stdout, logs are always printed on stderr. Possible values: {text,json}")
+	flag.StringVar(&configPath, "config", "", "Path to the configuration file (default $"+config.EnvPath+" or ~/.config/data-gateway/config.toml)")
+	flag.StringVar(&profileName, "profile", "", "Profile to use from the configuration file (default $"+config.EnvProfile+" or the profile set in the file)")
 }
 
 func main() {
@@ -136,35 +153,44 @@ func main() {
 	}
 	subcommand := flag.Args()[0]
 
+	if err := setOutputFormat(outputFormat); err != nil {
+		logs.Fatal(err)
+	}
+	if err := loadSettings(); err != nil {
+		finish(subcommand, 1, err)
+	}
 	logs.SetLevel(logLevel)
+	logSettings()
+
+	if handlers[subcommand].local {
+		code, err := handlers[subcommand].setup(flag.Args()[1:])
+		if err == nil && code == 0 {
+			code, err = handlers[subcommand].execute()
+		}
+		finish(subcommand, code, err)
+	}
 
 	if err := api.Setup(certs.Files); err != nil {
-		logs.Fatal(err)
+		finish(subcommand, 1, err)
 	}
 
 	access, err := api.GetProfileCLI()
 	if err != nil {
-		logs.Fatal(err)
+		finish(subcommand, 1, err)
 	}
 
 	code, err := handlers[subcommand].setup(flag.Args()[1:])
-	if err != nil {
-		logs.Fatal(err)
-	}
-	if code != 0 {
-		os.Exit(code)
+	if err != nil || code != 0 {
+		finish(subcommand, code, err)
 	}
 
 	if !access {
 		logs.Info("Passwordless session not possible")
 		if err := login(&stdinReader{}); err != nil {
-			logs.Fatal(err)
+			finish(subcommand, 1, err)
 		}
 	}
 
 	code, err = handlers[subcommand].execute()
-	if err != nil {
-		logs.Fatal(err)
-	}
-	os.Exit(code)
+	finish(subcommand, code, err)
 }
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strings"
 	"testing"
 
 	"sda-filesystem/internal/api"
@@ -13,6 +14,19 @@ import (
 
 var errExpected = errors.New("expected error for test")
 
+// runSetup calls `setup` while ignoring prints to stdout and stderr
+func runSetup(setup func([]string) (int, error), args string) (int, error) {
+	null, _ := os.Open(os.DevNull)
+	sout, serr, smessages := os.Stdout, os.Stderr, messages
+	os.Stdout, os.Stderr, messages = null, null, null
+	defer func() {
+		os.Stdout, os.Stderr, messages = sout, serr, smessages
+		null.Close()
+	}()
+
+	return setup(strings.Fields(args))
+}
+
 // testReader implements loginReader and contains password
 type testReader struct {
 	pwd string
@@ -80,13 +94,13 @@ func TestAskForPassword(t *testing.T) {
 		t.Run(tt.testname, func(t *testing.T) {
 			// Ignore prints to stdout
 			null, _ := os.Open(os.DevNull)
-			sout := os.Stdout
-			os.Stdout = null
+			sout, smessages := os.Stdout, messages
+			os.Stdout, messages = null, null
 
 			r := testReader{tt.password, tt.readerError}
 			password, err := askForPassword(r)
 
-			os.Stdout = sout
+			os.Stdout, messages = sout, smessages
 			null.Close()
 
 			switch {
@@ -233,12 +247,12 @@ func TestLogin(t *testing.T) {
 
 			// Ignore prints to stdout
 			null, _ := os.Open(os.DevNull)
-			sout := os.Stdout
-			os.Stdout = null
+			sout, smessages := os.Stdout, messages
+			os.Stdout, messages = null, null
 
 			err := login(testReader{"", tt.readerError})
 
-			os.Stdout = sout
+			os.Stdout, messages = sout, smessages
 			null.Close()
 
 			switch {
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"sda-filesystem/internal/logs"
+)
+
+// Statuses of files and objects in the results of a subcommand
+const (
+	statusUploaded   = "uploaded"
+	statusDownloaded = "downloaded"
+	statusVerified   = "verified"
+	statusSkipped    = "skipped"
+	statusUnchanged  = "unchanged"
+	statusPlanned    = "planned"
+	statusFound      = "found"
+	statusDeleted    = "deleted"
+	statusRemoved    = "removed"
+	statusFailed     = "failed"
+	statusCancelled  = "cancelled"
+)
+
+// stdout is where the results of subcommands are written
+var stdout io.Writer = os.Stdout
+
+// stderr is where diagnostics are written
+var stderr io.Writer = os.Stderr
+
+// messages is where usage messages and prompts are written
+var messages io.Writer = os.Stdout
+
+var outputFormat string
+var jsonOutput bool
+
+// fileResult is the outcome of a subcommand for a single file or object
+type fileResult struct {
+	File   string   `json:"file,omitempty"`
+	Object string   `json:"object,omitempty"`
+	Status string   `json:"status"`
+	Action string   `json:"action,omitempty"` // How a conflict with an existing object was resolved
+	Error  []string `json:"error,omitempty"`
+}
+
+// commandResult is printed on stdout once the subcommand has finished when the CLI is run with -output=json
+type commandResult struct {
+	Command  string       `json:"command"`
+	OK       bool         `json:"ok"`
+	ExitCode int          `json:"exit_code"`
+	Started  time.Time    `json:"started"`
+	Duration float64      `json:"duration_seconds"`
+	Result   any          `json:"result,omitempty"`
+	Files    []fileResult `json:"files,omitempty"`
+	Error    []string     `json:"error,omitempty"` // Error divided into the errors it wraps, outermost first
+}
+
+var results = commandResult{Started: time.Now()}
+
+// setOutputFormat checks the value of -output. With JSON output, usage messages and prompts
+// are written to stderr so that stdout contains only the results.
+func setOutputFormat(format string) error {
+	switch format {
+	case "text":
+		jsonOutput = false
+		messages = stdout
+	case "json":
+		jsonOutput = true
+		messages = stderr
+	default:
+		return fmt.Errorf("-output=%s is not supported, possible values are {text,json}", format)
+	}
+
+	return nil
+}
+
+// setResult sets the result of the subcommand
+func setResult(v any) {
+	results.Result = v
+}
+
+// addFileResult records the outcome for a file or object. A non-nil `err` is included as its chain of wrapped errors.
+func addFileResult(file, object, status string, err error) {
+	result := fileResult{File: file, Object: object, Status: status}
+	if err != nil {
+		result.Error = logs.StructureError(err)
+	}
+	results.Files = append(results.Files, result)
+}
+
+// printJSON writes `v` to stdout as indented JSON
+func printJSON(v any) error {
+	enc := json.NewEncoder(stdout)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(v)
+}
+
+// writeResult prints the results of `command` on stdout if the output format is JSON
+func writeResult(command string, code int, err error) {
+	if !jsonOutput {
+		return
+	}
+
+	results.Command = command
+	results.ExitCode = code
+	results.OK = code == 0 && err == nil
+	results.Duration = time.Since(results.Started).Seconds()
+	if err != nil {
+		results.Error = logs.StructureError(err)
+	}
+	if err := printJSON(results); err != nil {
+		logs.Errorf("Could not print results: %w", err)
+	}
+}
+
+// finish prints the results of `command` and exits. A non-nil `err` is logged and the exit code is then 1.
+func finish(command string, code int, err error) {
+	if err != nil {
+		code = 1
+	}
+	writeResult(command, code, err)
+	if err != nil {
+		logs.Fatal(err)
+	}
+	os.Exit(code)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSetOutputFormat(t *testing.T) {
+	var tests = []struct {
+		testname, format, errStr string
+		json                     bool
+	}{
+		{"OK_TEXT", "text", "", false},
+		{"OK_JSON", "json", "", true},
+		{"FAIL_FORMAT", "yaml", "-output=yaml is not supported, possible values are {text,json}", false},
+	}
+
+	origStdout, origOsStdout, origMessages := stdout, os.Stdout, messages
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			t.Cleanup(func() {
+				messages = origMessages
+				jsonOutput = false
+			})
+
+			err := setOutputFormat(tt.format)
+
+			switch {
+			case tt.errStr != "":
+				if err == nil {
+					t.Error("Function did not return error")
+				} else if err.Error() != tt.errStr {
+					t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+				}
+			case err != nil:
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			}
+			if jsonOutput != tt.json {
+				t.Errorf("Incorrect output format. Expected JSON=%t, received=%t", tt.json, jsonOutput)
+			}
+			// Other prints must not end up among the results
+			if tt.json && messages != stderr {
+				t.Error("Stdout should be reserved for the results")
+			}
+			if os.Stdout != origOsStdout || stdout != origStdout {
+				t.Error("Function should not change stdout")
+			}
+		})
+	}
+}
+
+func TestWriteResult(t *testing.T) {
+	var tests = []struct {
+		testname string
+		json     bool
+		code     int
+		err      error
+		ok       bool
+		errChain []string
+	}{
+		{"OK_TEXT", false, 0, nil, true, nil},
+		{"OK_JSON", true, 0, nil, true, nil},
+		{"FAIL_CODE", true, 1, nil, false, nil},
+		{"FAIL_ERROR", true, 1, fmt.Errorf("failed to upload: %w", errExpected), false, []string{"failed to upload", errExpected.Error()}},
+	}
+
+	origStdout := stdout
+	defer func() {
+		stdout = origStdout
+		jsonOutput = false
+		results = commandResult{}
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			results = commandResult{}
+			buf := &bytes.Buffer{}
+			stdout, jsonOutput = buf, tt.json
+
+			setResult(map[string]string{"bucket": "bucket"})
+			addFileResult("file.txt", "bucket/file.txt.c4gh", statusUploaded, nil)
+			addFileResult("other.txt", "bucket/other.txt.c4gh", statusFailed, errExpected)
+			writeResult("export", tt.code, tt.err)
+
+			if !tt.json {
+				if buf.Len() > 0 {
+					t.Errorf("Results should not be printed as text, received=%q", buf.String())
+				}
+
+				return
+			}
+
+			var received struct {
+				commandResult
+				Result map[string]string `json:"result"`
+			}
+			if err := json.Unmarshal(buf.Bytes(), &received); err != nil {
+				t.Fatalf("Output is not valid JSON: %s\n%s", err.Error(), buf.String())
+			}
+			if received.Command != "export" || received.OK != tt.ok || received.ExitCode != tt.code {
+				t.Errorf("Incorrect status. Expected=export %t %d, received=%s %t %d", tt.ok, tt.code, received.Command, received.OK, received.ExitCode)
+			}
+			if !reflect.DeepEqual(received.Error, tt.errChain) {
+				t.Errorf("Incorrect error\nExpected=%q\nReceived=%q", tt.errChain, received.Error)
+			}
+			if received.Result["bucket"] != "bucket" {
+				t.Errorf("Incorrect result: %v", received.Result)
+			}
+			expectedFiles := []fileResult{
+				{File: "file.txt", Object: "bucket/file.txt.c4gh", Status: statusUploaded},
+				{File: "other.txt", Object: "bucket/other.txt.c4gh", Status: statusFailed, Error: []string{errExpected.Error()}},
+			}
+			if !reflect.DeepEqual(received.Files, expectedFiles) {
+				t.Errorf("Incorrect files\nExpected=%+v\nReceived=%+v", expectedFiles, received.Files)
+			}
+			if received.Duration < 0 {
+				t.Errorf("Duration should not be negative, received=%f", received.Duration)
+			}
+		})
+	}
+}
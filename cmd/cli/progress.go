@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"sda-filesystem/internal/airlock"
+	"sda-filesystem/internal/logs"
+)
+
+// progressDisplay shows the progress of an export. On a terminal the progress is kept on the last line,
+// and logs written through the display are printed above it. Otherwise, progress is logged whenever a file finishes.
+type progressDisplay struct {
+	mu       sync.Mutex
+	out      io.Writer
+	terminal bool
+	line     string
+}
+
+func newProgressDisplay(out io.Writer, terminal bool) *progressDisplay {
+	return &progressDisplay{out: out, terminal: terminal}
+}
+
+func (d *progressDisplay) update(p airlock.Progress) {
+	if !d.terminal {
+		if p.Done {
+			logs.Infof("Progress: %s", formatProgress(p))
+		}
+
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.line = formatProgress(p)
+	switch {
+	case p.Done:
+	case p.Size < 0:
+		d.line += fmt.Sprintf(", %s %s", filepath.Base(p.File), formatBytes(p.Bytes))
+	default:
+		d.line += fmt.Sprintf(", %s %d%%", filepath.Base(p.File), percentage(p.Bytes, p.Size))
+	}
+	fmt.Fprint(d.out, "\r\033[K"+d.line)
+}
+
+// Write prints `p` above the progress line
+func (d *progressDisplay) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.line == "" {
+		return d.out.Write(p)
+	}
+	fmt.Fprint(d.out, "\r\033[K")
+	n, err := d.out.Write(p)
+	fmt.Fprint(d.out, d.line)
+
+	return n, err
+}
+
+// close moves the cursor past the progress line
+func (d *progressDisplay) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.line != "" {
+		fmt.Fprintln(d.out)
+		d.line = ""
+	}
+}
+
+func formatProgress(p airlock.Progress) string {
+	eta := "unknown"
+	if p.ETA >= 0 {
+		eta = (time.Duration(math.Round(p.ETA)) * time.Second).String()
+	}
+	if p.TotalSize < 0 {
+		return fmt.Sprintf("%d/%d files, %s, %s/s", p.Finished, p.Files, formatBytes(p.TotalBytes), formatBytes(int64(p.Rate)))
+	}
+
+	return fmt.Sprintf("%d/%d files, %s of %s (%d%%), %s/s, ETA %s",
+		p.Finished, p.Files, formatBytes(p.TotalBytes), formatBytes(p.TotalSize),
+		percentage(p.TotalBytes, p.TotalSize), formatBytes(int64(p.Rate)), eta)
+}
+
+func percentage(part, whole int64) int {
+	if whole <= 0 {
+		return 100
+	}
+
+	return int(part * 100 / whole)
+}
+
+// formatBytes returns `n` in binary units with one decimal
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 5; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"sda-filesystem/internal/airlock"
+)
+
+func TestFormatBytes(t *testing.T) {
+	var tests = []struct {
+		bytes    int64
+		expected string
+	}{
+		{0, "0 B"},
+		{1023, "1023 B"},
+		{1024, "1.0 KiB"},
+		{1536, "1.5 KiB"},
+		{5 << 20, "5.0 MiB"},
+		{3 << 40, "3.0 TiB"},
+	}
+
+	for _, tt := range tests {
+		if received := formatBytes(tt.bytes); received != tt.expected {
+			t.Errorf("Function returned incorrect string. Expected=%s, received=%s", tt.expected, received)
+		}
+	}
+}
+
+func TestFormatProgress(t *testing.T) {
+	p := airlock.Progress{
+		File: "dir/file.txt", Bytes: 50, Size: 100,
+		TotalBytes: 3 << 20, TotalSize: 12 << 20, Files: 4, Finished: 1,
+		Rate: 1 << 20, ETA: 250.4,
+	}
+	expected := "1/4 files, 3.0 MiB of 12.0 MiB (25%), 1.0 MiB/s, ETA 4m10s"
+	if received := formatProgress(p); received != expected {
+		t.Errorf("Function returned incorrect string\nExpected=%s\nReceived=%s", expected, received)
+	}
+
+	p.ETA = -1
+	expected = "1/4 files, 3.0 MiB of 12.0 MiB (25%), 1.0 MiB/s, ETA unknown"
+	if received := formatProgress(p); received != expected {
+		t.Errorf("Function returned incorrect string\nExpected=%s\nReceived=%s", expected, received)
+	}
+
+	p.TotalSize = -1
+	expected = "1/4 files, 3.0 MiB, 1.0 MiB/s"
+	if received := formatProgress(p); received != expected {
+		t.Errorf("Function returned incorrect string\nExpected=%s\nReceived=%s", expected, received)
+	}
+}
+
+func TestProgressDisplay(t *testing.T) {
+	var buf strings.Builder
+	display := newProgressDisplay(&buf, true)
+
+	_, _ = display.Write([]byte("first log\n"))
+	display.update(airlock.Progress{File: "dir/file.txt", Bytes: 1, Size: 2, TotalSize: 2, Files: 1, ETA: -1})
+	_, _ = display.Write([]byte("second log\n"))
+	display.close()
+
+	line := "0/1 files, 0 B of 2 B (0%), 0 B/s, ETA unknown, file.txt 50%"
+	expected := "first log\n" + "\r\033[K" + line + "\r\033[K" + "second log\n" + line + "\n"
+	if buf.String() != expected {
+		t.Errorf("Display printed incorrect output\nExpected=%q\nReceived=%q", expected, buf.String())
+	}
+}
+
+func TestProgressDisplay_NoTerminal(t *testing.T) {
+	var buf strings.Builder
+	display := newProgressDisplay(&buf, false)
+
+	display.update(airlock.Progress{File: "file.txt", Bytes: 1, Size: 2, Files: 1})
+	display.close()
+
+	if buf.String() != "" {
+		t.Errorf("Display should not print progress line without a terminal, received %q", buf.String())
+	}
+}
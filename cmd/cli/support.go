@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"sda-filesystem/certs"
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+	"sda-filesystem/internal/support"
+)
+
+var bundleFile string
+
+// bundleResult is the result of support-bundle with -output=json
+type bundleResult struct {
+	File   string `json:"file"`
+	Source string `json:"source"` // daemon or local
+}
+
+func init() {
+	handlers["support-bundle"] = handlerFuncs{setup: supportSetup, execute: supportHandler, local: true}
+}
+
+func supportSetup(args []string) (int, error) {
+	set := flag.NewFlagSet("support-bundle", flag.ContinueOnError)
+	set.StringVar(&bundleFile, "file", "", "Path of the created archive (default data-gateway-support-<time>.zip in the current directory)")
+	set.StringVar(&controlSocket, "socket", defaultSocket(), "Path to the control socket of 'import -daemon'")
+	set.StringVar(&mount, "mount", "", "Path to the Data Gateway mount point, if it is not running with -daemon (default from the configuration file)")
+	set.Usage = func() {
+		fmt.Fprintln(messages, "Usage of support-bundle:")
+		fmt.Fprintln(messages, "  Package recent logs, version, configuration, profile, cache statistics, mount state and certificate expiry")
+		fmt.Fprintln(messages, "  into a single archive that can be attached to a support ticket. Secrets are redacted from the archive.")
+		fmt.Fprintln(messages, "  If Data Gateway is running with 'import -daemon', the information is collected from it.")
+		fmt.Fprintln(messages, "Examples:")
+		fmt.Fprintln(messages, " ", os.Args[0], "support-bundle")
+		fmt.Fprintln(messages, " ", os.Args[0], "support-bundle -file=/tmp/support.zip")
+		fmt.Fprintln(messages, "Options:")
+		set.PrintDefaults()
+	}
+
+	if err := set.Parse(args); err != nil {
+		return 2, nil
+	}
+	if set.NArg() > 0 {
+		set.Usage()
+
+		return 2, nil
+	}
+
+	return 0, applySettings(set, map[string]string{"mount": "filesystem.mount"})
+}
+
+func supportHandler() (int, error) {
+	bundle, source := collectBundle()
+
+	path := bundleFile
+	if path == "" {
+		path = support.FileName(time.Now())
+	}
+	path = filepath.Clean(path)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 1, fmt.Errorf("could not create support bundle: %w", err)
+	}
+	if err = bundle.Write(f); err != nil {
+		f.Close()
+		os.Remove(path)
+
+		return 1, err
+	}
+	if err = f.Close(); err != nil {
+		return 1, fmt.Errorf("could not write support bundle: %w", err)
+	}
+
+	if jsonOutput {
+		setResult(bundleResult{File: path, Source: source})
+	} else {
+		fmt.Fprintf(stdout, "Support bundle written to %s\n", path)
+	}
+
+	return 0, nil
+}
+
+// collectBundle asks the daemon for the support bundle, since it has the logs of the mounted filesystem.
+// If no daemon is running, the information is collected by this process.
+func collectBundle() (support.Bundle, string) {
+	resp, err := sendControl(controlSocket, controlRequest{Command: "bundle"})
+	switch {
+	case err != nil:
+		logs.Debugf("Collecting support bundle in this process: %s", err.Error())
+	case !resp.OK:
+		logs.Warningf("Data Gateway could not create support bundle: %s", resp.Message)
+	default:
+		var bundle support.Bundle
+		if data, ok := resp.Data.(*json.RawMessage); !ok {
+			err = errors.New("response contains no data")
+		} else if err = json.Unmarshal(*data, &bundle); err == nil {
+			return bundle, "daemon"
+		}
+		logs.Warningf("Could not read support bundle from Data Gateway: %s", err.Error())
+	}
+
+	// Fetching the profile logs the problem if there is one, so that it ends up in the bundle
+	if err = api.Setup(certs.Files); err != nil {
+		logs.Warning(err)
+	} else if _, err = api.GetProfileCLI(); err != nil {
+		logs.Warning(err)
+	}
+
+	return support.Collect(settings, mount, false, certs.Files), "local"
+}
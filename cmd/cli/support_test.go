@@ -0,0 +1,182 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/config"
+	"sda-filesystem/internal/support"
+)
+
+func TestSupportSetup(t *testing.T) {
+	var tests = []struct {
+		testname, args, file, mount string
+		code                        int
+	}{
+		{"OK_DEFAULT", "", "", "", 0},
+		{"OK_FLAGS", "-file=/tmp/support.zip -mount=/tmp/gateway", "/tmp/support.zip", "/tmp/gateway", 0},
+		{"FAIL_ARGS", "extra", "", "", 2},
+		{"FAIL_FLAG", "-sdapply", "", "", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			t.Cleanup(func() { bundleFile, mount, controlSocket = "", "", "" })
+
+			code, err := runSetup(supportSetup, tt.args)
+			if err != nil {
+				t.Errorf("Returned unexpected err: %s", err.Error())
+			}
+			if code != tt.code {
+				t.Errorf("Received incorrect status code. Expected=%d, received=%d", tt.code, code)
+			}
+			if bundleFile != tt.file {
+				t.Errorf("Incorrect file. Expected=%s, received=%s", tt.file, bundleFile)
+			}
+			if mount != tt.mount {
+				t.Errorf("Incorrect mount point. Expected=%s, received=%s", tt.mount, mount)
+			}
+		})
+	}
+}
+
+func TestSupportHandler(t *testing.T) {
+	var tests = []struct {
+		testname, source string
+		daemon, json     bool
+	}{
+		{"OK_LOCAL", "local", false, false},
+		{"OK_LOCAL_JSON", "local", false, true},
+		{"OK_DAEMON", "daemon", true, false},
+		{"OK_DAEMON_JSON", "daemon", true, true},
+	}
+
+	origSetup := api.Setup
+	origCollect := support.Collect
+	origStdout := stdout
+	defer func() {
+		api.Setup = origSetup
+		support.Collect = origCollect
+		stdout = origStdout
+		jsonOutput = false
+		results = commandResult{}
+		bundleFile, mount, controlSocket = "", "", ""
+	}()
+
+	api.Setup = func(api.FileReader) error {
+		return errExpected
+	}
+	support.Collect = func(_ *config.Config, mount string, mounted bool, _ api.FileReader) support.Bundle {
+		return support.Bundle{Info: support.Info{Version: "1.0.0", Mount: mount, Mounted: mounted}, Logs: []string{"line"}}
+	}
+	mount = "/home/user/Projects"
+
+	dir := t.TempDir()
+	controlSocket = filepath.Join(dir, "control.sock")
+	server, err := listenControl(controlSocket)
+	if err != nil {
+		t.Fatalf("Failed to create control socket: %s", err.Error())
+	}
+	defer server.close()
+	go server.serve()
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			stdout, jsonOutput, results = buf, tt.json, commandResult{}
+			bundleFile = filepath.Join(dir, tt.testname+".zip")
+			controlSocket = filepath.Join(dir, "control.sock")
+			if !tt.daemon {
+				controlSocket = filepath.Join(dir, "missing.sock")
+			}
+
+			code, err := supportHandler()
+			if err != nil {
+				t.Fatalf("Returned unexpected err: %s", err.Error())
+			}
+			if code != 0 {
+				t.Errorf("Received incorrect status code. Expected=0, received=%d", code)
+			}
+
+			if tt.json {
+				expected := bundleResult{File: bundleFile, Source: tt.source}
+				if !reflect.DeepEqual(results.Result, expected) {
+					t.Errorf("Incorrect result\nExpected=%+v\nReceived=%+v", expected, results.Result)
+				}
+			} else if expected := "Support bundle written to " + bundleFile + "\n"; buf.String() != expected {
+				t.Errorf("Received incorrect output\nExpected=%s\nReceived=%s", expected, buf.String())
+			}
+
+			info, err := os.Stat(bundleFile)
+			if err != nil {
+				t.Fatalf("Support bundle was not created: %s", err.Error())
+			}
+			if info.Mode().Perm() != 0o600 {
+				t.Errorf("Support bundle has incorrect permissions %v", info.Mode().Perm())
+			}
+			zr, err := zip.OpenReader(bundleFile)
+			if err != nil {
+				t.Fatalf("Support bundle is not a valid zip archive: %s", err.Error())
+			}
+			defer zr.Close()
+			rc, err := zr.Open("info.json")
+			if err != nil {
+				t.Fatalf("Support bundle does not contain info.json: %s", err.Error())
+			}
+			defer rc.Close()
+			var received support.Info
+			if err = json.NewDecoder(rc).Decode(&received); err != nil {
+				t.Fatalf("info.json is not valid JSON: %s", err.Error())
+			}
+			// Only the daemon has the filesystem mounted
+			if received.Version != "1.0.0" || received.Mount != mount || received.Mounted != tt.daemon {
+				t.Errorf("Support bundle has incorrect info: %+v", received)
+			}
+		})
+	}
+}
+
+func TestSupportHandler_Exists(t *testing.T) {
+	origSetup := api.Setup
+	origCollect := support.Collect
+	defer func() {
+		api.Setup = origSetup
+		support.Collect = origCollect
+		bundleFile, controlSocket = "", ""
+	}()
+
+	api.Setup = func(api.FileReader) error {
+		return errExpected
+	}
+	support.Collect = func(*config.Config, string, bool, api.FileReader) support.Bundle {
+		return support.Bundle{}
+	}
+	dir := t.TempDir()
+	controlSocket = filepath.Join(dir, "missing.sock")
+	bundleFile = filepath.Join(dir, "support.zip")
+	if err := os.WriteFile(bundleFile, []byte("existing"), 0o600); err != nil {
+		t.Fatalf("Failed to create file: %s", err.Error())
+	}
+
+	code, err := supportHandler()
+
+	errStr := "could not create support bundle"
+	if err == nil {
+		t.Errorf("Function did not return error")
+	} else if !strings.HasPrefix(err.Error(), errStr) {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+	if code != 1 {
+		t.Errorf("Received incorrect status code. Expected=1, received=%d", code)
+	}
+	if data, _ := os.ReadFile(bundleFile); string(data) != "existing" {
+		t.Errorf("Existing file was overwritten")
+	}
+}
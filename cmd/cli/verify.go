@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"sda-filesystem/internal/airlock"
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+)
+
+var verifyObjects []string
+
+func init() {
+	handlers["verify"] = handlerFuncs{setup: verifySetup, execute: verifyHandler}
+}
+
+func verifySetup(args []string) (int, error) {
+	set := flag.NewFlagSet("verify", flag.ContinueOnError)
+	set.Usage = func() {
+		fmt.Fprintln(messages, "Usage of verify:")
+		fmt.Fprintln(messages, "  Decrypt exported objects in SD Connect and compare their content to the checksum recorded during export")
+		fmt.Fprintln(messages, "Examples:")
+		fmt.Fprintln(messages, " ", os.Args[0], "verify testbucket/path/to/file.c4gh")
+		fmt.Fprintln(messages, " ", os.Args[0], "verify testbucket/file.c4gh testbucket/another-file.c4gh")
+	}
+
+	if err := set.Parse(args); err != nil {
+		return 2, nil
+	}
+	if set.NArg() < 1 {
+		set.Usage()
+
+		return 2, nil
+	}
+
+	for _, arg := range set.Args() {
+		bucket, object, _ := strings.Cut(strings.TrimPrefix(arg, "/"), "/")
+		if bucket == "" || object == "" || strings.HasSuffix(object, "/") {
+			return 2, fmt.Errorf("invalid object %q, expected format bucket/path/to/object", arg)
+		}
+	}
+	verifyObjects = set.Args()
+
+	if !api.SDConnectEnabled() {
+		return 0, errors.New("you do not have SD Connect enabled")
+	}
+
+	return 0, nil
+}
+
+func verifyHandler() (int, error) {
+	defer api.DeleteWhitelistedKeys()
+
+	failed := 0
+	for _, arg := range verifyObjects {
+		bucket, object, _ := strings.Cut(strings.TrimPrefix(arg, "/"), "/")
+		logs.Infof("Verifying object %s", arg)
+		if err := airlock.VerifyObject(bucket, object); err != nil {
+			logs.Errorf("Verification failed for %s: %w", arg, err)
+			addFileResult("", arg, statusFailed, err)
+			failed++
+
+			continue
+		}
+		logs.Infof("Checksum matches for %s", arg)
+		addFileResult("", arg, statusVerified, nil)
+	}
+
+	if failed > 0 {
+		logs.Errorf("%d of %d object(s) failed verification", failed, len(verifyObjects))
+
+		return 1, nil
+	}
+	logs.Info("All objects verified")
+
+	return 0, nil
+}
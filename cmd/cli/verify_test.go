@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sda-filesystem/internal/airlock"
+	"sda-filesystem/internal/api"
+)
+
+func TestVerifySetup(t *testing.T) {
+	var tests = []struct {
+		testname, args, errStr string
+		code                   int
+		enabled                bool
+		objects                []string
+	}{
+		{"OK_1", "bucket/file.c4gh", "", 0, true, []string{"bucket/file.c4gh"}},
+		{"OK_2", "bucket/dir/file.c4gh /bucket-2/file.c4gh", "", 0, true, []string{"bucket/dir/file.c4gh", "/bucket-2/file.c4gh"}},
+		{"FAIL_NO_ARGS", "", "", 2, true, nil},
+		{"FAIL_BAD_FLAG", "-sync bucket/file.c4gh", "", 2, true, nil},
+		{"FAIL_BUCKET_ONLY", "bucket", "invalid object \"bucket\", expected format bucket/path/to/object", 2, true, nil},
+		{"FAIL_FOLDER", "bucket/dir/", "invalid object \"bucket/dir/\", expected format bucket/path/to/object", 2, true, nil},
+		{"FAIL_NOT_ENABLED", "bucket/file.c4gh", "you do not have SD Connect enabled", 0, false, []string{"bucket/file.c4gh"}},
+	}
+
+	origSDConnectEnabled := api.SDConnectEnabled
+	defer func() { api.SDConnectEnabled = origSDConnectEnabled }()
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			t.Cleanup(func() { verifyObjects = nil })
+
+			api.SDConnectEnabled = func() bool {
+				return tt.enabled
+			}
+
+			args := strings.Fields(tt.args)
+
+			// Ignore prints to stdout
+			null, _ := os.Open(os.DevNull)
+			sout, smessages := os.Stdout, messages
+			os.Stdout, messages = null, null
+
+			code, err := verifySetup(args)
+
+			os.Stdout, messages = sout, smessages
+			null.Close()
+
+			if code != tt.code {
+				t.Errorf("Received incorrect status code. Expected=%d, received=%d", tt.code, code)
+			}
+			switch {
+			case tt.errStr == "":
+				if err != nil {
+					t.Errorf("Returned unexpected err: %s", err.Error())
+				}
+			case err == nil:
+				t.Error("Function should have returned error")
+			case err.Error() != tt.errStr:
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+			if !reflect.DeepEqual(tt.objects, verifyObjects) {
+				t.Errorf("Received incorrect objects. Expected=%v, received=%v", tt.objects, verifyObjects)
+			}
+		})
+	}
+}
+
+func TestVerifyHandler(t *testing.T) {
+	origVerifyObject := airlock.VerifyObject
+	origDeleteWhitelistedKeys := api.DeleteWhitelistedKeys
+	defer func() {
+		airlock.VerifyObject = origVerifyObject
+		api.DeleteWhitelistedKeys = origDeleteWhitelistedKeys
+		verifyObjects = nil
+	}()
+
+	keysDeleted := false
+	api.DeleteWhitelistedKeys = func() {
+		keysDeleted = true
+	}
+	verified := []string{}
+	airlock.VerifyObject = func(bucket, object string) error {
+		verified = append(verified, bucket+"/"+object)
+		if object == "bad.c4gh" {
+			return errExpected
+		}
+
+		return nil
+	}
+
+	verifyObjects = []string{"bucket/dir/good.c4gh", "/bucket/bad.c4gh"}
+	code, err := verifyHandler()
+	if err != nil {
+		t.Errorf("Returned unexpected err: %s", err.Error())
+	}
+	if code != 1 {
+		t.Errorf("Received incorrect status code. Expected=1, received=%d", code)
+	}
+	if expected := []string{"bucket/dir/good.c4gh", "bucket/bad.c4gh"}; !reflect.DeepEqual(expected, verified) {
+		t.Errorf("Incorrect objects were verified. Expected=%v, received=%v", expected, verified)
+	}
+	if !keysDeleted {
+		t.Error("Whitelisted keys were not deleted")
+	}
+
+	verifyObjects = []string{"bucket/dir/good.c4gh"}
+	if code, err := verifyHandler(); err != nil || code != 0 {
+		t.Errorf("Function should have succeeded, received code %d and error %v", code, err)
+	}
+}
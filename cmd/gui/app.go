@@ -10,15 +10,18 @@ import (
 	"path/filepath"
 	"runtime"
 	"slices"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"sda-filesystem/certs"
 	"sda-filesystem/internal/airlock"
 	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/config"
 	"sda-filesystem/internal/filesystem"
 	"sda-filesystem/internal/logs"
 	"sda-filesystem/internal/mountpoint"
+	"sda-filesystem/internal/support"
 
 	wailsruntime "github.com/wailsapp/wails/v2/pkg/runtime"
 )
@@ -29,21 +32,29 @@ type App struct {
 	ph          *ProjectHandler
 	lh          *LogHandler
 	mountpoint  string
+	settings    *config.Config
 	preventQuit atomic.Bool
 	paniced     bool
 	mounted     bool
+
+	exportLock   sync.Mutex
+	cancelExport context.CancelFunc
 }
 
 // NewApp creates a new App application struct
 func NewApp(ph *ProjectHandler, lh *LogHandler) *App {
-	return &App{ph: ph, lh: lh}
+	return &App{ph: ph, lh: lh, settings: &config.Config{}}
 }
 
 // startup is called when the app starts. The context is saved
 // so we can call the runtime methods
 func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
+	a.loadSettings()
 	filesystem.SetSignalBridge(a.Panic)
+	airlock.SetProgressFunc(func(p airlock.Progress) {
+		wailsruntime.EventsEmit(a.ctx, "exportProgress", p)
+	})
 }
 
 func (a *App) beforeClose(_ context.Context) (prevent bool) {
@@ -88,6 +99,17 @@ func (a *App) Panic() {
 }
 
 func (a *App) GetDefaultMountPoint() string {
+	if mount, ok := a.settings.String("filesystem.mount"); ok {
+		mount = filepath.Clean(mount)
+		err := mountpoint.CheckMountPoint(mount)
+		if err == nil {
+			a.mountpoint = mount
+
+			return a.mountpoint
+		}
+		logs.Warning(fmt.Errorf("cannot use mount point from %s: %w", a.settings.Path(), err))
+	}
+
 	var err error
 	a.mountpoint, err = mountpoint.DefaultMountPoint()
 	if err != nil {
@@ -277,8 +299,35 @@ func (a *App) SelectFiles() ([]string, error) {
 	return files, nil
 }
 
+// SelectPrivateKey lets the user choose their crypt4gh private key
+func (a *App) SelectPrivateKey() (string, error) {
+	home, _ := os.UserHomeDir()
+	options := wailsruntime.OpenDialogOptions{DefaultDirectory: home, Title: "Select your crypt4gh private key"}
+	file, err := wailsruntime.OpenFileDialog(a.ctx, options)
+	if err != nil {
+		logs.Error(err)
+
+		return "", err
+	}
+
+	return file, nil
+}
+
+// SetPrivateKey sets the private key used for re-encrypting the headers of files that are already encrypted.
+// An empty filename removes the key.
+func (a *App) SetPrivateKey(filename, passphrase string) error {
+	if err := airlock.SetPrivateKey(filename, []byte(passphrase)); err != nil {
+		logs.Error(err)
+		message, _ := logs.Wrapper(err)
+
+		return errors.New(message)
+	}
+
+	return nil
+}
+
 func (a *App) CheckObjectExistences(set airlock.UploadSet) ([]bool, error) {
-	err := airlock.CheckObjectExistences(&set, nil)
+	err := airlock.CheckObjectExistences(&set, nil, nil)
 	if err != nil {
 		logs.Error(err)
 		message, _ := logs.Wrapper(err)
@@ -289,6 +338,33 @@ func (a *App) CheckObjectExistences(set airlock.UploadSet) ([]bool, error) {
 	return set.Exists, err
 }
 
+// ResolveConflicts applies `policy` to the files in `set` whose objects already exist in the bucket.
+// The result of each file is returned in the order of `set`.
+func (a *App) ResolveConflicts(set airlock.UploadSet, policy string) ([]airlock.ConflictResult, error) {
+	conflictPolicy, err := airlock.ParseConflictPolicy(policy)
+	var results []airlock.ConflictResult
+	if err == nil {
+		results, err = airlock.ResolveConflicts(&set, conflictPolicy)
+	}
+	if err != nil {
+		logs.Error(err)
+		message, _ := logs.Wrapper(err)
+
+		return nil, errors.New(message)
+	}
+
+	for _, result := range results {
+		switch result.Action {
+		case airlock.ActionSkipped:
+			logs.Infof("Skipped file %s", result.File)
+		case airlock.ActionOverwritten, airlock.ActionRenamed:
+			logs.Infof("File %s will be uploaded as %s (%s)", result.File, result.Object, result.Action)
+		}
+	}
+
+	return results, nil
+}
+
 func (a *App) CheckBucketExistence(bucket string) (bool, error) {
 	exists, err := api.BucketExists(api.SDConnect, bucket)
 	if err != nil {
@@ -322,24 +398,122 @@ func (a *App) ValidateEmail(email string) string {
 	return e.Address
 }
 
-func (a *App) ExportFiles(set airlock.UploadSet, exists bool, metadata map[string]string) error {
+func (a *App) GetUploadLimits() airlock.UploadLimits {
+	return airlock.GetUploadLimits()
+}
+
+func (a *App) SetUploadLimits(limits airlock.UploadLimits) error {
+	if err := airlock.SetUploadLimits(limits); err != nil {
+		logs.Error(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// SetDecryptCheck determines whether uploaded objects are partly decrypted when they are verified
+func (a *App) SetDecryptCheck(enabled bool) {
+	airlock.SetDecryptCheck(enabled)
+}
+
+func (a *App) GetBandwidthLimits() api.BandwidthLimits {
+	return api.GetBandwidthLimits()
+}
+
+func (a *App) SetBandwidthLimits(limits api.BandwidthLimits) error {
+	if err := api.SetBandwidthLimits(limits); err != nil {
+		logs.Error(err)
+
+		return err
+	}
+
+	return nil
+}
+
+// ExportFiles uploads the files in `set`. If the export is cancelled with CancelExport(),
+// the files that were uploaded before the cancellation are returned without an error.
+func (a *App) ExportFiles(set airlock.UploadSet, exists bool, metadata map[string]string) ([]string, error) {
+	ctx, cancel := context.WithCancel(a.ctx)
+	defer cancel()
+
+	a.exportLock.Lock()
+	a.cancelExport = cancel
+	a.exportLock.Unlock()
+	defer func() {
+		a.exportLock.Lock()
+		a.cancelExport = nil
+		a.exportLock.Unlock()
+	}()
+
 	var err error
+	var completed []string
 	time.Sleep(1000 * time.Millisecond) // So that progressbar animation is detectable
-	if !exists {
+	if !exists && ctx.Err() == nil {
 		logs.Info("Creating bucket ", set.Bucket)
 		err = api.CreateBucket(api.SDConnect, set.Bucket)
 	}
 
 	if err == nil {
-		err = airlock.Upload(set, metadata)
+		completed, err = airlock.Upload(ctx, set, metadata)
 	}
 
+	if errors.Is(err, context.Canceled) {
+		logs.Infof("Export cancelled, %d of %d file(s) were uploaded", len(completed), len(set.Files))
+
+		return completed, nil
+	}
 	if err != nil {
 		logs.Error(err)
 		message, _ := logs.Wrapper(err)
 
-		return errors.New(message)
+		return completed, errors.New(message)
 	}
 
-	return nil
+	return completed, nil
+}
+
+// CancelExport stops the export in progress. Unfinished objects are removed from SD Connect.
+func (a *App) CancelExport() {
+	a.exportLock.Lock()
+	defer a.exportLock.Unlock()
+
+	if a.cancelExport != nil {
+		logs.Info("Cancelling export")
+		a.cancelExport()
+	}
+}
+
+// SaveSupportBundle packages recent logs, version, configuration and state of Data Gateway into
+// an archive that the user can attach to a support ticket
+func (a *App) SaveSupportBundle() {
+	home, _ := os.UserHomeDir()
+	options := wailsruntime.SaveDialogOptions{DefaultDirectory: home, DefaultFilename: support.FileName(time.Now())}
+	file, err := wailsruntime.SaveFileDialog(a.ctx, options)
+	if err != nil {
+		logs.Errorf("Could not select file name: %w", err)
+
+		return
+	}
+	if file == "" { // Cancelled
+		return
+	}
+
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		logs.Errorf("Could not create file %s: %w", file, err)
+
+		return
+	}
+	err = support.Collect(a.settings, a.mountpoint, a.mounted, certs.Files).Write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		logs.Errorf("Could not write support bundle to file %s: %w", file, err)
+
+		return
+	}
+
+	logs.Infof("Support bundle written successfully to file %s", file)
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+
+	"sda-filesystem/internal/airlock"
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/cache"
+	"sda-filesystem/internal/config"
+	"sda-filesystem/internal/filesystem"
+	"sda-filesystem/internal/logs"
+)
+
+// loadSettings reads the configuration file and applies its settings. Environment variables take precedence
+// over the file, and the settings can still be changed in the user interface.
+func (a *App) loadSettings() {
+	settings, err := config.Load("", "")
+	if err != nil {
+		logs.Error(err)
+
+		return
+	}
+	a.settings = settings
+	if len(settings.Keys()) == 0 {
+		return
+	}
+	if settings.Profile() != "" {
+		logs.Infof("Using configuration file %s with profile %s", settings.Path(), settings.Profile())
+	} else {
+		logs.Infof("Using configuration file %s", settings.Path())
+	}
+
+	invalid := func(key string, err error) {
+		logs.Warning(fmt.Errorf("invalid value for %s in %s: %w", key, settings.Path(), err))
+	}
+
+	if level, ok := settings.String("logging.level"); ok {
+		logs.SetLevel(level)
+	}
+	if format, ok := settings.String("logging.format"); ok {
+		if err := logs.SetFormat(format); err != nil {
+			invalid("logging.format", err)
+		}
+	}
+	if patterns, ok := settings.Strings("logging.redact"); ok {
+		for _, pattern := range patterns {
+			if err := logs.AddRedactPattern(pattern); err != nil {
+				invalid("logging.redact", err)
+			}
+		}
+	}
+	if path, ok := settings.String("logging.file"); ok {
+		maxSize, backups := int64(logs.DefaultMaxSize), int64(logs.DefaultBackups)
+		if size, ok := settings.Int("logging.max-size"); ok {
+			if err := logs.CheckMaxSize(size); err != nil {
+				invalid("logging.max-size", err)
+			} else {
+				maxSize = size
+			}
+		}
+		if n, ok := settings.Int("logging.backups"); ok {
+			backups = n
+		}
+		if err := logs.SetFile(path, maxSize, int(backups)); err != nil {
+			invalid("logging.file", err)
+		}
+	}
+	if proxy, ok := settings.String("connection.proxy-url"); ok {
+		api.SetEnvDefault("PROXY_URL", proxy)
+	}
+	if endpoint, ok := settings.String("connection.config-endpoint"); ok {
+		api.SetEnvDefault("CONFIG_ENDPOINT", endpoint)
+	}
+	if size, ok := settings.Int("filesystem.cache-size"); ok {
+		if err := cache.SetMaxSize(size); err != nil {
+			invalid("filesystem.cache-size", err)
+		}
+	}
+	if browse, ok := settings.Bool("filesystem.browse-archives"); ok {
+		filesystem.SetBrowseArchives(browse)
+	}
+	if decrypt, ok := settings.Bool("export.verify-decrypt"); ok {
+		airlock.SetDecryptCheck(decrypt)
+	}
+
+	bandwidth := api.GetBandwidthLimits()
+	if rate, ok := settings.Int("transfer.upload-limit"); ok {
+		bandwidth.Upload = rate
+	}
+	if rate, ok := settings.Int("transfer.download-limit"); ok {
+		bandwidth.Download = rate
+	}
+	if err := api.SetBandwidthLimits(bandwidth); err != nil {
+		invalid("transfer", err)
+	}
+
+	limits := airlock.GetUploadLimits()
+	if concurrency, ok := settings.Int("export.part-concurrency"); ok {
+		limits.PartConcurrency = int(concurrency)
+	}
+	if budget, ok := settings.Int("export.memory-budget"); ok {
+		limits.MemoryBudget = budget
+	}
+	if err := airlock.SetUploadLimits(limits); err != nil {
+		invalid("export", err)
+	}
+}
+
+// GetConfiguredRepositories returns the repositories that are selected by default in the configuration file
+func (a *App) GetConfiguredRepositories() []string {
+	names, _ := a.settings.Strings("filesystem.repositories")
+	repositories := make([]string, 0, len(names))
+	for _, name := range names {
+		rep, bucket, _, err := airlock.ParseStoragePath(name)
+		if err != nil || rep == "" || bucket != "" {
+			logs.Warningf("Ignoring invalid repository %q in %s", name, a.settings.Path())
+
+			continue
+		}
+		repositories = append(repositories, rep.ForPath())
+	}
+
+	return repositories
+}
+
+// GetDecryptCheck returns whether uploaded objects are partly decrypted when they are verified
+func (a *App) GetDecryptCheck() bool {
+	return airlock.GetDecryptCheck()
+}
@@ -0,0 +1,57 @@
+#!/bin/bash -eu
+
+# This script checks that KrakenD serves the requests that Data Gateway makes to the Vault headers
+# endpoint (`/desktop/file-headers` in configuration.json). KrakenD passes them on to the c4ghtransit
+# plugin, whose files/<project>/<bucket>/<object> path the policy in ../vault/helpers/vault_policy.hcl
+# allows to be created, read, listed and deleted.
+#   POST   <headers>/<bucket>?object=<object>  stores a header (PostHeader)
+#   GET    <headers>/<bucket>?list=true        lists the objects that have a header (ListHeaders)
+#   DELETE <headers>/<bucket>?object=<object>  removes a header (DeleteHeader)
+
+# Dependencies
+# - curl
+# - jq
+
+# Usage: PROXY_URL=http://localhost:8082 SDS_ACCESS_TOKEN=<token> ./vault_headers.sh [bucket]
+
+BUCKET=${1:-vault-headers-check}
+OBJECT="check-$(date +%s).c4gh"
+HEADERS_PATH=${HEADERS_PATH:-/desktop/file-headers}
+
+password_header=()
+if [ -n "${CSC_PASSWORD:-}" ]; then
+    password_header=(-H "CSC-Password: $(printf '%s' "$CSC_PASSWORD" | base64)")
+fi
+
+request() {
+    curl -sS --fail-with-body "${password_header[@]}" -H "Authorization: Bearer ${TOKEN}" "$@"
+}
+
+# The headers endpoint is used with the token from the user profile
+TOKEN=${SDS_ACCESS_TOKEN}
+TOKEN=$(request "${PROXY_URL}/profile" | jq -r '.access_token')
+
+listed() {
+    request "${PROXY_URL}${HEADERS_PATH}/${BUCKET}?list=true" | jq -e --arg object "$OBJECT" '.keys | index($object) != null' >/dev/null
+}
+
+echo "Storing header of ${BUCKET}/${OBJECT}"
+header=$(printf 'crypt4gh-header-check' | base64)
+request -X POST -H "Content-Type: application/json" -d "{\"header\": \"${header}\"}" \
+    "${PROXY_URL}${HEADERS_PATH}/${BUCKET}?object=${OBJECT}" >/dev/null
+
+echo "Listing headers in ${BUCKET}"
+if ! listed; then
+    echo "Header of ${OBJECT} was not listed" >&2
+    exit 1
+fi
+
+echo "Deleting header of ${BUCKET}/${OBJECT}"
+request -X DELETE "${PROXY_URL}${HEADERS_PATH}/${BUCKET}?object=${OBJECT}" >/dev/null
+
+if listed; then
+    echo "Header of ${OBJECT} was listed after it was deleted" >&2
+    exit 1
+fi
+
+echo "Headers endpoint supports storing, listing and deleting headers"
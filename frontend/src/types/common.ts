@@ -16,3 +16,16 @@ export type ValidationResult = {
 };
 
 export type TabType = "Log in" | "Access" | "Export" | "Logs";
+
+export type ExportProgress = {
+  file: string;
+  bytes: number;
+  size: number;
+  totalBytes: number;
+  totalSize: number;
+  files: number;
+  finished: number;
+  rate: number; // bytes per second
+  eta: number; // seconds, negative if not known
+  done: boolean;
+};
@@ -1,6 +1,9 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 import {airlock} from '../models';
+import {api} from '../models';
+
+export function CancelExport():Promise<void>;
 
 export function ChangeMountPoint():Promise<string>;
 
@@ -8,12 +11,20 @@ export function CheckBucketExistence(arg1:string):Promise<boolean>;
 
 export function CheckObjectExistences(arg1:airlock.UploadSet):Promise<Array<boolean>>;
 
-export function ExportFiles(arg1:airlock.UploadSet,arg2:boolean,arg3:Record<string, string>):Promise<void>;
+export function ExportFiles(arg1:airlock.UploadSet,arg2:boolean,arg3:Record<string, string>):Promise<Array<string>>;
 
 export function FilesOpen():Promise<boolean>;
 
+export function GetBandwidthLimits():Promise<api.BandwidthLimits>;
+
+export function GetConfiguredRepositories():Promise<Array<string>>;
+
+export function GetDecryptCheck():Promise<boolean>;
+
 export function GetDefaultMountPoint():Promise<string>;
 
+export function GetUploadLimits():Promise<airlock.UploadLimits>;
+
 export function GetUsername():Promise<string>;
 
 export function GetVersion():Promise<string>;
@@ -28,8 +39,22 @@ export function Panic():Promise<void>;
 
 export function Quit():Promise<void>;
 
+export function ResolveConflicts(arg1:airlock.UploadSet,arg2:string):Promise<Array<airlock.ConflictResult>>;
+
+export function SaveSupportBundle():Promise<void>;
+
 export function SelectFiles():Promise<Array<string>>;
 
+export function SelectPrivateKey():Promise<string>;
+
+export function SetBandwidthLimits(arg1:api.BandwidthLimits):Promise<void>;
+
+export function SetDecryptCheck(arg1:boolean):Promise<void>;
+
+export function SetPrivateKey(arg1:string,arg2:string):Promise<void>;
+
+export function SetUploadLimits(arg1:airlock.UploadLimits):Promise<void>;
+
 export function UpdateFuse():Promise<void>;
 
 export function UpdateRepositorySelection(arg1:Record<string, boolean>):Promise<void>;
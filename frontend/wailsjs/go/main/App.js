@@ -2,6 +2,10 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
+export function CancelExport() {
+  return window['go']['main']['App']['CancelExport']();
+}
+
 export function ChangeMountPoint() {
   return window['go']['main']['App']['ChangeMountPoint']();
 }
@@ -22,10 +26,26 @@ export function FilesOpen() {
   return window['go']['main']['App']['FilesOpen']();
 }
 
+export function GetBandwidthLimits() {
+  return window['go']['main']['App']['GetBandwidthLimits']();
+}
+
+export function GetConfiguredRepositories() {
+  return window['go']['main']['App']['GetConfiguredRepositories']();
+}
+
+export function GetDecryptCheck() {
+  return window['go']['main']['App']['GetDecryptCheck']();
+}
+
 export function GetDefaultMountPoint() {
   return window['go']['main']['App']['GetDefaultMountPoint']();
 }
 
+export function GetUploadLimits() {
+  return window['go']['main']['App']['GetUploadLimits']();
+}
+
 export function GetUsername() {
   return window['go']['main']['App']['GetUsername']();
 }
@@ -54,10 +74,38 @@ export function Quit() {
   return window['go']['main']['App']['Quit']();
 }
 
+export function ResolveConflicts(arg1, arg2) {
+  return window['go']['main']['App']['ResolveConflicts'](arg1, arg2);
+}
+
+export function SaveSupportBundle() {
+  return window['go']['main']['App']['SaveSupportBundle']();
+}
+
 export function SelectFiles() {
   return window['go']['main']['App']['SelectFiles']();
 }
 
+export function SelectPrivateKey() {
+  return window['go']['main']['App']['SelectPrivateKey']();
+}
+
+export function SetBandwidthLimits(arg1) {
+  return window['go']['main']['App']['SetBandwidthLimits'](arg1);
+}
+
+export function SetDecryptCheck(arg1) {
+  return window['go']['main']['App']['SetDecryptCheck'](arg1);
+}
+
+export function SetPrivateKey(arg1, arg2) {
+  return window['go']['main']['App']['SetPrivateKey'](arg1, arg2);
+}
+
+export function SetUploadLimits(arg1) {
+  return window['go']['main']['App']['SetUploadLimits'](arg1);
+}
+
 export function UpdateFuse() {
   return window['go']['main']['App']['UpdateFuse']();
 }
@@ -1,5 +1,35 @@
 export namespace airlock {
 	
+	export class ConflictResult {
+	    file: string;
+	    object: string;
+	    action: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new ConflictResult(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.file = source["file"];
+	        this.object = source["object"];
+	        this.action = source["action"];
+	    }
+	}
+	export class UploadLimits {
+	    partConcurrency: number;
+	    memoryBudget: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new UploadLimits(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.partConcurrency = source["partConcurrency"];
+	        this.memoryBudget = source["memoryBudget"];
+	    }
+	}
 	export class UploadSet {
 	    bucket: string;
 	    files: string[];
@@ -21,6 +51,25 @@ export namespace airlock {
 
 }
 
+export namespace api {
+	
+	export class BandwidthLimits {
+	    upload: number;
+	    download: number;
+	
+	    static createFrom(source: any = {}) {
+	        return new BandwidthLimits(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.upload = source["upload"];
+	        this.download = source["download"];
+	    }
+	}
+
+}
+
 export namespace main {
 	
 	export class Log {
@@ -5,6 +5,7 @@ go 1.25.7
 toolchain go1.26.5
 
 require (
+	github.com/BurntSushi/toml v1.6.0
 	github.com/aws/aws-sdk-go-v2 v1.42.1
 	github.com/aws/aws-sdk-go-v2/config v1.32.30
 	github.com/aws/aws-sdk-go-v2/feature/s3/transfermanager v0.3.4
@@ -13,6 +14,7 @@ require (
 	github.com/dgraph-io/ristretto/v2 v2.4.2
 	github.com/google/go-cmp v0.7.0
 	github.com/google/uuid v1.6.0
+	github.com/klauspost/compress v1.18.0
 	github.com/neicnordic/crypt4gh v1.15.0
 	github.com/sirupsen/logrus v1.9.4
 	github.com/wailsapp/wails/v2 v2.13.0
@@ -3,15 +3,21 @@ package airlock
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"sda-filesystem/internal/api"
 	"sda-filesystem/internal/logs"
@@ -20,6 +26,7 @@ import (
 	"github.com/neicnordic/crypt4gh/streaming"
 	"golang.org/x/crypto/chacha20poly1305"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 )
 
 const numRoutines = 4
@@ -27,13 +34,34 @@ const minSegmentSize int64 = 1 << 27
 const maxParts int64 = 10000
 const maxObjectSize int64 = 5 * (1 << 40)
 const headerSize = 16 + 108 // Fixed size since we only have one public key
+const defaultPartConcurrency = 4
+const defaultMemoryBudget int64 = 1 << 30
+
+// StdinFile is the name of the file that is read from standard input
+const StdinFile = "-"
+
+// errNotStarted is returned by UploadObject if the upload was cancelled before it began
+var errNotStarted = errors.New("upload was cancelled before it started")
 
 var isLowerAlphaNumericHyphen = regexp.MustCompile(`^[a-z0-9-]+$`).MatchString
 
-var ai = airlockInfo{}
+var ai = airlockInfo{
+	limits: UploadLimits{PartConcurrency: defaultPartConcurrency, MemoryBudget: defaultMemoryBudget},
+	memory: semaphore.NewWeighted(defaultMemoryBudget),
+}
 
 type airlockInfo struct {
-	publicKey [chacha20poly1305.KeySize]byte
+	publicKey   [chacha20poly1305.KeySize]byte
+	privateKey  *[chacha20poly1305.KeySize]byte // For re-encrypting the headers of files that are already encrypted
+	limitsMu    sync.RWMutex                    // Guards limits and memory, which the GUI may change during an export
+	limits      UploadLimits
+	memory      *semaphore.Weighted // Memory reserved for buffering object parts, shared by all uploads
+	progressFun func(Progress)
+	// Whether the first and last block of uploaded objects are decrypted during verification
+	decryptCheck bool
+	// How many times a file that is modified during its upload is uploaded again
+	changeRetries int
+	symlinks      SymlinkMode
 }
 
 type walkPacket struct {
@@ -41,6 +69,12 @@ type walkPacket struct {
 	object string
 }
 
+// UploadLimits restricts the resources used by uploads
+type UploadLimits struct {
+	PartConcurrency int   `json:"partConcurrency"` // How many parts of a single object are uploaded at once
+	MemoryBudget    int64 `json:"memoryBudget"`    // Bytes available for buffering parts across all uploads
+}
+
 type UploadSet struct {
 	Bucket  string   `json:"bucket"`
 	Files   []string `json:"files"`
@@ -48,6 +82,64 @@ type UploadSet struct {
 	Exists  []bool   `json:"exists"` // Used only in GUI
 }
 
+// uploadResources are the limits and the memory budget with which an upload runs from start to finish
+type uploadResources struct {
+	limits UploadLimits
+	memory *semaphore.Weighted
+}
+
+type resourcesKey struct{}
+
+// GetUploadLimits returns the limits currently applied to uploads
+func GetUploadLimits() UploadLimits {
+	return currentResources().limits
+}
+
+// currentResources returns the limits and the memory budget that new uploads start with
+func currentResources() uploadResources {
+	ai.limitsMu.RLock()
+	defer ai.limitsMu.RUnlock()
+
+	return uploadResources{ai.limits, ai.memory}
+}
+
+// withResources returns a context in which uploads keep using the current limits even if they are changed
+func withResources(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(resourcesKey{}).(uploadResources); ok {
+		return ctx
+	}
+
+	return context.WithValue(ctx, resourcesKey{}, currentResources())
+}
+
+// resourcesOf returns the limits and the memory budget of the upload that `ctx` belongs to
+func resourcesOf(ctx context.Context) uploadResources {
+	if res, ok := ctx.Value(resourcesKey{}).(uploadResources); ok {
+		return res
+	}
+
+	return currentResources()
+}
+
+// SetUploadLimits changes the limits applied to uploads. Uploads that are already in progress
+// keep the limits they started with, and the new limits apply to the next export.
+func SetUploadLimits(limits UploadLimits) error {
+	if limits.PartConcurrency < 1 {
+		return fmt.Errorf("part concurrency must be at least 1")
+	}
+	if limits.MemoryBudget < minSegmentSize {
+		return fmt.Errorf("memory budget must be at least %d MiB", minSegmentSize>>20)
+	}
+
+	ai.limitsMu.Lock()
+	ai.limits = limits
+	ai.memory = semaphore.NewWeighted(limits.MemoryBudget)
+	ai.limitsMu.Unlock()
+	logs.Debugf("Uploading %d part(s) concurrently with a memory budget of %d MiB", limits.PartConcurrency, limits.MemoryBudget>>20)
+
+	return nil
+}
+
 // ExportPossible indicates whether or not user the user is allowed to export files outside the VM.
 // The user must be the project manager and have SD Connect enabled.
 var ExportPossible = func() bool {
@@ -71,7 +163,14 @@ var ExportPossible = func() bool {
 // WalkDirs receives a selection of files and folders, and returns all the files
 // that can be found in this selection, including the files recursively found under the folders.
 // The function also returns the bucket name and the objects that will be uploaded from the files.
+// Named pipes are included only if they are selected directly. Symbolic links are handled according to
+// the mode set with SetSymlinkMode(). If the selection consists of StdinFile, standard input is uploaded
+// as the object `prefix` points to.
 func WalkDirs(selection, currentObjects []string, prefix string) (UploadSet, error) {
+	if slices.Contains(selection, StdinFile) {
+		return stdinSet(selection, prefix)
+	}
+
 	bucket, subfolder, _ := strings.Cut(filepath.Clean(prefix), "/")
 	if subfolder != "" {
 		subfolder += "/"
@@ -92,40 +191,77 @@ func WalkDirs(selection, currentObjects []string, prefix string) (UploadSet, err
 		wait <- nil
 	}()
 
-	for i := range selection {
-		root := filepath.Clean(selection[i])
-
-		g.Go(func() error {
-			return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+	// walk sends the files under `root` to filesChan. `object` gives the object name of a path under `root`.
+	// `chain` contains the real paths of the directories whose content is being walked through symbolic links.
+	var walk func(root string, object func(string) string, chain []string) error
+	walk = func(root string, object func(string) string, chain []string) error {
+		return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			pipe := path == root && d.Type()&fs.ModeNamedPipe != 0
+			link := d.Type()&fs.ModeSymlink != 0
+			switch {
+			case link && ai.symlinks == SymlinkFollow:
+				info, target, err := resolveSymlink(path, chain)
 				if err != nil {
-					return err
-				}
-				if !d.Type().IsRegular() {
-					if !d.IsDir() {
-						logs.Warningf("%s is not a regular file or directory, skipping...", path)
-					}
+					logs.Warningf("Skipping symbolic link %s: %w", path, err)
 
 					return nil
 				}
+				if info.IsDir() {
+					base := object(path)
 
-				var obj string
-				if path == root {
-					obj = subfolder + filepath.Base(path+".c4gh")
-				} else {
-					obj = subfolder + strings.TrimPrefix(path, filepath.Dir(root)+"/") + ".c4gh"
-				}
-				if slices.Contains(currentObjects, obj) {
-					return errors.New("you have already selected files with similar object names")
+					return walk(path+string(filepath.Separator), func(p string) string {
+						return base + strings.TrimPrefix(p, path)
+					}, slices.Concat(chain, []string{target}))
 				}
+				if !info.Mode().IsRegular() {
+					logs.Warningf("%s does not link to a regular file or directory, skipping...", path)
 
-				select {
-				case filesChan <- walkPacket{file: path, object: obj}:
-				case <-ctx.Done():
-					return ctx.Err()
+					return nil
+				}
+				link = false
+			case link && ai.symlinks == SymlinkObject:
+				// The link itself is exported
+			case !d.Type().IsRegular() && !pipe:
+				if !d.IsDir() {
+					logs.Warningf("%s is not a regular file or directory, skipping...", path)
 				}
 
 				return nil
-			})
+			}
+
+			obj := object(path)
+			// Files that are uploaded as they are keep their name. Pipes cannot be peeked at without consuming them.
+			if !strings.HasSuffix(obj, ".c4gh") || pipe || link || !isPassedThrough(path) {
+				obj += ".c4gh"
+			}
+			if slices.Contains(currentObjects, obj) {
+				return errors.New("you have already selected files with similar object names")
+			}
+
+			select {
+			case filesChan <- walkPacket{file: path, object: obj}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			return nil
+		})
+	}
+
+	for i := range selection {
+		root := filepath.Clean(selection[i])
+
+		g.Go(func() error {
+			return walk(root, func(path string) string {
+				if path == root {
+					return subfolder + filepath.Base(path)
+				}
+
+				return subfolder + strings.TrimPrefix(path, filepath.Dir(root)+"/")
+			}, rootChain(root))
 		})
 	}
 
@@ -145,6 +281,22 @@ func WalkDirs(selection, currentObjects []string, prefix string) (UploadSet, err
 	return UploadSet{bucket, files, objects, make([]bool, len(objects))}, nil
 }
 
+// stdinSet returns the set that uploads standard input as the object named by `prefix`
+func stdinSet(selection []string, prefix string) (UploadSet, error) {
+	if len(selection) != 1 {
+		return UploadSet{}, errors.New("standard input cannot be exported together with other files")
+	}
+	bucket, object, _ := strings.Cut(strings.Trim(filepath.Clean(prefix), "/"), "/")
+	if object == "" {
+		return UploadSet{}, errors.New("object name is required when exporting standard input, expected format bucket/path/to/object")
+	}
+	if !strings.HasSuffix(object, ".c4gh") {
+		object += ".c4gh"
+	}
+
+	return UploadSet{bucket, []string{StdinFile}, []string{object}, []bool{false}}, nil
+}
+
 // ValidateBucket validates the bucket name and creates a valid bucket if it does not yet exist
 // Called only from CLI
 func ValidateBucket(bucket string) (bool, error) {
@@ -174,13 +326,12 @@ func ValidateBucket(bucket string) (bool, error) {
 
 // CheckObjectExistences checks if the files that are to be uploaded already have
 // equivalent objects in S3 storage. If any objects exists, user is given a choice to either
-// quit or overwrite the objects with the new files. Function assumes bucket exists.
-func CheckObjectExistences(set *UploadSet, rd io.Reader) error {
-	// these objects should already be sorted
-	path := api.SDConnect.ForPath() + "/" + api.GetProjectName() + "/" + set.Bucket
-	existingObjects, err := api.GetObjects(api.SDConnect, set.Bucket, path, "", "")
+// quit or overwrite the objects with the new files. The question is written to `w` and the
+// answer read from `rd`. Function assumes bucket exists.
+func CheckObjectExistences(set *UploadSet, rd io.Reader, w io.Writer) error {
+	existingObjects, err := listObjects(set.Bucket)
 	if err != nil {
-		return fmt.Errorf("could not determine if export will overwrite data: %w", err)
+		return err
 	}
 
 	for i := range set.Objects {
@@ -200,7 +351,7 @@ func CheckObjectExistences(set *UploadSet, rd io.Reader) error {
 		r := bufio.NewReader(rd)
 
 		for {
-			fmt.Fprintf(os.Stdout, "Export will overwrite existing objects in Allas. Continue? [y/n] ")
+			fmt.Fprint(w, "Export will overwrite existing objects in Allas. Continue? [y/n] ")
 			ans, err := r.ReadString('\n')
 			if err != nil {
 				return fmt.Errorf("failed to read user input: %w", err)
@@ -222,38 +373,90 @@ func CheckObjectExistences(set *UploadSet, rd io.Reader) error {
 	return nil
 }
 
-// Upload uploads files to a bucket with object names taken from the matching index in `objects`
-func Upload(set UploadSet, metadata map[string]string) error {
+// listObjects returns the objects that currently exist in `bucket`, sorted by name
+func listObjects(bucket string) ([]api.Metadata, error) {
+	// these objects should already be sorted
+	path := api.SDConnect.ForPath() + "/" + api.GetProjectName() + "/" + bucket
+	existingObjects, err := api.GetObjects(api.SDConnect, bucket, path, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("could not determine if export will overwrite data: %w", err)
+	}
+
+	return existingObjects, nil
+}
+
+// Upload uploads files to a bucket with object names taken from the matching index in `objects`.
+// If `ctx` is cancelled, unfinished uploads are aborted and their partial objects removed.
+// The files that were uploaded successfully are returned even if the upload fails.
+func Upload(ctx context.Context, set UploadSet, metadata map[string]string) ([]string, error) {
 	var err error
 	ai.publicKey, err = api.GetPublicKey() // May rotate between uploads so have to fetch it each time
 	if err != nil {
-		return fmt.Errorf("failed to get project public key: %w", err)
+		return nil, fmt.Errorf("failed to get project public key: %w", err)
 	}
 
-	g, ctx := errgroup.WithContext(context.Background())
+	if ai.progressFun != nil {
+		ctx = context.WithValue(ctx, progressKey{}, newProgressTracker(ai.progressFun, set.Files))
+	}
+	ctx = withResources(ctx)
+
+	var mu sync.Mutex
+	completed := make([]string, 0, len(set.Files))
+	var changed []string
+
+	g, gctx := errgroup.WithContext(ctx)
 	g.SetLimit(numRoutines)
 	for i := range set.Objects {
 		filename := set.Files[i]
 		object := set.Objects[i]
 		g.Go(func() error {
-			err := UploadObject(ctx, filename, object, set.Bucket, metadata)
+			err := UploadObject(gctx, filename, object, set.Bucket, metadata)
+			for retry := 1; errors.Is(err, ErrFileChanged) && retry <= ai.changeRetries; retry++ {
+				logs.Warningf("File %s was modified during upload, uploading it again (%d/%d)", filename, retry, ai.changeRetries)
+				err = UploadObject(gctx, filename, object, set.Bucket, metadata)
+			}
+			if errors.Is(err, errNotStarted) {
+				return nil
+			}
+			if errors.Is(err, ErrFileChanged) {
+				// The other files can still be uploaded
+				logs.Error(err)
+				mu.Lock()
+				changed = append(changed, filename)
+				mu.Unlock()
+
+				return nil
+			}
 			if err != nil {
 				logs.Error(err)
 
 				return errors.New("upload interrupted due to errors")
 			}
+			mu.Lock()
+			completed = append(completed, filename)
+			mu.Unlock()
 
 			return nil
 		})
 	}
 
-	return g.Wait()
+	err = g.Wait()
+	slices.Sort(completed)
+	if ctx.Err() != nil {
+		return completed, fmt.Errorf("upload cancelled: %w", ctx.Err())
+	}
+	if err == nil && len(changed) > 0 {
+		slices.Sort(changed)
+		err = fmt.Errorf("%d file(s) were modified during upload and were not exported: %s", len(changed), strings.Join(changed, ", "))
+	}
+
+	return completed, err
 }
 
 // UploadObject uploads a file to SD Connect, and possibly to CESSNA
 var UploadObject = func(ctx context.Context, filename, object, bucket string, metadata map[string]string) error {
 	if err := ctx.Err(); err != nil {
-		return nil // We don't need to print out the same context error over and over again
+		return errNotStarted // We don't need to print out the same context error over and over again
 	}
 
 	file, encryptedFileSize, err := getFileDetails(filename)
@@ -262,7 +465,25 @@ var UploadObject = func(ctx context.Context, filename, object, bucket string, me
 	}
 	defer file.Close()
 
+	var ef *encryptedFile
+	if rs, ok := file.(io.ReadSeeker); ok {
+		ef, err = openEncrypted(rs, filename)
+		if err != nil {
+			return fmt.Errorf("failed to process encrypted file %s: %w", filename, err)
+		}
+	}
+
+	// The size of a stream is known only once it has been read
+	stream := encryptedFileSize < 0
 	objectSize := encryptedFileSize - headerSize
+	switch {
+	case ef != nil && api.FindataUpload(): // File is decrypted and encrypted again
+		objectSize = api.CalculateEncryptedSize(ef.plainSize)
+		encryptedFileSize = objectSize + headerSize
+	case ef != nil:
+		objectSize = ef.bodySize
+		encryptedFileSize = int64(len(ef.header)) + ef.bodySize
+	}
 	if objectSize > maxObjectSize {
 		return fmt.Errorf("file %s is too large (%d bytes)", filename, objectSize)
 	}
@@ -271,53 +492,188 @@ var UploadObject = func(ctx context.Context, filename, object, bucket string, me
 	for maxParts*segmentSize < encryptedFileSize {
 		segmentSize <<= 1
 	}
-	logs.Info("Encrypting file ", filename)
-	logs.Debugf("Encrypted file size %v for %s", encryptedFileSize, filename)
-	logs.Debugf("Segment size %v for %s", segmentSize, filename)
+	partSize := encryptedFileSize
+	if stream {
+		// Streams may be at most maxParts segments long, which is enough for over a terabyte
+		partSize = maxParts * segmentSize
+	}
+	// In Findata projects, each file is uploaded twice at the same time
+	streams := int64(1)
+	if api.FindataUpload() {
+		streams = 2
+	}
+	res := resourcesOf(ctx)
+	concurrency, reserved := partConcurrency(res.limits, partSize, segmentSize, streams)
+	memory := res.memory
+	if err := memory.Acquire(ctx, reserved); err != nil {
+		return errNotStarted
+	}
+	defer memory.Release(reserved)
+
+	logger := logs.WithSubsystem("airlock").WithFields(logs.Fields{logs.FieldBucket: bucket, logs.FieldObject: object})
+	start := time.Now()
+
+	if ef != nil && !api.FindataUpload() {
+		logger.Info("Re-encrypting header of file ", filename)
+	} else {
+		logger.Info("Encrypting file ", filename)
+	}
+	if stream {
+		logger.Debugf("Size of %s is not known in advance", filename)
+	} else {
+		logger.Debugf("Encrypted file size %v for %s", encryptedFileSize, filename)
+	}
+	logger.Debugf("Segment size %v for %s", segmentSize, filename)
+	logger.Debugf("Uploading %d part(s) concurrently for %s", concurrency, filename)
+
+	before := statSource(file)
+	objectMetadata := sourceMetadata(file)
+	if link, ok := file.(symlinkFile); ok {
+		objectMetadata[api.MetaSymlink] = link.target
+	}
+	var src io.Reader = file
+	read := &countingWriter{w: io.Discard}
+	if stream {
+		src = io.TeeReader(src, read)
+	}
+	// The checksum describes the decrypted content, and it is calculated while the file is uploaded
+	var checksum func() string
+	if ef != nil {
+		if src, checksum, err = ef.source(src, api.FindataUpload()); err != nil {
+			return fmt.Errorf("failed to stream encrypted file %s: %w", filename, err)
+		}
+	} else {
+		cr := newChecksumReader(src)
+		src, checksum = cr, cr.checksum
+	}
 
 	errc1 := make(chan error, 1)
 	errc2 := make(chan error, 2)
 	pr, pw := io.Pipe() // So that 'c4ghWriter' can pass its contents to an io.Reader
 
-	if !api.FindataUpload() {
-		go encrypt(file, pw, errc2)
+	switch {
+	case api.FindataUpload():
+	case ef != nil:
+		go passThrough(src, pw, errc2)
+	default:
+		go encrypt(src, pw, errc2)
 	}
 	go func() {
-		errc1 <- uploadAllas(ctx, pr, bucket, object, segmentSize)
+		errc1 <- uploadAllas(trackFile(ctx, filename), pr, bucket, object, segmentSize, concurrency, objectMetadata)
 		pr.Close()
 	}()
 
 	err = nil
+	findataMetadata := maps.Clone(metadata)
 	if api.FindataUpload() {
 		errc2 <- nil
-		if err = uploadFindata(ctx, file, pw, bucket, object, segmentSize, metadata); err != nil {
+		if err = uploadFindata(ctx, src, pw, bucket, object, segmentSize, concurrency, findataMetadata); err != nil {
 			logs.Error(err)
 		}
 	}
 
 	err2 := <-errc2
 	if err2 != nil {
-		logs.Debugf("Deleting object %s from bucket %s", object, bucket)
-		if delErr := api.DeleteObject(api.SDConnect, bucket, object); delErr != nil {
-			logs.Warningf("Data left in Allas after failed upload: %w", delErr)
-		}
 		logs.Errorf("Streaming file %s failed: %w", filename, err2)
 	}
 	err1 := <-errc1
 	if err1 != nil {
 		logs.Error(err1)
 	}
+	sum := checksum()
+	if err == nil && err1 == nil && err2 == nil && before != nil && sourceChanged(filename, *before) {
+		removeObject(bucket, object)
+
+		return fmt.Errorf("uploading file %s failed: %w", filename, ErrFileChanged)
+	}
 	if err != nil || err1 != nil || err2 != nil {
+		// Remove what was stored of an object whose upload was cancelled or whose content could not be streamed
+		if err2 != nil || ctx.Err() != nil {
+			removeObject(bucket, object)
+		}
+
+		return fmt.Errorf("uploading file %s failed", filename)
+	}
+	if stream {
+		objectSize = api.CalculateEncryptedSize(read.n)
+		logger.Debugf("Read %d bytes from %s", read.n, filename)
+	}
+	if err = verifyUpload(bucket, object, objectSize); err != nil {
+		logs.Errorf("Verification of object %s failed: %w", object, err)
+		removeObject(bucket, object)
+
 		return fmt.Errorf("uploading file %s failed", filename)
 	}
-	logs.Info("Finished uploading file ", filename)
+	// Without a checksum, the object could not be verified and sync would compare only its size and modification time
+	if err = recordChecksum(bucket, object, objectSize, objectMetadata, findataMetadata, sum); err != nil {
+		logs.Errorf("Recording checksum of object %s failed: %w", object, err)
+		removeObject(bucket, object)
+
+		return fmt.Errorf("uploading file %s failed", filename)
+	}
+	logger.WithFields(logs.Fields{logs.FieldDuration: time.Since(start)}).Info("Finished uploading file ", filename)
+	finishFile(ctx, filename)
 
 	return nil
 }
 
+// recordChecksum adds the checksum of the uploaded content to the metadata of the object, and in Findata
+// projects to the metadata of the unencrypted object. The checksum is known only once the file has been
+// read, whereas metadata is sent when the upload begins, so the metadata is replaced afterwards.
+func recordChecksum(bucket, object string, objectSize int64, objectMetadata, findataMetadata map[string]string, checksum string) error {
+	objectMetadata[api.MetaChecksum] = checksum
+	if err := api.SetObjectMetadata(api.SDConnect, bucket, object, objectSize, objectMetadata); err != nil {
+		return err
+	}
+	if !api.FindataUpload() {
+		return nil
+	}
+
+	if findataMetadata == nil {
+		findataMetadata = make(map[string]string)
+	}
+	findataMetadata[api.MetaChecksum] = checksum
+	object = strings.TrimSuffix(object, ".c4gh")
+	if err := api.SetObjectMetadata(api.Findata, bucket, object, api.CalculateDecryptedSize(objectSize), findataMetadata); err != nil {
+		return fmt.Errorf("checksum of %s object %s was not recorded: %w", api.Findata, object, err)
+	}
+
+	return nil
+}
+
+// removeObject deletes an object whose upload did not finish, along with its header in Vault
+var removeObject = func(bucket, object string) {
+	logs.Debugf("Deleting object %s from bucket %s", object, bucket)
+	if err := api.DeleteObject(api.SDConnect, bucket, object); err != nil {
+		logs.Warningf("Data left in Allas after failed upload: %w", err)
+	}
+	if err := api.DeleteHeader(bucket, object); err != nil {
+		logs.Warningf("Header left in Vault after failed upload: %w", err)
+	}
+}
+
+// partConcurrency determines how many parts of a file are uploaded concurrently, and how much memory
+// needs to be reserved from the shared budget for buffering them. Each of the `streams` uploads
+// of the file buffers its parts separately. A file that would not fit in the budget even one part
+// at a time reserves the entire budget.
+func partConcurrency(limits UploadLimits, fileSize, segmentSize, streams int64) (int, int64) {
+	parts := max((fileSize+segmentSize-1)/segmentSize, 1)
+	partSize := min(segmentSize, fileSize)
+	concurrency := min(int64(limits.PartConcurrency), parts, max(limits.MemoryBudget/(partSize*streams), 1))
+
+	return int(concurrency), min(concurrency*partSize*streams, limits.MemoryBudget)
+}
+
 // uploadAllas exports an encrypted file by uploading its header to Vault and
 // its body to SD Connect. pr is assumed to contain an encrypted file.
-var uploadAllas = func(ctx context.Context, pr io.Reader, bucket, object string, segmentSize int64) error {
+var uploadAllas = func(
+	ctx context.Context,
+	pr io.Reader,
+	bucket, object string,
+	segmentSize int64,
+	concurrency int,
+	metadata map[string]string,
+) error {
 	logs.Infof("Beginning to upload %s object %s to bucket %s", api.SDConnect, object, bucket)
 	header, err := c4ghHeaders.ReadHeader(pr)
 	if err != nil {
@@ -329,7 +685,17 @@ var uploadAllas = func(ctx context.Context, pr io.Reader, bucket, object string,
 	}
 	logs.Debugf("Uploading body of object %s to Allas", object)
 
-	return api.UploadObject(ctx, pr, api.SDConnect, bucket, object, segmentSize, nil)
+	err = api.UploadObject(ctx, pr, api.SDConnect, bucket, object, segmentSize, concurrency, metadata)
+	if err != nil {
+		// The header would otherwise be used for a later object with the same name
+		if errHeader := api.DeleteHeader(bucket, object); errHeader != nil {
+			logs.Warningf("Header left in Vault after failed upload: %w", errHeader)
+		}
+
+		return err
+	}
+
+	return nil
 }
 
 // uploadFindata uploads the selected file unencrypted to CESSNA. At the same time
@@ -342,6 +708,7 @@ func uploadFindata(
 	pw *io.PipeWriter,
 	bucket, object string,
 	segmentSize int64,
+	concurrency int,
 	metadata map[string]string,
 ) (err error) {
 	object = strings.TrimSuffix(object, ".c4gh")
@@ -356,7 +723,7 @@ func uploadFindata(
 	}
 
 	r := io.TeeReader(file, c4ghWriter)
-	err = api.UploadObject(ctx, r, api.Findata, bucket, object, segmentSize, metadata)
+	err = api.UploadObject(ctx, r, api.Findata, bucket, object, segmentSize, concurrency, metadata)
 	if err != nil {
 		err = fmt.Errorf("failed to upload %s object: %w", api.Findata, err)
 
@@ -393,13 +760,83 @@ var encrypt = func(file io.Reader, pw *io.PipeWriter, errc chan error) {
 	errc <- nil
 }
 
+// checksumReader calculates the SHA-256 checksum of the content read through it
+type checksumReader struct {
+	rd   io.Reader
+	hash hash.Hash
+}
+
+func newChecksumReader(rd io.Reader) *checksumReader {
+	return &checksumReader{rd: rd, hash: sha256.New()}
+}
+
+func (cr *checksumReader) Read(p []byte) (int, error) {
+	n, err := cr.rd.Read(p)
+	cr.hash.Write(p[:n])
+
+	return n, err
+}
+
+// checksum returns the hex encoded checksum of the content that has been read
+func (cr *checksumReader) checksum() string {
+	return hex.EncodeToString(cr.hash.Sum(nil))
+}
+
+// streamFile hides the methods of a file that cannot be used with a stream, such as Seek() and Stat()
+type streamFile struct {
+	io.ReadCloser
+}
+
+// uploadSize returns the size of the object that `filename` is uploaded to, or -1 if the file is a stream.
+// The body of a file that is already encrypted is uploaded as it is, unless it is decrypted for Findata.
+var uploadSize = func(filename string) (int64, error) {
+	if ai.symlinks == SymlinkObject {
+		if target, err := os.Readlink(filename); err == nil {
+			return api.CalculateEncryptedSize(int64(len(target))), nil
+		}
+	}
+
+	info, err := os.Stat(filename)
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return -1, nil
+	}
+	if size, ok := passedThroughHeader(filename); ok {
+		bodySize := info.Size() - size
+		if api.FindataUpload() {
+			return api.CalculateEncryptedSize(api.CalculateDecryptedSize(bodySize)), nil
+		}
+
+		return bodySize, nil
+	}
+
+	return api.CalculateEncryptedSize(info.Size()), nil
+}
+
+// getFileDetails opens `filename` and returns the size of the encrypted file. Standard input and
+// named pipes are returned as streams, whose size is -1 as it is not known before they have been read.
+// Symbolic links that are exported as objects are returned as their target.
 var getFileDetails = func(filename string) (io.ReadCloser, int64, error) {
+	if filename == StdinFile {
+		return streamFile{io.NopCloser(os.Stdin)}, -1, nil
+	}
+	if ai.symlinks == SymlinkObject {
+		if target, err := os.Readlink(filename); err == nil {
+			return symlinkFile{strings.NewReader(target), target}, api.CalculateEncryptedSize(int64(len(target))) + headerSize, nil
+		}
+	}
+
 	file, err := os.Open(filename)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	fileInfo, _ := file.Stat()
+	if fileInfo.Mode()&fs.ModeNamedPipe != 0 {
+		return streamFile{file}, -1, nil
+	}
 
 	return file, api.CalculateEncryptedSize(fileInfo.Size()) + headerSize, nil
 }
@@ -3,14 +3,17 @@ package airlock
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"reflect"
 	"slices"
 	"strings"
 	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -26,6 +29,9 @@ var errExpected = errors.New("expected error for test")
 
 func TestMain(m *testing.M) {
 	logs.SetSignal(func(string, []string) {})
+	api.SetObjectMetadata = func(api.Repo, string, string, int64, map[string]string) error {
+		return nil
+	}
 	os.Exit(m.Run())
 }
 
@@ -249,6 +255,73 @@ func TestWalkDirs_Error(t *testing.T) {
 	}
 }
 
+func TestWalkDirs_Stream(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(tmpDir+"/dir", 0755); err != nil {
+		t.Fatalf("Failed to create folder: %s", err.Error())
+	}
+	if err := syscall.Mkfifo(tmpDir+"/pipe.c4gh", 0600); err != nil {
+		t.Fatalf("Failed to create named pipe: %s", err.Error())
+	}
+	if err := syscall.Mkfifo(tmpDir+"/dir/pipe", 0600); err != nil {
+		t.Fatalf("Failed to create named pipe: %s", err.Error())
+	}
+	if err := os.WriteFile(tmpDir+"/dir/file.txt", []byte("hello world\n"), 0600); err != nil {
+		t.Fatalf("Failed to create file: %s", err.Error())
+	}
+
+	var tests = []struct {
+		testname, prefix string
+		selection        []string
+		expected         UploadSet
+	}{
+		{
+			"OK_PIPE", "bucket", []string{tmpDir + "/pipe.c4gh", tmpDir + "/dir"},
+			UploadSet{"bucket", []string{tmpDir + "/dir/file.txt", tmpDir + "/pipe.c4gh"}, []string{"dir/file.txt.c4gh", "pipe.c4gh.c4gh"}, []bool{false, false}},
+		},
+		{
+			"OK_STDIN_1", "bucket/dir/object.c4gh", []string{"-"},
+			UploadSet{"bucket", []string{"-"}, []string{"dir/object.c4gh"}, []bool{false}},
+		},
+		{
+			"OK_STDIN_2", "/bucket/object/", []string{"-"},
+			UploadSet{"bucket", []string{"-"}, []string{"object.c4gh"}, []bool{false}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			set, err := WalkDirs(tt.selection, nil, tt.prefix)
+			if err != nil {
+				t.Fatalf("Function returned unexpected error: %s", err.Error())
+			}
+			slices.Sort(set.Files)
+			slices.Sort(set.Objects)
+			if !reflect.DeepEqual(set, tt.expected) {
+				t.Errorf("Function returned incorrect set\nExpected=%+v\nReceived=%+v", tt.expected, set)
+			}
+		})
+	}
+
+	var errTests = []struct {
+		testname, prefix, errStr string
+		selection                []string
+	}{
+		{"FAIL_NO_OBJECT", "bucket", "object name is required when exporting standard input, expected format bucket/path/to/object", []string{"-"}},
+		{"FAIL_OTHER_FILES", "bucket/object", "standard input cannot be exported together with other files", []string{"-", tmpDir + "/dir"}},
+	}
+
+	for _, tt := range errTests {
+		t.Run(tt.testname, func(t *testing.T) {
+			if _, err := WalkDirs(tt.selection, nil, tt.prefix); err == nil {
+				t.Error("Function did not return error")
+			} else if err.Error() != tt.errStr {
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+		})
+	}
+}
+
 func TestValidateBucket(t *testing.T) {
 	var tests = []struct {
 		testname, bucket string
@@ -428,6 +501,43 @@ func TestFileDetails_NoFile(t *testing.T) {
 	}
 }
 
+func TestGetFileDetails_Stream(t *testing.T) {
+	filename := t.TempDir() + "/pipe"
+	if err := syscall.Mkfifo(filename, 0600); err != nil {
+		t.Fatalf("Failed to create named pipe: %s", err.Error())
+	}
+	go func() {
+		if err := os.WriteFile(filename, []byte("pipe message"), 0600); err != nil {
+			t.Errorf("Failed to write to named pipe: %s", err.Error())
+		}
+	}()
+
+	rc, size, err := getFileDetails(filename)
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	defer rc.Close()
+
+	if size != -1 {
+		t.Errorf("Function returned incorrect size. Expected=-1, received=%d", size)
+	}
+	if _, ok := rc.(io.Seeker); ok {
+		t.Error("Stream should not be seekable")
+	}
+	if message, err := io.ReadAll(rc); err != nil {
+		t.Errorf("Failed to read from pipe: %s", err.Error())
+	} else if string(message) != "pipe message" {
+		t.Errorf("Received incorrect message\nExpected=pipe message\nReceived=%s", string(message))
+	}
+
+	rc, size, err = getFileDetails("-")
+	if err != nil || size != -1 {
+		t.Errorf("Function should have returned standard input, received size %d and error %v", size, err)
+	} else if _, ok := rc.(io.Seeker); ok {
+		t.Error("Standard input should not be seekable")
+	}
+}
+
 func TestCheckObjectExistences_UserInput(t *testing.T) {
 	var tests = []struct {
 		testname, userInput, errStr string
@@ -524,16 +634,8 @@ func TestCheckObjectExistences_UserInput(t *testing.T) {
 				return tt.existingObjects, nil
 			}
 
-			// Ignore prints to stdout
-			null, _ := os.Open(os.DevNull)
-			sout := os.Stdout
-			os.Stdout = null
-
 			set := UploadSet{Bucket: "bucket", Objects: tt.inputObjects, Exists: make([]bool, len(tt.inputObjects))}
-			err := CheckObjectExistences(&set, strings.NewReader(tt.userInput))
-
-			os.Stdout = sout
-			null.Close()
+			err := CheckObjectExistences(&set, strings.NewReader(tt.userInput), io.Discard)
 
 			switch {
 			case tt.errStr != "":
@@ -637,7 +739,7 @@ func TestCheckObjectExistences_NilReader(t *testing.T) {
 			os.Stdout = null
 
 			set := UploadSet{Bucket: "bucket", Objects: tt.inputObjects, Exists: make([]bool, len(tt.inputObjects))}
-			err := CheckObjectExistences(&set, nil)
+			err := CheckObjectExistences(&set, nil, nil)
 
 			os.Stdout = sout
 			null.Close()
@@ -665,7 +767,7 @@ func TestCheckObjectExistence_Error(t *testing.T) {
 
 	errStr := "could not determine if export will overwrite data: " + errExpected.Error()
 	set := UploadSet{Bucket: "bucket", Objects: []string{"file.txt.c4gh"}, Exists: make([]bool, 1)}
-	err := CheckObjectExistences(&set, strings.NewReader(""))
+	err := CheckObjectExistences(&set, strings.NewReader(""), io.Discard)
 	if err == nil {
 		t.Error("Function did not return error")
 	} else if err.Error() != errStr {
@@ -712,19 +814,27 @@ func TestUpload(t *testing.T) {
 	origFindataUpload := api.FindataUpload
 	origPostHeader := api.PostHeader
 	origUploadObject := api.UploadObject
+	origVerifyUpload := verifyUpload
 	origDeleteObject := api.DeleteObject
+	origSetObjectMetadata := api.SetObjectMetadata
 	origPublicKey := ai.publicKey
 	defer func() {
+		api.SetObjectMetadata = origSetObjectMetadata
 		api.GetPublicKey = origGetPublicKey
 		getFileDetails = origGetFileDetails
 		api.GetProjectName = origProjectName
 		api.FindataUpload = origFindataUpload
 		api.PostHeader = origPostHeader
 		api.UploadObject = origUploadObject
+		verifyUpload = origVerifyUpload
 		api.DeleteObject = origDeleteObject
 		ai.publicKey = origPublicKey
 	}()
 
+	verifyUpload = func(bucket, object string, objectSize int64) error {
+		return nil
+	}
+
 	api.GetProjectName = func() string {
 		return "findata-project"
 	}
@@ -755,7 +865,10 @@ func TestUpload(t *testing.T) {
 				}
 				obj := tt.objects[idx]
 				value, _ := content.Load(obj)
-				rc := io.NopCloser(bytes.NewReader(value.([]byte)))
+				rc := struct {
+					io.ReadSeeker
+					io.Closer
+				}{bytes.NewReader(value.([]byte)), io.NopCloser(nil)}
 
 				return rc, tt.fileSize, nil
 			}
@@ -779,6 +892,7 @@ func TestUpload(t *testing.T) {
 					rep api.Repo,
 					bucket, object string,
 					segmentSize int64,
+					concurrency int,
 					metadata map[string]string,
 				) error {
 					if rep != api.SDConnect {
@@ -793,6 +907,9 @@ func TestUpload(t *testing.T) {
 					if segmentSize != tt.segmentSize {
 						t.Errorf("api.UploadObject() received incorrect segment size. Expected=%d, received=%d", tt.segmentSize, segmentSize)
 					}
+					if _, ok := metadata[api.MetaChecksum]; ok {
+						t.Error("Checksum should not be known before the upload")
+					}
 
 					bodyBytes, err := io.ReadAll(body)
 					if err != nil {
@@ -822,6 +939,7 @@ func TestUpload(t *testing.T) {
 					rep api.Repo,
 					bucket, object string,
 					segmentSize int64,
+					concurrency int,
 					metadata map[string]string,
 				) error {
 					if rep != api.SDConnect && rep != api.Findata {
@@ -846,12 +964,13 @@ func TestUpload(t *testing.T) {
 					}
 
 					if rep == api.Findata {
+						origObject := strings.TrimPrefix(object, "test-bucket/")
+						value, _ := content.Load(origObject)
+
 						if !reflect.DeepEqual(metadata, tt.metadata) {
 							t.Errorf("api.UploadObject() received incorrect metadata\nExpected=%v\nReceived=%v", tt.metadata, metadata)
 						}
 
-						origObject := strings.TrimPrefix(object, "test-bucket/")
-						value, _ := content.Load(origObject)
 						expectedObjContent := string(value.([]byte))
 						if string(bodyBytes) != expectedObjContent {
 							t.Fatalf("findata reader returned incorrect body for object %s.\nExpected=%s\nReceived=%s", object, expectedObjContent, string(bodyBytes))
@@ -878,6 +997,29 @@ func TestUpload(t *testing.T) {
 					return nil
 				}
 			}
+			var recorded sync.Map
+			api.SetObjectMetadata = func(rep api.Repo, bucket, object string, size int64, metadata map[string]string) error {
+				key := string(rep) + "/" + object
+				if rep == api.Findata {
+					object += ".c4gh"
+				}
+				value, _ := content.Load(object)
+				expectedMetadata := map[string]string{}
+				if rep == api.Findata {
+					expectedMetadata = maps.Clone(tt.metadata)
+				}
+				expectedMetadata[api.MetaChecksum] = fmt.Sprintf("%x", sha256.Sum256(value.([]byte)))
+				if _, ok := expectedMetadata[api.MetaModified]; !ok {
+					delete(metadata, api.MetaModified)
+					delete(metadata, api.MetaMode)
+				}
+				if !reflect.DeepEqual(metadata, expectedMetadata) {
+					t.Errorf("api.SetObjectMetadata() received incorrect metadata for %s\nExpected=%v\nReceived=%v", key, expectedMetadata, metadata)
+				}
+				recorded.Store(key, true)
+
+				return nil
+			}
 			api.DeleteObject = func(rep api.Repo, bucket, object string) error {
 				t.Error("Should not call api.DeleteObject()")
 
@@ -889,8 +1031,20 @@ func TestUpload(t *testing.T) {
 				Files:   tt.files,
 				Objects: tt.objects,
 			}
-			if err := Upload(set, tt.metadata); err != nil {
+			completed, err := Upload(context.Background(), set, tt.metadata)
+			for i := range tt.objects {
+				if _, ok := recorded.Load(string(api.SDConnect) + "/" + tt.objects[i]); !ok {
+					t.Errorf("Checksum of object %s was not recorded", tt.objects[i])
+				}
+				if _, ok := recorded.Load(string(api.Findata) + "/" + strings.TrimSuffix(tt.objects[i], ".c4gh")); ok != tt.findata {
+					t.Errorf("Checksum of %s object %s was recorded=%t, expected=%t", api.Findata, tt.objects[i], ok, tt.findata)
+				}
+			}
+			expectedCompleted := slices.Sorted(slices.Values(tt.files))
+			if err != nil {
 				t.Errorf("Function returned unexpected error: %s", err.Error())
+			} else if !reflect.DeepEqual(expectedCompleted, completed) {
+				t.Errorf("Function returned incorrect completed files\nExpected=%v\nReceived=%v", expectedCompleted, completed)
 			}
 		})
 	}
@@ -917,7 +1071,9 @@ func TestUpload_Error(t *testing.T) {
 	origGetFileDetails := getFileDetails
 	origFindataUpload := api.FindataUpload
 	origUploadAllas := uploadAllas
+	origVerifyUpload := verifyUpload
 	origDeleteObject := api.DeleteObject
+	origDeleteHeader := api.DeleteHeader
 	origPublicKey := ai.publicKey
 	origError := logs.Error
 	origErrorf := logs.Errorf
@@ -926,12 +1082,18 @@ func TestUpload_Error(t *testing.T) {
 		getFileDetails = origGetFileDetails
 		api.FindataUpload = origFindataUpload
 		uploadAllas = origUploadAllas
+		verifyUpload = origVerifyUpload
 		api.DeleteObject = origDeleteObject
+		api.DeleteHeader = origDeleteHeader
 		ai.publicKey = origPublicKey
 		logs.Error = origError
 		logs.Errorf = origErrorf
 	}()
 
+	verifyUpload = func(bucket, object string, objectSize int64) error {
+		return nil
+	}
+
 	for _, tt := range tests {
 		t.Run(tt.testname, func(t *testing.T) {
 			publicKey, _, err := keys.GenerateKeyPair()
@@ -950,7 +1112,7 @@ func TestUpload_Error(t *testing.T) {
 			api.FindataUpload = func() bool {
 				return tt.findata
 			}
-			uploadAllas = func(ctx context.Context, pr io.Reader, bucket, object string, segmentSize int64) error {
+			uploadAllas = func(ctx context.Context, pr io.Reader, bucket, object string, segmentSize int64, _ int, _ map[string]string) error {
 				if tt.uploadErr != nil {
 					if object == "subfolder/test-file.txt.c4gh" {
 						time.Sleep(10 * time.Millisecond) // We have to be sure other goroutines have called the function
@@ -966,6 +1128,9 @@ func TestUpload_Error(t *testing.T) {
 			api.DeleteObject = func(rep api.Repo, bucket, object string) error {
 				return nil
 			}
+			api.DeleteHeader = func(bucket, object string) error {
+				return nil
+			}
 
 			errs := make([]string, 0)
 			errc := make(chan error)
@@ -1009,7 +1174,7 @@ func TestUpload_Error(t *testing.T) {
 					"log.txt.c4gh",
 				},
 			}
-			if err = Upload(set, nil); err == nil {
+			if _, err = Upload(context.Background(), set, nil); err == nil {
 				t.Error("Function did not return error")
 			} else if err.Error() != tt.errStr {
 				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
@@ -1061,6 +1226,99 @@ func (br *badReader) Close() error {
 	return nil
 }
 
+func TestUpload_Cancel(t *testing.T) {
+	origGetPublicKey := api.GetPublicKey
+	origGetFileDetails := getFileDetails
+	origFindataUpload := api.FindataUpload
+	origUploadAllas := uploadAllas
+	origVerifyUpload := verifyUpload
+	origRemoveObject := removeObject
+	origProgressFun := ai.progressFun
+	origError := logs.Error
+	origErrorf := logs.Errorf
+	defer func() {
+		api.GetPublicKey = origGetPublicKey
+		getFileDetails = origGetFileDetails
+		api.FindataUpload = origFindataUpload
+		uploadAllas = origUploadAllas
+		verifyUpload = origVerifyUpload
+		removeObject = origRemoveObject
+		ai.progressFun = origProgressFun
+		logs.Error = origError
+		logs.Errorf = origErrorf
+	}()
+
+	verifyUpload = func(bucket, object string, objectSize int64) error {
+		return nil
+	}
+
+	publicKey, _, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	api.GetPublicKey = func() ([32]byte, error) {
+		return publicKey, nil
+	}
+	getFileDetails = func(filename string) (io.ReadCloser, int64, error) {
+		return io.NopCloser(strings.NewReader("I am content")), 100, nil
+	}
+	api.FindataUpload = func() bool {
+		return false
+	}
+	var started sync.WaitGroup // Slow uploads have to begin before the export is cancelled
+	started.Add(2)
+	uploadAllas = func(ctx context.Context, pr io.Reader, bucket, object string, segmentSize int64, _ int, _ map[string]string) error {
+		_, _ = io.ReadAll(pr)
+		if object == "done.txt.c4gh" {
+			started.Wait()
+
+			return nil
+		}
+		started.Done()
+		<-ctx.Done()
+
+		return ctx.Err()
+	}
+	var mu sync.Mutex
+	var removed []string
+	removeObject = func(bucket, object string) {
+		mu.Lock()
+		defer mu.Unlock()
+		removed = append(removed, object)
+	}
+	logs.Error = func(err error) {}
+	logs.Errorf = func(format string, args ...any) {}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	SetProgressFunc(func(p Progress) {
+		if p.Done {
+			cancel()
+		}
+	})
+
+	set := UploadSet{
+		Bucket:  "test-bucket",
+		Files:   []string{"slow.txt", "done.txt", "another-slow.txt"},
+		Objects: []string{"slow.txt.c4gh", "done.txt.c4gh", "another-slow.txt.c4gh"},
+	}
+	completed, err := Upload(ctx, set, nil)
+
+	errStr := "upload cancelled: context canceled"
+	if err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+	if !reflect.DeepEqual([]string{"done.txt"}, completed) {
+		t.Errorf("Function returned incorrect completed files\nExpected=%v\nReceived=%v", []string{"done.txt"}, completed)
+	}
+	slices.Sort(removed)
+	if expected := []string{"another-slow.txt.c4gh", "slow.txt.c4gh"}; !reflect.DeepEqual(expected, removed) {
+		t.Errorf("Function removed incorrect objects\nExpected=%v\nReceived=%v", expected, removed)
+	}
+}
+
 func TestUploadObject_Error(t *testing.T) {
 	var tests = []struct {
 		testname, errStr                            string
@@ -1147,7 +1405,9 @@ func TestUploadObject_Error(t *testing.T) {
 	origFindataUpload := api.FindataUpload
 	origPostHeader := api.PostHeader
 	origUploadObject := api.UploadObject
+	origVerifyUpload := verifyUpload
 	origDeleteObject := api.DeleteObject
+	origDeleteHeader := api.DeleteHeader
 	origPublicKey := ai.publicKey
 	origError := logs.Error
 	origErrorf := logs.Errorf
@@ -1156,12 +1416,18 @@ func TestUploadObject_Error(t *testing.T) {
 		api.FindataUpload = origFindataUpload
 		api.PostHeader = origPostHeader
 		api.UploadObject = origUploadObject
+		verifyUpload = origVerifyUpload
 		api.DeleteObject = origDeleteObject
+		api.DeleteHeader = origDeleteHeader
 		ai.publicKey = origPublicKey
 		logs.Error = origError
 		logs.Errorf = origErrorf
 	}()
 
+	verifyUpload = func(bucket, object string, objectSize int64) error {
+		return nil
+	}
+
 	for _, tt := range tests {
 		t.Run(tt.testname, func(t *testing.T) {
 			publicKey, _, err := keys.GenerateKeyPair()
@@ -1201,6 +1467,7 @@ func TestUploadObject_Error(t *testing.T) {
 				rep api.Repo,
 				bucket, object string,
 				segmentSize int64,
+				concurrency int,
 				metadata map[string]string,
 			) error {
 				_, err := io.ReadAll(body)
@@ -1226,6 +1493,12 @@ func TestUploadObject_Error(t *testing.T) {
 
 				return tt.deleteErr
 			}
+			headerDeleted := false
+			api.DeleteHeader = func(bucket, object string) error {
+				headerDeleted = true
+
+				return tt.deleteErr
+			}
 
 			errs := make([]string, 0)
 			logs.Error = func(err error) {
@@ -1244,8 +1517,372 @@ func TestUploadObject_Error(t *testing.T) {
 			if slices.Compare(tt.loggedErrors, errs) != 0 {
 				t.Errorf("Function logged incorrect errors\nExpected=%q\nReceived=%q", tt.loggedErrors, errs)
 			}
-			if tt.badCopy && !tt.findata && !deleted {
-				t.Error("Object was not deleted")
+			if tt.badCopy && !tt.findata && (!deleted || !headerDeleted) {
+				t.Errorf("Object or its header was not deleted, object=%t, header=%t", deleted, headerDeleted)
+			}
+			if tt.testname == "FAIL_UPLOAD" && !headerDeleted {
+				t.Error("Header of object whose upload failed was not deleted")
+			}
+			if tt.testname == "FAIL_POST_HEADER" && headerDeleted {
+				t.Error("Header should not be deleted when it was not uploaded")
+			}
+		})
+	}
+}
+
+func TestUploadObject_VerifyError(t *testing.T) {
+	origGetFileDetails := getFileDetails
+	origFindataUpload := api.FindataUpload
+	origPostHeader := api.PostHeader
+	origUploadObject := api.UploadObject
+	origVerifyUpload := verifyUpload
+	origRemoveObject := removeObject
+	origPublicKey := ai.publicKey
+	origErrorf := logs.Errorf
+	defer func() {
+		getFileDetails = origGetFileDetails
+		api.FindataUpload = origFindataUpload
+		api.PostHeader = origPostHeader
+		api.UploadObject = origUploadObject
+		verifyUpload = origVerifyUpload
+		removeObject = origRemoveObject
+		ai.publicKey = origPublicKey
+		logs.Errorf = origErrorf
+	}()
+
+	publicKey, _, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	ai.publicKey = publicKey
+	content := test.GenerateRandomText(100)
+
+	api.FindataUpload = func() bool {
+		return false
+	}
+	getFileDetails = func(filename string) (io.ReadCloser, int64, error) {
+		return io.NopCloser(bytes.NewReader(content)), api.CalculateEncryptedSize(100) + headerSize, nil
+	}
+	api.PostHeader = func(header []byte, bucket, object string) error {
+		return nil
+	}
+	api.UploadObject = func(
+		ctx context.Context,
+		body io.Reader,
+		rep api.Repo,
+		bucket, object string,
+		segmentSize int64,
+		concurrency int,
+		metadata map[string]string,
+	) error {
+		_, err := io.Copy(io.Discard, body)
+
+		return err
+	}
+	verifyUpload = func(bucket, object string, objectSize int64) error {
+		if objectSize != api.CalculateEncryptedSize(100) {
+			t.Errorf("verifyUpload() received incorrect size. Expected=%d, received=%d", api.CalculateEncryptedSize(100), objectSize)
+		}
+
+		return errExpected
+	}
+	var removed []string
+	removeObject = func(bucket, object string) {
+		removed = append(removed, object)
+	}
+	var loggedErrors []string
+	logs.Errorf = func(format string, args ...any) {
+		loggedErrors = append(loggedErrors, fmt.Errorf(format, args...).Error())
+	}
+
+	errStr := "uploading file test-file.txt failed"
+	err = UploadObject(context.Background(), "test-file.txt", "test-file.txt.c4gh", "bucket", nil)
+	if err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+	if !slices.Equal(removed, []string{"test-file.txt.c4gh"}) {
+		t.Errorf("Function removed incorrect objects %v", removed)
+	}
+	expectedErrors := []string{"Verification of object test-file.txt.c4gh failed: " + errExpected.Error()}
+	if !slices.Equal(loggedErrors, expectedErrors) {
+		t.Errorf("Function logged incorrect errors\nExpected=%v\nReceived=%v", expectedErrors, loggedErrors)
+	}
+}
+
+func TestUploadObject_Stream(t *testing.T) {
+	origGetFileDetails := getFileDetails
+	origFindataUpload := api.FindataUpload
+	origPostHeader := api.PostHeader
+	origUploadObject := api.UploadObject
+	origVerifyUpload := verifyUpload
+	origSetObjectMetadata := api.SetObjectMetadata
+	origRemoveObject := removeObject
+	origPublicKey := ai.publicKey
+	defer func() {
+		getFileDetails = origGetFileDetails
+		api.FindataUpload = origFindataUpload
+		api.PostHeader = origPostHeader
+		api.UploadObject = origUploadObject
+		api.SetObjectMetadata = origSetObjectMetadata
+		removeObject = origRemoveObject
+		verifyUpload = origVerifyUpload
+		ai.publicKey = origPublicKey
+	}()
+
+	publicKey, _, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	ai.publicKey = publicKey
+	content := test.GenerateRandomText(100000)
+
+	api.FindataUpload = func() bool {
+		return false
+	}
+	getFileDetails = func(filename string) (io.ReadCloser, int64, error) {
+		return streamFile{io.NopCloser(bytes.NewReader(content))}, -1, nil
+	}
+	api.PostHeader = func(header []byte, bucket, object string) error {
+		return nil
+	}
+	uploaded := int64(0)
+	api.UploadObject = func(
+		ctx context.Context,
+		body io.Reader,
+		rep api.Repo,
+		bucket, object string,
+		segmentSize int64,
+		concurrency int,
+		metadata map[string]string,
+	) error {
+		if segmentSize != minSegmentSize {
+			t.Errorf("api.UploadObject() received incorrect segment size. Expected=%d, received=%d", minSegmentSize, segmentSize)
+		}
+		n, err := io.Copy(io.Discard, body)
+		uploaded = n
+
+		return err
+	}
+	verifyUpload = func(bucket, object string, objectSize int64) error {
+		if objectSize != uploaded {
+			t.Errorf("verifyUpload() received incorrect size. Expected=%d, received=%d", uploaded, objectSize)
+		}
+
+		return nil
+	}
+	checksum := ""
+	api.SetObjectMetadata = func(rep api.Repo, bucket, object string, size int64, metadata map[string]string) error {
+		if size != uploaded {
+			t.Errorf("api.SetObjectMetadata() received incorrect size. Expected=%d, received=%d", uploaded, size)
+		}
+		checksum = metadata[api.MetaChecksum]
+
+		return nil
+	}
+
+	if err = UploadObject(context.Background(), "-", "object.c4gh", "bucket", nil); err != nil {
+		t.Errorf("Function returned unexpected error: %s", err.Error())
+	}
+	if expected := fmt.Sprintf("%x", sha256.Sum256(content)); checksum != expected {
+		t.Errorf("Stream has incorrect checksum\nExpected=%s\nReceived=%s", expected, checksum)
+	}
+	if expected := api.CalculateEncryptedSize(100000); uploaded != expected {
+		t.Errorf("Uploaded object has incorrect size. Expected=%d, received=%d", expected, uploaded)
+	}
+
+	// An object whose checksum could not be recorded is removed so that it is uploaded again
+	api.SetObjectMetadata = func(rep api.Repo, bucket, object string, size int64, metadata map[string]string) error {
+		return errExpected
+	}
+	removed := ""
+	removeObject = func(bucket, object string) {
+		removed = object
+	}
+	errStr := "uploading file - failed"
+	if err = UploadObject(context.Background(), "-", "object.c4gh", "bucket", nil); err == nil || err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%v", errStr, err)
+	}
+	if removed != "object.c4gh" {
+		t.Errorf("Object without checksum was not removed")
+	}
+}
+
+func TestChecksumReader(t *testing.T) {
+	content := "some file content"
+	checksum := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+
+	cr := newChecksumReader(strings.NewReader(content))
+	if data, err := io.ReadAll(cr); err != nil {
+		t.Errorf("Reading returned unexpected error: %s", err.Error())
+	} else if string(data) != content {
+		t.Errorf("Reader returned incorrect content\nExpected=%s\nReceived=%s", content, string(data))
+	}
+	if cr.checksum() != checksum {
+		t.Errorf("Reader returned incorrect checksum\nExpected=%s\nReceived=%s", checksum, cr.checksum())
+	}
+}
+
+func TestSetUploadLimits(t *testing.T) {
+	origLimits := ai.limits
+	origMemory := ai.memory
+	defer func() {
+		ai.limits = origLimits
+		ai.memory = origMemory
+	}()
+
+	var tests = []struct {
+		testname, errStr string
+		limits           UploadLimits
+	}{
+		{"OK", "", UploadLimits{PartConcurrency: 8, MemoryBudget: 1 << 31}},
+		{"FAIL_CONCURRENCY", "part concurrency must be at least 1", UploadLimits{PartConcurrency: 0, MemoryBudget: 1 << 31}},
+		{"FAIL_MEMORY", "memory budget must be at least 128 MiB", UploadLimits{PartConcurrency: 2, MemoryBudget: 1 << 26}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			ai.limits = origLimits
+
+			err := SetUploadLimits(tt.limits)
+			switch {
+			case tt.errStr == "":
+				if err != nil {
+					t.Errorf("Function returned unexpected error: %s", err.Error())
+				} else if GetUploadLimits() != tt.limits {
+					t.Errorf("Limits were not changed. Expected=%v, received=%v", tt.limits, GetUploadLimits())
+				}
+			case err == nil:
+				t.Error("Function did not return error")
+			case err.Error() != tt.errStr:
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			case GetUploadLimits() != origLimits:
+				t.Errorf("Limits should not have changed, received %v", GetUploadLimits())
+			}
+		})
+	}
+}
+
+func TestSetUploadLimits_DuringUpload(t *testing.T) {
+	origGetPublicKey := api.GetPublicKey
+	origGetFileDetails := getFileDetails
+	origFindataUpload := api.FindataUpload
+	origPostHeader := api.PostHeader
+	origUploadObject := api.UploadObject
+	origVerifyUpload := verifyUpload
+	origSetObjectMetadata := api.SetObjectMetadata
+	origLimits := GetUploadLimits()
+	defer func() {
+		api.GetPublicKey = origGetPublicKey
+		getFileDetails = origGetFileDetails
+		api.FindataUpload = origFindataUpload
+		api.PostHeader = origPostHeader
+		api.UploadObject = origUploadObject
+		verifyUpload = origVerifyUpload
+		api.SetObjectMetadata = origSetObjectMetadata
+		_ = SetUploadLimits(origLimits)
+	}()
+
+	publicKey, _, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	api.GetPublicKey = func() ([32]byte, error) {
+		return publicKey, nil
+	}
+	api.FindataUpload = func() bool {
+		return false
+	}
+	getFileDetails = func(filename string) (io.ReadCloser, int64, error) {
+		return streamFile{io.NopCloser(strings.NewReader("content"))}, -1, nil
+	}
+	api.PostHeader = func(header []byte, bucket, object string) error {
+		return nil
+	}
+	started := make(chan struct{}, 4)
+	release := make(chan struct{})
+	api.UploadObject = func(
+		ctx context.Context,
+		body io.Reader,
+		rep api.Repo,
+		bucket, object string,
+		segmentSize int64,
+		concurrency int,
+		metadata map[string]string,
+	) error {
+		started <- struct{}{}
+		<-release
+		_, err := io.Copy(io.Discard, body)
+
+		return err
+	}
+	verifyUpload = func(bucket, object string, objectSize int64) error {
+		return nil
+	}
+	api.SetObjectMetadata = func(api.Repo, string, string, int64, map[string]string) error {
+		return nil
+	}
+
+	limits := UploadLimits{PartConcurrency: 2, MemoryBudget: 1 << 30}
+	if err = SetUploadLimits(limits); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	memory := currentResources().memory
+
+	set := UploadSet{Bucket: "bucket", Files: []string{"a", "b", "c", "d"}, Objects: []string{"a.c4gh", "b.c4gh", "c.c4gh", "d.c4gh"}}
+	errc := make(chan error, 1)
+	go func() {
+		_, err := Upload(context.Background(), set, nil)
+		errc <- err
+	}()
+	for range set.Files {
+		<-started
+	}
+	// The limits are changed while all the files are being uploaded
+	for i := range 10 {
+		if err = SetUploadLimits(UploadLimits{PartConcurrency: i + 1, MemoryBudget: 1 << 28}); err != nil {
+			t.Errorf("Function returned unexpected error: %s", err.Error())
+		}
+	}
+	close(release)
+	if err = <-errc; err != nil {
+		t.Fatalf("Upload returned unexpected error: %s", err.Error())
+	}
+
+	// The uploads released their memory into the budget they started with
+	if !memory.TryAcquire(limits.MemoryBudget) {
+		t.Errorf("Memory reserved by the uploads was not released")
+	}
+	if received := GetUploadLimits(); received.MemoryBudget != 1<<28 || received.PartConcurrency != 10 {
+		t.Errorf("Limits were not changed. Expected={10 %d}, received=%v", 1<<28, received)
+	}
+}
+
+func TestPartConcurrency(t *testing.T) {
+	var tests = []struct {
+		testname                       string
+		limits                         UploadLimits
+		fileSize, segmentSize, streams int64
+		expectedConcurrency            int
+		expectedReserved               int64
+	}{
+		{"OK_SMALL_FILE", UploadLimits{4, 1 << 30}, 5000, 1 << 27, 1, 1, 5000},
+		{"OK_FEW_PARTS", UploadLimits{4, 1 << 30}, 3<<27 - 100, 1 << 27, 1, 3, 3 << 27},
+		{"OK_LARGE_FILE", UploadLimits{4, 1 << 30}, 1 << 40, 1 << 27, 1, 4, 4 << 27},
+		{"OK_FINDATA", UploadLimits{4, 1 << 30}, 1 << 40, 1 << 27, 2, 4, 8 << 27},
+		{"OK_MEMORY_LIMITED", UploadLimits{16, 1 << 30}, 1 << 40, 1 << 28, 2, 2, 1 << 30},
+		{"OK_OVER_BUDGET", UploadLimits{4, 1 << 27}, 1 << 42, 1 << 28, 1, 1, 1 << 27},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			concurrency, reserved := partConcurrency(tt.limits, tt.fileSize, tt.segmentSize, tt.streams)
+			if concurrency != tt.expectedConcurrency {
+				t.Errorf("Function returned incorrect concurrency. Expected=%d, received=%d", tt.expectedConcurrency, concurrency)
+			}
+			if reserved != tt.expectedReserved {
+				t.Errorf("Function returned incorrect reserved memory. Expected=%d, received=%d", tt.expectedReserved, reserved)
 			}
 		})
 	}
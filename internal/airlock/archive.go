@@ -0,0 +1,301 @@
+package airlock
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// tarBlockSize is the size of a tar header and the unit to which file content is padded
+const tarBlockSize = 512
+
+// Archive is a selection of files that are uploaded as a single tar object
+type Archive struct {
+	Bucket   string
+	Object   string
+	Files    []string
+	Members  []string // Names of the files inside the archive
+	Compress bool     // Whether the tar is compressed with zstd
+}
+
+// WalkArchive collects the files under `root` into an archive that will be uploaded under `prefix`.
+// The archive object is named after `root`, and its members are named relative to the parent of `root`.
+func WalkArchive(root, prefix string, compress bool) (Archive, error) {
+	bucket, subfolder, _ := strings.Cut(filepath.Clean(prefix), "/")
+	if subfolder != "" {
+		subfolder += "/"
+	}
+	root = filepath.Clean(root)
+
+	archive := Archive{Bucket: bucket, Object: subfolder + filepath.Base(root) + ".tar", Compress: compress}
+	if compress {
+		archive.Object += ".zst"
+	}
+	archive.Object += ".c4gh"
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			if !d.IsDir() {
+				logs.Warningf("%s is not a regular file or directory, skipping...", path)
+			}
+
+			return nil
+		}
+
+		member := filepath.Base(path)
+		if path != root {
+			member = strings.TrimPrefix(path, filepath.Dir(root)+"/")
+		}
+		archive.Files = append(archive.Files, path)
+		archive.Members = append(archive.Members, filepath.ToSlash(member))
+
+		return nil
+	})
+	if err != nil {
+		return Archive{}, err
+	}
+	if len(archive.Files) == 0 {
+		return Archive{}, fmt.Errorf("%s does not contain any files", root)
+	}
+
+	return archive, nil
+}
+
+// UploadArchive streams the files of `archive` as a tar through the crypt4gh writer into a single object.
+// Once the archive has been uploaded, an index of its members is uploaded as a separate object.
+var UploadArchive = func(ctx context.Context, archive Archive) error {
+	if api.FindataUpload() {
+		return errors.New("archives cannot be exported in Findata projects")
+	}
+
+	var err error
+	ai.publicKey, err = api.GetPublicKey()
+	if err != nil {
+		return fmt.Errorf("failed to get project public key: %w", err)
+	}
+
+	// The size of the archive is not known beforehand, so the segment size is based on its upper limit
+	estimatedSize := estimateArchiveSize(archive.Files)
+	encryptedSize := api.CalculateEncryptedSize(estimatedSize) + headerSize
+	if encryptedSize-headerSize > maxObjectSize {
+		return fmt.Errorf("archive %s is too large (%d bytes)", archive.Object, estimatedSize)
+	}
+	segmentSize := minSegmentSize
+	for maxParts*segmentSize < encryptedSize {
+		segmentSize <<= 1
+	}
+	res := resourcesOf(ctx)
+	concurrency, reserved := partConcurrency(res.limits, encryptedSize, segmentSize, 1)
+	memory := res.memory
+	if err := memory.Acquire(ctx, reserved); err != nil {
+		return fmt.Errorf("upload cancelled: %w", err)
+	}
+	defer memory.Release(reserved)
+
+	logs.Infof("Archiving %d file(s) into object %s", len(archive.Files), archive.Object)
+	var members []api.ArchiveMember
+	errc := make(chan error, 1)
+	pr, pw := io.Pipe()
+	written := &countingWriter{w: pw} // Size of the object is known only once the archive has been written
+	go func() {
+		var err error
+		members, err = writeArchive(written, archive)
+		pw.CloseWithError(err)
+		errc <- err
+	}()
+
+	compression := "none"
+	if archive.Compress {
+		compression = "zstd"
+	}
+	metadata := map[string]string{
+		api.MetaArchiveIndex:       api.ArchiveIndexObject(archive.Object),
+		api.MetaArchiveCompression: compression,
+	}
+	err = uploadAllas(ctx, pr, archive.Bucket, archive.Object, segmentSize, concurrency, metadata)
+	pr.Close()
+	if errArchive := <-errc; errArchive != nil && err == nil {
+		err = fmt.Errorf("failed to create archive: %w", errArchive)
+	}
+	if err == nil {
+		err = verifyUpload(archive.Bucket, archive.Object, written.n-headerSize)
+	}
+	if err == nil {
+		err = uploadIndex(ctx, archive, members)
+	}
+	if err != nil {
+		// The index is removed with the archive, as an index left from an overwritten archive would no longer match it
+		removeObject(archive.Bucket, archive.Object)
+		removeObject(archive.Bucket, api.ArchiveIndexObject(archive.Object))
+
+		return fmt.Errorf("uploading archive %s failed: %w", archive.Object, err)
+	}
+	logs.Infof("Finished uploading archive %s with %d file(s)", archive.Object, len(members))
+
+	return nil
+}
+
+// estimateArchiveSize returns the largest size a tar of `files` can have
+func estimateArchiveSize(files []string) int64 {
+	size := int64(2 * tarBlockSize) // End of archive
+	for i := range files {
+		// Header, possible PAX header for long names, and padding
+		size += 4 * tarBlockSize
+		if info, err := os.Stat(files[i]); err == nil {
+			size += info.Size()
+		}
+	}
+
+	return size
+}
+
+// countingWriter keeps track of the number of bytes written through it
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+
+	return n, err
+}
+
+// writeArchive writes the files of `archive` as an encrypted tar into `w`, and returns the index of its members
+func writeArchive(w io.Writer, archive Archive) ([]api.ArchiveMember, error) {
+	c4ghWriter, err := newCrypt4GHWriter(w)
+	if err != nil {
+		return nil, err
+	}
+
+	var out io.Writer = c4ghWriter
+	var zw *zstd.Encoder
+	if archive.Compress {
+		if zw, err = zstd.NewWriter(c4ghWriter); err != nil {
+			return nil, fmt.Errorf("failed to create zstd writer: %w", err)
+		}
+		out = zw
+	}
+	cw := &countingWriter{w: out}
+	tw := tar.NewWriter(cw)
+
+	members := make([]api.ArchiveMember, 0, len(archive.Files))
+	for i := range archive.Files {
+		member, err := addToArchive(tw, cw, archive.Files[i], archive.Members[i])
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	if err = tw.Close(); err != nil {
+		return nil, err
+	}
+	if zw != nil {
+		if err = zw.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err = c4ghWriter.Close(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+// addToArchive writes a single file into the tar
+func addToArchive(tw *tar.Writer, cw *countingWriter, filename, name string) (api.ArchiveMember, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return api.ArchiveMember{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return api.ArchiveMember{}, err
+	}
+	hdr, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return api.ArchiveMember{}, err
+	}
+	hdr.Name = name
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+	if err = tw.WriteHeader(hdr); err != nil {
+		return api.ArchiveMember{}, fmt.Errorf("failed to write tar header for file %s: %w", filename, err)
+	}
+	offset := cw.n
+
+	h := sha256.New()
+	if _, err = io.CopyN(tw, io.TeeReader(file, h), hdr.Size); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("file was truncated while it was being archived")
+		}
+
+		return api.ArchiveMember{}, fmt.Errorf("failed to archive file %s: %w", filename, err)
+	}
+	if sourceChanged(filename, newSourceState(info)) {
+		return api.ArchiveMember{}, fmt.Errorf("failed to archive file %s: %w", filename, ErrFileChanged)
+	}
+	logs.Debugf("Archived file %s as %s", filename, name)
+
+	return api.ArchiveMember{
+		Name:     name,
+		Offset:   offset,
+		Size:     hdr.Size,
+		Mode:     hdr.Mode,
+		Modified: info.ModTime().UTC(),
+		Checksum: hex.EncodeToString(h.Sum(nil)),
+	}, nil
+}
+
+// uploadIndex uploads the index of the members of `archive` as an encrypted JSON object
+func uploadIndex(ctx context.Context, archive Archive, members []api.ArchiveMember) error {
+	index, err := json.Marshal(members)
+	if err != nil {
+		return fmt.Errorf("failed to encode archive index: %w", err)
+	}
+
+	var buf bytes.Buffer
+	c4ghWriter, err := newCrypt4GHWriter(&buf)
+	if err != nil {
+		return err
+	}
+	if _, err = c4ghWriter.Write(index); err != nil {
+		return fmt.Errorf("failed to encrypt archive index: %w", err)
+	}
+	if err = c4ghWriter.Close(); err != nil {
+		return fmt.Errorf("failed to encrypt archive index: %w", err)
+	}
+
+	object := api.ArchiveIndexObject(archive.Object)
+	objectSize := int64(buf.Len()) - headerSize
+	err = uploadAllas(ctx, &buf, archive.Bucket, object, minSegmentSize, 1, nil)
+	if err == nil {
+		err = verifyUpload(archive.Bucket, object, objectSize)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to upload archive index: %w", err)
+	}
+
+	return nil
+}
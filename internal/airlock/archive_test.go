@@ -0,0 +1,384 @@
+package airlock
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"sda-filesystem/internal/api"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/neicnordic/crypt4gh/keys"
+	"github.com/neicnordic/crypt4gh/streaming"
+)
+
+func createArchiveTree(t *testing.T) (string, map[string]string) {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	content := map[string]string{
+		"data/file.txt":               "hello world\n",
+		"data/sub/another.txt":        "another file\n",
+		"data/sub/deeper/empty.txt":   "",
+		"data/sub/deeper/longer.text": strings.Repeat("content ", 200),
+	}
+	for name, data := range content {
+		if err := os.MkdirAll(tmpDir+"/"+name[:strings.LastIndex(name, "/")], 0755); err != nil {
+			t.Fatalf("Failed to create folder: %s", err.Error())
+		}
+		if err := os.WriteFile(tmpDir+"/"+name, []byte(data), 0600); err != nil {
+			t.Fatalf("Failed to create file: %s", err.Error())
+		}
+	}
+
+	return tmpDir, content
+}
+
+func TestWalkArchive(t *testing.T) {
+	tmpDir, content := createArchiveTree(t)
+
+	var tests = []struct {
+		testname, root, prefix string
+		compress               bool
+		expected               Archive
+	}{
+		{
+			"OK_1", tmpDir + "/data/", "bucket/subfolder", false,
+			Archive{Bucket: "bucket", Object: "subfolder/data.tar.c4gh", Members: slices.Sorted(maps.Keys(content))},
+		},
+		{
+			"OK_2", tmpDir + "/data/file.txt", "bucket", true,
+			Archive{Bucket: "bucket", Object: "file.txt.tar.zst.c4gh", Members: []string{"file.txt"}, Compress: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			archive, err := WalkArchive(tt.root, tt.prefix, tt.compress)
+			if err != nil {
+				t.Fatalf("Function returned unexpected error: %s", err.Error())
+			}
+			for i := range archive.Files {
+				expectedFile := tmpDir + "/" + archive.Members[i]
+				if tt.testname == "OK_2" {
+					expectedFile = tmpDir + "/data/" + archive.Members[i]
+				}
+				if archive.Files[i] != expectedFile {
+					t.Errorf("File %s does not match member %s", archive.Files[i], archive.Members[i])
+				}
+			}
+			archive.Files = nil
+			slices.Sort(archive.Members)
+			if !reflect.DeepEqual(archive, tt.expected) {
+				t.Errorf("Function returned incorrect archive\nExpected=%+v\nReceived=%+v", tt.expected, archive)
+			}
+		})
+	}
+}
+
+func TestWalkArchive_Error(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	errStr := tmpDir + " does not contain any files"
+	if _, err := WalkArchive(tmpDir, "bucket", false); err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+
+	errStr = "lstat " + tmpDir + "/missing: no such file or directory"
+	if _, err := WalkArchive(tmpDir+"/missing", "bucket", false); err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	origPublicKey := ai.publicKey
+	defer func() { ai.publicKey = origPublicKey }()
+
+	tmpDir, content := createArchiveTree(t)
+	publicKey, privateKey, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	ai.publicKey = publicKey
+
+	for _, compress := range []bool{false, true} {
+		t.Run(fmt.Sprintf("OK_COMPRESS_%t", compress), func(t *testing.T) {
+			archive, err := WalkArchive(tmpDir+"/data", "bucket", compress)
+			if err != nil {
+				t.Fatalf("Failed to walk archive: %s", err.Error())
+			}
+
+			var buf bytes.Buffer
+			members, err := writeArchive(&buf, archive)
+			if err != nil {
+				t.Fatalf("Function returned unexpected error: %s", err.Error())
+			}
+
+			c4ghReader, err := streaming.NewCrypt4GHReader(&buf, privateKey, nil)
+			if err != nil {
+				t.Fatalf("Failed to create crypt4gh reader: %s", err.Error())
+			}
+			var rd io.Reader = c4ghReader
+			if compress {
+				zr, err := zstd.NewReader(rd)
+				if err != nil {
+					t.Fatalf("Failed to create zstd reader: %s", err.Error())
+				}
+				defer zr.Close()
+				rd = zr
+			}
+			tarball, err := io.ReadAll(rd)
+			if err != nil {
+				t.Fatalf("Failed to decrypt archive: %s", err.Error())
+			}
+
+			// Members can be read both through the tar and with the offsets in the index
+			tr := tar.NewReader(bytes.NewReader(tarball))
+			for i := range members {
+				hdr, err := tr.Next()
+				if err != nil {
+					t.Fatalf("Failed to read tar: %s", err.Error())
+				}
+				data, _ := io.ReadAll(tr)
+				member := members[i]
+				switch {
+				case hdr.Name != member.Name:
+					t.Errorf("Incorrect member name. Expected=%s, received=%s", hdr.Name, member.Name)
+				case string(data) != content[member.Name]:
+					t.Errorf("Member %s has incorrect content in tar", member.Name)
+				case string(tarball[member.Offset:member.Offset+member.Size]) != content[member.Name]:
+					t.Errorf("Member %s has incorrect offset %d", member.Name, member.Offset)
+				case member.Checksum != fmt.Sprintf("%x", sha256.Sum256(data)):
+					t.Errorf("Member %s has incorrect checksum", member.Name)
+				case member.Mode != 0600:
+					t.Errorf("Member %s has incorrect mode %o", member.Name, member.Mode)
+				}
+			}
+			if _, err = tr.Next(); err != io.EOF {
+				t.Errorf("Tar has more files than the index")
+			}
+		})
+	}
+}
+
+func TestUploadArchive(t *testing.T) {
+	origGetPublicKey := api.GetPublicKey
+	origFindataUpload := api.FindataUpload
+	origPostHeader := api.PostHeader
+	origUploadObject := api.UploadObject
+	origVerifyUpload := verifyUpload
+	origRemoveObject := removeObject
+	defer func() {
+		api.GetPublicKey = origGetPublicKey
+		api.FindataUpload = origFindataUpload
+		api.PostHeader = origPostHeader
+		api.UploadObject = origUploadObject
+		verifyUpload = origVerifyUpload
+		removeObject = origRemoveObject
+	}()
+
+	verified := make(map[string]int64)
+	verifyUpload = func(bucket, object string, objectSize int64) error {
+		verified[object] = objectSize
+
+		return nil
+	}
+
+	tmpDir, content := createArchiveTree(t)
+	publicKey, privateKey, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	api.GetPublicKey = func() ([32]byte, error) {
+		return publicKey, nil
+	}
+	api.FindataUpload = func() bool {
+		return false
+	}
+	removeObject = func(bucket, object string) {
+		t.Errorf("Object %s should not have been removed", object)
+	}
+
+	headers := make(map[string][]byte)
+	bodies := make(map[string][]byte)
+	metadatas := make(map[string]map[string]string)
+	api.PostHeader = func(header []byte, bucket, object string) error {
+		headers[object] = header
+
+		return nil
+	}
+	api.UploadObject = func(
+		ctx context.Context,
+		body io.Reader,
+		rep api.Repo,
+		bucket, object string,
+		segmentSize int64,
+		concurrency int,
+		metadata map[string]string,
+	) error {
+		if bucket != "bucket" {
+			t.Errorf("api.UploadObject() received incorrect bucket. Expected=bucket, received=%s", bucket)
+		}
+		data, err := io.ReadAll(body)
+		bodies[object] = data
+		metadatas[object] = metadata
+
+		return err
+	}
+
+	archive, err := WalkArchive(tmpDir+"/data", "bucket/dir", false)
+	if err != nil {
+		t.Fatalf("Failed to walk archive: %s", err.Error())
+	}
+	if err = UploadArchive(context.Background(), archive); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+
+	for object, data := range bodies {
+		if size, ok := verified[object]; !ok {
+			t.Errorf("Object %s was not verified", object)
+		} else if size != int64(len(data)) {
+			t.Errorf("Object %s was verified with incorrect size. Expected=%d, received=%d", object, len(data), size)
+		}
+	}
+
+	expectedMetadata := map[string]string{api.MetaArchiveIndex: "dir/data.tar.index.json.c4gh", api.MetaArchiveCompression: "none"}
+	if !reflect.DeepEqual(metadatas["dir/data.tar.c4gh"], expectedMetadata) {
+		t.Errorf("Archive has incorrect metadata\nExpected=%v\nReceived=%v", expectedMetadata, metadatas["dir/data.tar.c4gh"])
+	}
+
+	decrypt := func(object string) []byte {
+		c4ghReader, err := streaming.NewCrypt4GHReader(io.MultiReader(bytes.NewReader(headers[object]), bytes.NewReader(bodies[object])), privateKey, nil)
+		if err != nil {
+			t.Fatalf("Failed to create crypt4gh reader for %s: %s", object, err.Error())
+		}
+		data, err := io.ReadAll(c4ghReader)
+		if err != nil {
+			t.Fatalf("Failed to decrypt %s: %s", object, err.Error())
+		}
+
+		return data
+	}
+
+	tarball := decrypt("dir/data.tar.c4gh")
+	var members []api.ArchiveMember
+	if err = json.Unmarshal(decrypt("dir/data.tar.index.json.c4gh"), &members); err != nil {
+		t.Fatalf("Failed to decode index: %s", err.Error())
+	}
+	if len(members) != len(content) {
+		t.Fatalf("Index has incorrect number of members. Expected=%d, received=%d", len(content), len(members))
+	}
+	for _, member := range members {
+		if string(tarball[member.Offset:member.Offset+member.Size]) != content[member.Name] {
+			t.Errorf("Member %s cannot be read from the archive with the index", member.Name)
+		}
+	}
+}
+
+func TestUploadArchive_Error(t *testing.T) {
+	origGetPublicKey := api.GetPublicKey
+	origFindataUpload := api.FindataUpload
+	origPostHeader := api.PostHeader
+	origUploadObject := api.UploadObject
+	origVerifyUpload := verifyUpload
+	origRemoveObject := removeObject
+	defer func() {
+		api.GetPublicKey = origGetPublicKey
+		api.FindataUpload = origFindataUpload
+		api.PostHeader = origPostHeader
+		api.UploadObject = origUploadObject
+		verifyUpload = origVerifyUpload
+		removeObject = origRemoveObject
+	}()
+
+	verifyUpload = func(bucket, object string, objectSize int64) error {
+		return nil
+	}
+
+	tmpDir, _ := createArchiveTree(t)
+	publicKey, _, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	api.GetPublicKey = func() ([32]byte, error) {
+		return publicKey, nil
+	}
+	api.PostHeader = func(header []byte, bucket, object string) error {
+		return nil
+	}
+
+	var tests = []struct {
+		testname, errStr string
+		findata          bool
+		failObject       string
+		removed          []string
+	}{
+		{
+			"FAIL_FINDATA", "archives cannot be exported in Findata projects",
+			true, "", nil,
+		},
+		{
+			"FAIL_ARCHIVE", "uploading archive data.tar.c4gh failed: " + errExpected.Error(),
+			false, "data.tar.c4gh", []string{"data.tar.c4gh", "data.tar.index.json.c4gh"},
+		},
+		{
+			"FAIL_INDEX", "uploading archive data.tar.c4gh failed: failed to upload archive index: " + errExpected.Error(),
+			false, "data.tar.index.json.c4gh", []string{"data.tar.c4gh", "data.tar.index.json.c4gh"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			api.FindataUpload = func() bool {
+				return tt.findata
+			}
+			api.UploadObject = func(
+				ctx context.Context,
+				body io.Reader,
+				rep api.Repo,
+				bucket, object string,
+				segmentSize int64,
+				concurrency int,
+				metadata map[string]string,
+			) error {
+				if object == tt.failObject {
+					return errExpected
+				}
+				_, err := io.ReadAll(body)
+
+				return err
+			}
+			var removed []string
+			removeObject = func(bucket, object string) {
+				removed = append(removed, object)
+			}
+
+			archive, err := WalkArchive(tmpDir+"/data", "bucket", false)
+			if err != nil {
+				t.Fatalf("Failed to walk archive: %s", err.Error())
+			}
+			if err = UploadArchive(context.Background(), archive); err == nil {
+				t.Error("Function did not return error")
+			} else if err.Error() != tt.errStr {
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+			if !reflect.DeepEqual(removed, tt.removed) {
+				t.Errorf("Function removed incorrect objects\nExpected=%q\nReceived=%q", tt.removed, removed)
+			}
+		})
+	}
+}
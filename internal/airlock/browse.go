@@ -0,0 +1,378 @@
+package airlock
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+)
+
+// Entry is a repository, bucket, folder or object in storage
+type Entry struct {
+	Path     string     `json:"path"` // repository/bucket/path/to/object
+	Name     string     `json:"name"`
+	Dir      bool       `json:"dir"`
+	Size     int64      `json:"size"` // Size in storage, for folders the total size of the objects in them
+	Modified *time.Time `json:"modified,omitempty"`
+	Owner    string     `json:"owner,omitempty"` // Project that shared the bucket, or the service of an SD Apply dataset
+	ID       string     `json:"id,omitempty"`    // File ID of an SD Apply object
+}
+
+// EntryInfo is the result of StatPath()
+type EntryInfo struct {
+	Entry
+	Objects  int               `json:"objects,omitempty"`  // Number of objects in a folder
+	Metadata map[string]string `json:"metadata,omitempty"` // Metadata of an SD Connect object
+}
+
+// FindFilter selects the objects returned by Find(). Zero values do not restrict the results.
+type FindFilter struct {
+	Name    string // Glob matched against the name of the object, or its whole path if the glob contains a slash
+	MinSize int64
+	MaxSize int64
+	Newer   time.Time
+	Older   time.Time
+}
+
+// ParseStoragePath splits `p`, given as [repository[/bucket[/prefix]]], into its parts
+func ParseStoragePath(p string) (api.Repo, string, string, error) {
+	repository, rest, _ := strings.Cut(strings.Trim(p, "/"), "/")
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if strings.HasSuffix(p, "/") && prefix != "" {
+		prefix += "/"
+	}
+	if repository == "" {
+		return "", "", "", nil
+	}
+
+	idx := slices.IndexFunc(api.GetAllRepositories(), func(rep api.Repo) bool {
+		return strings.EqualFold(rep.ForPath(), repository)
+	})
+	if idx < 0 {
+		return "", "", "", fmt.Errorf("unknown repository %q, expected one of %s", repository, strings.Join(repositoryNames(), ", "))
+	}
+
+	return api.GetAllRepositories()[idx], bucket, prefix, nil
+}
+
+func repositoryNames() []string {
+	names := []string{}
+	for _, rep := range api.GetAllRepositories() {
+		names = append(names, rep.ForPath())
+	}
+
+	return names
+}
+
+// datasetName returns the name of an SD Apply dataset as it is shown in the filesystem
+func datasetName(name string) string {
+	if u, err := url.ParseRequestURI(name); err == nil {
+		return strings.TrimLeft(strings.TrimPrefix(name, u.Scheme), ":/")
+	}
+
+	return name
+}
+
+// resolveBucket returns the bucket or dataset in repository `rep` that `bucket` and `prefix` refer to,
+// along with the prefix inside it. Dataset names may contain slashes, so the longest matching name is chosen.
+// Buckets of the project itself are preferred over shared buckets with the same name.
+func resolveBucket(rep api.Repo, bucket, prefix string) (api.Metadata, string, error) {
+	buckets, err := api.GetBuckets(rep)
+	if err != nil {
+		return api.Metadata{}, "", err
+	}
+	slices.SortStableFunc(buckets, func(a, b api.Metadata) int {
+		return strings.Compare(a.Owner, b.Owner)
+	})
+
+	full := bucket
+	if prefix != "" {
+		full += "/" + prefix
+	}
+	idx, matched := -1, ""
+	for i := range buckets {
+		for _, name := range []string{buckets[i].Name, datasetName(buckets[i].Name)} {
+			if (full == name || strings.HasPrefix(full, name+"/")) && len(name) > len(matched) {
+				idx, matched = i, name
+			}
+		}
+	}
+	if idx < 0 {
+		return api.Metadata{}, "", fmt.Errorf("bucket %s not found in %s", bucket, rep)
+	}
+
+	return buckets[idx], strings.TrimPrefix(strings.TrimPrefix(full, matched), "/"), nil
+}
+
+// listBucket returns the objects in `bucket` whose names begin with `prefix`
+func listBucket(rep api.Repo, bucket api.Metadata, prefix string) ([]api.Metadata, error) {
+	objects, err := api.GetObjects(rep, bucket.Name, rep.ForPath()+"/"+datasetName(bucket.Name), bucket.Owner, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(objects, func(meta api.Metadata) bool {
+		return strings.HasSuffix(meta.Name, "/")
+	}), nil
+}
+
+func objectEntry(rep api.Repo, bucket api.Metadata, object api.Metadata) Entry {
+	return Entry{
+		Path:     rep.ForPath() + "/" + datasetName(bucket.Name) + "/" + object.Name,
+		Name:     path.Base(object.Name),
+		Size:     object.Size,
+		Modified: object.LastModified,
+		Owner:    bucket.Owner,
+		ID:       object.ID,
+	}
+}
+
+// ListPath lists the contents of `p`, given as [repository[/bucket[/prefix]]]. Without a repository,
+// the repositories the user can access are listed. Objects deeper than the next level of `prefix` are grouped into folders.
+var ListPath = func(p string) ([]Entry, error) {
+	rep, bucketName, prefix, err := ParseStoragePath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []Entry{}
+	switch {
+	case rep == "":
+		for _, r := range api.GetRepositories() {
+			entries = append(entries, Entry{Path: r.ForPath(), Name: r.ForPath(), Dir: true})
+		}
+	case bucketName == "":
+		buckets, err := api.GetBuckets(rep)
+		if err != nil {
+			return nil, err
+		}
+		for i := range buckets {
+			name := datasetName(buckets[i].Name)
+			entries = append(entries, Entry{
+				Path: rep.ForPath() + "/" + name, Name: name, Dir: true,
+				Size: buckets[i].Size, Modified: buckets[i].LastModified, Owner: buckets[i].Owner,
+			})
+		}
+	default:
+		entries, err = listLevel(rep, bucketName, prefix)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+
+	return entries, nil
+}
+
+// listLevel lists the objects and folders directly under `prefix`, or the object `prefix` itself
+func listLevel(rep api.Repo, bucketName, prefix string) ([]Entry, error) {
+	bucket, prefix, err := resolveBucket(rep, bucketName, prefix)
+	if err != nil {
+		return nil, err
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	objects, err := listBucket(rep, bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	dirPrefix := ""
+	if prefix != "" {
+		dirPrefix = prefix + "/"
+	}
+	bucketPath := rep.ForPath() + "/" + datasetName(bucket.Name) + "/"
+
+	entries := []Entry{}
+	dirs := make(map[string]int)
+	for i := range objects {
+		if objects[i].Name == prefix {
+			entries = append(entries, objectEntry(rep, bucket, objects[i]))
+
+			continue
+		}
+		rest, ok := strings.CutPrefix(objects[i].Name, dirPrefix)
+		if !ok {
+			continue
+		}
+		dir, _, isDir := strings.Cut(rest, "/")
+		if !isDir {
+			entries = append(entries, objectEntry(rep, bucket, objects[i]))
+
+			continue
+		}
+
+		idx, ok := dirs[dir]
+		if !ok {
+			idx = len(entries)
+			dirs[dir] = idx
+			entries = append(entries, Entry{Path: bucketPath + dirPrefix + dir, Name: dir, Dir: true, Owner: bucket.Owner})
+		}
+		entries[idx].Size += objects[i].Size
+		if modified := objects[i].LastModified; modified != nil &&
+			(entries[idx].Modified == nil || modified.After(*entries[idx].Modified)) {
+			entries[idx].Modified = modified
+		}
+	}
+	if len(entries) == 0 && prefix != "" {
+		return nil, fmt.Errorf("no such folder or object: %s%s", bucketPath, prefix)
+	}
+
+	return entries, nil
+}
+
+// StatPath returns information about the bucket, folder or object `p`, given as repository/bucket[/path/to/object].
+// The metadata of SD Connect objects, such as the checksum recorded during export, is included.
+var StatPath = func(p string) (EntryInfo, error) {
+	rep, bucketName, prefix, err := ParseStoragePath(p)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	if bucketName == "" {
+		return EntryInfo{}, fmt.Errorf("invalid path %q, expected format repository/bucket/path/to/object", p)
+	}
+
+	bucket, prefix, err := resolveBucket(rep, bucketName, prefix)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	objects, err := listBucket(rep, bucket, prefix)
+	if err != nil {
+		return EntryInfo{}, err
+	}
+
+	if idx := slices.IndexFunc(objects, func(meta api.Metadata) bool { return meta.Name == prefix }); idx >= 0 {
+		info := EntryInfo{Entry: objectEntry(rep, bucket, objects[idx])}
+		if rep == api.SDConnect {
+			_, info.Metadata, err = api.GetObjectMetadata(rep, bucket.Name, prefix)
+			if err != nil {
+				return EntryInfo{}, err
+			}
+		}
+
+		return info, nil
+	}
+
+	path := strings.TrimSuffix(rep.ForPath()+"/"+datasetName(bucket.Name)+"/"+prefix, "/")
+	info := EntryInfo{Entry: Entry{Path: path, Name: datasetName(bucket.Name), Dir: true, Owner: bucket.Owner}}
+	if prefix != "" {
+		info.Name = prefix[strings.LastIndex(prefix, "/")+1:]
+	}
+	for i := range objects {
+		if prefix != "" && !strings.HasPrefix(objects[i].Name, prefix+"/") {
+			continue
+		}
+		info.Objects++
+		info.Size += objects[i].Size
+		if modified := objects[i].LastModified; modified != nil && (info.Modified == nil || modified.After(*info.Modified)) {
+			info.Modified = modified
+		}
+	}
+	if info.Objects == 0 && prefix != "" {
+		return EntryInfo{}, fmt.Errorf("no such folder or object: %s", path)
+	}
+
+	return info, nil
+}
+
+// Find returns the objects under `p`, given as [repository[/bucket[/prefix]]], that match `filter`.
+// Without a repository, all the repositories the user can access are searched. Buckets that cannot
+// be listed are skipped with a warning when more than one bucket is searched.
+var Find = func(p string, filter FindFilter) ([]Entry, error) {
+	if _, err := path.Match(filter.Name, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", filter.Name, err)
+	}
+	rep, bucketName, prefix, err := ParseStoragePath(p)
+	if err != nil {
+		return nil, err
+	}
+
+	repositories := []api.Repo{rep}
+	if rep == "" {
+		repositories = api.GetRepositories()
+	}
+
+	entries := []Entry{}
+	for _, r := range repositories {
+		var buckets []api.Metadata
+		if bucketName != "" {
+			var bucket api.Metadata
+			bucket, prefix, err = resolveBucket(r, bucketName, prefix)
+			if err != nil {
+				return nil, err
+			}
+			buckets = []api.Metadata{bucket}
+		} else if buckets, err = api.GetBuckets(r); err != nil {
+			if rep != "" {
+				return nil, err
+			}
+			logs.Warning(err)
+
+			continue
+		}
+
+		for _, bucket := range buckets {
+			objects, err := listBucket(r, bucket, prefix)
+			if err != nil {
+				if bucketName != "" {
+					return nil, err
+				}
+				logs.Warning(err)
+
+				continue
+			}
+			for i := range objects {
+				entry := objectEntry(r, bucket, objects[i])
+				if inPath(objects[i].Name, prefix) && filter.matches(entry) {
+					entries = append(entries, entry)
+				}
+			}
+		}
+	}
+
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return cmp.Compare(a.Path, b.Path)
+	})
+
+	return entries, nil
+}
+
+// inPath tells whether object `name` is the object `prefix` or inside the folder `prefix`.
+// Listing by `prefix` alone would also return the objects of sibling folders, such as dir2 for dir.
+func inPath(name, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+
+	return prefix == "" || name == prefix || strings.HasPrefix(name, prefix+"/")
+}
+
+func (f FindFilter) matches(entry Entry) bool {
+	if f.Name != "" {
+		target := entry.Name
+		if strings.Contains(f.Name, "/") {
+			target = entry.Path
+		}
+		if ok, _ := path.Match(f.Name, target); !ok {
+			return false
+		}
+	}
+	if entry.Size < f.MinSize || (f.MaxSize > 0 && entry.Size > f.MaxSize) {
+		return false
+	}
+	if !f.Newer.IsZero() && (entry.Modified == nil || !entry.Modified.After(f.Newer)) {
+		return false
+	}
+	if !f.Older.IsZero() && (entry.Modified == nil || !entry.Modified.Before(f.Older)) {
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,311 @@
+package airlock
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"sda-filesystem/internal/api"
+)
+
+var (
+	browseTime1 = time.Date(2024, 1, 10, 8, 0, 0, 0, time.UTC)
+	browseTime2 = time.Date(2024, 3, 5, 8, 0, 0, 0, time.UTC)
+)
+
+// mockStorage replaces the listing functions of api with a fixed set of buckets and objects
+func mockStorage(t *testing.T) {
+	t.Helper()
+
+	origGetRepositories := api.GetRepositories
+	origGetBuckets := api.GetBuckets
+	origGetObjects := api.GetObjects
+	origGetObjectMetadata := api.GetObjectMetadata
+	t.Cleanup(func() {
+		api.GetRepositories = origGetRepositories
+		api.GetBuckets = origGetBuckets
+		api.GetObjects = origGetObjects
+		api.GetObjectMetadata = origGetObjectMetadata
+	})
+
+	objects := map[string][]api.Metadata{
+		"bucket": {
+			{Name: "dir/", Size: 0},
+			{Name: "dir/a.txt.c4gh", Size: 10, LastModified: &browseTime1},
+			{Name: "dir/sub/b.txt.c4gh", Size: 2000, LastModified: &browseTime2},
+			{Name: "c.vcf.c4gh", Size: 300, LastModified: &browseTime1},
+		},
+		"shared": {
+			{Name: "d.vcf.c4gh", Size: 40, LastModified: &browseTime2},
+		},
+		"https://example.com/dataset": {
+			{Name: "e.txt.c4gh", Size: 50, LastModified: &browseTime2, ID: "file-id"},
+		},
+	}
+
+	api.GetRepositories = func() []api.Repo {
+		return []api.Repo{api.SDConnect, api.SDApply}
+	}
+	api.GetBuckets = func(rep api.Repo) ([]api.Metadata, error) {
+		if rep == api.SDApply {
+			return []api.Metadata{{Name: "https://example.com/dataset", Owner: "sd"}}, nil
+		}
+
+		return []api.Metadata{{Name: "bucket"}, {Name: "shared", Owner: "project_2002"}}, nil
+	}
+	api.GetObjects = func(rep api.Repo, bucket, path, owner, prefix string) ([]api.Metadata, error) {
+		matching := []api.Metadata{}
+		for _, obj := range objects[bucket] {
+			if strings.HasPrefix(obj.Name, prefix) {
+				matching = append(matching, obj)
+			}
+		}
+
+		return matching, nil
+	}
+	api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+		return 0, map[string]string{api.MetaChecksum: "abc"}, nil
+	}
+}
+
+func TestParseStoragePath(t *testing.T) {
+	var tests = []struct {
+		testname, path, bucket, prefix, errStr string
+		rep                                    api.Repo
+	}{
+		{"OK_EMPTY", "", "", "", "", ""},
+		{"OK_REPOSITORY", "/sd-apply/", "", "", "", api.SDApply},
+		{"OK_BUCKET", "SD-Connect/bucket/", "bucket", "", "", api.SDConnect},
+		{"OK_FOLDER", "SD-Connect/bucket/dir/", "bucket", "dir/", "", api.SDConnect},
+		{"OK_OBJECT", "SD-Connect/bucket/dir/a.txt.c4gh", "bucket", "dir/a.txt.c4gh", "", api.SDConnect},
+		{"FAIL_REPOSITORY", "Allas/bucket", "", "", "unknown repository \"Allas\", expected one of SD-Connect, SD-Apply", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			rep, bucket, prefix, err := ParseStoragePath(tt.path)
+
+			switch {
+			case tt.errStr != "":
+				if err == nil {
+					t.Error("Function did not return error")
+				} else if err.Error() != tt.errStr {
+					t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+				}
+			case err != nil:
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			case rep != tt.rep || bucket != tt.bucket || prefix != tt.prefix:
+				t.Errorf("Incorrect result. Expected=%q %q %q, received=%q %q %q", tt.rep, tt.bucket, tt.prefix, rep, bucket, prefix)
+			}
+		})
+	}
+}
+
+func TestListPath(t *testing.T) {
+	mockStorage(t)
+
+	var tests = []struct {
+		testname, path, errStr string
+		expected               []Entry
+	}{
+		{
+			"OK_ROOT", "", "",
+			[]Entry{
+				{Path: "SD-Apply", Name: "SD-Apply", Dir: true},
+				{Path: "SD-Connect", Name: "SD-Connect", Dir: true},
+			},
+		},
+		{
+			"OK_REPOSITORY", "SD-Apply", "",
+			[]Entry{{Path: "SD-Apply/example.com/dataset", Name: "example.com/dataset", Dir: true, Owner: "sd"}},
+		},
+		{
+			"OK_BUCKET", "SD-Connect/bucket", "",
+			[]Entry{
+				{Path: "SD-Connect/bucket/c.vcf.c4gh", Name: "c.vcf.c4gh", Size: 300, Modified: &browseTime1},
+				{Path: "SD-Connect/bucket/dir", Name: "dir", Dir: true, Size: 2010, Modified: &browseTime2},
+			},
+		},
+		{
+			"OK_FOLDER", "SD-Connect/bucket/dir/", "",
+			[]Entry{
+				{Path: "SD-Connect/bucket/dir/a.txt.c4gh", Name: "a.txt.c4gh", Size: 10, Modified: &browseTime1},
+				{Path: "SD-Connect/bucket/dir/sub", Name: "sub", Dir: true, Size: 2000, Modified: &browseTime2},
+			},
+		},
+		{
+			"OK_OBJECT", "SD-Connect/shared/d.vcf.c4gh", "",
+			[]Entry{{Path: "SD-Connect/shared/d.vcf.c4gh", Name: "d.vcf.c4gh", Size: 40, Modified: &browseTime2, Owner: "project_2002"}},
+		},
+		{
+			"OK_DATASET", "SD-Apply/example.com/dataset", "",
+			[]Entry{{Path: "SD-Apply/example.com/dataset/e.txt.c4gh", Name: "e.txt.c4gh", Size: 50, Modified: &browseTime2, Owner: "sd", ID: "file-id"}},
+		},
+		{"FAIL_PREFIX", "SD-Connect/bucket/di", "no such folder or object: SD-Connect/bucket/di", nil},
+		{"FAIL_BUCKET", "SD-Connect/other", "bucket other not found in SD Connect", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			entries, err := ListPath(tt.path)
+
+			switch {
+			case tt.errStr != "":
+				if err == nil {
+					t.Error("Function did not return error")
+				} else if err.Error() != tt.errStr {
+					t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+				}
+			case err != nil:
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			case !reflect.DeepEqual(entries, tt.expected):
+				t.Errorf("Incorrect entries\nExpected=%+v\nReceived=%+v", tt.expected, entries)
+			}
+		})
+	}
+}
+
+func TestStatPath(t *testing.T) {
+	mockStorage(t)
+
+	var tests = []struct {
+		testname, path, errStr string
+		expected               EntryInfo
+	}{
+		{
+			"OK_OBJECT", "SD-Connect/bucket/dir/a.txt.c4gh", "",
+			EntryInfo{
+				Entry:    Entry{Path: "SD-Connect/bucket/dir/a.txt.c4gh", Name: "a.txt.c4gh", Size: 10, Modified: &browseTime1},
+				Metadata: map[string]string{api.MetaChecksum: "abc"},
+			},
+		},
+		{
+			"OK_FOLDER", "SD-Connect/bucket/dir/", "",
+			EntryInfo{Entry: Entry{Path: "SD-Connect/bucket/dir", Name: "dir", Dir: true, Size: 2010, Modified: &browseTime2}, Objects: 2},
+		},
+		{
+			"OK_BUCKET", "SD-Connect/bucket", "",
+			EntryInfo{Entry: Entry{Path: "SD-Connect/bucket", Name: "bucket", Dir: true, Size: 2310, Modified: &browseTime2}, Objects: 3},
+		},
+		{
+			"OK_SD_APPLY", "SD-Apply/example.com/dataset/e.txt.c4gh", "",
+			EntryInfo{Entry: Entry{Path: "SD-Apply/example.com/dataset/e.txt.c4gh", Name: "e.txt.c4gh", Size: 50, Modified: &browseTime2, Owner: "sd", ID: "file-id"}},
+		},
+		{"FAIL_MISSING", "SD-Connect/bucket/dir/c", "no such folder or object: SD-Connect/bucket/dir/c", EntryInfo{}},
+		{"FAIL_NO_BUCKET", "SD-Connect", "invalid path \"SD-Connect\", expected format repository/bucket/path/to/object", EntryInfo{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			info, err := StatPath(tt.path)
+
+			switch {
+			case tt.errStr != "":
+				if err == nil {
+					t.Error("Function did not return error")
+				} else if err.Error() != tt.errStr {
+					t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+				}
+			case err != nil:
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			case !reflect.DeepEqual(info, tt.expected):
+				t.Errorf("Incorrect information\nExpected=%+v\nReceived=%+v", tt.expected, info)
+			}
+		})
+	}
+}
+
+func TestFind(t *testing.T) {
+	mockStorage(t)
+
+	var tests = []struct {
+		testname, path, errStr string
+		filter                 FindFilter
+		expected               []string
+	}{
+		{
+			"OK_ALL", "", "", FindFilter{},
+			[]string{
+				"SD-Apply/example.com/dataset/e.txt.c4gh", "SD-Connect/bucket/c.vcf.c4gh",
+				"SD-Connect/bucket/dir/a.txt.c4gh", "SD-Connect/bucket/dir/sub/b.txt.c4gh", "SD-Connect/shared/d.vcf.c4gh",
+			},
+		},
+		{"OK_NAME", "", "", FindFilter{Name: "*.vcf.c4gh"}, []string{"SD-Connect/bucket/c.vcf.c4gh", "SD-Connect/shared/d.vcf.c4gh"}},
+		{"OK_PATH", "SD-Connect", "", FindFilter{Name: "SD-Connect/*/dir/*"}, []string{"SD-Connect/bucket/dir/a.txt.c4gh"}},
+		{"OK_SIZE", "", "", FindFilter{MinSize: 40, MaxSize: 300}, []string{"SD-Apply/example.com/dataset/e.txt.c4gh", "SD-Connect/bucket/c.vcf.c4gh", "SD-Connect/shared/d.vcf.c4gh"}},
+		{"OK_NEWER", "SD-Connect/bucket", "", FindFilter{Newer: browseTime1}, []string{"SD-Connect/bucket/dir/sub/b.txt.c4gh"}},
+		{"OK_OLDER", "SD-Connect/bucket/dir", "", FindFilter{Older: browseTime2}, []string{"SD-Connect/bucket/dir/a.txt.c4gh"}},
+		{"OK_NONE", "SD-Apply", "", FindFilter{Name: "*.vcf.c4gh"}, []string{}},
+		{"FAIL_PATTERN", "", "invalid pattern \"[\": syntax error in pattern", FindFilter{Name: "["}, nil},
+		{"FAIL_BUCKET", "SD-Connect/other", "bucket other not found in SD Connect", FindFilter{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			entries, err := Find(tt.path, tt.filter)
+
+			switch {
+			case tt.errStr != "":
+				if err == nil {
+					t.Error("Function did not return error")
+				} else if err.Error() != tt.errStr {
+					t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+				}
+
+				return
+			case err != nil:
+				t.Fatalf("Function returned unexpected error: %s", err.Error())
+			}
+
+			paths := []string{}
+			for _, entry := range entries {
+				paths = append(paths, entry.Path)
+			}
+			if !slices.Equal(paths, tt.expected) {
+				t.Errorf("Incorrect objects\nExpected=%v\nReceived=%v", tt.expected, paths)
+			}
+		})
+	}
+}
+
+func TestFind_SiblingFolder(t *testing.T) {
+	mockStorage(t)
+	api.GetObjects = func(rep api.Repo, bucket, path, owner, prefix string) ([]api.Metadata, error) {
+		matching := []api.Metadata{}
+		for _, name := range []string{"dir", "dir/a.txt.c4gh", "dir2/b.txt.c4gh", "dir.txt.c4gh"} {
+			if strings.HasPrefix(name, prefix) {
+				matching = append(matching, api.Metadata{Name: name})
+			}
+		}
+
+		return matching, nil
+	}
+
+	var tests = []struct {
+		testname, path string
+		expected       []string
+	}{
+		{"OK_FOLDER", "SD-Connect/bucket/dir", []string{"SD-Connect/bucket/dir", "SD-Connect/bucket/dir/a.txt.c4gh"}},
+		{"OK_SLASH", "SD-Connect/bucket/dir/", []string{"SD-Connect/bucket/dir/a.txt.c4gh"}},
+		{"OK_OBJECT", "SD-Connect/bucket/dir.txt.c4gh", []string{"SD-Connect/bucket/dir.txt.c4gh"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			entries, err := Find(tt.path, FindFilter{})
+			if err != nil {
+				t.Fatalf("Function returned unexpected error: %s", err.Error())
+			}
+
+			paths := []string{}
+			for _, entry := range entries {
+				paths = append(paths, entry.Path)
+			}
+			if !slices.Equal(paths, tt.expected) {
+				t.Errorf("Incorrect objects\nExpected=%v\nReceived=%v", tt.expected, paths)
+			}
+		})
+	}
+}
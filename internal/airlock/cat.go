@@ -0,0 +1,100 @@
+package airlock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"sda-filesystem/internal/api"
+)
+
+// resolveObject finds the object `p`, given as repository/bucket/path/to/object
+func resolveObject(p string) (DownloadObject, error) {
+	rep, bucketName, prefix, err := ParseStoragePath(p)
+	if err != nil {
+		return DownloadObject{}, err
+	}
+	invalid := fmt.Errorf("invalid path %q, expected format repository/bucket/path/to/object", p)
+	if bucketName == "" || prefix == "" || strings.HasSuffix(prefix, "/") {
+		return DownloadObject{}, invalid
+	}
+
+	bucket, object, err := resolveBucket(rep, bucketName, prefix)
+	if err != nil {
+		return DownloadObject{}, err
+	}
+	if object == "" {
+		return DownloadObject{}, invalid
+	}
+	objects, err := listBucket(rep, bucket, object)
+	if err != nil {
+		return DownloadObject{}, err
+	}
+	idx := slices.IndexFunc(objects, func(meta api.Metadata) bool { return meta.Name == object })
+	if idx < 0 {
+		return DownloadObject{}, fmt.Errorf("no such object: %s", p)
+	}
+
+	return DownloadObject{
+		Repository: rep,
+		Bucket:     bucket.Name,
+		Object:     object,
+		Owner:      bucket.Owner,
+		ID:         objects[idx].ID,
+		Size:       objects[idx].Size,
+	}, nil
+}
+
+// Cat writes the decrypted content of the object `p`, given as repository/bucket/path/to/object, to `w`.
+// Only `length` bytes starting at `offset` are written. A negative offset counts from the end of the content,
+// and a negative length means the rest of the content. The object is read in the same chunks as in the filesystem,
+// so only the chunks that overlap the range are downloaded.
+var Cat = func(ctx context.Context, w io.Writer, p string, offset, length int64) error {
+	obj, err := resolveObject(p)
+	if err != nil {
+		return err
+	}
+	src, err := getDownloadSource(obj)
+	if err != nil {
+		return err
+	}
+	if src.symlink != "" {
+		return fmt.Errorf("object %s is a symbolic link to %s", p, src.symlink)
+	}
+
+	start := offset
+	if start < 0 {
+		start = max(src.size+offset, 0)
+	}
+	start = min(start, src.size)
+	end := src.size
+	if length >= 0 {
+		end = min(start+length, src.size)
+	}
+
+	nodes := append([]string{obj.Bucket}, strings.Split(obj.Object, "/")...)
+	objPath := obj.Repository.ForPath() + "/" + obj.Bucket + "/" + obj.Object
+	defer api.DeleteFileFromCache(obj.Repository, nodes, src.size)
+
+	for start < end {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		next := min((start/api.ChunkSize+1)*api.ChunkSize, end)
+		data, err := api.DownloadData(obj.Repository, nodes, objPath, obj.Owner, obj.ID, src.header, start, next, src.offset, src.size)
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) != next-start {
+			return fmt.Errorf("received %d bytes for range [%d, %d)", len(data), start, next)
+		}
+		if _, err = w.Write(data); err != nil {
+			return fmt.Errorf("failed to write output: %w", err)
+		}
+		start = next
+	}
+
+	return nil
+}
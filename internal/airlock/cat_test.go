@@ -0,0 +1,123 @@
+package airlock
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"sda-filesystem/internal/api"
+)
+
+func TestCat(t *testing.T) {
+	// Content of the object is generated on demand so that it can span several chunks
+	size := int64(2*api.ChunkSize + 100)
+	content := func(start, end int64) []byte {
+		data := make([]byte, end-start)
+		for i := range data {
+			data[i] = byte((start + int64(i)) % 251)
+		}
+
+		return data
+	}
+
+	var tests = []struct {
+		testname, path, symlink, errStr string
+		offset, length, start, end      int64
+		calls                           int
+	}{
+		{"OK_RANGE", "SD-Connect/bucket/dir/a.txt.c4gh", "", "", 10, 20, 10, 30, 1},
+		{"OK_ACROSS_CHUNKS", "SD-Connect/bucket/dir/a.txt.c4gh", "", "", api.ChunkSize - 5, 10, api.ChunkSize - 5, api.ChunkSize + 5, 2},
+		{"OK_SUFFIX", "SD-Connect/bucket/dir/a.txt.c4gh", "", "", -50, -1, size - 50, size, 1},
+		{"OK_PAST_END", "SD-Connect/bucket/dir/a.txt.c4gh", "", "", size + 10, 5, size, size, 0},
+		{"OK_SD_APPLY", "SD-Apply/example.com/dataset/e.txt.c4gh", "", "", 0, 5, 0, 5, 1},
+		{"FAIL_FOLDER", "SD-Connect/bucket/dir/", "", "invalid path \"SD-Connect/bucket/dir/\", expected format repository/bucket/path/to/object", 0, -1, 0, 0, 0},
+		{"FAIL_BUCKET", "SD-Apply/example.com/dataset", "", "invalid path \"SD-Apply/example.com/dataset\", expected format repository/bucket/path/to/object", 0, -1, 0, 0, 0},
+		{"FAIL_MISSING", "SD-Connect/bucket/dir/a.txt", "", "no such object: SD-Connect/bucket/dir/a.txt", 0, -1, 0, 0, 0},
+		{"FAIL_SYMLINK", "SD-Connect/bucket/dir/a.txt.c4gh", "../c.vcf", "object SD-Connect/bucket/dir/a.txt.c4gh is a symbolic link to ../c.vcf", 0, -1, 0, 0, 0},
+	}
+
+	mockStorage(t)
+	origGetFileHeader := api.GetFileHeader
+	origDownloadData := api.DownloadData
+	origDeleteFileFromCache := api.DeleteFileFromCache
+	defer func() {
+		api.GetFileHeader = origGetFileHeader
+		api.DownloadData = origDownloadData
+		api.DeleteFileFromCache = origDeleteFileFromCache
+	}()
+
+	api.DeleteFileFromCache = func(rep api.Repo, nodes []string, size int64) {}
+	api.GetFileHeader = func(rep api.Repo, bucket, object, owner, id string) (string, error) {
+		return "header", nil
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			objectSize := size
+			if tt.path == "SD-Apply/example.com/dataset/e.txt.c4gh" {
+				objectSize = api.CalculateDecryptedSize(50)
+			}
+			api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+				return api.CalculateEncryptedSize(size), map[string]string{api.MetaSymlink: tt.symlink}, nil
+			}
+			calls := 0
+			api.DownloadData = func(rep api.Repo, nodes []string, path, owner, fileID, header string,
+				startDecrypted, endDecrypted, oldOffset, fileSize int64,
+			) ([]byte, error) {
+				calls++
+				if fileSize != objectSize {
+					t.Errorf("Incorrect file size. Expected=%d, received=%d", objectSize, fileSize)
+				}
+				if startDecrypted/api.ChunkSize != (endDecrypted-1)/api.ChunkSize {
+					t.Errorf("Range [%d, %d) spans more than one chunk", startDecrypted, endDecrypted)
+				}
+
+				return content(startDecrypted, endDecrypted), nil
+			}
+
+			buf := &bytes.Buffer{}
+			err := Cat(context.Background(), buf, tt.path, tt.offset, tt.length)
+
+			switch {
+			case tt.errStr != "":
+				if err == nil {
+					t.Error("Function did not return error")
+				} else if err.Error() != tt.errStr {
+					t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+				}
+			case err != nil:
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			case !bytes.Equal(buf.Bytes(), content(tt.start, tt.end)):
+				t.Errorf("Incorrect content. Expected %d bytes starting at %d, received %d bytes", tt.end-tt.start, tt.start, buf.Len())
+			}
+			if calls != tt.calls {
+				t.Errorf("Incorrect number of requests. Expected=%d, received=%d", tt.calls, calls)
+			}
+		})
+	}
+}
+
+func TestCat_Cancelled(t *testing.T) {
+	mockStorage(t)
+	origGetFileHeader := api.GetFileHeader
+	origDeleteFileFromCache := api.DeleteFileFromCache
+	defer func() {
+		api.GetFileHeader = origGetFileHeader
+		api.DeleteFileFromCache = origDeleteFileFromCache
+	}()
+
+	api.DeleteFileFromCache = func(rep api.Repo, nodes []string, size int64) {}
+	api.GetFileHeader = func(rep api.Repo, bucket, object, owner, id string) (string, error) {
+		return "header", nil
+	}
+	api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+		return 1000, nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := Cat(ctx, &bytes.Buffer{}, "SD-Connect/bucket/dir/a.txt.c4gh", 0, -1); !errors.Is(err, context.Canceled) {
+		t.Errorf("Function returned incorrect error. Expected=%v, received=%v", context.Canceled, err)
+	}
+}
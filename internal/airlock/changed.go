@@ -0,0 +1,80 @@
+package airlock
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+
+	"sda-filesystem/internal/logs"
+)
+
+// ErrFileChanged is returned by UploadObject if the file was modified while it was being uploaded
+var ErrFileChanged = errors.New("file was modified during upload")
+
+// sourceState identifies the version of a file that is being uploaded
+type sourceState struct {
+	size     int64
+	modified time.Time
+	inode    uint64
+}
+
+// SetChangeRetries sets how many times a file that is modified during its upload is uploaded again
+// before it is reported as changed
+func SetChangeRetries(retries int) {
+	ai.changeRetries = max(retries, 0)
+}
+
+func newSourceState(info fs.FileInfo) sourceState {
+	state := sourceState{size: info.Size(), modified: info.ModTime()}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		state.inode = uint64(st.Ino) // #nosec G115
+	}
+
+	return state
+}
+
+// equal tells whether `s` and `other` describe the same version of a file. The modification times are compared
+// as instants, since the same time read twice may differ in its location or monotonic clock reading.
+func (s sourceState) equal(other sourceState) bool {
+	return s.size == other.size && s.modified.Equal(other.modified) && s.inode == other.inode
+}
+
+// statSource records the state of `file` before it is uploaded. Nil is returned for streams,
+// whose state cannot be compared afterwards.
+func statSource(file io.Reader) *sourceState {
+	f, ok := file.(interface{ Stat() (fs.FileInfo, error) })
+	if !ok {
+		return nil
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	state := newSourceState(info)
+
+	return &state
+}
+
+// sourceChanged tells whether `filename` is no longer the same file it was when `before` was recorded.
+// A file has changed if its size or modification time is different, or if it has been replaced by another file.
+var sourceChanged = func(filename string, before sourceState) bool {
+	info, err := os.Stat(filename)
+	if err != nil {
+		logs.Warningf("Could not check whether file %s changed during upload: %w", filename, err)
+
+		return true
+	}
+
+	after := newSourceState(info)
+	if !after.equal(before) {
+		logs.Debugf("File %s changed during upload: size %d -> %d, modified %s -> %s, inode %d -> %d",
+			filename, before.size, after.size, before.modified, after.modified, before.inode, after.inode)
+
+		return true
+	}
+
+	return false
+}
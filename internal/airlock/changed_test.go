@@ -0,0 +1,324 @@
+package airlock
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+
+	"github.com/neicnordic/crypt4gh/keys"
+)
+
+func TestSetChangeRetries(t *testing.T) {
+	origRetries := ai.changeRetries
+	defer func() { ai.changeRetries = origRetries }()
+
+	SetChangeRetries(3)
+	if ai.changeRetries != 3 {
+		t.Errorf("Incorrect number of retries. Expected=3, received=%d", ai.changeRetries)
+	}
+	SetChangeRetries(-1)
+	if ai.changeRetries != 0 {
+		t.Errorf("Incorrect number of retries. Expected=0, received=%d", ai.changeRetries)
+	}
+}
+
+func TestStatSource(t *testing.T) {
+	filename := t.TempDir() + "/file.txt"
+	if err := os.WriteFile(filename, []byte("content"), 0600); err != nil {
+		t.Fatalf("Failed to create file: %s", err.Error())
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open file: %s", err.Error())
+	}
+	defer file.Close()
+
+	state := statSource(file)
+	switch {
+	case state == nil:
+		t.Error("Function did not return state of file")
+	case state.size != 7 || state.inode == 0 || state.modified.IsZero():
+		t.Errorf("Function returned incorrect state %+v", *state)
+	}
+	if state := statSource(strings.NewReader("content")); state != nil {
+		t.Errorf("Function should not have returned state for a stream, received %+v", *state)
+	}
+}
+
+func TestSourceChanged(t *testing.T) {
+	origWarningf := logs.Warningf
+	defer func() { logs.Warningf = origWarningf }()
+	logs.Warningf = func(format string, args ...any) {}
+
+	modified := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	var tests = []struct {
+		testname string
+		change   func(filename string) error
+		changed  bool
+	}{
+		{"OK_UNCHANGED", func(filename string) error { return nil }, false},
+		{"OK_TIME", func(filename string) error {
+			return os.Chtimes(filename, modified.Add(time.Second), modified.Add(time.Second))
+		}, true},
+		{"OK_SIZE", func(filename string) error {
+			if err := os.WriteFile(filename, []byte("longer content"), 0600); err != nil {
+				return err
+			}
+
+			return os.Chtimes(filename, modified, modified)
+		}, true},
+		{"OK_REPLACED", func(filename string) error {
+			if err := os.WriteFile(filename+".new", []byte("content"), 0600); err != nil {
+				return err
+			}
+			if err := os.Chtimes(filename+".new", modified, modified); err != nil {
+				return err
+			}
+
+			return os.Rename(filename+".new", filename)
+		}, true},
+		{"OK_REMOVED", os.Remove, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			filename := t.TempDir() + "/file.txt"
+			if err := os.WriteFile(filename, []byte("content"), 0600); err != nil {
+				t.Fatalf("Failed to create file: %s", err.Error())
+			}
+			if err := os.Chtimes(filename, modified, modified); err != nil {
+				t.Fatalf("Failed to change file times: %s", err.Error())
+			}
+			info, err := os.Stat(filename)
+			if err != nil {
+				t.Fatalf("Failed to stat file: %s", err.Error())
+			}
+
+			before := newSourceState(info)
+			if err := tt.change(filename); err != nil {
+				t.Fatalf("Failed to change file: %s", err.Error())
+			}
+			if changed := sourceChanged(filename, before); changed != tt.changed {
+				t.Errorf("Function returned incorrect value. Expected=%t, received=%t", tt.changed, changed)
+			}
+		})
+	}
+}
+
+func TestSourceStateEqual(t *testing.T) {
+	modified := time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+	state := sourceState{size: 7, modified: modified, inode: 42}
+
+	var tests = []struct {
+		testname string
+		other    sourceState
+		equal    bool
+	}{
+		{"OK_SAME", sourceState{size: 7, modified: modified, inode: 42}, true},
+		{"OK_LOCATION", sourceState{size: 7, modified: modified.In(time.FixedZone("EET", 2*60*60)), inode: 42}, true},
+		{"OK_TIME", sourceState{size: 7, modified: modified.Add(time.Nanosecond), inode: 42}, false},
+		{"OK_SIZE", sourceState{size: 8, modified: modified, inode: 42}, false},
+		{"OK_INODE", sourceState{size: 7, modified: modified, inode: 43}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			if equal := state.equal(tt.other); equal != tt.equal {
+				t.Errorf("Function returned incorrect value. Expected=%t, received=%t", tt.equal, equal)
+			}
+		})
+	}
+
+	now := time.Now()
+	if !(sourceState{modified: now}).equal(sourceState{modified: now.Round(0)}) {
+		t.Error("Times with and without a monotonic clock reading should be equal")
+	}
+}
+
+func TestUploadObject_Changed(t *testing.T) {
+	origGetFileDetails := getFileDetails
+	origFindataUpload := api.FindataUpload
+	origPostHeader := api.PostHeader
+	origUploadObject := api.UploadObject
+	origVerifyUpload := verifyUpload
+	origRemoveObject := removeObject
+	origSourceChanged := sourceChanged
+	origPublicKey := ai.publicKey
+	origErrorf := logs.Errorf
+	defer func() {
+		getFileDetails = origGetFileDetails
+		api.FindataUpload = origFindataUpload
+		api.PostHeader = origPostHeader
+		api.UploadObject = origUploadObject
+		verifyUpload = origVerifyUpload
+		removeObject = origRemoveObject
+		sourceChanged = origSourceChanged
+		ai.publicKey = origPublicKey
+		logs.Errorf = origErrorf
+	}()
+
+	publicKey, _, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	ai.publicKey = publicKey
+
+	filename := t.TempDir() + "/file.txt"
+	if err := os.WriteFile(filename, []byte("content"), 0600); err != nil {
+		t.Fatalf("Failed to create file: %s", err.Error())
+	}
+	api.FindataUpload = func() bool {
+		return false
+	}
+	api.PostHeader = func(header []byte, bucket, object string) error {
+		return nil
+	}
+	api.UploadObject = func(
+		ctx context.Context,
+		body io.Reader,
+		rep api.Repo,
+		bucket, object string,
+		segmentSize int64,
+		concurrency int,
+		metadata map[string]string,
+	) error {
+		_, err := io.Copy(io.Discard, body)
+
+		return err
+	}
+	verifyUpload = func(bucket, object string, objectSize int64) error {
+		return nil
+	}
+	logs.Errorf = func(format string, args ...any) {}
+
+	var tests = []struct {
+		testname string
+		details  func(filename string) (io.ReadCloser, int64, error)
+		changed  bool
+		errStr   string
+	}{
+		{
+			"OK", origGetFileDetails, false, "",
+		},
+		{
+			"FAIL_STAT", origGetFileDetails, true,
+			fmt.Sprintf("uploading file %s failed: file was modified during upload", filename),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			getFileDetails = tt.details
+			sourceChanged = func(name string, before sourceState) bool {
+				if name != filename || before.size != 7 {
+					t.Errorf("sourceChanged() received incorrect file %s or state %+v", name, before)
+				}
+
+				return tt.changed
+			}
+			var removed []string
+			removeObject = func(bucket, object string) {
+				removed = append(removed, object)
+			}
+
+			err := UploadObject(context.Background(), filename, "file.txt.c4gh", "bucket", nil)
+			switch {
+			case tt.errStr == "":
+				if err != nil {
+					t.Errorf("Function returned unexpected error: %s", err.Error())
+				}
+				if len(removed) > 0 {
+					t.Errorf("Function should not have removed objects, removed %v", removed)
+				}
+			case err == nil:
+				t.Error("Function did not return error")
+			case err.Error() != tt.errStr:
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			case !slices.Equal(removed, []string{"file.txt.c4gh"}):
+				t.Errorf("Function removed incorrect objects %v", removed)
+			}
+		})
+	}
+}
+
+func TestUpload_Changed(t *testing.T) {
+	origGetPublicKey := api.GetPublicKey
+	origUploadObject := UploadObject
+	origRetries := ai.changeRetries
+	origError := logs.Error
+	origWarningf := logs.Warningf
+	defer func() {
+		api.GetPublicKey = origGetPublicKey
+		UploadObject = origUploadObject
+		ai.changeRetries = origRetries
+		logs.Error = origError
+		logs.Warningf = origWarningf
+	}()
+
+	api.GetPublicKey = func() ([32]byte, error) {
+		return [32]byte{}, nil
+	}
+	logs.Error = func(err error) {}
+	logs.Warningf = func(format string, args ...any) {}
+
+	var tests = []struct {
+		testname, errStr string
+		retries          int
+		completed        []string
+		attempts         map[string]int
+	}{
+		{
+			"FAIL_NO_RETRIES", "2 file(s) were modified during upload and were not exported: always.txt, once.txt",
+			0, []string{"fine.txt"}, map[string]int{"fine.txt": 1, "once.txt": 1, "always.txt": 1},
+		},
+		{
+			"FAIL_RETRIES", "1 file(s) were modified during upload and were not exported: always.txt",
+			2, []string{"fine.txt", "once.txt"}, map[string]int{"fine.txt": 1, "once.txt": 2, "always.txt": 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			var mu sync.Mutex
+			attempts := make(map[string]int)
+			UploadObject = func(ctx context.Context, filename, object, bucket string, metadata map[string]string) error {
+				mu.Lock()
+				defer mu.Unlock()
+				attempts[filename]++
+				if filename == "always.txt" || (filename == "once.txt" && attempts[filename] == 1) {
+					return fmt.Errorf("uploading file %s failed: %w", filename, ErrFileChanged)
+				}
+
+				return nil
+			}
+
+			SetChangeRetries(tt.retries)
+			set := UploadSet{
+				Bucket:  "bucket",
+				Files:   []string{"fine.txt", "once.txt", "always.txt"},
+				Objects: []string{"fine.txt.c4gh", "once.txt.c4gh", "always.txt.c4gh"},
+			}
+			completed, err := Upload(context.Background(), set, nil)
+			if err == nil {
+				t.Error("Function did not return error")
+			} else if err.Error() != tt.errStr {
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+			if !slices.Equal(completed, tt.completed) {
+				t.Errorf("Function returned incorrect completed files. Expected=%v, received=%v", tt.completed, completed)
+			}
+			if fmt.Sprint(attempts) != fmt.Sprint(tt.attempts) {
+				t.Errorf("Files were uploaded incorrect number of times. Expected=%v, received=%v", tt.attempts, attempts)
+			}
+		})
+	}
+}
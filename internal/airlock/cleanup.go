@@ -0,0 +1,34 @@
+package airlock
+
+import (
+	"fmt"
+	"slices"
+
+	"sda-filesystem/internal/api"
+)
+
+// FindOrphanedHeaders returns the objects that have a header in Vault but do not exist in SD Connect `bucket`.
+// Such headers are left behind by uploads that failed before the header could be removed.
+var FindOrphanedHeaders = func(bucket string) ([]string, error) {
+	headers, err := api.ListHeaders(bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	path := api.SDConnect.ForPath() + "/" + api.GetProjectName() + "/" + bucket
+	objects, err := api.GetObjects(api.SDConnect, bucket, path, "", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list objects in bucket %s: %w", bucket, err)
+	}
+	existing := make(map[string]bool, len(objects))
+	for i := range objects {
+		existing[objects[i].Name] = true
+	}
+
+	orphans := slices.DeleteFunc(slices.Clone(headers), func(object string) bool {
+		return existing[object]
+	})
+	slices.Sort(orphans)
+
+	return orphans, nil
+}
@@ -0,0 +1,56 @@
+package airlock
+
+import (
+	"reflect"
+	"testing"
+
+	"sda-filesystem/internal/api"
+)
+
+func TestFindOrphanedHeaders(t *testing.T) {
+	origListHeaders := api.ListHeaders
+	origGetObjects := api.GetObjects
+	defer func() {
+		api.ListHeaders = origListHeaders
+		api.GetObjects = origGetObjects
+	}()
+
+	var tests = []struct {
+		testname, errStr       string
+		expected               []string
+		listErr, getObjectsErr error
+	}{
+		{"OK", "", []string{"dir/failed.c4gh", "failed.c4gh"}, nil, nil},
+		{"FAIL_LIST", errExpected.Error(), nil, errExpected, nil},
+		{"FAIL_OBJECTS", "failed to list objects in bucket bucket: " + errExpected.Error(), nil, nil, errExpected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			api.ListHeaders = func(bucket string) ([]string, error) {
+				if bucket != "bucket" {
+					t.Errorf("api.ListHeaders() received incorrect bucket. Expected=bucket, received=%s", bucket)
+				}
+
+				return []string{"file.c4gh", "failed.c4gh", "dir/file.c4gh", "dir/failed.c4gh"}, tt.listErr
+			}
+			api.GetObjects = func(rep api.Repo, bucket, path, owner, prefix string) ([]api.Metadata, error) {
+				return []api.Metadata{{Name: "dir/file.c4gh"}, {Name: "file.c4gh"}, {Name: "no-header.txt"}}, tt.getObjectsErr
+			}
+
+			orphans, err := FindOrphanedHeaders("bucket")
+			switch {
+			case tt.errStr != "":
+				if err == nil {
+					t.Error("Function did not return error")
+				} else if err.Error() != tt.errStr {
+					t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+				}
+			case err != nil:
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			case !reflect.DeepEqual(orphans, tt.expected):
+				t.Errorf("Function returned incorrect headers\nExpected=%v\nReceived=%v", tt.expected, orphans)
+			}
+		})
+	}
+}
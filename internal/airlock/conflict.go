@@ -0,0 +1,142 @@
+package airlock
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+)
+
+// ConflictPolicy determines what is done to a file whose object already exists in the bucket
+type ConflictPolicy string
+
+const (
+	ConflictSkip      ConflictPolicy = "skip"      // The file is not uploaded
+	ConflictOverwrite ConflictPolicy = "overwrite" // The existing object is replaced
+	ConflictRename    ConflictPolicy = "rename"    // The file is uploaded under a new, unused name
+	ConflictFail      ConflictPolicy = "fail"      // The whole export is stopped
+)
+
+// Actions taken on the files of an upload set when resolving conflicts
+const (
+	ActionNew         = "new"
+	ActionSkipped     = "skipped"
+	ActionOverwritten = "overwritten"
+	ActionRenamed     = "renamed"
+)
+
+// ConflictResult tells what happens to a file once conflicts with existing objects have been resolved
+type ConflictResult struct {
+	File   string `json:"file"`
+	Object string `json:"object"` // Object the file will be uploaded as, empty if the file is skipped
+	Action string `json:"action"`
+}
+
+// ParseConflictPolicy checks that `policy` is one of the supported policies
+func ParseConflictPolicy(policy string) (ConflictPolicy, error) {
+	switch p := ConflictPolicy(strings.ToLower(policy)); p {
+	case ConflictSkip, ConflictOverwrite, ConflictRename, ConflictFail:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("invalid conflict policy %q, should be one of skip, overwrite, rename or fail", policy)
+}
+
+// ResolveConflicts applies `policy` to each file in `set` whose object already exists in the bucket.
+// Skipped files are removed from `set` and renamed files get a new object name. The result of each file
+// in the original set is returned in order. With ConflictFail, an error is returned if any object exists.
+var ResolveConflicts = func(set *UploadSet, policy ConflictPolicy) ([]ConflictResult, error) {
+	return resolveConflicts(set, policy, false)
+}
+
+// ResolveArchiveConflicts is ResolveConflicts for a set whose objects are archives. The index of each archive
+// is resolved together with it, so an archive conflicts if either of the objects exists, and a renamed
+// archive gets a name under which neither of them exists.
+var ResolveArchiveConflicts = func(set *UploadSet, policy ConflictPolicy) ([]ConflictResult, error) {
+	return resolveConflicts(set, policy, true)
+}
+
+func resolveConflicts(set *UploadSet, policy ConflictPolicy, archives bool) ([]ConflictResult, error) {
+	existingObjects, err := listObjects(set.Bucket)
+	if err != nil {
+		return nil, err
+	}
+
+	taken := make(map[string]bool, len(existingObjects)+len(set.Objects))
+	for i := range existingObjects {
+		taken[existingObjects[i].Name] = true
+	}
+	exists := make([]bool, len(set.Objects))
+	for i := range set.Objects {
+		for _, object := range uploadedObjects(set.Objects[i], archives) {
+			exists[i] = exists[i] || taken[object]
+			taken[object] = true
+		}
+	}
+
+	results := make([]ConflictResult, 0, len(set.Files))
+	kept := UploadSet{Bucket: set.Bucket}
+	for i := range set.Files {
+		result := ConflictResult{File: set.Files[i], Object: set.Objects[i], Action: ActionNew}
+		if exists[i] {
+			switch policy {
+			case ConflictSkip:
+				result.Object, result.Action = "", ActionSkipped
+				logs.Debugf("Skipping file %s, object %s already exists", set.Files[i], set.Objects[i])
+			case ConflictOverwrite:
+				result.Action = ActionOverwritten
+			case ConflictRename:
+				result.Object, result.Action = renameObject(set.Objects[i], taken, archives), ActionRenamed
+				for _, object := range uploadedObjects(result.Object, archives) {
+					taken[object] = true
+				}
+				logs.Debugf("Renaming object %s to %s", set.Objects[i], result.Object)
+			case ConflictFail:
+				return nil, fmt.Errorf("object %s already exists in bucket %s", set.Objects[i], set.Bucket)
+			default:
+				return nil, fmt.Errorf("invalid conflict policy %q", policy)
+			}
+		}
+
+		results = append(results, result)
+		if result.Action != ActionSkipped {
+			kept.Files = append(kept.Files, result.File)
+			kept.Objects = append(kept.Objects, result.Object)
+			kept.Exists = append(kept.Exists, false)
+		}
+	}
+	*set = kept
+
+	return results, nil
+}
+
+// uploadedObjects returns the objects that are written when `object` is uploaded, which for an archive include its index
+func uploadedObjects(object string, archive bool) []string {
+	if archive {
+		return []string{object, api.ArchiveIndexObject(object)}
+	}
+
+	return []string{object}
+}
+
+// renameObject adds the first free counter to the name of `object`, before the extensions of the file,
+// e.g. dir/file.txt.c4gh becomes dir/file_1.txt.c4gh. A leading dot belongs to the name, so .bashrc.c4gh
+// becomes .bashrc_1.c4gh. The counter is free when none of the objects uploaded under the new name are taken.
+func renameObject(object string, taken map[string]bool, archive bool) string {
+	dir, name := path.Split(object)
+	hidden := name[:len(name)-len(strings.TrimLeft(name, "."))]
+	base, ext, _ := strings.Cut(name[len(hidden):], ".")
+	if ext != "" {
+		ext = "." + ext
+	}
+
+	for i := 1; ; i++ {
+		renamed := fmt.Sprintf("%s%s%s_%d%s", dir, hidden, base, i, ext)
+		if !slices.ContainsFunc(uploadedObjects(renamed, archive), func(o string) bool { return taken[o] }) {
+			return renamed
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package airlock
+
+import (
+	"reflect"
+	"testing"
+
+	"sda-filesystem/internal/api"
+)
+
+func TestParseConflictPolicy(t *testing.T) {
+	var tests = []struct {
+		testname, policy string
+		expected         ConflictPolicy
+		errStr           string
+	}{
+		{"OK_SKIP", "skip", ConflictSkip, ""},
+		{"OK_OVERWRITE", "Overwrite", ConflictOverwrite, ""},
+		{"OK_RENAME", "rename", ConflictRename, ""},
+		{"OK_FAIL", "FAIL", ConflictFail, ""},
+		{"FAIL_INVALID", "ask", "", "invalid conflict policy \"ask\", should be one of skip, overwrite, rename or fail"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			policy, err := ParseConflictPolicy(tt.policy)
+			switch {
+			case tt.errStr != "":
+				if err == nil {
+					t.Error("Function did not return error")
+				} else if err.Error() != tt.errStr {
+					t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+				}
+			case err != nil:
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			case policy != tt.expected:
+				t.Errorf("Function returned incorrect policy. Expected=%s, received=%s", tt.expected, policy)
+			}
+		})
+	}
+}
+
+func TestResolveConflicts(t *testing.T) {
+	var tests = []struct {
+		testname        string
+		policy          ConflictPolicy
+		expectedSet     UploadSet
+		expectedResults []ConflictResult
+	}{
+		{
+			"OK_SKIP", ConflictSkip,
+			UploadSet{
+				Bucket:  "bucket",
+				Files:   []string{"dir/new.txt"},
+				Objects: []string{"dir/new.txt.c4gh"},
+				Exists:  []bool{false},
+			},
+			[]ConflictResult{
+				{File: "dir/file.txt", Object: "", Action: ActionSkipped},
+				{File: "dir/new.txt", Object: "dir/new.txt.c4gh", Action: ActionNew},
+				{File: "data.tar.gz", Object: "", Action: ActionSkipped},
+			},
+		},
+		{
+			"OK_OVERWRITE", ConflictOverwrite,
+			UploadSet{
+				Bucket:  "bucket",
+				Files:   []string{"dir/file.txt", "dir/new.txt", "data.tar.gz"},
+				Objects: []string{"dir/file.txt.c4gh", "dir/new.txt.c4gh", "data.tar.gz.c4gh"},
+				Exists:  []bool{false, false, false},
+			},
+			[]ConflictResult{
+				{File: "dir/file.txt", Object: "dir/file.txt.c4gh", Action: ActionOverwritten},
+				{File: "dir/new.txt", Object: "dir/new.txt.c4gh", Action: ActionNew},
+				{File: "data.tar.gz", Object: "data.tar.gz.c4gh", Action: ActionOverwritten},
+			},
+		},
+		{
+			"OK_RENAME", ConflictRename,
+			UploadSet{
+				Bucket:  "bucket",
+				Files:   []string{"dir/file.txt", "dir/new.txt", "data.tar.gz"},
+				Objects: []string{"dir/file_2.txt.c4gh", "dir/new.txt.c4gh", "data_1.tar.gz.c4gh"},
+				Exists:  []bool{false, false, false},
+			},
+			[]ConflictResult{
+				{File: "dir/file.txt", Object: "dir/file_2.txt.c4gh", Action: ActionRenamed},
+				{File: "dir/new.txt", Object: "dir/new.txt.c4gh", Action: ActionNew},
+				{File: "data.tar.gz", Object: "data_1.tar.gz.c4gh", Action: ActionRenamed},
+			},
+		},
+	}
+
+	origGetObjects := api.GetObjects
+	defer func() { api.GetObjects = origGetObjects }()
+
+	api.GetObjects = func(rep api.Repo, bucket, path, owner, prefix string) ([]api.Metadata, error) {
+		if bucket != "bucket" {
+			t.Errorf("api.GetObjects() received incorrect bucket. Expected=bucket, received=%s", bucket)
+		}
+
+		return []api.Metadata{
+			{Name: "data.tar.gz.c4gh"},
+			{Name: "dir/file.txt.c4gh"},
+			{Name: "dir/file_1.txt.c4gh"},
+		}, nil
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			set := UploadSet{
+				Bucket:  "bucket",
+				Files:   []string{"dir/file.txt", "dir/new.txt", "data.tar.gz"},
+				Objects: []string{"dir/file.txt.c4gh", "dir/new.txt.c4gh", "data.tar.gz.c4gh"},
+				Exists:  []bool{true, false, true},
+			}
+			results, err := ResolveConflicts(&set, tt.policy)
+			switch {
+			case err != nil:
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			case !reflect.DeepEqual(results, tt.expectedResults):
+				t.Errorf("Function returned incorrect results\nExpected=%+v\nReceived=%+v", tt.expectedResults, results)
+			case !reflect.DeepEqual(set, tt.expectedSet):
+				t.Errorf("Function modified set incorrectly\nExpected=%+v\nReceived=%+v", tt.expectedSet, set)
+			}
+		})
+	}
+}
+
+func TestResolveConflicts_Error(t *testing.T) {
+	var tests = []struct {
+		testname, errStr string
+		policy           ConflictPolicy
+		getObjectsErr    error
+	}{
+		{
+			"FAIL_POLICY", "object file.txt.c4gh already exists in bucket bucket",
+			ConflictFail, nil,
+		},
+		{
+			"FAIL_INVALID_POLICY", "invalid conflict policy \"ask\"",
+			"ask", nil,
+		},
+		{
+			"FAIL_GET_OBJECTS", "could not determine if export will overwrite data: " + errExpected.Error(),
+			ConflictSkip, errExpected,
+		},
+	}
+
+	origGetObjects := api.GetObjects
+	defer func() { api.GetObjects = origGetObjects }()
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			api.GetObjects = func(rep api.Repo, bucket, path, owner, prefix string) ([]api.Metadata, error) {
+				return []api.Metadata{{Name: "file.txt.c4gh"}}, tt.getObjectsErr
+			}
+
+			set := UploadSet{
+				Bucket:  "bucket",
+				Files:   []string{"new.txt", "file.txt"},
+				Objects: []string{"new.txt.c4gh", "file.txt.c4gh"},
+				Exists:  []bool{false, false},
+			}
+			if _, err := ResolveConflicts(&set, tt.policy); err == nil {
+				t.Error("Function did not return error")
+			} else if err.Error() != tt.errStr {
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+		})
+	}
+}
+
+func TestRenameObject(t *testing.T) {
+	taken := map[string]bool{"file_1.txt.c4gh": true, "data_1.tar.index.json.c4gh": true}
+	var tests = []struct {
+		object, expected string
+		archive          bool
+	}{
+		{"file.txt.c4gh", "file_2.txt.c4gh", false},
+		{"dir.v2/archive.tar.c4gh", "dir.v2/archive_1.tar.c4gh", false},
+		{"noext", "noext_1", false},
+		{".bashrc.c4gh", ".bashrc_1.c4gh", false},
+		{"dir/.config", "dir/.config_1", false},
+		{"a.tar.gz", "a_1.tar.gz", false},
+		{"data.tar.c4gh", "data_1.tar.c4gh", false},
+		{"data.tar.c4gh", "data_2.tar.c4gh", true},
+	}
+
+	for _, tt := range tests {
+		if received := renameObject(tt.object, taken, tt.archive); received != tt.expected {
+			t.Errorf("Function returned incorrect name for %s. Expected=%s, received=%s", tt.object, tt.expected, received)
+		}
+	}
+}
+
+func TestResolveArchiveConflicts(t *testing.T) {
+	var tests = []struct {
+		testname        string
+		policy          ConflictPolicy
+		existing        []api.Metadata
+		expectedResults []ConflictResult
+	}{
+		{
+			"OK_NEW", ConflictRename,
+			[]api.Metadata{{Name: "other.tar.c4gh"}},
+			[]ConflictResult{{File: "data", Object: "data.tar.c4gh", Action: ActionNew}},
+		},
+		{
+			"OK_INDEX_EXISTS", ConflictOverwrite,
+			[]api.Metadata{{Name: "data.tar.index.json.c4gh"}},
+			[]ConflictResult{{File: "data", Object: "data.tar.c4gh", Action: ActionOverwritten}},
+		},
+		{
+			"OK_RENAME", ConflictRename,
+			[]api.Metadata{{Name: "data.tar.c4gh"}, {Name: "data.tar.index.json.c4gh"}, {Name: "data_1.tar.index.json.c4gh"}},
+			[]ConflictResult{{File: "data", Object: "data_2.tar.c4gh", Action: ActionRenamed}},
+		},
+	}
+
+	origGetObjects := api.GetObjects
+	defer func() { api.GetObjects = origGetObjects }()
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			api.GetObjects = func(rep api.Repo, bucket, path, owner, prefix string) ([]api.Metadata, error) {
+				return tt.existing, nil
+			}
+
+			set := UploadSet{Bucket: "bucket", Files: []string{"data"}, Objects: []string{"data.tar.c4gh"}, Exists: []bool{false}}
+			results, err := ResolveArchiveConflicts(&set, tt.policy)
+			switch {
+			case err != nil:
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			case !reflect.DeepEqual(results, tt.expectedResults):
+				t.Errorf("Function returned incorrect results\nExpected=%+v\nReceived=%+v", tt.expectedResults, results)
+			case !reflect.DeepEqual(set.Objects, []string{tt.expectedResults[0].Object}):
+				t.Errorf("Function modified set incorrectly\nExpected=%q\nReceived=%q", tt.expectedResults[0].Object, set.Objects)
+			}
+		})
+	}
+}
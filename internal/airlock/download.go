@@ -0,0 +1,464 @@
+package airlock
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// PartSuffix is added to the name of a file while it is being downloaded
+const PartSuffix = ".part"
+
+// DownloadObject is an object in SD Connect or SD Apply that is downloaded to `Path`
+type DownloadObject struct {
+	Repository api.Repo `json:"repository"`
+	Bucket     string   `json:"bucket"`
+	Object     string   `json:"object"`
+	Owner      string   `json:"owner,omitempty"` // Project that shared the bucket, or the service of an SD Apply dataset
+	ID         string   `json:"id,omitempty"`    // File ID of an SD Apply object
+	Size       int64    `json:"size"`            // Size of the object in storage
+	Path       string   `json:"path"`
+	Dir        string   `json:"dir"` // Folder under which the objects are downloaded
+}
+
+// StoragePath returns the object as repository/bucket/path/to/object, with the bucket named as it is in the filesystem
+func (obj DownloadObject) StoragePath() string {
+	return obj.Repository.ForPath() + "/" + datasetName(obj.Bucket) + "/" + obj.Object
+}
+
+// downloadSource is an object along with its header and the data needed to read it
+type downloadSource struct {
+	DownloadObject
+	header   string
+	offset   int64 // Where the body of the object begins, if the header is in the object
+	size     int64 // Size of the decrypted content
+	checksum string
+	modified string
+	mode     string
+	symlink  string
+}
+
+// ParseDownloadSource splits `source`, given as repository/bucket[/prefix], into its parts
+func ParseDownloadSource(source string) (api.Repo, string, string, error) {
+	rep, bucket, prefix, err := ParseStoragePath(source)
+	if err != nil || bucket == "" {
+		return "", "", "", fmt.Errorf("invalid source %q, expected format repository/bucket/path/to/prefix, where repository is one of %s",
+			source, strings.Join(repositoryNames(), ", "))
+	}
+
+	return rep, bucket, prefix, nil
+}
+
+// ResolveDownload lists the objects of `bucket` in repository `rep` whose names begin with the folder or object `prefix`.
+// An object is downloaded under `dir` with the same path relative to the parent of `prefix` that it has in the bucket,
+// without the .c4gh extension. The whole bucket is downloaded into a folder named after it.
+var ResolveDownload = func(rep api.Repo, bucket, prefix, dir string) ([]DownloadObject, error) {
+	meta, prefix, err := resolveBucket(rep, bucket, prefix)
+	if err != nil {
+		return nil, err
+	}
+	bucket, owner := meta.Name, meta.Owner
+
+	objects, err := api.GetObjects(rep, bucket, rep.ForPath()+"/"+bucket, owner, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	base := datasetName(bucket) + "/"
+	if prefix != "" {
+		base = path.Dir(strings.TrimSuffix(prefix, "/")) + "/"
+		if base == "./" {
+			base = ""
+		}
+	}
+
+	downloads := []DownloadObject{}
+	for i := range objects {
+		name := objects[i].Name
+		if strings.HasSuffix(name, "/") {
+			continue
+		}
+		// The prefix must match a whole object or folder
+		if prefix != "" && name != prefix && !strings.HasPrefix(name, strings.TrimSuffix(prefix, "/")+"/") {
+			continue
+		}
+
+		relative := strings.TrimSuffix(strings.TrimPrefix(name, base), ".c4gh")
+		if prefix == "" {
+			relative = base + strings.TrimSuffix(name, ".c4gh")
+		}
+		// Object names such as ../file would be written outside of `dir`
+		if !filepath.IsLocal(filepath.FromSlash(relative)) {
+			logs.Warningf("Skipping object %q in %s/%s with an invalid name", name, rep.ForPath(), datasetName(bucket))
+
+			continue
+		}
+		downloads = append(downloads, DownloadObject{
+			Repository: rep,
+			Bucket:     bucket,
+			Object:     name,
+			Owner:      owner,
+			ID:         objects[i].ID,
+			Size:       objects[i].Size,
+			Path:       filepath.Join(dir, filepath.FromSlash(relative)),
+			Dir:        dir,
+		})
+	}
+	if len(downloads) == 0 {
+		return nil, fmt.Errorf("no objects found in %s/%s with prefix %q", rep.ForPath(), datasetName(bucket), prefix)
+	}
+
+	return downloads, nil
+}
+
+// Download downloads and decrypts `objects` using `parallel` workers. A download that was interrupted continues
+// from where it stopped. Objects that have a checksum in their metadata are verified once they have been downloaded.
+// Files that already exist are skipped if they match their object, and otherwise replaced only if `overwrite` is set.
+// Symbolic links are created once all the files have been written, so that no file is written through them.
+// The error of each object is returned in the same order as `objects`, nil for those that were downloaded or skipped.
+var Download = func(ctx context.Context, objects []DownloadObject, parallel int, overwrite bool) ([]error, error) {
+	var pt *progressTracker
+	if ai.progressFun != nil {
+		sizes := make(map[string]int64, len(objects))
+		for i := range objects {
+			sizes[objects[i].Path] = api.CalculateDecryptedSize(objects[i].Size)
+		}
+		pt = newSizedTracker(ai.progressFun, sizes)
+	}
+
+	errs := make([]error, len(objects))
+	links := make([]string, len(objects))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(parallel, 1))
+	for i := range objects {
+		g.Go(func() error {
+			if errs[i] = gctx.Err(); errs[i] != nil {
+				return nil
+			}
+			link, err := downloadObject(gctx, objects[i], overwrite, pt)
+			if err != nil {
+				if gctx.Err() == nil {
+					logs.Errorf("Downloading %s failed: %w", objects[i].Object, err)
+				}
+				errs[i] = err
+
+				return nil
+			}
+			links[i] = link
+			if pt != nil && link == "" {
+				pt.finish(objects[i].Path)
+			}
+
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	for i := range objects {
+		if links[i] == "" || ctx.Err() != nil {
+			continue
+		}
+		if err := downloadSymlink(objects[i], links[i], overwrite); err != nil {
+			logs.Errorf("Downloading %s failed: %w", objects[i].Object, err)
+			errs[i] = err
+
+			continue
+		}
+		if pt != nil {
+			pt.finish(objects[i].Path)
+		}
+	}
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if ctx.Err() != nil {
+		return errs, fmt.Errorf("download cancelled: %w", ctx.Err())
+	}
+	if failed > 0 {
+		return errs, fmt.Errorf("%d of %d object(s) could not be downloaded", failed, len(objects))
+	}
+
+	return errs, nil
+}
+
+// downloadObject downloads a single object to obj.Path through a temporary file with PartSuffix.
+// If the object is a symbolic link, nothing is written and the target of the link is returned instead.
+var downloadObject = func(ctx context.Context, obj DownloadObject, overwrite bool, pt *progressTracker) (string, error) {
+	src, err := getDownloadSource(obj)
+	if err != nil {
+		return "", err
+	}
+	if src.symlink != "" {
+		return src.symlink, nil
+	}
+	if err = createParent(obj); err != nil {
+		return "", err
+	}
+
+	return "", src.download(ctx, overwrite, pt)
+}
+
+// createParent creates the folder of obj.Path after checking that it does not lead outside of obj.Dir through symbolic links
+func createParent(obj DownloadObject) error {
+	if err := os.MkdirAll(obj.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create folder %s: %w", obj.Dir, err)
+	}
+	if err := insideDir(obj.Dir, filepath.Dir(obj.Path)); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(obj.Path), 0o755); err != nil {
+		return fmt.Errorf("failed to create folder for %s: %w", obj.Path, err)
+	}
+
+	return nil
+}
+
+// insideDir checks that `file` is inside `dir` once the symbolic links on its path have been followed.
+// The part of the path that does not exist yet is taken as it is.
+func insideDir(dir, file string) error {
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve folder %s: %w", dir, err)
+	}
+
+	existing, rest := filepath.Clean(file), ""
+	realFile, err := filepath.EvalSymlinks(existing)
+	for errors.Is(err, fs.ErrNotExist) && filepath.Dir(existing) != existing {
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = filepath.Dir(existing)
+		realFile, err = filepath.EvalSymlinks(existing)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", file, err)
+	}
+
+	rel, err := filepath.Rel(realDir, filepath.Join(realFile, rest))
+	if err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("%s is outside of %s", file, dir)
+	}
+
+	return nil
+}
+
+// download writes the decrypted content of the object to obj.Path
+func (src downloadSource) download(ctx context.Context, overwrite bool, pt *progressTracker) error {
+	obj := src.DownloadObject
+
+	if info, err := os.Lstat(obj.Path); err == nil {
+		if info.Mode().IsRegular() && info.Size() == src.size && src.matches(obj.Path) {
+			logs.Infof("File %s is already downloaded", obj.Path)
+
+			return nil
+		}
+		if !overwrite {
+			return fmt.Errorf("file %s already exists", obj.Path)
+		}
+	}
+
+	part := obj.Path + PartSuffix
+	// A symbolic link in place of the file must not redirect the download elsewhere
+	file, err := os.OpenFile(part, os.O_CREATE|os.O_WRONLY|syscall.O_NOFOLLOW, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	// Continue from the last complete chunk of an earlier attempt
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	start := min(info.Size(), src.size) / api.ChunkSize * api.ChunkSize
+	if start > 0 {
+		logs.Infof("Resuming download of %s at %d bytes", obj.Object, start)
+	}
+	if err = file.Truncate(start); err != nil {
+		return fmt.Errorf("failed to truncate file: %w", err)
+	}
+	if _, err = file.Seek(start, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek file: %w", err)
+	}
+	if pt != nil {
+		pt.add(obj.Path, start)
+	}
+
+	nodes := append([]string{obj.Bucket}, strings.Split(obj.Object, "/")...)
+	objPath := obj.Repository.ForPath() + "/" + obj.Bucket + "/" + obj.Object
+	defer api.DeleteFileFromCache(obj.Repository, nodes, src.size)
+
+	for offset := start; offset < src.size; offset += api.ChunkSize {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		end := min(offset+api.ChunkSize, src.size)
+		data, err := api.DownloadData(obj.Repository, nodes, objPath, obj.Owner, obj.ID, src.header, offset, end, src.offset, src.size)
+		if err != nil {
+			return err
+		}
+		if int64(len(data)) != end-offset {
+			return fmt.Errorf("received %d bytes for range [%d, %d)", len(data), offset, end)
+		}
+		if _, err = file.Write(data); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+		if pt != nil {
+			pt.add(obj.Path, int64(len(data)))
+		}
+	}
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	if src.checksum == "" {
+		logs.Debugf("Object %s has no checksum, only its size was verified", obj.Object)
+	} else if !src.matches(part) {
+		os.Remove(part)
+
+		return fmt.Errorf("checksum of %s does not match its object", obj.Path)
+	}
+
+	if err = os.Rename(part, obj.Path); err != nil {
+		return fmt.Errorf("failed to rename downloaded file: %w", err)
+	}
+	src.restoreAttributes(obj.Path)
+	logs.Infof("Downloaded %s to %s", obj.Object, obj.Path)
+
+	return nil
+}
+
+// getDownloadSource fetches the header and the metadata of `obj`
+func getDownloadSource(obj DownloadObject) (downloadSource, error) {
+	src := downloadSource{DownloadObject: obj}
+
+	if obj.Repository == api.SDConnect {
+		size, meta, err := api.GetObjectMetadata(obj.Repository, obj.Bucket, obj.Object)
+		if err != nil {
+			return src, err
+		}
+		// Segmented objects are listed with size zero
+		src.Size = size
+		src.checksum = meta[api.MetaChecksum]
+		src.modified = meta[api.MetaModified]
+		src.mode = meta[api.MetaMode]
+		src.symlink = meta[api.MetaSymlink]
+	}
+
+	bucket := obj.Bucket
+	if obj.Repository != api.SDConnect {
+		bucket = base64.RawURLEncoding.EncodeToString([]byte(obj.Bucket))
+	}
+	header, err := api.GetFileHeader(obj.Repository, bucket, obj.Object, obj.Owner, obj.ID)
+	if err != nil {
+		return src, fmt.Errorf("failed to retrieve header from Vault for object %s: %w", obj.Object, err)
+	}
+	if header == "" {
+		if obj.Repository != api.SDConnect {
+			return src, fmt.Errorf("object %s has no header", obj.Object)
+		}
+		header, src.offset, err = api.GetReencryptedHeader(obj.Bucket, obj.Object)
+		if err != nil {
+			return src, fmt.Errorf("failed to retrieve header from Allas for object %s: %w", obj.Object, err)
+		}
+	}
+	src.header = header
+
+	if src.Size < src.offset {
+		return src, fmt.Errorf("object %s is too small (%d bytes) for its header", obj.Object, src.Size)
+	}
+	src.size = api.CalculateDecryptedSize(src.Size - src.offset)
+
+	return src, nil
+}
+
+// matches checks if the content of `file` has the checksum of the object. Objects without a checksum
+// match any file of the correct size.
+func (src downloadSource) matches(file string) bool {
+	if src.checksum == "" {
+		info, err := os.Stat(file)
+
+		return err == nil && info.Size() == src.size
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	checksum, err := calculateChecksum(f)
+
+	return err == nil && checksum == src.checksum
+}
+
+// restoreAttributes sets the modification time and permissions the original file had when it was exported
+func (src downloadSource) restoreAttributes(file string) {
+	if mode, err := strconv.ParseUint(src.mode, 8, 32); err == nil {
+		if err = os.Chmod(file, os.FileMode(mode).Perm()); err != nil {
+			logs.Warningf("Could not set permissions of %s: %w", file, err)
+		}
+	}
+	if modified, err := time.Parse(time.RFC3339Nano, src.modified); err == nil {
+		if err = os.Chtimes(file, modified, modified); err != nil {
+			logs.Warningf("Could not set modification time of %s: %w", file, err)
+		}
+	}
+}
+
+// downloadSymlink creates the symbolic link stored in an object exported with 'export -symlink-objects'.
+// Only relative links that stay inside the download folder without going through a parent folder are created.
+func downloadSymlink(obj DownloadObject, target string, overwrite bool) error {
+	if filepath.IsAbs(target) {
+		return fmt.Errorf("symbolic link %s has an absolute target %s", obj.Path, target)
+	}
+	if slices.Contains(strings.Split(filepath.ToSlash(target), "/"), "..") {
+		return fmt.Errorf("symbolic link %s points to a parent folder: %s", obj.Path, target)
+	}
+	if err := createParent(obj); err != nil {
+		return err
+	}
+	if err := insideDir(obj.Dir, filepath.Join(filepath.Dir(obj.Path), target)); err != nil {
+		return fmt.Errorf("symbolic link %s points outside of %s: %s", obj.Path, obj.Dir, target)
+	}
+
+	if current, err := os.Readlink(obj.Path); err == nil && current == target {
+		logs.Infof("Symbolic link %s is already downloaded", obj.Path)
+
+		return nil
+	} else if _, err := os.Lstat(obj.Path); err == nil {
+		if !overwrite {
+			return fmt.Errorf("file %s already exists", obj.Path)
+		}
+		if err = os.Remove(obj.Path); err != nil {
+			return fmt.Errorf("failed to replace %s: %w", obj.Path, err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.Symlink(target, obj.Path); err != nil {
+		return fmt.Errorf("failed to create symbolic link: %w", err)
+	}
+	logs.Infof("Downloaded %s as a symbolic link to %s", obj.Object, target)
+
+	return nil
+}
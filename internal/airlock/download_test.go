@@ -0,0 +1,610 @@
+package airlock
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"sda-filesystem/internal/api"
+)
+
+func TestParseDownloadSource(t *testing.T) {
+	var tests = []struct {
+		testname, source, bucket, prefix string
+		rep                              api.Repo
+	}{
+		{"OK_1", "SD-Connect/bucket", "bucket", "", api.SDConnect},
+		{"OK_2", "/sd-connect/bucket/dir/file.c4gh", "bucket", "dir/file.c4gh", api.SDConnect},
+		{"OK_3", "SD-Apply/dataset/dir/", "dataset", "dir/", api.SDApply},
+		{"FAIL_REPOSITORY", "Findata/bucket", "", "", ""},
+		{"FAIL_NO_BUCKET", "SD-Connect/", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			rep, bucket, prefix, err := ParseDownloadSource(tt.source)
+
+			switch {
+			case tt.rep == "":
+				errStr := "invalid source \"" + tt.source + "\", expected format repository/bucket/path/to/prefix, where repository is one of SD-Connect, SD-Apply"
+				if err == nil {
+					t.Error("Function did not return error")
+				} else if err.Error() != errStr {
+					t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+				}
+			case err != nil:
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			case rep != tt.rep || bucket != tt.bucket || prefix != tt.prefix:
+				t.Errorf("Incorrect result. Expected=%s %s %s, received=%s %s %s", tt.rep, tt.bucket, tt.prefix, rep, bucket, prefix)
+			}
+		})
+	}
+}
+
+func TestResolveDownload(t *testing.T) {
+	objects := []api.Metadata{
+		{Name: "dir/", Size: 0},
+		{Name: "dir/a.txt.c4gh", Size: 10},
+		{Name: "dir/sub/b.txt.c4gh", Size: 20},
+		{Name: "dir/../../../escape.txt.c4gh", Size: 50}, // Would be written outside of the destination
+		{Name: "dirt/c.txt.c4gh", Size: 30, ID: "id"},
+		{Name: "d.txt", Size: 40},
+	}
+
+	var tests = []struct {
+		testname, bucket, prefix, errStr, listed string
+		rep                                      api.Repo
+		expected                                 []DownloadObject
+	}{
+		{
+			"OK_BUCKET", "bucket", "", "", "bucket", api.SDConnect,
+			[]DownloadObject{
+				{api.SDConnect, "bucket", "dir/a.txt.c4gh", "", "", 10, filepath.Join("dest", "bucket", "dir", "a.txt"), "dest"},
+				{api.SDConnect, "bucket", "dir/sub/b.txt.c4gh", "", "", 20, filepath.Join("dest", "bucket", "dir", "sub", "b.txt"), "dest"},
+				{api.SDConnect, "bucket", "dirt/c.txt.c4gh", "", "id", 30, filepath.Join("dest", "bucket", "dirt", "c.txt"), "dest"},
+				{api.SDConnect, "bucket", "d.txt", "", "", 40, filepath.Join("dest", "bucket", "d.txt"), "dest"},
+			},
+		},
+		{
+			"OK_FOLDER", "bucket", "dir", "", "bucket", api.SDConnect,
+			[]DownloadObject{
+				{api.SDConnect, "bucket", "dir/a.txt.c4gh", "", "", 10, filepath.Join("dest", "dir", "a.txt"), "dest"},
+				{api.SDConnect, "bucket", "dir/sub/b.txt.c4gh", "", "", 20, filepath.Join("dest", "dir", "sub", "b.txt"), "dest"},
+			},
+		},
+		{
+			"OK_SUBFOLDER", "bucket", "dir/sub/", "", "bucket", api.SDConnect,
+			[]DownloadObject{
+				{api.SDConnect, "bucket", "dir/sub/b.txt.c4gh", "", "", 20, filepath.Join("dest", "sub", "b.txt"), "dest"},
+			},
+		},
+		{
+			"OK_OBJECT", "bucket", "dir/a.txt.c4gh", "", "bucket", api.SDConnect,
+			[]DownloadObject{
+				{api.SDConnect, "bucket", "dir/a.txt.c4gh", "", "", 10, filepath.Join("dest", "a.txt"), "dest"},
+			},
+		},
+		{
+			"OK_SHARED", "shared-bucket", "d.txt", "", "shared-bucket", api.SDConnect,
+			[]DownloadObject{
+				{api.SDConnect, "shared-bucket", "d.txt", "project_2002", "", 40, filepath.Join("dest", "d.txt"), "dest"},
+			},
+		},
+		{
+			"OK_DATASET", "example.com/dataset", "dirt", "", "https://example.com/dataset", api.SDApply,
+			[]DownloadObject{
+				{api.SDApply, "https://example.com/dataset", "dirt/c.txt.c4gh", "sd", "id", 30, filepath.Join("dest", "dirt", "c.txt"), "dest"},
+			},
+		},
+		{
+			"FAIL_BUCKET", "unknown", "", "bucket unknown not found in SD Connect", "", api.SDConnect, nil,
+		},
+		{
+			"FAIL_PREFIX", "bucket", "di", "no objects found in SD-Connect/bucket with prefix \"di\"", "bucket", api.SDConnect, nil,
+		},
+	}
+
+	origGetBuckets := api.GetBuckets
+	origGetObjects := api.GetObjects
+	defer func() {
+		api.GetBuckets = origGetBuckets
+		api.GetObjects = origGetObjects
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			listed := ""
+			api.GetBuckets = func(rep api.Repo) ([]api.Metadata, error) {
+				if rep == api.SDApply {
+					return []api.Metadata{{Name: "https://example.com/dataset", Owner: "sd"}}, nil
+				}
+
+				return []api.Metadata{{Name: "shared-bucket", Owner: "project_2002"}, {Name: "bucket"}}, nil
+			}
+			api.GetObjects = func(rep api.Repo, bucket, path, owner, prefix string) ([]api.Metadata, error) {
+				listed = bucket
+				if prefix != tt.prefix {
+					t.Errorf("api.GetObjects() received incorrect prefix. Expected=%s, received=%s", tt.prefix, prefix)
+				}
+
+				return slices.DeleteFunc(slices.Clone(objects), func(meta api.Metadata) bool {
+					return !strings.HasPrefix(meta.Name, prefix)
+				}), nil
+			}
+
+			downloads, err := ResolveDownload(tt.rep, tt.bucket, tt.prefix, "dest")
+
+			switch {
+			case tt.errStr != "":
+				if err == nil {
+					t.Error("Function did not return error")
+				} else if err.Error() != tt.errStr {
+					t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+				}
+			case err != nil:
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			case !reflect.DeepEqual(downloads, tt.expected):
+				t.Errorf("Incorrect objects\nExpected=%v\nReceived=%v", tt.expected, downloads)
+			}
+			if listed != tt.listed {
+				t.Errorf("Listed incorrect bucket. Expected=%q, received=%q", tt.listed, listed)
+			}
+		})
+	}
+}
+
+func TestDownloadObject(t *testing.T) {
+	content := []byte("What's in a name? That which we call a rose by any other name would smell just as sweet.")
+	hash := sha256.Sum256(content)
+	checksum := hex.EncodeToString(hash[:])
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var tests = []struct {
+		testname, existing, part, checksum, errStr string
+		rep                                        api.Repo
+		overwrite, reencrypted, downloaded         bool
+	}{
+		{"OK_NEW", "", "", checksum, "", api.SDConnect, false, false, true},
+		{"OK_NO_CHECKSUM", "", "", "", "", api.SDConnect, false, false, true},
+		{"OK_PARTIAL", "", "What's in", checksum, "", api.SDConnect, false, false, true},
+		{"OK_REENCRYPTED", "", "", checksum, "", api.SDConnect, false, true, true},
+		{"OK_SD_APPLY", "", "", "", "", api.SDApply, false, false, true},
+		{"OK_EXISTS", string(content), "", checksum, "", api.SDConnect, false, false, false},
+		{"OK_OVERWRITE", "Romeo", "", checksum, "", api.SDConnect, true, false, true},
+		{"FAIL_EXISTS", "Romeo", "", checksum, "file %s already exists", api.SDConnect, false, false, false},
+		{"FAIL_CHECKSUM", "", "", strings.Repeat("0", 64), "checksum of %s does not match its object", api.SDConnect, false, false, true},
+		{"FAIL_NO_HEADER", "", "", "", "object dir/file.txt.c4gh has no header", api.SDApply, false, true, false},
+	}
+
+	origGetObjectMetadata := api.GetObjectMetadata
+	origGetFileHeader := api.GetFileHeader
+	origGetReencryptedHeader := api.GetReencryptedHeader
+	origDownloadData := api.DownloadData
+	origDeleteFileFromCache := api.DeleteFileFromCache
+	defer func() {
+		api.GetObjectMetadata = origGetObjectMetadata
+		api.GetFileHeader = origGetFileHeader
+		api.GetReencryptedHeader = origGetReencryptedHeader
+		api.DownloadData = origDownloadData
+		api.DeleteFileFromCache = origDeleteFileFromCache
+	}()
+
+	api.DeleteFileFromCache = func(rep api.Repo, nodes []string, size int64) {}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "dir", "file.txt")
+			if tt.existing != "" || tt.part != "" {
+				if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+					t.Fatalf("Failed to create folder: %s", err.Error())
+				}
+			}
+			if tt.existing != "" {
+				if err := os.WriteFile(file, []byte(tt.existing), 0o600); err != nil {
+					t.Fatalf("Failed to create file: %s", err.Error())
+				}
+			}
+			if tt.part != "" {
+				if err := os.WriteFile(file+PartSuffix, []byte(tt.part), 0o600); err != nil {
+					t.Fatalf("Failed to create file: %s", err.Error())
+				}
+			}
+
+			offset := int64(0)
+			if tt.reencrypted {
+				offset = 124
+			}
+			size := offset + api.CalculateEncryptedSize(int64(len(content)))
+			obj := DownloadObject{tt.rep, "bucket", "dir/file.txt.c4gh", "", "", size, file, filepath.Dir(file)}
+			if tt.rep == api.SDApply {
+				obj.Owner, obj.ID = "sd", "file-id"
+			}
+
+			api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+				return size, map[string]string{
+					api.MetaChecksum: tt.checksum,
+					api.MetaModified: modified.Format(time.RFC3339Nano),
+					api.MetaMode:     "0750",
+				}, nil
+			}
+			api.GetFileHeader = func(rep api.Repo, bucket, object, owner, id string) (string, error) {
+				expectedBucket := "bucket"
+				if rep == api.SDApply {
+					expectedBucket = base64.RawURLEncoding.EncodeToString([]byte("bucket"))
+				}
+				if bucket != expectedBucket || owner != obj.Owner || id != obj.ID {
+					t.Errorf("api.GetFileHeader() received incorrect arguments %s %s %s", bucket, owner, id)
+				}
+				if tt.reencrypted {
+					return "", nil
+				}
+
+				return "header", nil
+			}
+			api.GetReencryptedHeader = func(bucket, object string) (string, int64, error) {
+				return "reencrypted", offset, nil
+			}
+			downloaded := false
+			api.DownloadData = func(rep api.Repo, nodes []string, path, owner, fileID, header string,
+				start, end, oldOffset, fileSize int64,
+			) ([]byte, error) {
+				downloaded = true
+				expectedHeader := "header"
+				if tt.reencrypted {
+					expectedHeader = "reencrypted"
+				}
+				switch {
+				case !reflect.DeepEqual(nodes, []string{"bucket", "dir", "file.txt.c4gh"}):
+					t.Errorf("api.DownloadData() received incorrect nodes %v", nodes)
+				case header != expectedHeader || oldOffset != offset || fileSize != int64(len(content)):
+					t.Errorf("api.DownloadData() received incorrect arguments %s %d %d", header, oldOffset, fileSize)
+				case start != 0:
+					t.Errorf("api.DownloadData() received incorrect start %d", start)
+				}
+
+				return content[start:end], nil
+			}
+
+			_, err := downloadObject(context.Background(), obj, tt.overwrite, nil)
+
+			if downloaded != tt.downloaded {
+				t.Errorf("Object downloaded=%t, expected=%t", downloaded, tt.downloaded)
+			}
+			if tt.errStr != "" {
+				errStr := strings.ReplaceAll(tt.errStr, "%s", file)
+				if err == nil {
+					t.Error("Function did not return error")
+				} else if err.Error() != errStr {
+					t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+				}
+				if _, err := os.Stat(file + PartSuffix); tt.downloaded && !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("File %s was not removed", file+PartSuffix)
+				}
+
+				return
+			}
+			if err != nil {
+				t.Fatalf("Function returned unexpected error: %s", err.Error())
+			}
+
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("Failed to read file: %s", err.Error())
+			}
+			if !bytes.Equal(data, content) {
+				t.Errorf("File has incorrect content\nExpected=%s\nReceived=%s", content, data)
+			}
+			if _, err := os.Stat(file + PartSuffix); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("File %s was not removed", file+PartSuffix)
+			}
+
+			info, _ := os.Stat(file)
+			switch {
+			case !tt.downloaded:
+			case tt.rep == api.SDApply:
+				if info.Mode().Perm() != 0o600 {
+					t.Errorf("File has incorrect permissions. Expected=0600, received=%04o", info.Mode().Perm())
+				}
+			case info.Mode().Perm() != 0o750:
+				t.Errorf("File has incorrect permissions. Expected=0750, received=%04o", info.Mode().Perm())
+			case !info.ModTime().Equal(modified):
+				t.Errorf("File has incorrect modification time. Expected=%s, received=%s", modified, info.ModTime())
+			}
+		})
+	}
+}
+
+func TestDownloadObject_Resume(t *testing.T) {
+	origGetObjectMetadata := api.GetObjectMetadata
+	origGetFileHeader := api.GetFileHeader
+	origDownloadData := api.DownloadData
+	origDeleteFileFromCache := api.DeleteFileFromCache
+	defer func() {
+		api.GetObjectMetadata = origGetObjectMetadata
+		api.GetFileHeader = origGetFileHeader
+		api.DownloadData = origDownloadData
+		api.DeleteFileFromCache = origDeleteFileFromCache
+	}()
+
+	content := bytes.Repeat([]byte{'x'}, api.ChunkSize+100)
+	file := filepath.Join(t.TempDir(), "file.txt")
+	// Previous attempt stopped after the first chunk and part of the second one
+	if err := os.WriteFile(file+PartSuffix, content[:api.ChunkSize+10], 0o600); err != nil {
+		t.Fatalf("Failed to create file: %s", err.Error())
+	}
+
+	size := api.CalculateEncryptedSize(int64(len(content)))
+	api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+		return size, nil, nil
+	}
+	api.GetFileHeader = func(rep api.Repo, bucket, object, owner, id string) (string, error) {
+		return "header", nil
+	}
+	api.DeleteFileFromCache = func(rep api.Repo, nodes []string, size int64) {}
+	var starts []int64
+	api.DownloadData = func(rep api.Repo, nodes []string, path, owner, fileID, header string,
+		start, end, oldOffset, fileSize int64,
+	) ([]byte, error) {
+		starts = append(starts, start)
+
+		return content[start:end], nil
+	}
+
+	pt := newSizedTracker(func(Progress) {}, map[string]int64{file: int64(len(content))})
+	obj := DownloadObject{api.SDConnect, "bucket", "file.txt.c4gh", "", "", size, file, filepath.Dir(file)}
+	if _, err := downloadObject(context.Background(), obj, false, pt); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+
+	if !reflect.DeepEqual(starts, []int64{api.ChunkSize}) {
+		t.Errorf("Incorrect downloaded ranges. Expected=[%d], received=%v", api.ChunkSize, starts)
+	}
+	if info, err := os.Stat(file); err != nil || info.Size() != int64(len(content)) {
+		t.Errorf("File was not downloaded completely")
+	}
+	if pt.totalBytes != int64(len(content)) {
+		t.Errorf("Progress was not counted for the whole file. Expected=%d, received=%d", len(content), pt.totalBytes)
+	}
+}
+
+func TestDownloadObject_Symlink(t *testing.T) {
+	origGetObjectMetadata := api.GetObjectMetadata
+	origGetFileHeader := api.GetFileHeader
+	defer func() {
+		api.GetObjectMetadata = origGetObjectMetadata
+		api.GetFileHeader = origGetFileHeader
+	}()
+
+	api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+		return api.CalculateEncryptedSize(10), map[string]string{api.MetaSymlink: "sub/target.txt"}, nil
+	}
+	api.GetFileHeader = func(rep api.Repo, bucket, object, owner, id string) (string, error) {
+		return "header", nil
+	}
+
+	dir := t.TempDir()
+	link := filepath.Join(dir, "dir", "link")
+	obj := DownloadObject{api.SDConnect, "bucket", "dir/link.c4gh", "", "", 0, link, dir}
+	target, err := downloadObject(context.Background(), obj, false, nil)
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if target != "sub/target.txt" {
+		t.Errorf("Function returned incorrect target. Expected=sub/target.txt, received=%s", target)
+	}
+	if _, err = os.Lstat(link); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Symbolic link should not have been created yet")
+	}
+}
+
+func TestDownloadSymlink(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "dir", "link")
+	obj := DownloadObject{api.SDConnect, "bucket", "dir/link.c4gh", "", "", 0, link, dir}
+	for i := range 2 {
+		if err := downloadSymlink(obj, "sub/target.txt", false); err != nil {
+			t.Fatalf("Function returned unexpected error on call %d: %s", i+1, err.Error())
+		}
+		if target, err := os.Readlink(link); err != nil || target != "sub/target.txt" {
+			t.Fatalf("Symbolic link was not created correctly on call %d", i+1)
+		}
+	}
+
+	errStr := "file " + link + " already exists"
+	if err := downloadSymlink(obj, "other.txt", false); err == nil || err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%v", errStr, err)
+	}
+	if err := downloadSymlink(obj, "other.txt", true); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if target, _ := os.Readlink(link); target != "other.txt" {
+		t.Errorf("Symbolic link was not replaced")
+	}
+
+	// Links are not allowed to point outside of the download folder
+	for _, target := range []string{"/etc/passwd", "../target.txt", "sub/../../../outside.txt"} {
+		errStr := "symbolic link " + link + " points to a parent folder: " + target
+		if filepath.IsAbs(target) {
+			errStr = "symbolic link " + link + " has an absolute target " + target
+		}
+		if err := downloadSymlink(obj, target, true); err == nil || err.Error() != errStr {
+			t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%v", errStr, err)
+		}
+		if target, _ := os.Readlink(link); target != "other.txt" {
+			t.Errorf("Symbolic link was replaced with a link to %s", target)
+		}
+	}
+}
+
+func TestDownloadSymlink_Chained(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Fatalf("Failed to create symbolic link: %s", err.Error())
+	}
+
+	// a -> . and then b -> a/.. would point to the parent of the download folder
+	first := DownloadObject{api.SDConnect, "bucket", "a.c4gh", "", "", 0, filepath.Join(dir, "a"), dir}
+	if err := downloadSymlink(first, ".", false); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	second := DownloadObject{api.SDConnect, "bucket", "b.c4gh", "", "", 0, filepath.Join(dir, "b"), dir}
+	errStr := "symbolic link " + second.Path + " points to a parent folder: a/.."
+	if err := downloadSymlink(second, "a/..", false); err == nil || err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%v", errStr, err)
+	}
+
+	// Links that already exist in the download folder are followed before checking the target
+	third := DownloadObject{api.SDConnect, "bucket", "c.c4gh", "", "", 0, filepath.Join(dir, "c"), dir}
+	errStr = "symbolic link " + third.Path + " points outside of " + dir + ": escape/file"
+	if err := downloadSymlink(third, "escape/file", false); err == nil || err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%v", errStr, err)
+	}
+	fourth := DownloadObject{api.SDConnect, "bucket", "escape/d.c4gh", "", "", 0, filepath.Join(dir, "escape", "d"), dir}
+	errStr = filepath.Join(dir, "escape") + " is outside of " + dir
+	if err := downloadSymlink(fourth, "file", false); err == nil || err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%v", errStr, err)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) > 0 {
+		t.Errorf("Files were created outside of the download folder")
+	}
+}
+
+func TestDownloadObject_SymlinkedFolder(t *testing.T) {
+	origGetObjectMetadata := api.GetObjectMetadata
+	origGetFileHeader := api.GetFileHeader
+	defer func() {
+		api.GetObjectMetadata = origGetObjectMetadata
+		api.GetFileHeader = origGetFileHeader
+	}()
+
+	api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+		return api.CalculateEncryptedSize(10), nil, nil
+	}
+	api.GetFileHeader = func(rep api.Repo, bucket, object, owner, id string) (string, error) {
+		return "header", nil
+	}
+
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Fatalf("Failed to create symbolic link: %s", err.Error())
+	}
+	file := filepath.Join(dir, "file.txt")
+	if err := os.Symlink(filepath.Join(outside, "file.txt"), file+PartSuffix); err != nil {
+		t.Fatalf("Failed to create symbolic link: %s", err.Error())
+	}
+
+	obj := DownloadObject{api.SDConnect, "bucket", "escape/sub/file.txt.c4gh", "", "", 0, filepath.Join(dir, "escape", "sub", "file.txt"), dir}
+	errStr := filepath.Join(dir, "escape", "sub") + " is outside of " + dir
+	if _, err := downloadObject(context.Background(), obj, false, nil); err == nil || err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%v", errStr, err)
+	}
+
+	// A symbolic link in place of the partial file is not followed
+	obj = DownloadObject{api.SDConnect, "bucket", "file.txt.c4gh", "", "", 0, file, dir}
+	if _, err := downloadObject(context.Background(), obj, false, nil); err == nil || !errors.Is(err, syscall.ELOOP) {
+		t.Errorf("Function should have failed to open %s, received=%v", file+PartSuffix, err)
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) > 0 {
+		t.Errorf("Files were created outside of the download folder")
+	}
+}
+
+func TestDownload(t *testing.T) {
+	origDownloadObject := downloadObject
+	origProgressFun := ai.progressFun
+	defer func() {
+		downloadObject = origDownloadObject
+		ai.progressFun = origProgressFun
+	}()
+
+	objects := []DownloadObject{
+		{Object: "a.c4gh", Size: api.CalculateEncryptedSize(10), Path: "a"},
+		{Object: "b.c4gh", Size: api.CalculateEncryptedSize(20), Path: "b"},
+		{Object: "c.c4gh", Size: api.CalculateEncryptedSize(30), Path: "c"},
+	}
+
+	var mu sync.Mutex
+	var finished []string
+	ai.progressFun = func(p Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if p.Done {
+			finished = append(finished, p.File)
+		}
+		if p.TotalSize != 60 {
+			t.Errorf("Progress has incorrect total size. Expected=60, received=%d", p.TotalSize)
+		}
+	}
+	downloadObject = func(_ context.Context, obj DownloadObject, overwrite bool, _ *progressTracker) (string, error) {
+		if !overwrite {
+			t.Errorf("Overwrite was not passed to downloadObject()")
+		}
+		if obj.Object == "b.c4gh" {
+			return "", errExpected
+		}
+
+		return "", nil
+	}
+
+	errs, err := Download(context.Background(), objects, 2, true)
+	errStr := "1 of 3 object(s) could not be downloaded"
+	if err == nil || err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%v", errStr, err)
+	}
+	if !reflect.DeepEqual(errs, []error{nil, errExpected, nil}) {
+		t.Errorf("Incorrect errors for objects. Expected=[<nil> %v <nil>], received=%v", errExpected, errs)
+	}
+	slices.Sort(finished)
+	if !reflect.DeepEqual(finished, []string{"a", "c"}) {
+		t.Errorf("Incorrect finished files. Expected=[a c], received=%v", finished)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = Download(ctx, objects, 2, true); !errors.Is(err, context.Canceled) {
+		t.Errorf("Function should have returned context.Canceled, received=%v", err)
+	}
+}
+
+func TestDownload_SymlinksLast(t *testing.T) {
+	origDownloadObject := downloadObject
+	defer func() { downloadObject = origDownloadObject }()
+
+	dir := t.TempDir()
+	objects := []DownloadObject{
+		{Object: "link.c4gh", Path: filepath.Join(dir, "link"), Dir: dir},
+		{Object: "file.txt.c4gh", Path: filepath.Join(dir, "file.txt"), Dir: dir},
+	}
+	downloadObject = func(_ context.Context, obj DownloadObject, _ bool, _ *progressTracker) (string, error) {
+		if _, err := os.Lstat(objects[0].Path); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Symbolic link was created before %s was downloaded", obj.Object)
+		}
+		if obj.Object == "link.c4gh" {
+			return "file.txt", nil
+		}
+
+		return "", os.WriteFile(obj.Path, []byte("content"), 0o600)
+	}
+
+	if _, err := Download(context.Background(), objects, 1, false); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if target, err := os.Readlink(objects[0].Path); err != nil || target != "file.txt" {
+		t.Errorf("Symbolic link was not created correctly")
+	}
+}
@@ -0,0 +1,236 @@
+package airlock
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+
+	"github.com/neicnordic/crypt4gh/keys"
+	c4ghHeaders "github.com/neicnordic/crypt4gh/model/headers"
+	"github.com/neicnordic/crypt4gh/streaming"
+)
+
+// encryptedFile describes a file that was already encrypted with crypt4gh before the export
+type encryptedFile struct {
+	header    []byte // Header re-encrypted for the project public key
+	original  []byte // Header of the file, needed for decrypting the content
+	bodySize  int64
+	plainSize int64
+}
+
+// SetPrivateKey reads the crypt4gh private key that is used for re-encrypting the headers of files
+// that are already encrypted. An empty `filename` removes the key, after which such files are encrypted again.
+func SetPrivateKey(filename string, passphrase []byte) error {
+	if filename == "" {
+		ai.privateKey = nil
+
+		return nil
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open private key: %w", err)
+	}
+	defer file.Close()
+
+	key, err := keys.ReadPrivateKey(file, passphrase)
+	if err != nil {
+		return fmt.Errorf("failed to read private key %s: %w", filename, err)
+	}
+	ai.privateKey = &key
+
+	return nil
+}
+
+// hasMagicNumber checks if `rd` begins with the crypt4gh magic number
+func hasMagicNumber(rd io.Reader) bool {
+	magic := make([]byte, len(c4ghHeaders.MagicNumber))
+	if _, err := io.ReadFull(rd, magic); err != nil {
+		return false
+	}
+
+	return string(magic) == c4ghHeaders.MagicNumber
+}
+
+// isPassedThrough checks if the file at `path` is uploaded without encrypting it again, i.e.,
+// if it is already encrypted with crypt4gh and its header can be decrypted with the private key
+func isPassedThrough(path string) bool {
+	_, ok := passedThroughHeader(path)
+
+	return ok
+}
+
+// passedThroughHeader returns the size of the header of the file at `path` if the file is uploaded without encrypting it again
+func passedThroughHeader(path string) (int64, bool) {
+	if ai.privateKey == nil {
+		return 0, false
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer file.Close()
+
+	header, err := c4ghHeaders.ReadHeader(file)
+	if err != nil {
+		return 0, false
+	}
+	if _, err = c4ghHeaders.NewHeader(bytes.NewReader(header), *ai.privateKey); err != nil {
+		return 0, false
+	}
+
+	return int64(len(header)), true
+}
+
+// openEncrypted checks if `file` is already encrypted with crypt4gh. If it is, and its header can be
+// decrypted with the private key, the header is re-encrypted for the project public key. A nil value is
+// returned if the file should be encrypted as usual. `file` is rewound in either case.
+var openEncrypted = func(file io.ReadSeeker, filename string) (*encryptedFile, error) {
+	encrypted := hasMagicNumber(file)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	if !encrypted {
+		return nil, nil
+	}
+	if ai.privateKey == nil {
+		logs.Warningf("File %s is already encrypted, but no private key was given for re-encrypting its header. The file will be encrypted again", filename)
+
+		return nil, nil
+	}
+
+	header, err := c4ghHeaders.ReadHeader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header: %w", err)
+	}
+	if _, err = c4ghHeaders.NewHeader(bytes.NewReader(header), *ai.privateKey); err != nil {
+		logs.Warningf("File %s is already encrypted, but its header cannot be decrypted with the given private key. The file will be encrypted again", filename)
+		if _, err = file.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+
+		return nil, nil
+	}
+	newHeader, err := c4ghHeaders.ReEncryptHeader(header, *ai.privateKey, [][32]byte{ai.publicKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to re-encrypt header with the given private key: %w", err)
+	}
+	fileSize, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	bodySize := fileSize - int64(len(header))
+
+	return &encryptedFile{
+		header:    newHeader,
+		original:  header,
+		bodySize:  bodySize,
+		plainSize: api.CalculateDecryptedSize(bodySize),
+	}, nil
+}
+
+// source returns the content that is passed on to the uploads, given the content `rd` of the original file,
+// and a function that returns the checksum of the decrypted content once `rd` has been read. In Findata
+// projects the file is decrypted, since it is also uploaded unencrypted. Otherwise, the old header is
+// replaced with the re-encrypted one and the body is passed on unchanged, while it is decrypted alongside
+// the upload only for calculating the checksum.
+func (ef *encryptedFile) source(rd io.Reader, findata bool) (io.Reader, func() string, error) {
+	if findata {
+		c4ghReader, err := streaming.NewCrypt4GHReader(rd, *ai.privateKey, nil)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create crypt4gh reader: %w", err)
+		}
+		cr := newChecksumReader(c4ghReader)
+
+		return cr, cr.checksum, nil
+	}
+
+	if _, err := c4ghHeaders.ReadHeader(rd); err != nil {
+		return nil, nil, fmt.Errorf("failed to read header: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	dc := &decryptedChecksum{rd: io.TeeReader(rd, pw), pw: pw, result: make(chan decryptResult, 1)}
+	go func() {
+		var res decryptResult
+		c4ghReader, err := streaming.NewCrypt4GHReader(io.MultiReader(bytes.NewReader(ef.original), pr), *ai.privateKey, nil)
+		if err == nil {
+			cr := newChecksumReader(c4ghReader)
+			if _, err = io.Copy(io.Discard, cr); err == nil {
+				res.checksum = cr.checksum()
+			}
+		}
+		if err != nil {
+			res.err = fmt.Errorf("failed to decrypt file: %w", err)
+		}
+		pr.CloseWithError(res.err)
+		dc.result <- res
+	}()
+
+	return io.MultiReader(bytes.NewReader(ef.header), dc), dc.checksum, nil
+}
+
+type decryptResult struct {
+	checksum string
+	err      error
+}
+
+// decryptedChecksum passes on the body of an encrypted file while the body is decrypted for calculating
+// the checksum of its content. Reading returns an error instead of io.EOF if the body cannot be decrypted.
+type decryptedChecksum struct {
+	rd     io.Reader
+	pw     *io.PipeWriter
+	result chan decryptResult
+	once   sync.Once
+	res    decryptResult
+}
+
+func (dc *decryptedChecksum) Read(p []byte) (int, error) {
+	n, err := dc.rd.Read(p)
+	if errors.Is(err, io.EOF) {
+		if errDecrypt := dc.wait(); errDecrypt != nil {
+			err = errDecrypt
+		}
+	}
+
+	return n, err
+}
+
+// wait ends the decryption and returns its outcome
+func (dc *decryptedChecksum) wait() error {
+	dc.once.Do(func() {
+		dc.pw.Close()
+		dc.res = <-dc.result
+	})
+
+	return dc.res.err
+}
+
+// checksum returns the checksum of the decrypted content, or an empty string if the body could not be decrypted
+func (dc *decryptedChecksum) checksum() string {
+	if dc.wait() != nil {
+		return ""
+	}
+
+	return dc.res.checksum
+}
+
+// passThrough streams an already encrypted file into the pipe read by uploadAllas()
+var passThrough = func(file io.Reader, pw *io.PipeWriter, errc chan error) {
+	defer pw.Close()
+	if _, err := io.Copy(pw, file); err != nil {
+		errc <- err
+
+		return
+	}
+	errc <- nil
+}
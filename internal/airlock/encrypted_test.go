@@ -0,0 +1,448 @@
+package airlock
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+	"sda-filesystem/test"
+
+	"github.com/neicnordic/crypt4gh/keys"
+	c4ghHeaders "github.com/neicnordic/crypt4gh/model/headers"
+	"github.com/neicnordic/crypt4gh/streaming"
+)
+
+// encryptContent encrypts `content` with crypt4gh for `publicKey`
+func encryptContent(t *testing.T, content []byte, publicKey [32]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	c4ghWriter, err := streaming.NewCrypt4GHWriterWithoutPrivateKey(&buf, [][32]byte{publicKey}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create crypt4gh writer: %s", err.Error())
+	}
+	if _, err = c4ghWriter.Write(content); err != nil {
+		t.Fatalf("Failed to encrypt content: %s", err.Error())
+	}
+	c4ghWriter.Close()
+
+	return buf.Bytes()
+}
+
+func TestSetPrivateKey(t *testing.T) {
+	origPrivateKey := ai.privateKey
+	defer func() { ai.privateKey = origPrivateKey }()
+
+	_, privateKey, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	filename := t.TempDir() + "/key.sec"
+	file, err := os.Create(filename)
+	if err != nil {
+		t.Fatalf("Failed to create file: %s", err.Error())
+	}
+	if err = keys.WriteCrypt4GHX25519PrivateKey(file, privateKey, []byte("passphrase")); err != nil {
+		t.Fatalf("Failed to write private key: %s", err.Error())
+	}
+	file.Close()
+
+	if err = SetPrivateKey(filename, []byte("wrong")); err == nil {
+		t.Error("Function did not return error with incorrect passphrase")
+	} else if !strings.HasPrefix(err.Error(), "failed to read private key "+filename) {
+		t.Errorf("Function returned incorrect error: %s", err.Error())
+	}
+	if err = SetPrivateKey(filename, []byte("passphrase")); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if ai.privateKey == nil || *ai.privateKey != privateKey {
+		t.Errorf("Private key was not set correctly")
+	}
+	if err = SetPrivateKey("", nil); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if ai.privateKey != nil {
+		t.Errorf("Private key was not removed")
+	}
+}
+
+func TestWalkDirs_Encrypted(t *testing.T) {
+	origPrivateKey := ai.privateKey
+	defer func() { ai.privateKey = origPrivateKey }()
+
+	tmpDir := t.TempDir()
+	publicKey, privateKey, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	_, wrongKey, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+
+	files := map[string][]byte{
+		"/encrypted.c4gh":   encryptContent(t, []byte("hello world\n"), publicKey),
+		"/plain.c4gh":       []byte("hello world\n"),
+		"/encrypted.txt":    encryptContent(t, []byte("hello world\n"), publicKey),
+		"/dir/nested.c4gh":  encryptContent(t, []byte("hello world\n"), publicKey),
+		"/dir/another.text": []byte("hello world\n"),
+	}
+	if err = os.Mkdir(tmpDir+"/dir", 0755); err != nil {
+		t.Fatalf("Failed to create folder: %s", err.Error())
+	}
+	for name, content := range files {
+		if err = os.WriteFile(tmpDir+name, content, 0600); err != nil {
+			t.Fatalf("Failed to create file: %s", err.Error())
+		}
+	}
+
+	// Encrypted files keep their name only if they are not encrypted again
+	encryptedAgain := []string{"dir/another.text.c4gh", "dir/nested.c4gh.c4gh", "encrypted.c4gh.c4gh", "encrypted.txt.c4gh", "plain.c4gh.c4gh"}
+	var tests = []struct {
+		testname        string
+		privateKey      *[32]byte
+		expectedObjects []string
+	}{
+		{"OK_PRIVATE_KEY", &privateKey, []string{"dir/another.text.c4gh", "dir/nested.c4gh", "encrypted.c4gh", "encrypted.txt.c4gh", "plain.c4gh.c4gh"}},
+		{"OK_NO_PRIVATE_KEY", nil, encryptedAgain},
+		{"OK_WRONG_PRIVATE_KEY", &wrongKey, encryptedAgain},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			ai.privateKey = tt.privateKey
+
+			selection := []string{tmpDir + "/encrypted.c4gh", tmpDir + "/plain.c4gh", tmpDir + "/encrypted.txt", tmpDir + "/dir"}
+			set, err := WalkDirs(selection, nil, "bucket")
+			if err != nil {
+				t.Fatalf("Function returned unexpected error: %s", err.Error())
+			}
+
+			slices.Sort(set.Objects)
+			if !slices.Equal(set.Objects, tt.expectedObjects) {
+				t.Errorf("Received incorrect objects\nExpected=%q\nReceived=%q", tt.expectedObjects, set.Objects)
+			}
+		})
+	}
+}
+
+func TestUploadSize_Encrypted(t *testing.T) {
+	origPrivateKey := ai.privateKey
+	origFindataUpload := api.FindataUpload
+	origGetObjectMetadata := api.GetObjectMetadata
+	defer func() {
+		ai.privateKey = origPrivateKey
+		api.FindataUpload = origFindataUpload
+		api.GetObjectMetadata = origGetObjectMetadata
+	}()
+
+	publicKey, privateKey, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	content := test.GenerateRandomText(100000)
+	encrypted := encryptContent(t, content, publicKey)
+	header, _ := c4ghHeaders.ReadHeader(bytes.NewReader(encrypted))
+	bodySize := int64(len(encrypted) - len(header))
+
+	filename := t.TempDir() + "/file.c4gh"
+	if err = os.WriteFile(filename, encrypted, 0600); err != nil {
+		t.Fatalf("Failed to create file: %s", err.Error())
+	}
+	api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+		return 0, map[string]string{
+			api.MetaModified: "2020-01-01T00:00:00Z",
+			api.MetaChecksum: fmt.Sprintf("%x", sha256.Sum256(content)),
+		}, nil
+	}
+
+	var tests = []struct {
+		testname   string
+		privateKey *[32]byte
+		findata    bool
+		size       int64
+	}{
+		{"OK_PASSED_THROUGH", &privateKey, false, bodySize},
+		{"OK_FINDATA", &privateKey, true, api.CalculateEncryptedSize(api.CalculateDecryptedSize(bodySize))},
+		{"OK_ENCRYPTED_AGAIN", nil, false, api.CalculateEncryptedSize(int64(len(encrypted)))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			ai.privateKey = tt.privateKey
+			api.FindataUpload = func() bool {
+				return tt.findata
+			}
+
+			size, err := uploadSize(filename)
+			if err != nil {
+				t.Fatalf("Function returned unexpected error: %s", err.Error())
+			}
+			if size != tt.size {
+				t.Errorf("Function returned incorrect size. Expected=%d, received=%d", tt.size, size)
+			}
+			if pt := newProgressTracker(func(Progress) {}, []string{filename}); pt.totalSize != tt.size {
+				t.Errorf("Progress tracker has incorrect size. Expected=%d, received=%d", tt.size, pt.totalSize)
+			}
+
+			// Only the checksum of the decrypted content matches the metadata
+			changed, err := fileChanged(filename, "bucket", "file.c4gh", size)
+			if err != nil {
+				t.Fatalf("fileChanged() returned unexpected error: %s", err.Error())
+			}
+			if changed != (tt.privateKey == nil) {
+				t.Errorf("fileChanged() returned incorrect value. Expected=%t, received=%t", tt.privateKey == nil, changed)
+			}
+		})
+	}
+}
+
+func TestOpenEncrypted(t *testing.T) {
+	origPrivateKey := ai.privateKey
+	origPublicKey := ai.publicKey
+	origWarningf := logs.Warningf
+	defer func() {
+		ai.privateKey = origPrivateKey
+		ai.publicKey = origPublicKey
+		logs.Warningf = origWarningf
+	}()
+
+	userPublicKey, userPrivateKey, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	projectPublicKey, projectPrivateKey, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	ai.publicKey = projectPublicKey
+
+	content := test.GenerateRandomText(200000)
+	encrypted := encryptContent(t, content, userPublicKey)
+
+	var warnings []string
+	logs.Warningf = func(format string, args ...any) {
+		warnings = append(warnings, fmt.Errorf(format, args...).Error())
+	}
+
+	// Unencrypted files and files without a private key are encrypted as usual
+	ai.privateKey = nil
+	for _, data := range [][]byte{content, encrypted, []byte("short")} {
+		ef, err := openEncrypted(bytes.NewReader(data), "file")
+		if err != nil || ef != nil {
+			t.Errorf("Function should have returned nil values, received %v and %v", ef, err)
+		}
+	}
+	if len(warnings) != 1 || !strings.HasPrefix(warnings[0], "File file is already encrypted") {
+		t.Errorf("Function logged incorrect warnings: %q", warnings)
+	}
+
+	_, wrongKey, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	ai.privateKey = &wrongKey
+	warnings = nil
+	rd := bytes.NewReader(encrypted)
+	if ef, err := openEncrypted(rd, "file"); err != nil || ef != nil {
+		t.Errorf("Function should have returned nil values with incorrect private key, received %v and %v", ef, err)
+	}
+	if len(warnings) != 1 || !strings.HasSuffix(warnings[0], "cannot be decrypted with the given private key. The file will be encrypted again") {
+		t.Errorf("Function logged incorrect warnings: %q", warnings)
+	}
+	if pos, _ := rd.Seek(0, io.SeekCurrent); pos != 0 {
+		t.Errorf("File was not rewound, position is %d", pos)
+	}
+
+	ai.privateKey = &userPrivateKey
+	rd = bytes.NewReader(encrypted)
+	ef, err := openEncrypted(rd, "file")
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	oldHeader, _ := c4ghHeaders.ReadHeader(bytes.NewReader(encrypted))
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+	switch {
+	case ef == nil:
+		t.Fatal("Function did not recognise encrypted file")
+	case rd.Len() != len(encrypted):
+		t.Errorf("File was not rewound")
+	case ef.plainSize != int64(len(content)):
+		t.Errorf("Incorrect decrypted size. Expected=%d, received=%d", len(content), ef.plainSize)
+	case ef.bodySize != int64(len(encrypted)-len(oldHeader)):
+		t.Errorf("Incorrect body size. Expected=%d, received=%d", len(encrypted)-len(oldHeader), ef.bodySize)
+	}
+
+	// The new header and the unchanged body should be readable with the project key
+	src, sum, err := ef.source(rd, false)
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	reencrypted, _ := io.ReadAll(src)
+	if !bytes.Equal(reencrypted[len(ef.header):], encrypted[len(oldHeader):]) {
+		t.Errorf("Body of the file was modified")
+	}
+	if received := sum(); received != checksum {
+		t.Errorf("Incorrect checksum\nExpected=%s\nReceived=%s", checksum, received)
+	}
+	c4ghReader, err := streaming.NewCrypt4GHReader(bytes.NewReader(reencrypted), projectPrivateKey, nil)
+	if err != nil {
+		t.Fatalf("Failed to create crypt4gh reader: %s", err.Error())
+	}
+	if message, err := io.ReadAll(c4ghReader); err != nil {
+		t.Errorf("Failed to read re-encrypted file: %s", err.Error())
+	} else if !bytes.Equal(message, content) {
+		t.Errorf("Re-encrypted file has incorrect content")
+	}
+
+	// In Findata projects the file is decrypted
+	_, _ = rd.Seek(0, io.SeekStart)
+	src, sum, err = ef.source(rd, true)
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if message, _ := io.ReadAll(src); !bytes.Equal(message, content) {
+		t.Errorf("Decrypted file has incorrect content")
+	}
+	if received := sum(); received != checksum {
+		t.Errorf("Incorrect checksum in Findata project\nExpected=%s\nReceived=%s", checksum, received)
+	}
+
+	// A body that cannot be decrypted fails the upload
+	corrupted := bytes.Clone(encrypted)
+	corrupted[len(corrupted)-1] ^= 0xff
+	src, sum, err = ef.source(bytes.NewReader(corrupted), false)
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if _, err = io.ReadAll(src); err == nil {
+		t.Error("Reading a corrupted file did not return error")
+	} else if !strings.HasPrefix(err.Error(), "failed to decrypt file") {
+		t.Errorf("Reading a corrupted file returned incorrect error: %s", err.Error())
+	}
+	if received := sum(); received != "" {
+		t.Errorf("Corrupted file should not have a checksum, received %s", received)
+	}
+}
+
+func TestUploadObject_Encrypted(t *testing.T) {
+	origGetFileDetails := getFileDetails
+	origFindataUpload := api.FindataUpload
+	origPostHeader := api.PostHeader
+	origUploadObject := api.UploadObject
+	origVerifyUpload := verifyUpload
+	origSetObjectMetadata := api.SetObjectMetadata
+	origPrivateKey := ai.privateKey
+	origPublicKey := ai.publicKey
+	defer func() {
+		getFileDetails = origGetFileDetails
+		api.FindataUpload = origFindataUpload
+		api.PostHeader = origPostHeader
+		api.UploadObject = origUploadObject
+		api.SetObjectMetadata = origSetObjectMetadata
+		verifyUpload = origVerifyUpload
+		ai.privateKey = origPrivateKey
+		ai.publicKey = origPublicKey
+	}()
+
+	verifyUpload = func(bucket, object string, objectSize int64) error {
+		return nil
+	}
+
+	userPublicKey, userPrivateKey, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	projectPublicKey, projectPrivateKey, err := keys.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("Could not generate key pair: %s", err.Error())
+	}
+	ai.publicKey = projectPublicKey
+	ai.privateKey = &userPrivateKey
+
+	content := test.GenerateRandomText(100000)
+	encrypted := encryptContent(t, content, userPublicKey)
+	oldHeader, _ := c4ghHeaders.ReadHeader(bytes.NewReader(encrypted))
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content))
+
+	getFileDetails = func(filename string) (io.ReadCloser, int64, error) {
+		return struct {
+			io.ReadSeeker
+			io.Closer
+		}{bytes.NewReader(encrypted), io.NopCloser(nil)}, api.CalculateEncryptedSize(int64(len(encrypted))) + headerSize, nil
+	}
+
+	for _, findata := range []bool{false, true} {
+		t.Run(fmt.Sprintf("OK_FINDATA_%t", findata), func(t *testing.T) {
+			var header, body, findataBody []byte
+			api.FindataUpload = func() bool {
+				return findata
+			}
+			api.PostHeader = func(h []byte, bucket, object string) error {
+				header = h
+
+				return nil
+			}
+			api.UploadObject = func(
+				ctx context.Context,
+				rd io.Reader,
+				rep api.Repo,
+				bucket, object string,
+				segmentSize int64,
+				concurrency int,
+				metadata map[string]string,
+			) error {
+				data, err := io.ReadAll(rd)
+				if rep == api.Findata {
+					findataBody = data
+				} else {
+					body = data
+				}
+
+				return err
+			}
+
+			recorded := 0
+			api.SetObjectMetadata = func(rep api.Repo, bucket, object string, size int64, metadata map[string]string) error {
+				recorded++
+				if metadata[api.MetaChecksum] != checksum {
+					t.Errorf("api.SetObjectMetadata() received incorrect checksum\nExpected=%s\nReceived=%s", checksum, metadata[api.MetaChecksum])
+				}
+
+				return nil
+			}
+
+			if err := UploadObject(context.Background(), "file.c4gh", "file.c4gh", "bucket", nil); err != nil {
+				t.Fatalf("Function returned unexpected error: %s", err.Error())
+			}
+			if expected := map[bool]int{false: 1, true: 2}[findata]; recorded != expected {
+				t.Errorf("Checksum was recorded %d times, expected=%d", recorded, expected)
+			}
+
+			c4ghReader, err := streaming.NewCrypt4GHReader(io.MultiReader(bytes.NewReader(header), bytes.NewReader(body)), projectPrivateKey, nil)
+			if err != nil {
+				t.Fatalf("Failed to create crypt4gh reader: %s", err.Error())
+			}
+			if message, err := io.ReadAll(c4ghReader); err != nil {
+				t.Errorf("Failed to read uploaded object: %s", err.Error())
+			} else if !bytes.Equal(message, content) {
+				t.Errorf("Uploaded object has incorrect content")
+			}
+
+			switch {
+			case findata && !bytes.Equal(findataBody, content):
+				t.Errorf("Findata object has incorrect content")
+			case !findata && !bytes.Equal(body, encrypted[len(oldHeader):]):
+				t.Errorf("Body of the file should have been uploaded unchanged")
+			}
+		})
+	}
+}
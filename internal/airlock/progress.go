@@ -0,0 +1,160 @@
+package airlock
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"sda-filesystem/internal/api"
+)
+
+// progressInterval is how often the progress of a single file is reported at most
+const progressInterval = 500 * time.Millisecond
+
+// Progress describes how far the export of a file, and the export as a whole, has progressed.
+// Sizes are those of the encrypted objects, and are negative for streams whose size is not known.
+// During Download() the sizes are those of the decrypted files.
+type Progress struct {
+	File       string  `json:"file"`
+	Bytes      int64   `json:"bytes"`
+	Size       int64   `json:"size"`
+	TotalBytes int64   `json:"totalBytes"`
+	TotalSize  int64   `json:"totalSize"`
+	Files      int     `json:"files"`    // Number of files in the export
+	Finished   int     `json:"finished"` // Number of files that have been uploaded
+	Rate       float64 `json:"rate"`     // Average bytes per second since the export began
+	ETA        float64 `json:"eta"`      // Seconds until the export is complete, negative if not known
+	Done       bool    `json:"done"`     // Whether `File` has been uploaded
+}
+
+// SetProgressFunc sets the function that receives progress updates during Upload() and Download()
+func SetProgressFunc(fun func(Progress)) {
+	ai.progressFun = fun
+}
+
+type progressKey struct{}
+
+type fileProgress struct {
+	bytes, size int64
+	reported    time.Time
+}
+
+// progressTracker collects the bytes uploaded for each file and reports them to `fun`
+type progressTracker struct {
+	mu         sync.Mutex
+	fun        func(Progress)
+	start      time.Time
+	totalBytes int64
+	totalSize  int64
+	finished   int
+	files      map[string]*fileProgress
+}
+
+func newProgressTracker(fun func(Progress), files []string) *progressTracker {
+	sizes := make(map[string]int64, len(files))
+	for i := range files {
+		if files[i] == StdinFile {
+			sizes[files[i]] = -1
+		} else if size, err := uploadSize(files[i]); err == nil {
+			sizes[files[i]] = size
+		} else {
+			sizes[files[i]] = 0
+		}
+	}
+
+	return newSizedTracker(fun, sizes)
+}
+
+// newSizedTracker returns a tracker for files whose sizes are already known
+func newSizedTracker(fun func(Progress), sizes map[string]int64) *progressTracker {
+	pt := &progressTracker{fun: fun, start: time.Now(), files: make(map[string]*fileProgress, len(sizes))}
+	for file, size := range sizes {
+		pt.files[file] = &fileProgress{size: size}
+		if size < 0 || pt.totalSize < 0 {
+			pt.totalSize = -1
+		} else {
+			pt.totalSize += size
+		}
+	}
+
+	return pt
+}
+
+// trackFile returns a context in which the S3 requests of `file` are added to its progress
+func trackFile(ctx context.Context, file string) context.Context {
+	pt, ok := ctx.Value(progressKey{}).(*progressTracker)
+	if !ok {
+		return ctx
+	}
+	pt.add(file, 0)
+
+	return api.WithProgress(ctx, func(n int64) { pt.add(file, n) })
+}
+
+// finishFile marks `file` as uploaded
+func finishFile(ctx context.Context, file string) {
+	if pt, ok := ctx.Value(progressKey{}).(*progressTracker); ok {
+		pt.finish(file)
+	}
+}
+
+func (pt *progressTracker) add(file string, n int64) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	fp, ok := pt.files[file]
+	if !ok {
+		return
+	}
+	// Retried requests are counted again, so bytes may exceed the size of the file
+	if fp.size >= 0 {
+		n = max(min(n, fp.size-fp.bytes), 0)
+	}
+	fp.bytes += n
+	pt.totalBytes += n
+
+	now := time.Now()
+	if !fp.reported.IsZero() && now.Sub(fp.reported) < progressInterval {
+		return
+	}
+	fp.reported = now
+	pt.fun(pt.progress(file, fp, now, false))
+}
+
+func (pt *progressTracker) finish(file string) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	fp, ok := pt.files[file]
+	if !ok {
+		return
+	}
+	if fp.size >= 0 {
+		pt.totalBytes += fp.size - fp.bytes
+		fp.bytes = fp.size
+	}
+	pt.finished++
+	pt.fun(pt.progress(file, fp, time.Now(), true))
+}
+
+func (pt *progressTracker) progress(file string, fp *fileProgress, now time.Time, done bool) Progress {
+	p := Progress{
+		File:       file,
+		Bytes:      fp.bytes,
+		Size:       fp.size,
+		TotalBytes: pt.totalBytes,
+		TotalSize:  pt.totalSize,
+		Files:      len(pt.files),
+		Finished:   pt.finished,
+		ETA:        -1,
+		Done:       done,
+	}
+	if elapsed := now.Sub(pt.start).Seconds(); elapsed > 0 {
+		p.Rate = float64(pt.totalBytes) / elapsed
+	}
+	if p.Rate > 0 && pt.totalSize >= 0 {
+		p.ETA = float64(pt.totalSize-pt.totalBytes) / p.Rate
+	}
+
+	return p
+}
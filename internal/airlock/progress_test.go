@@ -0,0 +1,99 @@
+package airlock
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"sda-filesystem/internal/api"
+)
+
+func TestProgressTracker(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := []string{tmpDir + "/file1.txt", tmpDir + "/file2.txt", tmpDir + "/missing.txt"}
+	for i, content := range []string{"hello world\n", "another file with more content\n"} {
+		if err := os.WriteFile(files[i], []byte(content), 0600); err != nil {
+			t.Fatalf("Failed to create file: %s", err.Error())
+		}
+	}
+	size1, size2 := api.CalculateEncryptedSize(12), api.CalculateEncryptedSize(31)
+
+	var reports []Progress
+	pt := newProgressTracker(func(p Progress) { reports = append(reports, p) }, files)
+	pt.start = time.Now().Add(-2 * time.Second)
+	if pt.totalSize != size1+size2 {
+		t.Fatalf("Tracker has incorrect total size. Expected=%d, received=%d", size1+size2, pt.totalSize)
+	}
+
+	pt.add(files[0], 0)
+	pt.add(files[0], 10)    // Too soon to be reported
+	pt.add(files[0], 1<<20) // Retried request, should not exceed file size
+	pt.add("unknown", 10)
+	pt.finish(files[1])
+
+	if len(reports) != 2 {
+		t.Fatalf("Tracker sent incorrect number of reports. Expected=2, received=%d", len(reports))
+	}
+	first := Progress{File: files[0], Size: size1, TotalSize: size1 + size2, Files: 3, ETA: -1}
+	if reports[0] != first {
+		t.Errorf("Tracker sent incorrect report\nExpected=%+v\nReceived=%+v", first, reports[0])
+	}
+
+	last := reports[1]
+	switch {
+	case last.File != files[1] || !last.Done || last.Finished != 1:
+		t.Errorf("Last report does not describe finished file %s: %+v", files[1], last)
+	case last.Bytes != size2:
+		t.Errorf("Finished file has incorrect bytes. Expected=%d, received=%d", size2, last.Bytes)
+	case last.TotalBytes != size1+size2:
+		t.Errorf("Tracker has incorrect total bytes. Expected=%d, received=%d", size1+size2, last.TotalBytes)
+	case last.Rate <= 0 || last.ETA != 0:
+		t.Errorf("Tracker reported incorrect rate %f or ETA %f", last.Rate, last.ETA)
+	}
+}
+
+func TestTrackFile(t *testing.T) {
+	ctx := context.Background()
+	if trackFile(ctx, "file") != ctx {
+		t.Error("Context should not have been modified when there is no tracker")
+	}
+	finishFile(ctx, "file") // Should not panic
+
+	var reports []Progress
+	pt := newProgressTracker(func(p Progress) { reports = append(reports, p) }, []string{"file"})
+	ctx = trackFile(context.WithValue(ctx, progressKey{}, pt), "file")
+	finishFile(ctx, "file")
+
+	if len(reports) != 2 {
+		t.Fatalf("Tracker sent incorrect number of reports. Expected=2, received=%d", len(reports))
+	}
+	if reports[0].Done || !reports[1].Done {
+		t.Errorf("Reports have incorrect state: %+v", reports)
+	}
+}
+
+func TestProgressTracker_Stream(t *testing.T) {
+	filename := t.TempDir() + "/file.txt"
+	if err := os.WriteFile(filename, []byte("hello world\n"), 0600); err != nil {
+		t.Fatalf("Failed to create file: %s", err.Error())
+	}
+
+	var reports []Progress
+	pt := newProgressTracker(func(p Progress) { reports = append(reports, p) }, []string{filename, StdinFile})
+	pt.start = time.Now().Add(-2 * time.Second)
+	if pt.totalSize != -1 || pt.files[StdinFile].size != -1 {
+		t.Fatalf("Size of stream should be unknown, received total size %d", pt.totalSize)
+	}
+
+	pt.add(StdinFile, 1<<20)
+	pt.finish(StdinFile)
+
+	if len(reports) != 2 {
+		t.Fatalf("Tracker sent incorrect number of reports. Expected=2, received=%d", len(reports))
+	}
+	last := reports[1]
+	if last.Bytes != 1<<20 || last.TotalBytes != 1<<20 || last.TotalSize != -1 || last.ETA != -1 {
+		t.Errorf("Tracker sent incorrect report for stream: %+v", last)
+	}
+}
@@ -0,0 +1,96 @@
+package airlock
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// SymlinkMode determines how symbolic links are exported
+type SymlinkMode string
+
+const (
+	SymlinkSkip   SymlinkMode = "skip"   // Symbolic links are skipped with a warning
+	SymlinkFollow SymlinkMode = "follow" // The files and folders that symbolic links point to are exported in place of the links
+	SymlinkObject SymlinkMode = "object" // Symbolic links are exported as small objects that contain the target of the link
+)
+
+// errSymlinkLoop is returned if following a symbolic link would lead to a folder that is already being exported
+var errSymlinkLoop = errors.New("link points to a folder that contains it")
+
+// symlinkFile is the content of a symbolic link that is exported as an object
+type symlinkFile struct {
+	*strings.Reader
+	target string
+}
+
+func (symlinkFile) Close() error {
+	return nil
+}
+
+// SetSymlinkMode sets how symbolic links found by WalkDirs() are exported
+func SetSymlinkMode(mode SymlinkMode) {
+	ai.symlinks = mode
+}
+
+// GetSymlinkMode returns how symbolic links are exported
+func GetSymlinkMode() SymlinkMode {
+	if ai.symlinks == "" {
+		return SymlinkSkip
+	}
+
+	return ai.symlinks
+}
+
+// rootChain returns the chain of real folder paths a walk starting from `root` begins with
+func rootChain(root string) []string {
+	dir, err := realPath(filepath.Dir(root))
+	if err != nil {
+		return nil
+	}
+
+	return []string{filepath.Join(dir, filepath.Base(root))}
+}
+
+// resolveSymlink returns the file info and the real path of the file symbolic link `path` points to.
+// An error is returned if the link is broken, or if it points to a folder that is one of the folders
+// in `chain`, the folder containing the link, or a parent of these.
+func resolveSymlink(path string, chain []string) (fs.FileInfo, string, error) {
+	target, err := realPath(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("link is broken: %w", err)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return nil, "", err
+	}
+	if !info.IsDir() {
+		return info, target, nil
+	}
+
+	parent, err := realPath(filepath.Dir(path))
+	if err != nil {
+		return nil, "", err
+	}
+	for _, dir := range slices.Concat(chain, []string{parent}) {
+		if dir == target || strings.HasPrefix(dir, strings.TrimSuffix(target, "/")+"/") {
+			return nil, "", errSymlinkLoop
+		}
+	}
+
+	return info, target, nil
+}
+
+// realPath returns the absolute path of `path` with all symbolic links resolved
+func realPath(path string) (string, error) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Abs(path)
+}
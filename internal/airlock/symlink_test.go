@@ -0,0 +1,186 @@
+package airlock
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"testing"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+)
+
+// createSymlinkTree creates a folder with symbolic links to files, folders and
+// parent folders of the links, and returns the path of the folder
+func createSymlinkTree(t *testing.T) string {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"/data", "/other"} {
+		if err := os.MkdirAll(tmpDir+dir, 0755); err != nil {
+			t.Fatalf("Failed to create folder: %s", err.Error())
+		}
+	}
+	for _, file := range []string{"/data/file.txt", "/other/a.txt"} {
+		if err := os.WriteFile(tmpDir+file, []byte("content"), 0600); err != nil {
+			t.Fatalf("Failed to create file: %s", err.Error())
+		}
+	}
+	links := map[string]string{
+		"/data/loop":     "/data",
+		"/data/up":       "",
+		"/data/broken":   "/missing",
+		"/data/linked":   "/other",
+		"/data/filelink": "/other/a.txt",
+		"/other/back":    "/data",
+	}
+	for link, target := range links {
+		if err := os.Symlink(tmpDir+target, tmpDir+link); err != nil {
+			t.Fatalf("Failed to create symbolic link: %s", err.Error())
+		}
+	}
+
+	return tmpDir
+}
+
+func TestSetSymlinkMode(t *testing.T) {
+	origMode := ai.symlinks
+	defer func() { ai.symlinks = origMode }()
+
+	SetSymlinkMode("")
+	if mode := GetSymlinkMode(); mode != SymlinkSkip {
+		t.Errorf("Received incorrect mode. Expected=%s, received=%s", SymlinkSkip, mode)
+	}
+	SetSymlinkMode(SymlinkFollow)
+	if mode := GetSymlinkMode(); mode != SymlinkFollow {
+		t.Errorf("Received incorrect mode. Expected=%s, received=%s", SymlinkFollow, mode)
+	}
+}
+
+func TestWalkDirs_Symlinks(t *testing.T) {
+	origMode := ai.symlinks
+	origWarningf := logs.Warningf
+	defer func() {
+		ai.symlinks = origMode
+		logs.Warningf = origWarningf
+	}()
+	logs.Warningf = func(format string, args ...any) {}
+
+	tmpDir := createSymlinkTree(t)
+
+	var tests = []struct {
+		testname        string
+		mode            SymlinkMode
+		expectedFiles   []string
+		expectedObjects []string
+	}{
+		{
+			"OK_SKIP", SymlinkSkip,
+			[]string{tmpDir + "/data/file.txt"},
+			[]string{"data/file.txt.c4gh"},
+		},
+		{
+			"OK_FOLLOW", SymlinkFollow,
+			[]string{tmpDir + "/data/file.txt", tmpDir + "/data/filelink", tmpDir + "/data/linked/a.txt"},
+			[]string{"data/file.txt.c4gh", "data/filelink.c4gh", "data/linked/a.txt.c4gh"},
+		},
+		{
+			"OK_OBJECT", SymlinkObject,
+			[]string{
+				tmpDir + "/data/broken", tmpDir + "/data/file.txt", tmpDir + "/data/filelink",
+				tmpDir + "/data/linked", tmpDir + "/data/loop", tmpDir + "/data/up",
+			},
+			[]string{
+				"data/broken.c4gh", "data/file.txt.c4gh", "data/filelink.c4gh",
+				"data/linked.c4gh", "data/loop.c4gh", "data/up.c4gh",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			SetSymlinkMode(tt.mode)
+			set, err := WalkDirs([]string{tmpDir + "/data"}, nil, "bucket")
+			if err != nil {
+				t.Fatalf("Function returned unexpected error: %s", err.Error())
+			}
+			slices.Sort(set.Files)
+			slices.Sort(set.Objects)
+			if !reflect.DeepEqual(set.Files, tt.expectedFiles) {
+				t.Errorf("Function returned incorrect files\nExpected=%v\nReceived=%v", tt.expectedFiles, set.Files)
+			}
+			if !reflect.DeepEqual(set.Objects, tt.expectedObjects) {
+				t.Errorf("Function returned incorrect objects\nExpected=%v\nReceived=%v", tt.expectedObjects, set.Objects)
+			}
+		})
+	}
+}
+
+func TestResolveSymlink(t *testing.T) {
+	tmpDir := createSymlinkTree(t)
+	root := rootChain(tmpDir + "/data")
+
+	var tests = []struct {
+		testname, link string
+		chain          []string
+		err            error
+	}{
+		{"OK_FILE", "/data/filelink", root, nil},
+		{"OK_FOLDER", "/data/linked", root, nil},
+		{"FAIL_SELF", "/data/loop", root, errSymlinkLoop},
+		{"FAIL_PARENT", "/data/up", root, errSymlinkLoop},
+		{"FAIL_CHAIN", "/other/back", root, errSymlinkLoop},
+		{"FAIL_BROKEN", "/data/broken", root, os.ErrNotExist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			info, target, err := resolveSymlink(tmpDir+tt.link, tt.chain)
+			switch {
+			case tt.err != nil:
+				if !errors.Is(err, tt.err) {
+					t.Errorf("Function returned incorrect error. Expected=%v, received=%v", tt.err, err)
+				}
+			case err != nil:
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			case info == nil || target == "":
+				t.Errorf("Function did not return the target of the link")
+			}
+		})
+	}
+}
+
+func TestGetFileDetails_Symlink(t *testing.T) {
+	origMode := ai.symlinks
+	defer func() { ai.symlinks = origMode }()
+
+	tmpDir := createSymlinkTree(t)
+	SetSymlinkMode(SymlinkObject)
+
+	rc, size, err := getFileDetails(tmpDir + "/data/linked")
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	defer rc.Close()
+
+	target := tmpDir + "/other"
+	if expected := api.CalculateEncryptedSize(int64(len(target))) + headerSize; size != expected {
+		t.Errorf("Function returned incorrect size. Expected=%d, received=%d", expected, size)
+	}
+	if link, ok := rc.(symlinkFile); !ok || link.target != target {
+		t.Errorf("Function did not return symbolic link to %s", target)
+	}
+	if content, _ := io.ReadAll(rc); string(content) != target {
+		t.Errorf("Object has incorrect content\nExpected=%s\nReceived=%s", target, content)
+	}
+
+	// Regular files are read as usual
+	rc, _, err = getFileDetails(tmpDir + "/data/file.txt")
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	defer rc.Close()
+	if _, ok := rc.(*os.File); !ok {
+		t.Errorf("Function should have returned file, received %T", rc)
+	}
+}
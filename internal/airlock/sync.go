@@ -0,0 +1,251 @@
+package airlock
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+
+	"github.com/neicnordic/crypt4gh/streaming"
+	"golang.org/x/sync/errgroup"
+)
+
+// SyncReport describes how the selected files relate to the objects already in the bucket
+type SyncReport struct {
+	New       []string `json:"new"`
+	Changed   []string `json:"changed"`
+	Unchanged []string `json:"unchanged"`
+	Removed   []string `json:"removed"` // Objects whose files no longer exist locally
+}
+
+// sourceMetadata records the modification time and permissions of the local file in the metadata
+// of its object. The checksum of the content is added once the file has been uploaded.
+var sourceMetadata = func(file io.Reader) map[string]string {
+	meta := make(map[string]string)
+	if f, ok := file.(interface{ Stat() (fs.FileInfo, error) }); ok {
+		if info, err := f.Stat(); err == nil {
+			meta[api.MetaModified] = info.ModTime().UTC().Format(time.RFC3339Nano)
+			meta[api.MetaMode] = fmt.Sprintf("%04o", info.Mode().Perm())
+		} else {
+			logs.Warningf("Could not record modification time of file: %w", err)
+		}
+	}
+
+	return meta
+}
+
+// calculateChecksum returns the hex encoded SHA-256 checksum of the content of `rd`
+func calculateChecksum(rd io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, rd); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SyncSet compares the files in `set` to the objects that already exist in the bucket, and
+// removes the files whose objects are up to date. An object is up to date if its size matches the
+// size the file would be uploaded with, and either the modification time or the checksum in its metadata matches that of the file.
+// `selection` and `prefix` should be the same values that were given to WalkDirs().
+// Function assumes bucket exists.
+func SyncSet(set *UploadSet, selection []string, prefix string) (SyncReport, error) {
+	report := SyncReport{}
+
+	_, subfolder, _ := strings.Cut(filepath.Clean(prefix), "/")
+	if subfolder != "" {
+		subfolder += "/"
+	}
+
+	// these objects should already be sorted
+	path := api.SDConnect.ForPath() + "/" + api.GetProjectName() + "/" + set.Bucket
+	existingObjects, err := api.GetObjects(api.SDConnect, set.Bucket, path, "", subfolder)
+	if err != nil {
+		return report, fmt.Errorf("could not compare files to existing objects: %w", err)
+	}
+
+	changed := make([]bool, len(set.Objects))
+	var g errgroup.Group
+	g.SetLimit(numRoutines)
+
+	for i := range set.Objects {
+		idx, found := slices.BinarySearchFunc(existingObjects, set.Objects[i], func(meta api.Metadata, obj string) int {
+			return strings.Compare(meta.Name, obj)
+		})
+		if !found {
+			report.New = append(report.New, set.Files[i])
+
+			continue
+		}
+		set.Exists[i] = true
+
+		g.Go(func() error {
+			var err error
+			changed[i], err = fileChanged(set.Files[i], set.Bucket, set.Objects[i], existingObjects[idx].Size)
+
+			return err
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return report, fmt.Errorf("could not compare files to existing objects: %w", err)
+	}
+
+	report.Removed = removedObjects(existingObjects, set.Objects, selection, subfolder)
+
+	var files, objects []string
+	var exists []bool
+	for i := range set.Objects {
+		switch {
+		case !set.Exists[i]:
+		case changed[i]:
+			report.Changed = append(report.Changed, set.Files[i])
+		default:
+			report.Unchanged = append(report.Unchanged, set.Files[i])
+
+			continue
+		}
+		files = append(files, set.Files[i])
+		objects = append(objects, set.Objects[i])
+		exists = append(exists, set.Exists[i])
+	}
+	set.Files, set.Objects, set.Exists = files, objects, exists
+
+	return report, nil
+}
+
+// fileChanged determines if the local file differs from the object it was previously exported to
+var fileChanged = func(filename, bucket, object string, objectSize int64) (bool, error) {
+	size, err := uploadSize(filename)
+	if err != nil {
+		return false, err
+	}
+	if size != objectSize {
+		return true, nil
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return false, err
+	}
+
+	_, meta, err := api.GetObjectMetadata(api.SDConnect, bucket, object)
+	if err != nil {
+		return false, err
+	}
+
+	modified, err := time.Parse(time.RFC3339Nano, meta[api.MetaModified])
+	if err == nil && modified.Equal(info.ModTime()) {
+		return false, nil
+	}
+
+	// File may have been touched without its content changing
+	if checksum := meta[api.MetaChecksum]; checksum != "" {
+		file, err := os.Open(filename)
+		if err != nil {
+			return false, err
+		}
+		defer file.Close()
+
+		// The checksum describes the decrypted content of files that are uploaded as they are
+		var rd io.Reader = file
+		if isPassedThrough(filename) {
+			if rd, err = streaming.NewCrypt4GHReader(file, *ai.privateKey, nil); err != nil {
+				return false, fmt.Errorf("failed to create crypt4gh reader: %w", err)
+			}
+		}
+		localChecksum, err := calculateChecksum(rd)
+		if err != nil {
+			return false, err
+		}
+
+		return localChecksum != checksum, nil
+	}
+	logs.Debugf("Object %s has no matching modification time or checksum in its metadata", object)
+
+	return true, nil
+}
+
+// removedObjects returns the objects under the selected directories that no longer have a matching local file
+func removedObjects(existingObjects []api.Metadata, objects, selection []string, subfolder string) []string {
+	local := make(map[string]bool, len(objects))
+	for i := range objects {
+		local[objects[i]] = true
+	}
+
+	prefixes := make([]string, 0, len(selection))
+	for i := range selection {
+		root := filepath.Clean(selection[i])
+		if info, err := os.Stat(root); err == nil && info.IsDir() {
+			prefixes = append(prefixes, subfolder+filepath.Base(root)+"/")
+		}
+	}
+
+	var removed []string
+	for i := range existingObjects {
+		name := existingObjects[i].Name
+		if local[name] || !strings.HasSuffix(name, ".c4gh") {
+			continue
+		}
+		if slices.ContainsFunc(prefixes, func(p string) bool { return strings.HasPrefix(name, p) }) {
+			removed = append(removed, name)
+		}
+	}
+
+	return removed
+}
+
+// DeleteObjects removes objects and their headers in Vault from a bucket in SD Connect.
+// The headers are listed first, so that nothing is deleted if the gateway does not support
+// the headers endpoint, and only the headers that exist are deleted.
+// The objects that could not be deleted are returned with the errors that prevented it.
+func DeleteObjects(bucket string, objects []string) map[string]error {
+	var mu sync.Mutex
+	failed := make(map[string]error)
+
+	headers, err := api.ListHeaders(bucket)
+	if err != nil {
+		err = fmt.Errorf("objects were not deleted, as their headers could not be listed: %w", err)
+		logs.Error(err)
+		for i := range objects {
+			failed[objects[i]] = err
+		}
+
+		return failed
+	}
+
+	var g errgroup.Group
+	g.SetLimit(numRoutines)
+	for i := range objects {
+		g.Go(func() error {
+			logs.Infof("Deleting object %s from bucket %s", objects[i], bucket)
+			// The header is needed for as long as the object exists
+			err := api.DeleteObject(api.SDConnect, bucket, objects[i])
+			if err == nil && slices.Contains(headers, objects[i]) {
+				if err = api.DeleteHeader(bucket, objects[i]); err != nil {
+					err = fmt.Errorf("header of object %s was left in Vault: %w", objects[i], err)
+				}
+			}
+			if err != nil {
+				logs.Error(err)
+				mu.Lock()
+				failed[objects[i]] = err
+				mu.Unlock()
+			}
+
+			return nil
+		})
+	}
+	_ = g.Wait()
+
+	return failed
+}
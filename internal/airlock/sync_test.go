@@ -0,0 +1,275 @@
+package airlock
+
+import (
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"sda-filesystem/internal/api"
+)
+
+func TestSyncSet(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	if err := os.MkdirAll(tmpDir+"/dir/subdir", 0755); err != nil {
+		t.Fatalf("Failed to create folder: %s", err.Error())
+	}
+	files := []string{
+		tmpDir + "/file.txt", tmpDir + "/dir/new.txt", tmpDir + "/dir/subdir/old.txt",
+		tmpDir + "/dir/edited.txt", tmpDir + "/dir/touched.txt",
+	}
+	for i := range files {
+		if err := os.WriteFile(files[i], []byte("hello world\n"), 0600); err != nil {
+			t.Fatalf("Failed to create file: %s", err.Error())
+		}
+	}
+	modified := time.Date(2024, 5, 6, 7, 8, 9, 10, time.UTC)
+	if err := os.Chtimes(files[2], modified, modified); err != nil {
+		t.Fatalf("Failed to change file times: %s", err.Error())
+	}
+
+	origGetObjects := api.GetObjects
+	origGetObjectMetadata := api.GetObjectMetadata
+	defer func() {
+		api.GetObjects = origGetObjects
+		api.GetObjectMetadata = origGetObjectMetadata
+	}()
+
+	encryptedSize := api.CalculateEncryptedSize(12)
+	api.GetObjects = func(rep api.Repo, bucket, path, owner, prefix string) ([]api.Metadata, error) {
+		if prefix != "subfolder/" {
+			t.Errorf("api.GetObjects() received incorrect prefix. Expected=subfolder/, received=%s", prefix)
+		}
+
+		return []api.Metadata{
+			{Name: "subfolder/dir/deleted.txt.c4gh", Size: 60},
+			{Name: "subfolder/dir/edited.txt.c4gh", Size: encryptedSize},
+			{Name: "subfolder/dir/notes.txt", Size: 60},
+			{Name: "subfolder/dir/subdir/old.txt.c4gh", Size: encryptedSize},
+			{Name: "subfolder/dir/touched.txt.c4gh", Size: encryptedSize},
+			{Name: "subfolder/file.txt.c4gh", Size: 5},
+			{Name: "subfolder/other/deleted.txt.c4gh", Size: 60},
+		}, nil
+	}
+	api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+		switch object {
+		case "subfolder/dir/subdir/old.txt.c4gh":
+			return encryptedSize, map[string]string{api.MetaModified: modified.Format(time.RFC3339Nano)}, nil
+		case "subfolder/dir/touched.txt.c4gh":
+			return encryptedSize, map[string]string{
+				api.MetaModified: "2020-01-01T00:00:00Z",
+				api.MetaChecksum: "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447",
+			}, nil
+		case "subfolder/dir/edited.txt.c4gh":
+			return encryptedSize, map[string]string{
+				api.MetaModified: "2020-01-01T00:00:00Z",
+				api.MetaChecksum: "ed7002b439e9ac845f22357d822bac1444730fbdb6016d3ec9432297b9ec9f73",
+			}, nil
+		}
+
+		return encryptedSize, map[string]string{api.MetaModified: "2020-01-01T00:00:00Z"}, nil
+	}
+
+	set := UploadSet{
+		Bucket: "bucket",
+		Files:  files,
+		Objects: []string{
+			"subfolder/file.txt.c4gh", "subfolder/dir/new.txt.c4gh",
+			"subfolder/dir/subdir/old.txt.c4gh", "subfolder/dir/edited.txt.c4gh",
+			"subfolder/dir/touched.txt.c4gh",
+		},
+		Exists: make([]bool, 5),
+	}
+
+	report, err := SyncSet(&set, []string{tmpDir + "/file.txt", tmpDir + "/dir"}, "bucket/subfolder")
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+
+	expectedReport := SyncReport{
+		New:       []string{files[1]},
+		Changed:   []string{files[0], files[3]},
+		Unchanged: []string{files[2], files[4]},
+		Removed:   []string{"subfolder/dir/deleted.txt.c4gh"},
+	}
+	expectedSet := UploadSet{
+		Bucket:  "bucket",
+		Files:   []string{files[0], files[1], files[3]},
+		Objects: []string{"subfolder/file.txt.c4gh", "subfolder/dir/new.txt.c4gh", "subfolder/dir/edited.txt.c4gh"},
+		Exists:  []bool{true, false, true},
+	}
+	if !reflect.DeepEqual(expectedReport, report) {
+		t.Errorf("Function returned incorrect report\nExpected=%v\nReceived=%v", expectedReport, report)
+	}
+	if !reflect.DeepEqual(expectedSet, set) {
+		t.Errorf("Function modified set incorrectly\nExpected=%v\nReceived=%v", expectedSet, set)
+	}
+}
+
+func TestSyncSet_Error(t *testing.T) {
+	origGetObjects := api.GetObjects
+	origGetObjectMetadata := api.GetObjectMetadata
+	defer func() {
+		api.GetObjects = origGetObjects
+		api.GetObjectMetadata = origGetObjectMetadata
+	}()
+
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(tmpDir+"/file.txt", []byte("hello world\n"), 0600); err != nil {
+		t.Fatalf("Failed to create file: %s", err.Error())
+	}
+
+	var tests = []struct {
+		testname, errStr    string
+		objectsErr, headErr error
+	}{
+		{"FAIL_LIST", "could not compare files to existing objects: " + errExpected.Error(), errExpected, nil},
+		{"FAIL_HEAD", "could not compare files to existing objects: " + errExpected.Error(), nil, errExpected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			api.GetObjects = func(rep api.Repo, bucket, path, owner, prefix string) ([]api.Metadata, error) {
+				return []api.Metadata{{Name: "file.txt.c4gh", Size: api.CalculateEncryptedSize(12)}}, tt.objectsErr
+			}
+			api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+				return 0, nil, tt.headErr
+			}
+
+			set := UploadSet{Bucket: "bucket", Files: []string{tmpDir + "/file.txt"}, Objects: []string{"file.txt.c4gh"}, Exists: []bool{false}}
+			if _, err := SyncSet(&set, []string{tmpDir + "/file.txt"}, "bucket"); err == nil {
+				t.Error("Function did not return error")
+			} else if err.Error() != tt.errStr {
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+		})
+	}
+}
+
+func TestSourceMetadata(t *testing.T) {
+	filename := t.TempDir() + "/file.txt"
+	if err := os.WriteFile(filename, []byte("content"), 0600); err != nil {
+		t.Fatalf("Failed to create file: %s", err.Error())
+	}
+	modified := time.Date(2023, 1, 2, 3, 4, 5, 6, time.UTC)
+	if err := os.Chtimes(filename, modified, modified); err != nil {
+		t.Fatalf("Failed to change file times: %s", err.Error())
+	}
+	if err := os.Chmod(filename, 0750); err != nil {
+		t.Fatalf("Failed to change file mode: %s", err.Error())
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		t.Fatalf("Failed to open file: %s", err.Error())
+	}
+	defer file.Close()
+
+	var tests = []struct {
+		testname string
+		file     io.Reader
+		expected map[string]string
+	}{
+		{"OK_FILE", file, map[string]string{api.MetaModified: "2023-01-02T03:04:05.000000006Z", api.MetaMode: "0750"}},
+		{"OK_STREAM", io.MultiReader(strings.NewReader("content")), map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			meta := sourceMetadata(tt.file)
+			if !reflect.DeepEqual(tt.expected, meta) {
+				t.Errorf("Function returned incorrect metadata\nExpected=%v\nReceived=%v", tt.expected, meta)
+			}
+			// The content is read only once, during the upload
+			if data, _ := io.ReadAll(tt.file); string(data) != "content" {
+				t.Errorf("Function read the file, remaining content %q", data)
+			}
+		})
+	}
+}
+
+func TestDeleteObjects(t *testing.T) {
+	origDeleteObject := api.DeleteObject
+	origDeleteHeader := api.DeleteHeader
+	origListHeaders := api.ListHeaders
+	defer func() {
+		api.DeleteObject = origDeleteObject
+		api.DeleteHeader = origDeleteHeader
+		api.ListHeaders = origListHeaders
+	}()
+
+	var mu sync.Mutex
+	var headers []string
+	api.ListHeaders = func(bucket string) ([]string, error) {
+		return []string{"another.c4gh", "bad.c4gh", "good.c4gh", "header.c4gh"}, nil
+	}
+	api.DeleteObject = func(rep api.Repo, bucket, object string) error {
+		if object == "bad.c4gh" {
+			return errExpected
+		}
+
+		return nil
+	}
+	api.DeleteHeader = func(bucket, object string) error {
+		if object == "header.c4gh" {
+			return errExpected
+		}
+		mu.Lock()
+		headers = append(headers, object)
+		mu.Unlock()
+
+		return nil
+	}
+
+	if failed := DeleteObjects("bucket", []string{"good.c4gh", "another.c4gh", "no-header.c4gh"}); len(failed) != 0 {
+		t.Errorf("Function returned unexpected failures: %v", failed)
+	}
+	slices.Sort(headers)
+	if expected := []string{"another.c4gh", "good.c4gh"}; !slices.Equal(headers, expected) {
+		t.Errorf("Function deleted incorrect headers\nExpected=%v\nReceived=%v", expected, headers)
+	}
+
+	headers = nil
+	failed := DeleteObjects("bucket", []string{"good.c4gh", "bad.c4gh", "header.c4gh"})
+	if len(failed) != 2 {
+		t.Errorf("Function returned incorrect failures: %v", failed)
+	}
+	if !errors.Is(failed["bad.c4gh"], errExpected) {
+		t.Errorf("Function returned incorrect error for bad.c4gh: %v", failed["bad.c4gh"])
+	}
+	errStr := "header of object header.c4gh was left in Vault: " + errExpected.Error()
+	if err := failed["header.c4gh"]; err == nil || err.Error() != errStr {
+		t.Errorf("Function returned incorrect error for header.c4gh\nExpected=%s\nReceived=%v", errStr, err)
+	}
+	if !slices.Equal(headers, []string{"good.c4gh"}) {
+		t.Errorf("Header of an object that was not deleted should have been kept, deleted %v", headers)
+	}
+
+	// Nothing is deleted if the headers cannot be listed
+	headers = nil
+	deleted := false
+	api.DeleteObject = func(rep api.Repo, bucket, object string) error {
+		deleted = true
+
+		return nil
+	}
+	api.ListHeaders = func(bucket string) ([]string, error) {
+		return nil, errExpected
+	}
+	failed = DeleteObjects("bucket", []string{"good.c4gh", "another.c4gh"})
+	errStr = "objects were not deleted, as their headers could not be listed: " + errExpected.Error()
+	for _, object := range []string{"good.c4gh", "another.c4gh"} {
+		if err := failed[object]; err == nil || err.Error() != errStr {
+			t.Errorf("Function returned incorrect error for %s\nExpected=%s\nReceived=%v", object, errStr, err)
+		}
+	}
+	if deleted || len(headers) > 0 {
+		t.Errorf("Objects or headers were deleted even though headers could not be listed")
+	}
+}
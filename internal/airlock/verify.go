@@ -0,0 +1,96 @@
+package airlock
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+)
+
+// ErrNoChecksum is returned when an object was not exported with a checksum in its metadata
+var ErrNoChecksum = errors.New("object has no checksum in its metadata")
+
+// VerifyObject decrypts an SD Connect object and checks that its content matches
+// the checksum that was stored in the object's metadata during export
+var VerifyObject = func(bucket, object string) error {
+	_, meta, err := api.GetObjectMetadata(api.SDConnect, bucket, object)
+	if err != nil {
+		return err
+	}
+	expected := meta[api.MetaChecksum]
+	if expected == "" {
+		return ErrNoChecksum
+	}
+
+	rc, err := api.ReadObject(bucket, object)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	checksum, err := calculateChecksum(rc)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt object %s: %w", object, err)
+	}
+	if checksum != expected {
+		return fmt.Errorf("checksum of object %s does not match: expected %s, calculated %s", object, expected, checksum)
+	}
+
+	return nil
+}
+
+// SetDecryptCheck determines whether the first and last block of each uploaded object
+// are decrypted after the upload, in addition to checking its size and header
+func SetDecryptCheck(enabled bool) {
+	ai.decryptCheck = enabled
+}
+
+// GetDecryptCheck returns whether uploaded objects are partly decrypted after the upload
+func GetDecryptCheck() bool {
+	return ai.decryptCheck
+}
+
+// verifyUpload checks that an object that was just uploaded has the expected headerless size `objectSize`
+// and that its header can be retrieved from Vault. If enabled, the first and last block of the object
+// are also decrypted.
+var verifyUpload = func(bucket, object string, objectSize int64) error {
+	size, _, err := api.GetObjectMetadata(api.SDConnect, bucket, object)
+	if err != nil {
+		return err
+	}
+	if size != objectSize {
+		return fmt.Errorf("object %s has size %d, expected %d", object, size, objectSize)
+	}
+
+	header, err := api.GetFileHeader(api.SDConnect, bucket, object, "", "")
+	if err != nil {
+		return fmt.Errorf("failed to retrieve header of object %s from Vault: %w", object, err)
+	}
+	if header == "" {
+		return fmt.Errorf("header of object %s was not found in Vault", object)
+	}
+
+	contentSize := api.CalculateDecryptedSize(objectSize)
+	if !ai.decryptCheck || contentSize == 0 {
+		return nil
+	}
+
+	nodes := append([]string{bucket}, strings.Split(object, "/")...)
+	lastBlock := (contentSize - 1) / api.BlockSize * api.BlockSize
+	for _, start := range []int64{0, lastBlock} {
+		end := min(start+api.BlockSize, contentSize)
+		data, err := api.DownloadData(api.SDConnect, nodes, bucket+"/"+object, "", "", header, start, end, 0, contentSize)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt object %s: %w", object, err)
+		}
+		if int64(len(data)) != end-start {
+			return fmt.Errorf("decrypted block [%d, %d) of object %s has size %d", start, end, object, len(data))
+		}
+	}
+	api.DeleteFileFromCache(api.SDConnect, nodes, contentSize)
+	logs.Debugf("Decrypted first and last block of object %s", object)
+
+	return nil
+}
@@ -0,0 +1,189 @@
+package airlock
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"sda-filesystem/internal/api"
+)
+
+func TestVerifyObject(t *testing.T) {
+	origGetObjectMetadata := api.GetObjectMetadata
+	origReadObject := api.ReadObject
+	defer func() {
+		api.GetObjectMetadata = origGetObjectMetadata
+		api.ReadObject = origReadObject
+	}()
+
+	checksum := "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447" // "hello world\n"
+
+	var tests = []struct {
+		testname, content, checksum, errStr string
+		metaErr, readErr                    error
+	}{
+		{
+			"OK", "hello world\n", checksum, "", nil, nil,
+		},
+		{
+			"FAIL_MISMATCH", "hello world!\n", checksum,
+			"checksum of object dir/file.txt.c4gh does not match: expected " + checksum +
+				", calculated ecf701f727d9e2d77c4aa49ac6fbbcc997278aca010bddeeb961c10cf54d435a", nil, nil,
+		},
+		{
+			"FAIL_NO_CHECKSUM", "hello world\n", "", ErrNoChecksum.Error(), nil, nil,
+		},
+		{
+			"FAIL_METADATA", "hello world\n", checksum, errExpected.Error(), errExpected, nil,
+		},
+		{
+			"FAIL_READ", "hello world\n", checksum, errExpected.Error(), nil, errExpected,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+				if bucket != "bucket" || object != "dir/file.txt.c4gh" {
+					t.Errorf("api.GetObjectMetadata() received incorrect object %s/%s", bucket, object)
+				}
+
+				return 0, map[string]string{api.MetaChecksum: tt.checksum}, tt.metaErr
+			}
+			api.ReadObject = func(bucket, object string) (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader(tt.content)), tt.readErr
+			}
+
+			err := VerifyObject("bucket", "dir/file.txt.c4gh")
+			switch {
+			case tt.errStr == "":
+				if err != nil {
+					t.Errorf("Function returned unexpected error: %s", err.Error())
+				}
+			case err == nil:
+				t.Error("Function did not return error")
+			case err.Error() != tt.errStr:
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+			if tt.testname == "FAIL_NO_CHECKSUM" && !errors.Is(err, ErrNoChecksum) {
+				t.Errorf("Function should have returned ErrNoChecksum")
+			}
+		})
+	}
+}
+
+func TestVerifyUpload(t *testing.T) {
+	origGetObjectMetadata := api.GetObjectMetadata
+	origGetFileHeader := api.GetFileHeader
+	origDownloadData := api.DownloadData
+	origDeleteFileFromCache := api.DeleteFileFromCache
+	origDecryptCheck := ai.decryptCheck
+	defer func() {
+		api.GetObjectMetadata = origGetObjectMetadata
+		api.GetFileHeader = origGetFileHeader
+		api.DownloadData = origDownloadData
+		api.DeleteFileFromCache = origDeleteFileFromCache
+		ai.decryptCheck = origDecryptCheck
+	}()
+
+	contentSize := 2*api.BlockSize + 100
+	objectSize := api.CalculateEncryptedSize(contentSize)
+
+	var tests = []struct {
+		testname, header, errStr string
+		size                     int64
+		decryptCheck             bool
+		metaErr, headerErr       error
+		downloadErr              error
+		downloadShort            bool
+		expectedRanges           [][2]int64
+	}{
+		{
+			"OK", "header", "", objectSize, false, nil, nil, nil, false, nil,
+		},
+		{
+			"OK_DECRYPT", "header", "", objectSize, true, nil, nil, nil, false,
+			[][2]int64{{0, api.BlockSize}, {2 * api.BlockSize, contentSize}},
+		},
+		{
+			"FAIL_SIZE", "header", "object dir/file.txt.c4gh has size 100, expected " + strconv.FormatInt(objectSize, 10),
+			100, false, nil, nil, nil, false, nil,
+		},
+		{
+			"FAIL_METADATA", "header", errExpected.Error(), objectSize, false, errExpected, nil, nil, false, nil,
+		},
+		{
+			"FAIL_HEADER", "", "failed to retrieve header of object dir/file.txt.c4gh from Vault: " + errExpected.Error(),
+			objectSize, false, nil, errExpected, nil, false, nil,
+		},
+		{
+			"FAIL_NO_HEADER", "", "header of object dir/file.txt.c4gh was not found in Vault",
+			objectSize, false, nil, nil, nil, false, nil,
+		},
+		{
+			"FAIL_DECRYPT", "header", "failed to decrypt object dir/file.txt.c4gh: " + errExpected.Error(),
+			objectSize, true, nil, nil, errExpected, false, [][2]int64{{0, api.BlockSize}},
+		},
+		{
+			"FAIL_SHORT_BLOCK", "header", "decrypted block [0, 65536) of object dir/file.txt.c4gh has size 65535",
+			objectSize, true, nil, nil, nil, true, [][2]int64{{0, api.BlockSize}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			ai.decryptCheck = tt.decryptCheck
+			api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+				if bucket != "bucket" || object != "dir/file.txt.c4gh" {
+					t.Errorf("api.GetObjectMetadata() received incorrect object %s/%s", bucket, object)
+				}
+
+				return tt.size, nil, tt.metaErr
+			}
+			api.GetFileHeader = func(rep api.Repo, bucket, object, owner, id string) (string, error) {
+				if rep != api.SDConnect || bucket != "bucket" || object != "dir/file.txt.c4gh" {
+					t.Errorf("api.GetFileHeader() received incorrect object %s/%s/%s", rep, bucket, object)
+				}
+
+				return tt.header, tt.headerErr
+			}
+			var ranges [][2]int64
+			api.DownloadData = func(rep api.Repo, nodes []string, path, owner, fileID, header string,
+				start, end, oldOffset, fileSize int64,
+			) ([]byte, error) {
+				if !slices.Equal(nodes, []string{"bucket", "dir", "file.txt.c4gh"}) {
+					t.Errorf("api.DownloadData() received incorrect nodes %v", nodes)
+				}
+				if header != tt.header || fileSize != contentSize {
+					t.Errorf("api.DownloadData() received incorrect header %s or file size %d", header, fileSize)
+				}
+				ranges = append(ranges, [2]int64{start, end})
+				if tt.downloadShort {
+					end--
+				}
+
+				return make([]byte, end-start), tt.downloadErr
+			}
+			api.DeleteFileFromCache = func(rep api.Repo, nodes []string, size int64) {}
+
+			err := verifyUpload("bucket", "dir/file.txt.c4gh", objectSize)
+			switch {
+			case tt.errStr == "":
+				if err != nil {
+					t.Errorf("Function returned unexpected error: %s", err.Error())
+				}
+			case err == nil:
+				t.Error("Function did not return error")
+			case err.Error() != tt.errStr:
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+			if !reflect.DeepEqual(ranges, tt.expectedRanges) {
+				t.Errorf("Function decrypted incorrect blocks\nExpected=%v\nReceived=%v", tt.expectedRanges, ranges)
+			}
+		})
+	}
+}
@@ -32,8 +32,10 @@ var Version string // Defined at build time to indicate current binary version
 
 var ai = apiInfo{
 	hi: httpInfo{
-		httpRetry: 3,
-		client:    &http.Client{Transport: http.DefaultTransport},
+		httpRetry:    3,
+		client:       &http.Client{Transport: http.DefaultTransport},
+		uploadRate:   &rateLimiter{},
+		downloadRate: &rateLimiter{},
 	},
 	vi: vaultInfo{
 		keyName: uuid.NewString(),
@@ -62,7 +64,8 @@ type apiInfo struct {
 	sessionExpiredFun func()
 	scanResultFun     func(bool)
 	userProfile       profile
-	findataUpload     bool // If findata upload is possible
+	findataUpload     bool            // If findata upload is possible
+	config            *configResponse // configuration.json as it was received in Setup
 	hi                httpInfo
 	vi                vaultInfo
 	ui                unixInfo
@@ -74,6 +77,9 @@ type httpInfo struct {
 	endpoints apiEndpoints
 	client    *http.Client
 	s3Client  *s3.Client
+
+	uploadRate   *rateLimiter
+	downloadRate *rateLimiter
 }
 
 type unixInfo struct {
@@ -200,9 +206,20 @@ func GetVersion() string {
 	return Version
 }
 
-// GetEnv looks up environment variable given in `name`
+// envDefaults contains the values used for environment variables that are not set
+var envDefaults = make(map[string]string)
+
+// SetEnvDefault sets the value used for environment variable `name` when the variable is not set
+func SetEnvDefault(name, value string) {
+	envDefaults[name] = value
+}
+
+// GetEnv looks up environment variable given in `name`, or its default value if it is not set
 var GetEnv = func(name string, verifyURL bool) (string, error) {
 	env, ok := os.LookupEnv(name)
+	if !ok {
+		env, ok = envDefaults[name]
+	}
 	if !ok {
 		return "", fmt.Errorf("environment variable %s not set", name)
 	}
@@ -215,18 +232,11 @@ var GetEnv = func(name string, verifyURL bool) (string, error) {
 	return env, nil
 }
 
-// Setup reads the necessary environment varibles needed for requests,
-// generates key pair for vault, and initialises s3 client.
-// `files` contains all the files from the `certs` directory.
-func Setup(files FileReader) error {
-	var err error
-	ai.token, err = GetEnv("SDS_ACCESS_TOKEN", false)
-	if err != nil {
-		return fmt.Errorf("required environment variables missing: %w", err)
-	}
+// GetURLs returns the URL of the proxy and the URL of configuration.json based on the environment variables
+var GetURLs = func() (string, string, error) {
 	proxy, err := GetEnv("PROXY_URL", true)
 	if err != nil {
-		return fmt.Errorf("required environment variables missing: %w", err)
+		return "", "", fmt.Errorf("required environment variables missing: %w", err)
 	}
 
 	var config string
@@ -237,7 +247,7 @@ func Setup(files FileReader) error {
 		if _, ok := os.LookupEnv("OVERRIDE_PROXY_URL"); ok {
 			proxy, err = GetEnv("OVERRIDE_PROXY_URL", true)
 			if err != nil {
-				return fmt.Errorf("invalid environment variable: %w", err)
+				return "", "", fmt.Errorf("invalid environment variable: %w", err)
 			}
 		} else {
 			proxyURL, _ := url.ParseRequestURI(proxy)
@@ -248,8 +258,26 @@ func Setup(files FileReader) error {
 
 		config = proxy + "/static/configuration.json"
 	} else if config, err = GetEnv("CONFIG_ENDPOINT", true); err != nil {
+		return "", "", fmt.Errorf("required environment variables missing: %w", err)
+	}
+
+	return proxy, config, nil
+}
+
+// Setup reads the necessary environment varibles needed for requests,
+// generates key pair for vault, and initialises s3 client.
+// `files` contains all the files from the `certs` directory.
+var Setup = func(files FileReader) error {
+	var err error
+	ai.token, err = GetEnv("SDS_ACCESS_TOKEN", false)
+	if err != nil {
 		return fmt.Errorf("required environment variables missing: %w", err)
 	}
+	logs.AddSecret(ai.token)
+	proxy, config, err := GetURLs()
+	if err != nil {
+		return err
+	}
 
 	ai.proxy = "" // So that GUI reload works during development
 	// This needs to be called first before any other http requests
@@ -288,6 +316,7 @@ func getAPIEndpoints(url string) error {
 		return err
 	}
 
+	ai.config = &resp
 	ai.hi.endpoints = convertConfig(resp)
 	ai.findataUpload = resp.FindataUpload
 
@@ -314,7 +343,7 @@ func convertConfig(cfg configResponse) (ep apiEndpoints) {
 	return
 }
 
-func GetProfileCLI() (bool, error) {
+var GetProfileCLI = func() (bool, error) {
 	return GetProfile(func() {}, func(bool) {})
 }
 
@@ -328,6 +357,7 @@ func GetProfile(sessionFun func(), scanFun func(bool)) (bool, error) {
 		return false, fmt.Errorf("required environment variables missing: %w", err)
 	}
 	ai.token = ai.userProfile.DesktopToken
+	logs.AddSecret(ai.token)
 
 	if ai.userProfile.SDConnect {
 		ai.repositories = []Repo{SDApply, SDConnect}
@@ -347,6 +377,8 @@ func GetProfile(sessionFun func(), scanFun func(bool)) (bool, error) {
 // Authenticate checks that user passes the authentication checks in KrakenD with the given password.
 var Authenticate = func(password string) error {
 	ai.password = base64.StdEncoding.EncodeToString([]byte(password))
+	logs.AddSecret(password)
+	logs.AddSecret(ai.password)
 	err := makeRequest("GET", ai.hi.endpoints.Password, nil, nil, nil, nil)
 	if err != nil {
 		ai.password = ""
@@ -371,20 +403,15 @@ var loadCertificates = func(certFiles FileReader) error {
 	if err != nil {
 		return fmt.Errorf("could not parse proxy url: %w", err)
 	}
-	proxyHost := u.Hostname()
-
-	certBytes, err1 := certFiles.ReadFile(proxyHost + ".crt")
-	keyBytes, err2 := certFiles.ReadFile(proxyHost + ".key")
-	if err1 != nil || err2 != nil {
-		err = errors.Join(errors.New("disabled mTLS for S3 upload"), err1, err2)
-		logs.Warning(err)
+	cert, err := readCertificate(certFiles, u.Hostname())
+	var missing *missingCertificateError
+	if errors.As(err, &missing) {
+		logs.Warning(errors.Join(errors.New("disabled mTLS for S3 upload"), missing.err))
 
 		return nil
 	}
-
-	cert, err := tls.X509KeyPair(certBytes, keyBytes)
 	if err != nil {
-		return fmt.Errorf("failed to load client x509 key pair for host %s: %w", proxyHost, err)
+		return err
 	}
 
 	logs.Debugf("Client certificates will expire at %s", cert.Leaf.NotAfter.String())
@@ -401,6 +428,35 @@ var loadCertificates = func(certFiles FileReader) error {
 	return nil
 }
 
+// missingCertificateError is returned by readCertificate if the certificate or its key does not exist
+type missingCertificateError struct {
+	err error
+}
+
+func (e *missingCertificateError) Error() string {
+	return e.err.Error()
+}
+
+func (e *missingCertificateError) Unwrap() error {
+	return e.err
+}
+
+// readCertificate reads and parses the client certificate <host>.crt and its key <host>.key from `certFiles`
+func readCertificate(certFiles FileReader, host string) (tls.Certificate, error) {
+	certBytes, err1 := certFiles.ReadFile(host + ".crt")
+	keyBytes, err2 := certFiles.ReadFile(host + ".key")
+	if err1 != nil || err2 != nil {
+		return tls.Certificate{}, &missingCertificateError{err: errors.Join(err1, err2)}
+	}
+
+	cert, err := tls.X509KeyPair(certBytes, keyBytes)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to load client x509 key pair for host %s: %w", host, err)
+	}
+
+	return cert, nil
+}
+
 // GetAllRepositories returns the list of all possible repositories the user can access.
 // Findata is not listed because it is only used for export
 func GetAllRepositories() []Repo {
@@ -450,6 +506,24 @@ var IsProjectManager = func() bool {
 	return ai.userProfile.PI
 }
 
+var S3AccessEnabled = func() bool {
+	return ai.userProfile.S3Access
+}
+
+// GetConfiguration returns configuration.json as it was received in Setup, with secrets redacted.
+// The result is nil if Setup has not fetched it.
+var GetConfiguration = func() ([]byte, error) {
+	if ai.config == nil {
+		return nil, nil
+	}
+	data, err := json.MarshalIndent(ai.config, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode configuration.json: %w", err)
+	}
+
+	return []byte(logs.Redact(string(data))), nil
+}
+
 // makeRequest sends HTTP request to KrakenD and parses the response
 var makeRequest = func(method string, ep endpoint, query, headers map[string]string, reqBody io.ReadSeeker, ret any) error {
 	var response *http.Response
@@ -485,13 +559,15 @@ var makeRequest = func(method string, ep endpoint, query, headers map[string]str
 
 	escapedURL := strings.ReplaceAll(request.URL.EscapedPath(), "\n", "")
 	escapedURL = strings.ReplaceAll(escapedURL, "\r", "")
+	logger := logs.WithSubsystem("api").WithFields(logs.Fields{logs.FieldRequestID: uuid.NewString()})
+	start := time.Now()
 
 	// Execute HTTP request
 	// Retry the request as specified by ai.hi.httpRetry variable
 	count := 0
 	for {
 		response, err = ai.hi.client.Do(request)
-		logs.Debugf("Trying Request %s, attempt %d/%d", escapedURL, count+1, ai.hi.httpRetry)
+		logger.Debugf("Trying Request %s, attempt %d/%d", escapedURL, count+1, ai.hi.httpRetry)
 		count++
 
 		if err != nil && count >= ai.hi.httpRetry {
@@ -517,6 +593,8 @@ var makeRequest = func(method string, ep endpoint, query, headers map[string]str
 		if err != nil {
 			return fmt.Errorf("failed to read error response: %w", err)
 		}
+		logger.WithFields(logs.Fields{logs.FieldDuration: time.Since(start)}).
+			Debugf("Request %s failed with status %d", escapedURL, response.StatusCode)
 
 		return &RequestError{response.StatusCode, string(respBody)}
 	}
@@ -528,7 +606,7 @@ var makeRequest = func(method string, ep endpoint, query, headers map[string]str
 		}
 	}
 
-	logs.Debugf("Request %s returned a response", escapedURL)
+	logger.WithFields(logs.Fields{logs.FieldDuration: time.Since(start)}).Debugf("Request %s returned a response", escapedURL)
 
 	return nil
 }
@@ -548,6 +626,15 @@ var ClearCache = func() {
 	downloadCache.Clear()
 }
 
+// GetCacheStats returns the statistics of the download cache
+var GetCacheStats = func() cache.Stats {
+	if downloadCache == nil {
+		return cache.Stats{}
+	}
+
+	return downloadCache.Stats()
+}
+
 // DeleteFileFromCache clears all entries from a given file/object from cache
 var DeleteFileFromCache = func(rep Repo, nodes []string, size int64) {
 	i := int64(0)
@@ -224,6 +224,26 @@ func TestGetEnv(t *testing.T) {
 	}
 }
 
+func TestGetEnv_Default(t *testing.T) {
+	defer delete(envDefaults, "MUUTTUJA5521")
+
+	os.Unsetenv("MUUTTUJA5521")
+	SetEnvDefault("MUUTTUJA5521", "https://example.com")
+	if value, err := GetEnv("MUUTTUJA5521", true); err != nil {
+		t.Errorf("Returned unexpected err: %s", err.Error())
+	} else if value != "https://example.com" {
+		t.Errorf("Environment variable has incorrect value. Expected=https://example.com, received=%s", value)
+	}
+
+	// Environment variable takes precedence over the default
+	t.Setenv("MUUTTUJA5521", "https://github.com")
+	if value, err := GetEnv("MUUTTUJA5521", true); err != nil {
+		t.Errorf("Returned unexpected err: %s", err.Error())
+	} else if value != "https://github.com" {
+		t.Errorf("Environment variable has incorrect value. Expected=https://github.com, received=%s", value)
+	}
+}
+
 func TestSetup(t *testing.T) {
 	origGetEnv := GetEnv
 	origMakeRequest := makeRequest
@@ -1253,6 +1273,47 @@ func TestDeleteFileFromCache(t *testing.T) {
 	}
 }
 
+func TestGetCacheStats(t *testing.T) {
+	origCache := downloadCache
+	defer func() { downloadCache = origCache }()
+
+	downloadCache = nil
+	if stats := GetCacheStats(); stats != (cache.Stats{}) {
+		t.Errorf("Expected empty statistics without cache, received=%+v", stats)
+	}
+
+	downloadCache = &cache.Ristretto{Cacheable: &mockCache{}}
+	if stats := GetCacheStats(); stats != (cache.Stats{}) {
+		t.Errorf("Expected empty statistics from mock cache, received=%+v", stats)
+	}
+}
+
+func TestGetConfiguration(t *testing.T) {
+	origConfig := ai.config
+	defer func() { ai.config = origConfig }()
+
+	ai.config = nil
+	if data, err := GetConfiguration(); err != nil || data != nil {
+		t.Errorf("Expected nothing before Setup, received data=%s, err=%v", data, err)
+	}
+
+	ai.config = &configResponse{FindataUpload: true}
+	ai.config.Timeouts.Default = 10
+	ai.config.Endpoints.Profile = "/profile?token=0123456789abcdef0123"
+	data, err := GetConfiguration()
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+
+	var received configResponse
+	if err = json.Unmarshal(data, &received); err != nil {
+		t.Fatalf("Function returned invalid JSON: %s", err.Error())
+	}
+	if received.Endpoints.Profile != "/profile?token=[REDACTED]" || received.Timeouts.Default != 10 || !received.FindataUpload {
+		t.Errorf("Function returned incorrect configuration %s", data)
+	}
+}
+
 func handleConnection(conn net.Conn, expectedData string, errc chan<- error) {
 	cmdPrefix := "zINSTREAM\x00"
 	defer conn.Close()
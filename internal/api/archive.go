@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Keys of the user-defined object metadata of archive objects
+const MetaArchiveIndex = "archive-index"
+const MetaArchiveCompression = "archive-compression"
+
+// ArchiveSuffix is the suffix of uncompressed archive objects, which the filesystem is able to browse
+const ArchiveSuffix = ".tar.c4gh"
+
+// ArchiveMember describes a file stored in an archive object
+type ArchiveMember struct {
+	Name     string    `json:"name"`
+	Offset   int64     `json:"offset"` // Position of the content in the uncompressed tar
+	Size     int64     `json:"size"`
+	Mode     int64     `json:"mode"`
+	Modified time.Time `json:"modified"`
+	Checksum string    `json:"sha256"`
+}
+
+// ArchiveIndexObject returns the name of the object that stores the member index of archive `object`
+func ArchiveIndexObject(object string) string {
+	return strings.TrimSuffix(object, ".c4gh") + ".index.json.c4gh"
+}
+
+// GetArchiveIndex returns the members of an archive object in SD Connect
+var GetArchiveIndex = func(bucket, object string) ([]ArchiveMember, error) {
+	rc, err := ReadObject(bucket, ArchiveIndexObject(object))
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var members []ArchiveMember
+	if err = json.NewDecoder(rc).Decode(&members); err != nil {
+		return nil, fmt.Errorf("failed to decode index of archive %s: %w", object, err)
+	}
+
+	return members, nil
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchiveIndexObject(t *testing.T) {
+	var tests = []struct {
+		object, expected string
+	}{
+		{"data.tar.c4gh", "data.tar.index.json.c4gh"},
+		{"dir/data.tar.zst.c4gh", "dir/data.tar.zst.index.json.c4gh"},
+	}
+
+	for _, tt := range tests {
+		if received := ArchiveIndexObject(tt.object); received != tt.expected {
+			t.Errorf("Function returned incorrect object. Expected=%s, received=%s", tt.expected, received)
+		}
+	}
+}
+
+func TestGetArchiveIndex(t *testing.T) {
+	origReadObject := ReadObject
+	defer func() { ReadObject = origReadObject }()
+
+	index := `[{"name":"dir/file.txt","offset":512,"size":12,"mode":420,"modified":"2026-01-02T03:04:05Z","sha256":"abc"}]`
+	ReadObject = func(bucket, object string) (io.ReadCloser, error) {
+		if bucket != "bucket" || object != "dir/data.tar.index.json.c4gh" {
+			t.Errorf("ReadObject() received incorrect object %s/%s", bucket, object)
+		}
+
+		return io.NopCloser(strings.NewReader(index)), nil
+	}
+
+	members, err := GetArchiveIndex("bucket", "dir/data.tar.c4gh")
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	expected := []ArchiveMember{{
+		Name: "dir/file.txt", Offset: 512, Size: 12, Mode: 0644,
+		Modified: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Checksum: "abc",
+	}}
+	if !reflect.DeepEqual(members, expected) {
+		t.Errorf("Function returned incorrect members\nExpected=%+v\nReceived=%+v", expected, members)
+	}
+
+	ReadObject = func(bucket, object string) (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader("not json")), nil
+	}
+	errStr := "failed to decode index of archive data.tar.c4gh: invalid character 'o' in literal null (expecting 'u')"
+	if _, err = GetArchiveIndex("bucket", "data.tar.c4gh"); err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// throttleChunk is the largest amount of data read at once from a throttled body,
+// so that the rate stays even and changes to the limit take effect quickly
+const throttleChunk = 32 * 1024
+
+// BandwidthLimits contains the maximum transfer rates of S3 requests in bytes per second.
+// Zero means that the direction is not limited.
+type BandwidthLimits struct {
+	Upload   int64 `json:"upload"`
+	Download int64 `json:"download"`
+}
+
+// rateLimiter delays callers so that the data they report does not exceed the configured rate.
+// The limiter is shared by all requests in one direction.
+type rateLimiter struct {
+	mu   sync.Mutex
+	rate int64     // bytes per second, zero if unlimited
+	next time.Time // when the data reported so far has been transferred at the current rate
+}
+
+func (l *rateLimiter) setRate(rate int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.rate = rate
+	l.next = time.Time{}
+}
+
+func (l *rateLimiter) getRate() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.rate
+}
+
+// reserve records that n bytes have been transferred and returns how long the caller should wait
+func (l *rateLimiter) reserve(n int) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate <= 0 || n <= 0 {
+		return 0
+	}
+
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / float64(l.rate) * float64(time.Second)))
+
+	return l.next.Sub(now)
+}
+
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	delay := l.reserve(n)
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type progressKey struct{}
+
+// WithProgress returns a context whose S3 requests report the number of body bytes sent to `fun`.
+// Requests that are retried report their bytes again.
+func WithProgress(ctx context.Context, fun func(int64)) context.Context {
+	return context.WithValue(ctx, progressKey{}, fun)
+}
+
+// bodyProgress reports the bytes sent in the body of one request. The transport may send the body
+// again with GetBody, e.g. on a reused connection, so only bytes beyond those already reported are counted.
+type bodyProgress struct {
+	mu       sync.Mutex
+	reported int64
+	fun      func(int64)
+}
+
+func (p *bodyProgress) update(read int64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if read > p.reported {
+		p.fun(read - p.reported)
+		p.reported = read
+	}
+}
+
+// throttledBody limits the rate at which a request or response body can be read
+type throttledBody struct {
+	io.ReadCloser
+	ctx      context.Context
+	limiter  *rateLimiter
+	progress *bodyProgress
+	read     int64
+}
+
+func (b *throttledBody) Read(p []byte) (int, error) {
+	if b.limiter.getRate() > 0 && len(p) > throttleChunk {
+		p = p[:throttleChunk]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if b.progress != nil && n > 0 {
+		b.progress.update(b.read)
+	}
+	if waitErr := b.limiter.wait(b.ctx, n); waitErr != nil && err == nil {
+		err = waitErr
+	}
+
+	return n, err
+}
+
+// throttledTransport applies the bandwidth limits to the bodies of requests and responses
+type throttledTransport struct {
+	base     http.RoundTripper
+	upload   *rateLimiter
+	download *rateLimiter
+}
+
+func (t *throttledTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil && req.Body != http.NoBody {
+		req = req.Clone(req.Context())
+		var progress *bodyProgress
+		if fun, ok := req.Context().Value(progressKey{}).(func(int64)); ok {
+			progress = &bodyProgress{fun: fun}
+		}
+		wrap := func(body io.ReadCloser) io.ReadCloser {
+			return &throttledBody{ReadCloser: body, ctx: req.Context(), limiter: t.upload, progress: progress}
+		}
+		req.Body = wrap(req.Body)
+		// Bodies that are sent again are also throttled
+		if getBody := req.GetBody; getBody != nil {
+			req.GetBody = func() (io.ReadCloser, error) {
+				body, err := getBody()
+				if err != nil || body == http.NoBody {
+					return body, err
+				}
+
+				return wrap(body), nil
+			}
+		}
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = &throttledBody{ReadCloser: resp.Body, ctx: req.Context(), limiter: t.download}
+
+	return resp, nil
+}
+
+// GetBandwidthLimits returns the current bandwidth limits of S3 requests
+func GetBandwidthLimits() BandwidthLimits {
+	return BandwidthLimits{Upload: ai.hi.uploadRate.getRate(), Download: ai.hi.downloadRate.getRate()}
+}
+
+// SetBandwidthLimits changes the bandwidth limits of S3 requests.
+// The new limits also apply to transfers that are already in progress.
+func SetBandwidthLimits(limits BandwidthLimits) error {
+	if limits.Upload < 0 || limits.Download < 0 {
+		return errors.New("bandwidth limit cannot be negative")
+	}
+	ai.hi.uploadRate.setRate(limits.Upload)
+	ai.hi.downloadRate.setRate(limits.Download)
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Reserve(t *testing.T) {
+	limiter := &rateLimiter{}
+	if delay := limiter.reserve(1 << 20); delay != 0 {
+		t.Errorf("Unlimited limiter returned delay %v", delay)
+	}
+
+	limiter.setRate(1000)
+	if delay := limiter.reserve(500); delay < 490*time.Millisecond || delay > 500*time.Millisecond {
+		t.Errorf("Limiter returned incorrect delay. Expected=500ms, received=%v", delay)
+	}
+	if delay := limiter.reserve(500); delay < 990*time.Millisecond || delay > time.Second {
+		t.Errorf("Limiter returned incorrect delay. Expected=1s, received=%v", delay)
+	}
+
+	limiter.setRate(0)
+	if delay := limiter.reserve(500); delay != 0 {
+		t.Errorf("Limiter returned delay %v after limit was removed", delay)
+	}
+}
+
+func TestRateLimiter_Wait_Cancelled(t *testing.T) {
+	limiter := &rateLimiter{}
+	limiter.setRate(1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := limiter.wait(ctx, 10); !errors.Is(err, context.Canceled) {
+		t.Errorf("Function returned incorrect error\nExpected=%v\nReceived=%v", context.Canceled, err)
+	}
+}
+
+func TestThrottledTransport(t *testing.T) {
+	data := bytes.Repeat([]byte("a"), 64*1024)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(received)
+	}))
+	defer srv.Close()
+
+	var tests = []struct {
+		testname         string
+		upload, download int64
+		minDuration      time.Duration
+	}{
+		{"OK_UNLIMITED", 0, 0, 0},
+		{"OK_UPLOAD", 256 * 1024, 0, 200 * time.Millisecond},
+		{"OK_DOWNLOAD", 0, 256 * 1024, 200 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			tr := &throttledTransport{base: srv.Client().Transport, upload: &rateLimiter{}, download: &rateLimiter{}}
+			tr.upload.setRate(tt.upload)
+			tr.download.setRate(tt.download)
+			client := &http.Client{Transport: tr}
+
+			start := time.Now()
+			resp, err := client.Post(srv.URL, "application/octet-stream", bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Request failed: %s", err.Error())
+			}
+			received, err := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			elapsed := time.Since(start)
+
+			switch {
+			case err != nil:
+				t.Errorf("Reading response failed: %s", err.Error())
+			case !bytes.Equal(data, received):
+				t.Errorf("Response body does not match request body")
+			case elapsed < tt.minDuration:
+				t.Errorf("Transfer was not throttled. Expected at least %v, took %v", tt.minDuration, elapsed)
+			}
+		})
+	}
+}
+
+func TestSetBandwidthLimits(t *testing.T) {
+	origLimits := GetBandwidthLimits()
+	defer func() { _ = SetBandwidthLimits(origLimits) }()
+
+	limits := BandwidthLimits{Upload: 1000, Download: 2000}
+	if err := SetBandwidthLimits(limits); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if received := GetBandwidthLimits(); received != limits {
+		t.Errorf("Limits were not set correctly\nExpected=%v\nReceived=%v", limits, received)
+	}
+
+	errStr := "bandwidth limit cannot be negative"
+	if err := SetBandwidthLimits(BandwidthLimits{Upload: -1}); err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+	if received := GetBandwidthLimits(); received != limits {
+		t.Errorf("Invalid limits should not have been applied\nExpected=%v\nReceived=%v", limits, received)
+	}
+}
+
+func TestThrottledTransport_Progress(t *testing.T) {
+	data := bytes.Repeat([]byte("b"), 100*1024)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	tr := &throttledTransport{base: srv.Client().Transport, upload: &rateLimiter{}, download: &rateLimiter{}}
+	client := &http.Client{Transport: tr}
+
+	var sent atomic.Int64
+	ctx := WithProgress(context.Background(), func(n int64) { sent.Add(n) })
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, srv.URL, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to create request: %s", err.Error())
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %s", err.Error())
+	}
+	_, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if sent.Load() != int64(len(data)) {
+		t.Errorf("Incorrect number of bytes reported. Expected=%d, received=%d", len(data), sent.Load())
+	}
+}
+
+// replayTransport reads the request body, and then sends it again like http.Transport does on a reused connection
+type replayTransport struct {
+	replayed io.Reader
+}
+
+func (rt *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if _, err := io.Copy(io.Discard, req.Body); err != nil {
+		return nil, err
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	rt.replayed = body
+	if _, err = io.Copy(io.Discard, body); err != nil {
+		return nil, err
+	}
+
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+}
+
+func TestThrottledTransport_GetBody(t *testing.T) {
+	data := bytes.Repeat([]byte("c"), 64*1024)
+	base := &replayTransport{}
+	tr := &throttledTransport{base: base, upload: &rateLimiter{}, download: &rateLimiter{}}
+	tr.upload.setRate(512 * 1024)
+
+	var sent atomic.Int64
+	ctx := WithProgress(context.Background(), func(n int64) { sent.Add(n) })
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, "http://localhost", bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Failed to create request: %s", err.Error())
+	}
+
+	start := time.Now()
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("Request failed: %s", err.Error())
+	}
+	resp.Body.Close()
+	elapsed := time.Since(start)
+
+	if _, ok := base.replayed.(*throttledBody); !ok {
+		t.Errorf("Replayed body was not throttled")
+	}
+	// Both copies of the body are sent at the limited rate
+	if minDuration := 200 * time.Millisecond; elapsed < minDuration {
+		t.Errorf("Transfer was not throttled. Expected at least %v, took %v", minDuration, elapsed)
+	}
+	if sent.Load() != int64(len(data)) {
+		t.Errorf("Incorrect number of bytes reported. Expected=%d, received=%d", len(data), sent.Load())
+	}
+}
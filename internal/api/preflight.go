@@ -0,0 +1,136 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// preflightTimeout limits how long a single check waits for the proxy or ClamAV
+const preflightTimeout = 10 * time.Second
+
+// ErrNoCertificate is returned by CertificateExpiry if the client certificate or its key does not exist
+var ErrNoCertificate = errors.New("client certificate not found")
+
+// PingProxy checks that `proxy` answers HTTP requests. Any response is enough, whatever its status.
+var PingProxy = func(proxy string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), preflightTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, proxy, nil)
+	if err != nil {
+		return fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	resp, err := ai.hi.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("proxy %s cannot be reached: %w", proxy, err)
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// CheckConfiguration fetches configuration.json from `url` and checks that
+// it contains all the endpoints and timeouts that Data Gateway needs
+var CheckConfiguration = func(url string) error {
+	proxy := ai.proxy
+	ai.proxy = "" // So that the url can be given as the path
+	defer func() { ai.proxy = proxy }()
+
+	var resp configResponse
+	if err := makeRequest("GET", endpoint{url, 20}, nil, nil, nil, &resp); err != nil {
+		return fmt.Errorf("failed to get %s: %w", url, err)
+	}
+
+	return validateConfig(resp)
+}
+
+// validateConfig returns an error that lists the fields of configuration.json that are missing or invalid
+func validateConfig(cfg configResponse) error {
+	var missing []string
+	for name, value := range map[string]string{
+		"endpoints.profile":           cfg.Endpoints.Profile,
+		"endpoints.valid_password":    cfg.Endpoints.Password,
+		"endpoints.allas_header":      cfg.Endpoints.AllasHeader,
+		"endpoints.shared_buckets":    cfg.Endpoints.SharedBuckets,
+		"endpoints.s3.default":        cfg.Endpoints.S3.Default,
+		"endpoints.s3.head":           cfg.Endpoints.S3.Head,
+		"endpoints.vault.project_key": cfg.Endpoints.Vault.Key,
+		"endpoints.vault.headers":     cfg.Endpoints.Vault.Headers,
+		"endpoints.vault.whitelist":   cfg.Endpoints.Vault.Whitelist,
+	} {
+		if value == "" {
+			missing = append(missing, name)
+		}
+	}
+	for name, value := range map[string]int{
+		"timeouts.default":     cfg.Timeouts.Default,
+		"timeouts.s3":          cfg.Timeouts.S3,
+		"timeouts.vault.batch": cfg.Timeouts.Vault.Headers,
+	} {
+		if value <= 0 {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		slices.Sort(missing)
+
+		return fmt.Errorf("configuration.json is missing fields: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// CertificateExpiry returns the time when the client certificate for `proxy` in `files` expires
+var CertificateExpiry = func(files FileReader, proxy string) (time.Time, error) {
+	u, err := url.ParseRequestURI(proxy)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("could not parse proxy url: %w", err)
+	}
+	cert, err := readCertificate(files, u.Hostname())
+	var missing *missingCertificateError
+	if errors.As(err, &missing) {
+		return time.Time{}, fmt.Errorf("%w for host %s", ErrNoCertificate, u.Hostname())
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return cert.Leaf.NotAfter, nil
+}
+
+// PingClamAV checks that ClamAV answers a PING command on the socket given in CLAMAV_SOCKET
+var PingClamAV = func() error {
+	address, err := GetEnv("CLAMAV_SOCKET", false)
+	if err != nil {
+		return err
+	}
+	ai.ui.address = address
+
+	conn, err := ai.ui.dial()
+	if err != nil {
+		return fmt.Errorf("failed to connect to clamav socket: %w", err)
+	}
+	defer conn.Close()
+
+	_ = conn.SetDeadline(time.Now().Add(preflightTimeout))
+	if _, err = fmt.Fprint(conn, "zPING\x00"); err != nil {
+		return fmt.Errorf("failed to send command to ClamAV: %w", err)
+	}
+	response, err := bufio.NewReader(conn).ReadString('\x00')
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read ClamAV response: %w", err)
+	}
+	if response = strings.TrimRight(response, "\x00\n"); response != "PONG" {
+		return fmt.Errorf("ClamAV did not answer PING, received %q", response)
+	}
+
+	return nil
+}
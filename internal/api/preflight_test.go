@@ -0,0 +1,188 @@
+package api
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestPingProxy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	// Any response means that the proxy can be reached
+	if err := PingProxy(srv.URL); err != nil {
+		t.Errorf("Function returned unexpected error: %s", err.Error())
+	}
+
+	srv.Close()
+	if err := PingProxy(srv.URL); err == nil {
+		t.Error("Function did not return error for closed server")
+	}
+	if err := PingProxy("::not a url"); err == nil {
+		t.Error("Function did not return error for invalid URL")
+	}
+}
+
+func TestCheckConfiguration(t *testing.T) {
+	complete := configResponse{}
+	complete.Timeouts.Default, complete.Timeouts.S3, complete.Timeouts.Vault.Headers = 10, 18, 30
+	complete.Endpoints.Profile, complete.Endpoints.Password = "/profile", "/password"
+	complete.Endpoints.AllasHeader, complete.Endpoints.SharedBuckets = "/header", "/shared"
+	complete.Endpoints.S3.Default, complete.Endpoints.S3.Head = "/s3", "/s3-head"
+	complete.Endpoints.Vault.Key, complete.Endpoints.Vault.Headers, complete.Endpoints.Vault.Whitelist = "/key", "/headers", "/whitelist"
+
+	partial := complete
+	partial.Endpoints.Profile = ""
+	partial.Endpoints.Vault.Whitelist = ""
+	partial.Timeouts.S3 = 0
+	partial.Timeouts.Vault.Headers = 0
+
+	var tests = []struct {
+		testname, errStr string
+		resp             configResponse
+		reqErr           error
+	}{
+		{"OK", "", complete, nil},
+		{"FAIL_FIELDS", "configuration.json is missing fields: endpoints.profile, endpoints.vault.whitelist, timeouts.s3, timeouts.vault.batch", partial, nil},
+		{"FAIL_REQUEST", "failed to get config_url: " + errExpected.Error(), complete, errExpected},
+	}
+
+	origMakeRequest := makeRequest
+	origProxy := ai.proxy
+	defer func() {
+		makeRequest = origMakeRequest
+		ai.proxy = origProxy
+	}()
+
+	ai.proxy = "https://proxy.example.com"
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			makeRequest = func(method string, ep endpoint, query, headers map[string]string, reqBody io.ReadSeeker, ret any) error {
+				if ai.proxy != "" {
+					t.Errorf("ai.proxy should be empty, received=%s", ai.proxy)
+				}
+				if ep.path != "config_url" {
+					t.Errorf("Incorrect path\nExpected=config_url\nReceived=%s", ep.path)
+				}
+				if v, ok := ret.(*configResponse); ok {
+					*v = tt.resp
+				}
+
+				return tt.reqErr
+			}
+
+			err := CheckConfiguration("config_url")
+			switch {
+			case tt.errStr == "":
+				if err != nil {
+					t.Errorf("Function returned unexpected error: %s", err.Error())
+				}
+			case err == nil:
+				t.Error("Function did not return error")
+			case err.Error() != tt.errStr:
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+			if ai.proxy != "https://proxy.example.com" {
+				t.Errorf("ai.proxy was not restored, received=%s", ai.proxy)
+			}
+		})
+	}
+}
+
+func TestCertificateExpiry(t *testing.T) {
+	_, mockReader, err := setupCerts("localhost", false)
+	if err != nil {
+		t.Fatalf("Could not setup certificates: %s", err.Error())
+	}
+
+	expiry, err := CertificateExpiry(mockReader, "https://localhost:8080")
+	if err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if expiry.Before(time.Now()) {
+		t.Errorf("Certificate should not have expired, received=%s", expiry)
+	}
+
+	_, err = CertificateExpiry(mockReader, "https://example.com")
+	if !errors.Is(err, ErrNoCertificate) {
+		t.Errorf("Function returned incorrect error\nExpected=%v\nReceived=%v", ErrNoCertificate, err)
+	}
+	errStr := "could not parse proxy url: parse \"not-a-proper-url\": invalid URI for request"
+	if _, err = CertificateExpiry(mockReader, "not-a-proper-url"); err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+}
+
+func TestPingClamAV(t *testing.T) {
+	var tests = []struct {
+		testname, response, errStr string
+		dialErr                    error
+	}{
+		{"OK", "PONG\x00", "", nil},
+		{"FAIL_RESPONSE", "UNKNOWN COMMAND\x00", "ClamAV did not answer PING, received \"UNKNOWN COMMAND\"", nil},
+		{"FAIL_DIAL", "", "failed to connect to clamav socket: " + errExpected.Error(), errExpected},
+	}
+
+	origGetEnv := GetEnv
+	origDial := ai.ui.dial
+	origAddress := ai.ui.address
+	defer func() {
+		GetEnv = origGetEnv
+		ai.ui.dial = origDial
+		ai.ui.address = origAddress
+	}()
+
+	GetEnv = func(name string, verifyURL bool) (string, error) {
+		if name != "CLAMAV_SOCKET" {
+			return "", fmt.Errorf("unknown env %s", name)
+		}
+
+		return "clamav.sock", nil
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			ai.ui.dial = func() (net.Conn, error) {
+				if tt.dialErr != nil {
+					return nil, tt.dialErr
+				}
+				client, server := net.Pipe()
+				go func() {
+					defer server.Close()
+					command, _ := bufio.NewReader(server).ReadString('\x00')
+					if command != "zPING\x00" {
+						t.Errorf("ClamAV received incorrect command %q", command)
+					}
+					_, _ = server.Write([]byte(tt.response))
+				}()
+
+				return client, nil
+			}
+
+			err := PingClamAV()
+			switch {
+			case tt.errStr == "":
+				if err != nil {
+					t.Errorf("Function returned unexpected error: %s", err.Error())
+				}
+			case err == nil:
+				t.Error("Function did not return error")
+			case err.Error() != tt.errStr:
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+			if ai.ui.address != "clamav.sock" {
+				t.Errorf("Incorrect socket address. Expected=clamav.sock, received=%s", ai.ui.address)
+			}
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"math"
 	"net/http"
+	"net/url"
 	"slices"
 	"strings"
 	"sync"
@@ -33,11 +34,20 @@ import (
 // chunkSize is the size of a single request when requesting object content from storage
 const chunkSize = 1 << 25
 
+// ChunkSize is the size of the pieces in which DownloadData() fetches and caches objects
+const ChunkSize = chunkSize
+
 // Crypt4GH constants
 const BlockSize int64 = 65536
 const MacSize int64 = 28
 const CipherBlockSize = BlockSize + MacSize
 
+// Keys of the user-defined object metadata that describe the file an object was exported from
+const MetaModified = "source-mtime"
+const MetaChecksum = "source-sha256"
+const MetaMode = "source-mode"
+const MetaSymlink = "source-symlink"
+
 // Metadata standardises the metadata received for both buckets and objects
 type Metadata struct {
 	Name         string
@@ -115,6 +125,12 @@ var objectToQuery = middleware.SerializeMiddlewareFunc("objectToQuery", func(
 		key = *v.Key
 	case *s3.DeleteObjectInput:
 		key = *v.Key
+	case *s3.HeadObjectInput:
+		key = *v.Key
+	case *s3.CopyObjectInput:
+		key = *v.Key
+	case *s3.UploadPartCopyInput:
+		key = *v.Key
 	}
 
 	if key != "" {
@@ -185,7 +201,7 @@ var initialiseS3Client = func() error {
 	tr.MaxIdleConnsPerHost = 100
 
 	httpClient := &http.Client{
-		Transport: tr,
+		Transport: &throttledTransport{base: tr, upload: ai.hi.uploadRate, download: ai.hi.downloadRate},
 		Timeout:   time.Second * time.Duration(ai.hi.endpoints.S3.Default.timeout),
 	}
 
@@ -460,6 +476,104 @@ func getObjects(ctx context.Context, params *s3.ListObjectsV2Input, rep Repo) ([
 	return meta, nil
 }
 
+// GetObjectMetadata returns the size and the user-defined metadata of an object
+var GetObjectMetadata = func(rep Repo, bucket, object string) (int64, map[string]string, error) {
+	ctx := getContext(context.Background(), rep, true)
+
+	resp, err := ai.hi.s3Client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(object),
+	})
+	if err != nil {
+		var re *smithyhttp.ResponseError
+		if errors.As(err, &re) {
+			err = re.Err
+		}
+
+		return 0, nil, fmt.Errorf("failed to get metadata for object %s in bucket %s: %w", object, bucket, err)
+	}
+
+	return aws.ToInt64(resp.ContentLength), resp.Metadata, nil
+}
+
+// maxCopySize is the largest object, or part of an object, that storage copies with a single request
+const maxCopySize int64 = 5 << 30
+
+// SetObjectMetadata replaces the user-defined metadata of an object whose size is `size`. Metadata cannot be
+// changed in place, so the object is copied onto itself within storage, without transferring its content.
+var SetObjectMetadata = func(rep Repo, bucket, object string, size int64, metadata map[string]string) error {
+	ctx := getContext(context.Background(), rep, false)
+	source := aws.String(url.PathEscape(bucket) + "/" + url.PathEscape(object))
+
+	var err error
+	if size <= maxCopySize {
+		_, err = ai.hi.s3Client.CopyObject(ctx, &s3.CopyObjectInput{
+			Bucket:            aws.String(bucket),
+			Key:               aws.String(object),
+			CopySource:        source,
+			ContentType:       aws.String("application/octet-stream"),
+			Metadata:          metadata,
+			MetadataDirective: types.MetadataDirectiveReplace,
+		})
+	} else {
+		err = copyMultipart(ctx, bucket, object, source, size, metadata)
+	}
+	if err != nil {
+		var re *smithyhttp.ResponseError
+		if errors.As(err, &re) {
+			err = re.Err
+		}
+
+		return fmt.Errorf("failed to set metadata of object %s in bucket %s: %w", object, bucket, err)
+	}
+
+	return nil
+}
+
+// copyMultipart copies an object that is too large to be copied with a single request in parts of `maxCopySize`
+func copyMultipart(ctx context.Context, bucket, object string, source *string, size int64, metadata map[string]string) error {
+	upload, err := ai.hi.s3Client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(object),
+		ContentType: aws.String("application/octet-stream"),
+		Metadata:    metadata,
+	})
+	if err != nil {
+		return err
+	}
+
+	var parts []types.CompletedPart
+	for number, start := int32(1), int64(0); start < size; number, start = number+1, start+maxCopySize {
+		resp, err := ai.hi.s3Client.UploadPartCopy(ctx, &s3.UploadPartCopyInput{
+			Bucket:          aws.String(bucket),
+			Key:             aws.String(object),
+			CopySource:      source,
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", start, min(start+maxCopySize, size)-1)),
+			PartNumber:      aws.Int32(number),
+			UploadId:        upload.UploadId,
+		})
+		if err != nil {
+			_, _ = ai.hi.s3Client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{
+				Bucket:   aws.String(bucket),
+				Key:      aws.String(object),
+				UploadId: upload.UploadId,
+			})
+
+			return err
+		}
+		parts = append(parts, types.CompletedPart{ETag: resp.CopyPartResult.ETag, PartNumber: aws.Int32(number)})
+	}
+
+	_, err = ai.hi.s3Client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(bucket),
+		Key:             aws.String(object),
+		UploadId:        upload.UploadId,
+		MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+	})
+
+	return err
+}
+
 // DownloadData requests data between range [startDecrypted, endDecrypted).
 // As we want to split the data into chunks at consistent locations,
 // the requested byte interval may encompass one or two data chunks.
@@ -499,6 +613,13 @@ func CalculateEncryptedSize(decryptedSize int64) int64 {
 	return decryptedSize + int64(nBlocks)*MacSize
 }
 
+// CalculateDecryptedSize calculates the size of the content of a headerless encrypted file
+func CalculateDecryptedSize(encryptedSize int64) int64 {
+	nBlocks := (encryptedSize + CipherBlockSize - 1) / CipherBlockSize
+
+	return encryptedSize - nBlocks*MacSize
+}
+
 func getDataChunk(
 	ctx context.Context, rep Repo, nodes []string, path, header string,
 	chunk, startDecrypted, endDecrypted, oldOffset, fileSize int64,
@@ -512,11 +633,17 @@ func getDataChunk(
 	ofst := startDecrypted - chByteStart
 	endofst := endDecrypted - chByteStart
 
+	bucket := nodes[0]
+	object := strings.Join(nodes[1:], "/")
+	logger := logs.WithSubsystem("s3").WithFields(logs.Fields{
+		logs.FieldRepository: rep.ForPath(), logs.FieldBucket: bucket, logs.FieldObject: object,
+	})
+
 	cacheKey := toCacheKey(rep, nodes, chByteStart)
 	chunkData, found := downloadCache.Get(cacheKey)
 
 	if found {
-		logs.Debugf("Retrieved file %s from cache, with coordinates [%d, %d)", path, chByteStart+ofst, chByteStart+endofst)
+		logger.Debugf("Retrieved file %s from cache, with coordinates [%d, %d)", path, chByteStart+ofst, chByteStart+endofst)
 
 		return chunkData[ofst:endofst], nil
 	}
@@ -527,9 +654,7 @@ func getDataChunk(
 	startEncrypted := chByteStart/BlockSize*CipherBlockSize + oldOffset
 	endEncrypted := min((chByteEnd+BlockSize-1)/BlockSize*CipherBlockSize+oldOffset, encryptedBodySize)
 
-	bucket := nodes[0]
-	object := strings.Join(nodes[1:], "/")
-
+	start := time.Now()
 	resp, err := ai.hi.s3Client.GetObject(ctx, &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(object),
@@ -565,7 +690,8 @@ func getDataChunk(
 	}
 
 	downloadCache.Set(cacheKey, buffer, int64(len(buffer)), time.Minute*60)
-	logs.Debugf("File %s stored in cache, with coordinates [%d, %d)", path, chByteStart, chByteEnd)
+	logger.WithFields(logs.Fields{logs.FieldDuration: time.Since(start)}).
+		Debugf("File %s stored in cache, with coordinates [%d, %d)", path, chByteStart, chByteEnd)
 
 	if GetProjectType() == "findata" {
 		go scanForViruses(buffer, path)
@@ -574,20 +700,85 @@ func getDataChunk(
 	return buffer[ofst:endofst], nil
 }
 
+// ReadObject returns a reader that streams the decrypted content of an SD Connect object from the beginning.
+// The caller is responsible for closing the reader.
+var ReadObject = func(bucket, object string) (io.ReadCloser, error) {
+	header, offset, err := getObjectHeader(bucket, object)
+	if err != nil {
+		return nil, err
+	}
+	headerBytes, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode header: %w", err)
+	}
+
+	params := &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(object),
+	}
+	if offset > 0 {
+		params.Range = aws.String(fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	ctx := getContext(context.Background(), SDConnect, false)
+	resp, err := ai.hi.s3Client.GetObject(ctx, params)
+	if err != nil {
+		var re *smithyhttp.ResponseError
+		if errors.As(err, &re) {
+			err = re.Err
+		}
+
+		return nil, fmt.Errorf("failed to retrieve object %s from bucket %s: %w", object, bucket, err)
+	}
+
+	objectReader := io.MultiReader(bytes.NewReader(headerBytes), resp.Body)
+	crypt4GHReader, err := streaming.NewCrypt4GHReader(objectReader, ai.vi.privateKey, nil)
+	if err != nil {
+		resp.Body.Close()
+
+		return nil, fmt.Errorf("failed to construct reader: %w", err)
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{crypt4GHReader, resp.Body}, nil
+}
+
+const abortTimeout = 30 * time.Second
+
+// abortingClient aborts multipart uploads even if the context of the upload has been cancelled,
+// so that cancelled uploads do not leave parts behind in storage
+type abortingClient struct {
+	transfermanager.S3APIClient
+}
+
+func (c abortingClient) AbortMultipartUpload(
+	ctx context.Context,
+	params *s3.AbortMultipartUploadInput,
+	optFns ...func(*s3.Options),
+) (*s3.AbortMultipartUploadOutput, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), abortTimeout)
+	defer cancel()
+
+	return c.S3APIClient.AbortMultipartUpload(ctx, params, optFns...)
+}
+
 // UploadObject uploads object to bucket. Object is uploaded in segments of
-// size `segmentSize`. Upload Manager decides if the object is small enough
-// to use PutObject, or if multipart upload is necessary.
+// size `segmentSize`, at most `concurrency` segments at a time. Upload Manager decides
+// if the object is small enough to use PutObject, or if multipart upload is necessary.
 var UploadObject = func(
 	ctx context.Context,
 	body io.Reader,
 	rep Repo,
 	bucket, object string,
 	segmentSize int64,
+	concurrency int,
 	metadata map[string]string,
 ) error {
-	uploader := transfermanager.New(ai.hi.s3Client, func(o *transfermanager.Options) {
+	uploader := transfermanager.New(abortingClient{ai.hi.s3Client}, func(o *transfermanager.Options) {
 		o.PartSizeBytes = segmentSize
-		o.Concurrency = 1
+		o.Concurrency = max(concurrency, 1)
 	})
 
 	ctx = getContext(ctx, rep, false)
@@ -16,6 +16,8 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -1112,6 +1114,241 @@ func TestCalculateEncryptedSize(t *testing.T) {
 	}
 }
 
+func TestCalculateDecryptedSize(t *testing.T) {
+	for _, decrypted := range []int64{0, 484, 65536, 393220, 58993401} {
+		if size := CalculateDecryptedSize(CalculateEncryptedSize(decrypted)); size != decrypted {
+			t.Errorf("Function failed to calculate decrypted size. Expected=%d, received=%d", decrypted, size)
+		}
+	}
+}
+
+func TestGetObjectMetadata(t *testing.T) {
+	origClient := ai.hi.client
+	origProxy := ai.proxy
+	origS3Client := ai.hi.s3Client
+	origEndpoints := ai.hi.endpoints
+	defer func() {
+		ai.hi.client = origClient
+		ai.proxy = origProxy
+		ai.hi.s3Client = origS3Client
+		ai.hi.endpoints = origEndpoints
+	}()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Request has incorrect method\nExpected=GET\nReceived=%v", r.Method)
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+		if r.URL.Path != "/s3-head-endpoint/sd-connect/my-bucket/" {
+			t.Errorf("Request has incorrect path %v", r.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+		object := r.URL.Query().Get("object")
+		if object != "dir/obj.txt.c4gh" {
+			t.Errorf("Query parameter 'object' has incorrect value\nExpected=dir/obj.txt.c4gh\nReceived=%s", object)
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		w.Header().Set("X-Amz-Meta-Source-Mtime", "2024-05-06T07:08:09Z")
+		w.Header().Set("Content-Length", "1024")
+	}))
+
+	ai.hi.endpoints = testConfig
+	ai.hi.client = &http.Client{Transport: http.DefaultTransport}
+	ai.proxy = srv.URL
+	t.Cleanup(func() { srv.Close() })
+
+	expectedMeta := map[string]string{"source-mtime": "2024-05-06T07:08:09Z"}
+	if err := initialiseS3Client(); err != nil {
+		t.Errorf("Failed to initialize S3 client: %v", err.Error())
+	} else if size, meta, err := GetObjectMetadata(SDConnect, "my-bucket", "dir/obj.txt.c4gh"); err != nil {
+		t.Errorf("Function returned unexpected error: %s", err.Error())
+	} else if size != 1024 {
+		t.Errorf("Function returned incorrect size. Expected=1024, received=%d", size)
+	} else if !reflect.DeepEqual(expectedMeta, meta) {
+		t.Errorf("Function returned incorrect metadata\nExpected=%v\nReceived=%v", expectedMeta, meta)
+	}
+}
+
+func TestGetObjectMetadata_Error(t *testing.T) {
+	origClient := ai.hi.client
+	origProxy := ai.proxy
+	origS3Client := ai.hi.s3Client
+	defer func() {
+		ai.hi.client = origClient
+		ai.proxy = origProxy
+		ai.hi.s3Client = origS3Client
+	}()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+
+	ai.hi.client = &http.Client{Transport: http.DefaultTransport}
+	ai.proxy = srv.URL
+	errStr := "failed to get metadata for object obj.txt in bucket my-bucket: api error Forbidden: Forbidden"
+	t.Cleanup(func() { srv.Close() })
+
+	if err := initialiseS3Client(); err != nil {
+		t.Errorf("Failed to initialize S3 client: %v", err.Error())
+	} else if _, _, err := GetObjectMetadata(SDConnect, "my-bucket", "obj.txt"); err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+}
+
+func TestSetObjectMetadata(t *testing.T) {
+	origClient := ai.hi.client
+	origProxy := ai.proxy
+	origS3Client := ai.hi.s3Client
+	origEndpoints := ai.hi.endpoints
+	defer func() {
+		ai.hi.client = origClient
+		ai.proxy = origProxy
+		ai.hi.s3Client = origS3Client
+		ai.hi.endpoints = origEndpoints
+	}()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" {
+			t.Errorf("Request has incorrect method\nExpected=PUT\nReceived=%v", r.Method)
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+		if r.URL.Path != "/s3-default-endpoint/sd-connect/my-bucket/" {
+			t.Errorf("Request has incorrect path %v", r.URL.Path)
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+		if object := r.URL.Query().Get("object"); object != "dir/obj.txt.c4gh" {
+			t.Errorf("Query parameter 'object' has incorrect value\nExpected=dir/obj.txt.c4gh\nReceived=%s", object)
+		}
+		if source := r.Header.Get("X-Amz-Copy-Source"); source != "my-bucket/dir%2Fobj.txt.c4gh" {
+			t.Errorf("Request has incorrect copy source %s", source)
+		}
+		if directive := r.Header.Get("X-Amz-Metadata-Directive"); directive != "REPLACE" {
+			t.Errorf("Request has incorrect metadata directive %s", directive)
+		}
+		if checksum := r.Header.Get("X-Amz-Meta-Source-Sha256"); checksum != "abc123" {
+			t.Errorf("Request has incorrect checksum %s", checksum)
+		}
+
+		_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<CopyObjectResult><ETag>"etag"</ETag></CopyObjectResult>`))
+	}))
+
+	ai.hi.endpoints = testConfig
+	ai.hi.client = &http.Client{Transport: http.DefaultTransport}
+	ai.proxy = srv.URL
+	t.Cleanup(func() { srv.Close() })
+
+	if err := initialiseS3Client(); err != nil {
+		t.Errorf("Failed to initialize S3 client: %v", err.Error())
+	} else if err := SetObjectMetadata(SDConnect, "my-bucket", "dir/obj.txt.c4gh", 1024, map[string]string{MetaChecksum: "abc123"}); err != nil {
+		t.Errorf("Function returned unexpected error: %s", err.Error())
+	}
+}
+
+func TestSetObjectMetadata_Multipart(t *testing.T) {
+	origClient := ai.hi.client
+	origProxy := ai.proxy
+	origS3Client := ai.hi.s3Client
+	origEndpoints := ai.hi.endpoints
+	defer func() {
+		ai.hi.client = origClient
+		ai.proxy = origProxy
+		ai.hi.s3Client = origS3Client
+		ai.hi.endpoints = origEndpoints
+	}()
+
+	var ranges []string
+	completed := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		switch {
+		case r.Method == "POST" && r.URL.Query().Has("uploads"):
+			if checksum := r.Header.Get("X-Amz-Meta-Source-Sha256"); checksum != "abc123" {
+				t.Errorf("Request has incorrect checksum %s", checksum)
+			}
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<InitiateMultipartUploadResult><Bucket>my-bucket</Bucket><Key>obj</Key><UploadId>copy-id</UploadId></InitiateMultipartUploadResult>`))
+		case r.Method == "PUT" && r.URL.Query().Get("uploadId") == "copy-id":
+			if source := r.Header.Get("X-Amz-Copy-Source"); source != "my-bucket/obj" {
+				t.Errorf("Request has incorrect copy source %s", source)
+			}
+			ranges = append(ranges, r.Header.Get("X-Amz-Copy-Source-Range"))
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<CopyPartResult><ETag>"etag"</ETag></CopyPartResult>`))
+		case r.Method == "POST" && r.URL.Query().Get("uploadId") == "copy-id":
+			completed = true
+			_, _ = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<CompleteMultipartUploadResult><Bucket>my-bucket</Bucket><Key>obj</Key></CompleteMultipartUploadResult>`))
+		default:
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.String())
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+
+	ai.hi.endpoints = testConfig
+	ai.hi.client = &http.Client{Transport: http.DefaultTransport}
+	ai.proxy = srv.URL
+	t.Cleanup(func() { srv.Close() })
+
+	size := 2*maxCopySize + 10
+	expected := []string{
+		fmt.Sprintf("bytes=0-%d", maxCopySize-1),
+		fmt.Sprintf("bytes=%d-%d", maxCopySize, 2*maxCopySize-1),
+		fmt.Sprintf("bytes=%d-%d", 2*maxCopySize, size-1),
+	}
+	if err := initialiseS3Client(); err != nil {
+		t.Errorf("Failed to initialize S3 client: %v", err.Error())
+	} else if err := SetObjectMetadata(SDConnect, "my-bucket", "obj", size, map[string]string{MetaChecksum: "abc123"}); err != nil {
+		t.Errorf("Function returned unexpected error: %s", err.Error())
+	} else if !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("Object was copied in incorrect parts\nExpected=%v\nReceived=%v", expected, ranges)
+	} else if !completed {
+		t.Error("Multipart copy was not completed")
+	}
+}
+
+func TestSetObjectMetadata_Error(t *testing.T) {
+	origClient := ai.hi.client
+	origProxy := ai.proxy
+	origS3Client := ai.hi.s3Client
+	defer func() {
+		ai.hi.client = origClient
+		ai.proxy = origProxy
+		ai.hi.s3Client = origS3Client
+	}()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+
+	ai.hi.client = &http.Client{Transport: http.DefaultTransport}
+	ai.proxy = srv.URL
+	errStr := "failed to set metadata of object obj.txt in bucket my-bucket: api error Forbidden: Forbidden"
+	t.Cleanup(func() { srv.Close() })
+
+	if err := initialiseS3Client(); err != nil {
+		t.Errorf("Failed to initialize S3 client: %v", err.Error())
+	} else if err := SetObjectMetadata(SDConnect, "my-bucket", "obj.txt", 10, nil); err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+}
+
 func TestDownloadData(t *testing.T) {
 	origClient := ai.hi.client
 	origProxy := ai.proxy
@@ -1524,6 +1761,143 @@ func TestDownloadData_Error(t *testing.T) {
 	}
 }
 
+func TestReadObject(t *testing.T) {
+	origClient := ai.hi.client
+	origProxy := ai.proxy
+	origS3Client := ai.hi.s3Client
+	origPrivateKey := ai.vi.privateKey
+	origGetFileHeader := GetFileHeader
+	origGetReencryptedHeader := GetReencryptedHeader
+	defer func() {
+		ai.hi.client = origClient
+		ai.proxy = origProxy
+		ai.hi.s3Client = origS3Client
+		ai.vi.privateKey = origPrivateKey
+		GetFileHeader = origGetFileHeader
+		GetReencryptedHeader = origGetReencryptedHeader
+	}()
+
+	content := test.GenerateRandomText(200000)
+	headerBytes, encryptedContent, privateKey := test.EncryptData(t, content)
+	header64 := base64.StdEncoding.EncodeToString(headerBytes)
+
+	ai.vi.privateKey = privateKey
+	ai.hi.client = &http.Client{Transport: http.DefaultTransport}
+	ai.hi.endpoints = testConfig
+
+	var tests = []struct {
+		testname, vaultHeader string
+		offset                int64
+	}{
+		{"OK_VAULT", header64, 0},
+		{"OK_REENCRYPTED", "", 124},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			storedContent := append([]byte(strings.Repeat("A", int(tt.offset))), encryptedContent...)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "GET" || r.URL.Path != "/s3-default-endpoint/sd-connect/bucket/" {
+					t.Errorf("Server was called with unexpected method %s or path %s", r.Method, r.URL.Path)
+					w.WriteHeader(http.StatusBadRequest)
+
+					return
+				}
+				expectedRange := ""
+				if tt.offset > 0 {
+					expectedRange = fmt.Sprintf("bytes=%d-", tt.offset)
+				}
+				if byteRange := r.Header.Get("Range"); byteRange != expectedRange {
+					t.Errorf("Header Range has incorrect value\nExpected=%s\nReceived=%s", expectedRange, byteRange)
+				}
+
+				_, _ = w.Write(storedContent[tt.offset:])
+			}))
+			ai.proxy = srv.URL
+			t.Cleanup(func() { srv.Close() })
+
+			GetFileHeader = func(rep Repo, bucket, object, owner, id string) (string, error) {
+				return tt.vaultHeader, nil
+			}
+			GetReencryptedHeader = func(bucket, object string) (string, int64, error) {
+				return header64, tt.offset, nil
+			}
+
+			if err := initialiseS3Client(); err != nil {
+				t.Fatalf("Failed to initialize S3 client: %v", err.Error())
+			}
+
+			rc, err := ReadObject("bucket", "dir/object.c4gh")
+			if err != nil {
+				t.Fatalf("Function returned unexpected error: %s", err.Error())
+			}
+			defer rc.Close()
+
+			data, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("Failed to read decrypted object: %s", err.Error())
+			}
+			if !bytes.Equal(content, data) {
+				t.Errorf("Function returned incorrect data")
+			}
+		})
+	}
+}
+
+func TestReadObject_Error(t *testing.T) {
+	origClient := ai.hi.client
+	origProxy := ai.proxy
+	origS3Client := ai.hi.s3Client
+	origGetFileHeader := GetFileHeader
+	defer func() {
+		ai.hi.client = origClient
+		ai.proxy = origProxy
+		ai.hi.s3Client = origS3Client
+		GetFileHeader = origGetFileHeader
+	}()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	ai.hi.client = &http.Client{Transport: http.DefaultTransport}
+	ai.proxy = srv.URL
+	t.Cleanup(func() { srv.Close() })
+
+	var tests = []struct {
+		testname, header, errStr string
+		headerErr                error
+	}{
+		{
+			"FAIL_HEADER", "", "failed to retrieve header from Vault for object obj.txt.c4gh: " + errExpected.Error(), errExpected,
+		},
+		{
+			"FAIL_DECODE", "not base64", "failed to decode header: illegal base64 data at input byte 3", nil,
+		},
+		{
+			"FAIL_GET", "aGVhZGVy", "failed to retrieve object obj.txt.c4gh from bucket bucket: api error InternalServerError: Internal Server Error", nil,
+		},
+	}
+
+	if err := initialiseS3Client(); err != nil {
+		t.Fatalf("Failed to initialize S3 client: %v", err.Error())
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			GetFileHeader = func(rep Repo, bucket, object, owner, id string) (string, error) {
+				return tt.header, tt.headerErr
+			}
+
+			if _, err := ReadObject("bucket", "obj.txt.c4gh"); err == nil {
+				t.Error("Function did not return error")
+			} else if err.Error() != tt.errStr {
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+		})
+	}
+}
+
 func TestUploadObject(t *testing.T) {
 	origClient := ai.hi.client
 	origProxy := ai.proxy
@@ -1591,7 +1965,7 @@ func TestUploadObject(t *testing.T) {
 
 	if err := initialiseS3Client(); err != nil {
 		t.Errorf("Failed to initialize S3 client: %v", err.Error())
-	} else if err := UploadObject(context.Background(), reader, "some-repository", "bucket1000", "obj.txt", 1024*1024*5, metadata); err != nil {
+	} else if err := UploadObject(context.Background(), reader, "some-repository", "bucket1000", "obj.txt", 1024*1024*5, 1, metadata); err != nil {
 		t.Errorf("Request to mock server failed: %v", err)
 	} else if receivedData.String() != string(uploadedData) {
 		t.Errorf("Function uploaded incorrect data\nExpected=%s\nReceived=%s", string(uploadedData), receivedData.String())
@@ -1610,6 +1984,7 @@ func TestUploadObject_Multipart(t *testing.T) {
 
 	uploadID := ""
 	receivedData := make(map[string][]byte)
+	var mu sync.Mutex // Parts are uploaded concurrently
 	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer r.Body.Close()
 
@@ -1679,7 +2054,9 @@ func TestUploadObject_Multipart(t *testing.T) {
 		if err != nil {
 			t.Errorf("Failed to read request body: %s", err.Error())
 		} else {
+			mu.Lock()
 			receivedData[partNum] = nextChunk
+			mu.Unlock()
 		}
 	}))
 
@@ -1693,7 +2070,7 @@ func TestUploadObject_Multipart(t *testing.T) {
 
 	if err := initialiseS3Client(); err != nil {
 		t.Errorf("Failed to initialize S3 client: %v", err.Error())
-	} else if err := UploadObject(context.Background(), reader, "repo", "bucket1000", "obj.txt", 1024*1024*5, nil); err != nil {
+	} else if err := UploadObject(context.Background(), reader, "repo", "bucket1000", "obj.txt", 1024*1024*5, 3, nil); err != nil {
 		t.Errorf("Request to mock server failed: %v", err)
 	} else {
 		receivedDataStr := ""
@@ -1736,7 +2113,7 @@ func TestUploadObject_TooLarge(t *testing.T) {
 	errStr := "failed to upload object obj.txt to bucket bucket1000: object is too large"
 	if err := initialiseS3Client(); err != nil {
 		t.Fatalf("Failed to initialize S3 client: %v", err.Error())
-	} else if err := UploadObject(context.Background(), reader, "repo", "bucket1000", "obj.txt", 1024*1024*5, nil); err == nil {
+	} else if err := UploadObject(context.Background(), reader, "repo", "bucket1000", "obj.txt", 1024*1024*5, 1, nil); err == nil {
 		t.Error("Function did not return error")
 	} else if err.Error() != errStr {
 		t.Fatalf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
@@ -1768,13 +2145,66 @@ func TestUploadObject_ContextCancel(t *testing.T) {
 	errStr := "failed to upload object obj.txt to bucket bucket1000: canceled, context canceled" // `canceled,` comes from aws sdk
 	if err := initialiseS3Client(); err != nil {
 		t.Fatalf("Failed to initialize S3 client: %v", err.Error())
-	} else if err := UploadObject(ctx, reader, "repo", "bucket1000", "obj.txt", 1024*1024*5, nil); err == nil {
+	} else if err := UploadObject(ctx, reader, "repo", "bucket1000", "obj.txt", 1024*1024*5, 1, nil); err == nil {
 		t.Error("Function did not return error")
 	} else if err.Error() != errStr {
 		t.Fatalf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
 	}
 }
 
+func TestUploadObject_CancelMultipart(t *testing.T) {
+	origClient := ai.hi.client
+	origProxy := ai.proxy
+	origS3Client := ai.hi.s3Client
+	defer func() {
+		ai.hi.client = origClient
+		ai.proxy = origProxy
+		ai.hi.s3Client = origS3Client
+	}()
+
+	var aborted atomic.Bool
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer r.Body.Close()
+
+		switch {
+		case r.Method == "POST" && r.URL.Query().Has("uploads"):
+			fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<InitiateMultipartUploadResult>
+	<Bucket>bucket1000</Bucket>
+	<Key>obj.txt</Key>
+	<UploadId>upload-id</UploadId>
+</InitiateMultipartUploadResult>`)
+		case r.Method == "PUT":
+			_, _ = io.Copy(io.Discard, r.Body)
+			cancel()
+		case r.Method == "DELETE" && r.URL.Query().Get("uploadId") == "upload-id":
+			aborted.Store(true)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			t.Errorf("Unexpected %s request with query %s", r.Method, r.URL.RawQuery)
+		}
+	}))
+
+	ai.hi.endpoints = testConfig
+	ai.hi.client = &http.Client{Transport: http.DefaultTransport}
+	ai.proxy = srv.URL
+	t.Cleanup(func() { srv.Close() })
+
+	reader := bytes.NewReader(test.GenerateRandomText(1024 * 1024 * 18))
+
+	if err := initialiseS3Client(); err != nil {
+		t.Fatalf("Failed to initialize S3 client: %v", err.Error())
+	} else if err := UploadObject(ctx, reader, "repo", "bucket1000", "obj.txt", 1024*1024*5, 1, nil); err == nil {
+		t.Error("Function did not return error")
+	} else if !errors.Is(err, context.Canceled) {
+		t.Errorf("Function returned incorrect error\nExpected=%v\nReceived=%v", context.Canceled, err)
+	}
+	if !aborted.Load() {
+		t.Error("Multipart upload was not aborted after context was cancelled")
+	}
+}
+
 func TestUploadObject_Error(t *testing.T) {
 	origClient := ai.hi.client
 	origProxy := ai.proxy
@@ -1832,7 +2262,7 @@ func TestUploadObject_Error(t *testing.T) {
 	errStr := "failed to upload object objekti.txt to bucket bucket574: api error InternalServerError: Internal Server Error"
 	if err := initialiseS3Client(); err != nil {
 		t.Errorf("Failed to initialize S3 client: %v", err.Error())
-	} else if err := UploadObject(context.Background(), reader, "repo", "bucket574", "objekti.txt", 1024*1024*5, nil); err == nil {
+	} else if err := UploadObject(context.Background(), reader, "repo", "bucket574", "objekti.txt", 1024*1024*5, 1, nil); err == nil {
 		t.Error("Function did not return error")
 	} else if err.Error() != errStr {
 		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
@@ -144,6 +144,25 @@ var GetReencryptedHeader = func(bucket, object string) (string, int64, error) {
 	return resp.Header, resp.Offset, err
 }
 
+// getObjectHeader returns the header of an SD Connect object re-encrypted with the filesystem's public key,
+// along with the offset at which the body of the object begins in storage
+func getObjectHeader(bucket, object string) (string, int64, error) {
+	header, err := GetFileHeader(SDConnect, bucket, object, "", "")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to retrieve header from Vault for object %s: %w", object, err)
+	}
+	if header != "" {
+		return header, 0, nil
+	}
+
+	header, offset, err := GetReencryptedHeader(bucket, object)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to retrieve header from Allas for object %s: %w", object, err)
+	}
+
+	return header, offset, nil
+}
+
 // PostHeader sends header of an encrypted object to be stored in Vault (only for SD Connect).
 var PostHeader = func(header []byte, bucket, object string) error {
 	body := `{
@@ -158,6 +177,42 @@ var PostHeader = func(header []byte, bucket, object string) error {
 	return makeRequest("POST", ep, query, nil, strings.NewReader(body), nil)
 }
 
+// DeleteHeader removes the header of an SD Connect object from Vault. KrakenD passes the request
+// to the c4ghtransit path files/<project>/<bucket>/<object>, like the requests of PostHeader.
+// dev-tools/scripts/vault_headers.sh checks that the gateway supports it. A missing header is an error,
+// since a gateway without the endpoint responds in the same way.
+var DeleteHeader = func(bucket, object string) error {
+	query := map[string]string{"object": object}
+
+	ep := ai.hi.endpoints.Vault.Headers
+	ep.path += "/" + bucket
+
+	if err := makeRequest("DELETE", ep, query, nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete header of object %s in bucket %s: %w", object, bucket, err)
+	}
+
+	return nil
+}
+
+// ListHeaders returns the names of the objects in SD Connect `bucket` that have a header in Vault.
+// KrakenD lists the c4ghtransit path files/<project>/<bucket>, which responds with the object names in `keys`.
+// Callers use it to check that the gateway supports the headers endpoint before deleting headers.
+var ListHeaders = func(bucket string) ([]string, error) {
+	query := map[string]string{"list": "true"}
+
+	ep := ai.hi.endpoints.Vault.Headers
+	ep.path += "/" + bucket
+
+	var resp struct {
+		Keys []string `json:"keys"`
+	}
+	if err := makeRequest("GET", ep, query, nil, nil, &resp); err != nil {
+		return nil, fmt.Errorf("failed to list headers in bucket %s: %w", bucket, err)
+	}
+
+	return resp.Keys, nil
+}
+
 var GetPublicKey = func() ([32]byte, error) {
 	var encryptionKey keyResponse
 	err := makeRequest("GET", ai.hi.endpoints.Vault.Key, nil, nil, nil, &encryptionKey)
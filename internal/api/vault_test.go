@@ -1,6 +1,7 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"reflect"
@@ -190,6 +191,125 @@ func TestDeleteWhitelistedKeys(t *testing.T) {
 	}
 }
 
+func TestDeleteHeader(t *testing.T) {
+	origMakeRequest := makeRequest
+	defer func() { makeRequest = origMakeRequest }()
+
+	ai.hi.endpoints = testConfig
+
+	var tests = []struct {
+		testname, errStr string
+		err              error
+	}{
+		{"OK", "", nil},
+		{"FAIL_NOT_FOUND", "failed to delete header of object dir/obj.c4gh in bucket bucket: " + (&RequestError{StatusCode: 404}).Error(), &RequestError{StatusCode: 404}},
+		{"FAIL", "failed to delete header of object dir/obj.c4gh in bucket bucket: " + errExpected.Error(), errExpected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			makeRequest = func(method string, ep endpoint, query, headers map[string]string, reqBody io.ReadSeeker, ret any) error {
+				if method != "DELETE" {
+					t.Errorf("Request has incorrect method\nExpected=DELETE\nReceived=%v", method)
+				}
+				expectedPath := testConfig.Vault.Headers.path + "/bucket"
+				if ep.path != expectedPath {
+					t.Errorf("Request has incorrect path\nExpected=%v\nReceived=%v", expectedPath, ep.path)
+				}
+				if query["object"] != "dir/obj.c4gh" {
+					t.Errorf("Request has incorrect object\nExpected=dir/obj.c4gh\nReceived=%v", query["object"])
+				}
+
+				return tt.err
+			}
+
+			err := DeleteHeader("bucket", "dir/obj.c4gh")
+			switch {
+			case tt.errStr == "" && err != nil:
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			case tt.errStr != "" && err == nil:
+				t.Error("Function did not return error")
+			case tt.errStr != "" && err.Error() != tt.errStr:
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+		})
+	}
+}
+
+func TestListHeaders(t *testing.T) {
+	origMakeRequest := makeRequest
+	defer func() { makeRequest = origMakeRequest }()
+
+	ai.hi.endpoints = testConfig
+
+	var tests = []struct {
+		testname, errStr string
+		keys             []string
+		err              error
+	}{
+		{"OK", "", []string{"dir/obj.c4gh", "obj.c4gh"}, nil},
+		{"FAIL_NOT_FOUND", "failed to list headers in bucket bucket: " + (&RequestError{StatusCode: 404}).Error(), nil, &RequestError{StatusCode: 404}},
+		{"FAIL", "failed to list headers in bucket bucket: " + errExpected.Error(), nil, errExpected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			makeRequest = func(method string, ep endpoint, query, headers map[string]string, reqBody io.ReadSeeker, ret any) error {
+				if method != "GET" {
+					t.Errorf("Request has incorrect method\nExpected=GET\nReceived=%v", method)
+				}
+				expectedPath := testConfig.Vault.Headers.path + "/bucket"
+				if ep.path != expectedPath {
+					t.Errorf("Request has incorrect path\nExpected=%v\nReceived=%v", expectedPath, ep.path)
+				}
+				if query["list"] != "true" {
+					t.Errorf("Request is missing list parameter")
+				}
+				if tt.err != nil {
+					return tt.err
+				}
+
+				return json.Unmarshal([]byte(`{"keys": ["dir/obj.c4gh", "obj.c4gh"]}`), ret)
+			}
+
+			keys, err := ListHeaders("bucket")
+			switch {
+			case tt.errStr == "" && err != nil:
+				t.Errorf("Function returned unexpected error: %s", err.Error())
+			case tt.errStr != "" && err == nil:
+				t.Error("Function did not return error")
+			case tt.errStr != "" && err.Error() != tt.errStr:
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			case !reflect.DeepEqual(keys, tt.keys):
+				t.Errorf("Function returned incorrect keys\nExpected=%v\nReceived=%v", tt.keys, keys)
+			}
+		})
+	}
+}
+
+func TestGetObjectHeader_Error(t *testing.T) {
+	origGetFileHeader := GetFileHeader
+	origGetReencryptedHeader := GetReencryptedHeader
+	defer func() {
+		GetFileHeader = origGetFileHeader
+		GetReencryptedHeader = origGetReencryptedHeader
+	}()
+
+	GetFileHeader = func(rep Repo, bucket, object, owner, id string) (string, error) {
+		return "", nil
+	}
+	GetReencryptedHeader = func(bucket, object string) (string, int64, error) {
+		return "", 0, errExpected
+	}
+
+	errStr := "failed to retrieve header from Allas for object obj.c4gh: " + errExpected.Error()
+	if _, _, err := getObjectHeader("bucket", "obj.c4gh"); err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+}
+
 func TestGetPublicKey(t *testing.T) {
 	var tests = []struct {
 		testname, key64 string
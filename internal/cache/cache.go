@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"fmt"
 	"sync"
 	"time"
 
@@ -13,6 +14,12 @@ var onceCache sync.Once
 // RistrettoCacheTTL contains the default time after which a key-value in cache pair will expire
 const RistrettoCacheTTL = 60 * time.Minute
 
+// minSize is the smallest allowed cache size, which fits one chunk of the largest size that is requested
+const minSize int64 = 32 << 20
+
+// maxSize is the maximum size of the cache in bytes
+var maxSize int64 = 1 << 30
+
 // Ristretto is the final data type used when dealing with cache
 type Ristretto struct {
 	Cacheable
@@ -26,6 +33,14 @@ type Cacheable interface {
 	Clear()
 }
 
+// Stats contains the hit and miss counters of the cache and its current contents
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Items  uint64 `json:"items"`
+	Bytes  uint64 `json:"bytes"`
+}
+
 // Because otherwise we cannot mock cache for tests
 type storage struct {
 	cache *ristretto.Cache[string, []byte]
@@ -39,8 +54,8 @@ var NewRistrettoCache = func() (*Ristretto, error) {
 		ristrettoCache, err = ristretto.NewCache(&ristretto.Config[string, []byte]{
 			// Maximum number of items in cache
 			// A recommended number is expected maximum times 10
-			// so 30 * 10 = 300
-			NumCounters: 300,
+			// so 30 * 10 = 300 for every GiB of cache
+			NumCounters: 300 * max(1, maxSize>>30),
 			// Maximum size of cache
 			// Maximum chunk size that is requested is 32MiB.
 			// 1GiB cache can fit 32 items of size 32MiB each
@@ -48,8 +63,10 @@ var NewRistrettoCache = func() (*Ristretto, error) {
 			// there are not more than 300 items.
 			// Runtime seems to allocate roughly double the size of max cache size,
 			// so this now allocates ~2GiB of memory during runtime.
-			MaxCost:     1 << 30, // 1GiB
+			MaxCost:     maxSize, // 1GiB by default
 			BufferItems: 64,
+			// Hit and miss counters are reported by Stats()
+			Metrics: true,
 		})
 
 		if err == nil {
@@ -60,6 +77,21 @@ var NewRistrettoCache = func() (*Ristretto, error) {
 	return cache, err
 }
 
+// SetMaxSize changes the maximum size of the cache in bytes. Has no effect once the cache has been created.
+func SetMaxSize(size int64) error {
+	if size < minSize {
+		return fmt.Errorf("cache size must be at least %d MiB", minSize>>20)
+	}
+	maxSize = size
+
+	return nil
+}
+
+// GetMaxSize returns the maximum size of the cache in bytes
+func GetMaxSize() int64 {
+	return maxSize
+}
+
 // Get returns item behind key "key" and a boolean representing whether the item was found or not
 func (s *storage) Get(key string) ([]byte, bool) {
 	return s.cache.Get(key)
@@ -87,3 +119,20 @@ func (s *storage) Del(key string) {
 func (s *storage) Clear() {
 	s.cache.Clear()
 }
+
+// Stats returns the statistics of the cache. Caches that do not keep statistics return zero values.
+func (r *Ristretto) Stats() Stats {
+	s, ok := r.Cacheable.(*storage)
+	if !ok || s.cache == nil || s.cache.Metrics == nil {
+		return Stats{}
+	}
+
+	m := s.cache.Metrics
+
+	return Stats{
+		Hits:   m.Hits(),
+		Misses: m.Misses(),
+		Items:  m.KeysAdded() - m.KeysEvicted(),
+		Bytes:  m.CostAdded() - m.CostEvicted(),
+	}
+}
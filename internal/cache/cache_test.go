@@ -124,3 +124,51 @@ func TestClear(t *testing.T) {
 		t.Errorf("Key 'key3' with value %q was not cleared from cache", value)
 	}
 }
+
+func TestStats(t *testing.T) {
+	c, err := NewRistrettoCache()
+	if err != nil {
+		t.Fatalf("Creating cache failed: %s", err.Error())
+	}
+	c.Clear()
+
+	content := "Off with their heads!"
+	c.Set("queen", []byte(content), int64(len(content)), -1)
+	time.Sleep(wait)
+	c.Get("queen")
+	c.Get("king")
+
+	// Ristretto adds its own internal cost to each item
+	stats := c.Stats()
+	expected := Stats{Hits: 1, Misses: 1, Items: 1, Bytes: stats.Bytes}
+	if stats != expected || stats.Bytes < uint64(len(content)) {
+		t.Errorf("Received incorrect statistics\nExpected=%+v\nReceived=%+v", expected, stats)
+	}
+
+	mock := &Ristretto{Cacheable: &storage{}}
+	if stats := mock.Stats(); stats != (Stats{}) {
+		t.Errorf("Expected empty statistics, received=%+v", stats)
+	}
+}
+
+func TestSetMaxSize(t *testing.T) {
+	origSize := maxSize
+	defer func() { maxSize = origSize }()
+
+	if err := SetMaxSize(2 << 30); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if GetMaxSize() != 2<<30 {
+		t.Errorf("Incorrect cache size. Expected=%d, received=%d", 2<<30, GetMaxSize())
+	}
+
+	errStr := "cache size must be at least 32 MiB"
+	if err := SetMaxSize(1 << 20); err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+	if GetMaxSize() != 2<<30 {
+		t.Errorf("Cache size should not have changed, received=%d", GetMaxSize())
+	}
+}
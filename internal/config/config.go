@@ -0,0 +1,348 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"sda-filesystem/internal/logs"
+
+	"github.com/BurntSushi/toml"
+)
+
+// EnvPath is the environment variable that overrides the location of the configuration file
+const EnvPath = "DATA_GATEWAY_CONFIG"
+
+// EnvProfile is the environment variable that selects the profile
+const EnvProfile = "DATA_GATEWAY_PROFILE"
+
+// profilesTable contains the named profiles, e.g. [profiles.fast.transfer]
+const profilesTable = "profiles"
+
+type kind int
+
+const (
+	kindString kind = iota
+	kindPath        // String where a leading ~ is replaced with the home directory
+	kindInt
+	kindBool
+	kindList // Array of strings
+	kindSize // Integer in bytes, or a string with an optional K, M or G suffix
+)
+
+var kindNames = map[kind]string{
+	kindString: "a string", kindPath: "a string", kindInt: "an integer", kindBool: "a boolean",
+	kindList: "an array of strings", kindSize: "a size such as 10M",
+}
+
+// settings lists the keys that can be set in the configuration file and in each profile
+var settings = map[string]kind{
+	"logging.level":              kindString,
+	"logging.format":             kindString,
+	"logging.file":               kindPath,
+	"logging.max-size":           kindSize,
+	"logging.backups":            kindInt,
+	"logging.redact":             kindList,
+	"connection.proxy-url":       kindString,
+	"connection.config-endpoint": kindString,
+	"filesystem.mount":           kindPath,
+	"filesystem.repositories":    kindList,
+	"filesystem.cache-size":      kindSize,
+	"filesystem.browse-archives": kindBool,
+	"transfer.parallel":          kindInt,
+	"transfer.upload-limit":      kindSize,
+	"transfer.download-limit":    kindSize,
+	"export.on-conflict":         kindString,
+	"export.verify-decrypt":      kindBool,
+	"export.retry-changed":       kindInt,
+	"export.part-concurrency":    kindInt,
+	"export.memory-budget":       kindSize,
+}
+
+var sizeUnits = map[byte]int64{'K': 1 << 10, 'M': 1 << 20, 'G': 1 << 30}
+
+// Config contains the settings of the selected profile. The zero value contains no settings.
+type Config struct {
+	path    string
+	profile string
+	values  map[string]any
+}
+
+// DefaultPath returns the location of the configuration file when it is not given explicitly,
+// i.e. data-gateway/config.toml in the user's configuration directory (usually ~/.config)
+var DefaultPath = func() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("could not determine configuration directory: %w", err)
+	}
+
+	return filepath.Join(dir, "data-gateway", "config.toml"), nil
+}
+
+// Load reads the configuration file from `path`, or if `path` is empty, from the file named in
+// DATA_GATEWAY_CONFIG or the default location. A missing file at the default location is not an error.
+// The settings of the profile named in `profile`, DATA_GATEWAY_PROFILE or the top-level key `profile`,
+// in that order, override the settings outside the profiles.
+var Load = func(path, profile string) (*Config, error) {
+	explicit := true
+	if path == "" {
+		path = os.Getenv(EnvPath)
+	}
+	if path == "" {
+		var err error
+		if path, err = DefaultPath(); err != nil {
+			return nil, err
+		}
+		explicit = false
+	}
+	if profile == "" {
+		profile = os.Getenv(EnvProfile)
+	}
+
+	c := &Config{path: path, values: make(map[string]any)}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist) && !explicit:
+		if profile != "" {
+			return nil, fmt.Errorf("profile %q not found, configuration file %s does not exist", profile, path)
+		}
+
+		return c, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read configuration file: %w", err)
+	}
+
+	var tables map[string]any
+	if err := toml.Unmarshal(data, &tables); err != nil {
+		return nil, fmt.Errorf("failed to parse configuration file %s: %w", path, err)
+	}
+	values := make(map[string]any)
+	flatten("", tables, values)
+	if profile == "" {
+		if p, ok := values["profile"]; ok {
+			if profile, ok = p.(string); !ok {
+				return nil, fmt.Errorf("invalid value for profile in %s: expected a string", path)
+			}
+		}
+	}
+	delete(values, "profile")
+
+	profiles := make(map[string]bool)
+	overrides := make(map[string]any)
+	for key, value := range values {
+		setting := key
+		if rest, ok := strings.CutPrefix(key, profilesTable+"."); ok {
+			name, profileKey, _ := strings.Cut(rest, ".")
+			profiles[name] = true
+			if name != profile {
+				if _, known := settings[profileKey]; !known {
+					logs.Warningf("Unknown setting %s in %s", key, path)
+				}
+
+				continue
+			}
+			setting = profileKey
+		}
+		if _, known := settings[setting]; !known {
+			logs.Warningf("Unknown setting %s in %s", key, path)
+
+			continue
+		}
+		if value, err = convert(setting, value); err != nil {
+			return nil, fmt.Errorf("invalid value for %s in %s: %w", key, path, err)
+		}
+		if setting != key {
+			overrides[setting] = value
+		} else {
+			c.values[setting] = value
+		}
+	}
+
+	if profile != "" {
+		if !profiles[profile] {
+			return nil, fmt.Errorf("profile %q not found in %s", profile, path)
+		}
+		c.profile = profile
+		for key, value := range overrides {
+			c.values[key] = value
+		}
+	}
+
+	return c, nil
+}
+
+// flatten copies the values in the nested `tables` into `values`, with each key prefixed with the
+// names of its tables, e.g. "filesystem.mount"
+func flatten(prefix string, tables, values map[string]any) {
+	for key, value := range tables {
+		if table, ok := value.(map[string]any); ok {
+			flatten(prefix+key+".", table, values)
+		} else {
+			values[prefix+key] = value
+		}
+	}
+}
+
+// convert checks that `value` has the type that `key` expects and normalises it
+func convert(key string, value any) (any, error) {
+	k := settings[key]
+	switch k {
+	case kindString:
+		if _, ok := value.(string); ok {
+			return value, nil
+		}
+	case kindPath:
+		if path, ok := value.(string); ok {
+			return expandHome(path), nil
+		}
+	case kindInt:
+		if _, ok := value.(int64); ok {
+			return value, nil
+		}
+	case kindBool:
+		if _, ok := value.(bool); ok {
+			return value, nil
+		}
+	case kindList:
+		items, ok := value.([]any)
+		if !ok {
+			break
+		}
+		list := make([]string, 0, len(items))
+		for _, item := range items {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected %s", kindNames[k])
+			}
+			list = append(list, s)
+		}
+
+		return list, nil
+	case kindSize:
+		switch v := value.(type) {
+		case int64:
+			if v >= 0 {
+				return v, nil
+			}
+		case string:
+			return ParseSize(v)
+		}
+	}
+
+	return nil, fmt.Errorf("expected %s", kindNames[k])
+}
+
+// expandHome replaces a leading ~ in `path` with the home directory of the user
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+
+	return filepath.Join(home, path[1:])
+}
+
+// ParseSize converts a size such as 500K, 10M or 1G into bytes
+func ParseSize(input string) (int64, error) {
+	size := strings.ToUpper(strings.TrimSpace(input))
+	if size == "" {
+		return 0, fmt.Errorf("invalid size %q", input)
+	}
+
+	unit := int64(1)
+	if u, ok := sizeUnits[size[len(size)-1]]; ok {
+		unit = u
+		size = size[:len(size)-1]
+	}
+	value, err := strconv.ParseInt(size, 10, 64)
+	if err != nil || value < 0 || value > math.MaxInt64/unit {
+		return 0, fmt.Errorf("invalid size %q", input)
+	}
+
+	return value * unit, nil
+}
+
+// FormatSize is the inverse of ParseSize, e.g. 10485760 becomes 10M
+func FormatSize(size int64) string {
+	for _, suffix := range []byte{'G', 'M', 'K'} {
+		if unit := sizeUnits[suffix]; size != 0 && size%unit == 0 {
+			return fmt.Sprintf("%d%c", size/unit, suffix)
+		}
+	}
+
+	return strconv.FormatInt(size, 10)
+}
+
+// Path returns the location of the configuration file
+func (c *Config) Path() string {
+	return c.path
+}
+
+// Profile returns the name of the selected profile, or an empty string if no profile is used
+func (c *Config) Profile() string {
+	return c.profile
+}
+
+// Keys returns the settings that have a value, in sorted order
+func (c *Config) Keys() []string {
+	keys := make([]string, 0, len(c.values))
+	for key := range c.values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	return keys
+}
+
+// Value returns the setting `key` formatted as a string, so that it can be given to a command line flag
+func (c *Config) Value(key string) (string, bool) {
+	switch v := c.values[key].(type) {
+	case string:
+		return v, true
+	case int64:
+		return strconv.FormatInt(v, 10), true
+	case bool:
+		return strconv.FormatBool(v), true
+	case []string:
+		return strings.Join(v, ","), true
+	}
+
+	return "", false
+}
+
+// String returns the setting `key` if it is a string
+func (c *Config) String(key string) (string, bool) {
+	v, ok := c.values[key].(string)
+
+	return v, ok
+}
+
+// Int returns the setting `key` if it is an integer or a size
+func (c *Config) Int(key string) (int64, bool) {
+	v, ok := c.values[key].(int64)
+
+	return v, ok
+}
+
+// Bool returns the setting `key` if it is a boolean
+func (c *Config) Bool(key string) (bool, bool) {
+	v, ok := c.values[key].(bool)
+
+	return v, ok
+}
+
+// Strings returns the setting `key` if it is an array of strings
+func (c *Config) Strings(key string) ([]string, bool) {
+	v, ok := c.values[key].([]string)
+
+	return v, ok
+}
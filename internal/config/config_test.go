@@ -0,0 +1,248 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"sda-filesystem/internal/logs"
+)
+
+const testConfig = `profile = "fast"
+
+[logging]
+level = "debug"
+
+[filesystem]
+mount = "~/gateway"
+repositories = ["SD-Apply"]
+
+[transfer]
+download-limit = "1M"
+upload-limit = 1024
+
+[export]
+verify-decrypt = true
+mystery = 5
+
+[profiles.fast.transfer]
+download-limit = 0
+parallel = 8
+
+[profiles.quiet]
+logging = { level = "error" }
+`
+
+func TestLoad(t *testing.T) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Fatalf("Could not get home directory: %s", err.Error())
+	}
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+	if err = os.WriteFile(file, []byte(testConfig), 0o600); err != nil {
+		t.Fatalf("Could not write configuration file: %s", err.Error())
+	}
+
+	base := map[string]any{
+		"logging.level":           "debug",
+		"filesystem.mount":        filepath.Join(home, "gateway"),
+		"filesystem.repositories": []string{"SD-Apply"},
+		"transfer.download-limit": int64(1 << 20),
+		"transfer.upload-limit":   int64(1024),
+		"export.verify-decrypt":   true,
+	}
+	withProfile := func(profile map[string]any) map[string]any {
+		values := make(map[string]any)
+		for key, value := range base {
+			values[key] = value
+		}
+		for key, value := range profile {
+			values[key] = value
+		}
+
+		return values
+	}
+
+	var tests = []struct {
+		testname, path, profile, envPath, envProfile string
+		expectedPath, expectedProfile                string
+		values                                       map[string]any
+	}{
+		{
+			"OK_TOP_LEVEL_PROFILE", file, "", "", "", file, "fast",
+			withProfile(map[string]any{"transfer.download-limit": int64(0), "transfer.parallel": int64(8)}),
+		},
+		{"OK_PROFILE_ARG", file, "quiet", "", "fast", file, "quiet", withProfile(map[string]any{"logging.level": "error"})},
+		{"OK_PROFILE_ENV", "", "", file, "quiet", file, "quiet", withProfile(map[string]any{"logging.level": "error"})},
+		{"OK_NO_FILE", "", "", "", "", filepath.Join(dir, "missing.toml"), "", map[string]any{}},
+	}
+
+	origDefaultPath := DefaultPath
+	origWarningf := logs.Warningf
+	defer func() {
+		DefaultPath = origDefaultPath
+		logs.Warningf = origWarningf
+	}()
+
+	DefaultPath = func() (string, error) {
+		return filepath.Join(dir, "missing.toml"), nil
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			t.Setenv(EnvPath, tt.envPath)
+			t.Setenv(EnvProfile, tt.envProfile)
+			var warnings []string
+			logs.Warningf = func(format string, args ...any) {
+				warnings = append(warnings, fmt.Sprintf(format, args...))
+			}
+
+			c, err := Load(tt.path, tt.profile)
+			if err != nil {
+				t.Fatalf("Function returned unexpected error: %s", err.Error())
+			}
+			if c.Path() != tt.expectedPath || c.Profile() != tt.expectedProfile {
+				t.Errorf("Incorrect path or profile. Expected=%s %q, received=%s %q", tt.expectedPath, tt.expectedProfile, c.Path(), c.Profile())
+			}
+			if !reflect.DeepEqual(c.values, tt.values) {
+				t.Errorf("Incorrect settings\nExpected=%v\nReceived=%v", tt.values, c.values)
+			}
+
+			var expectedWarnings []string
+			if tt.testname != "OK_NO_FILE" {
+				expectedWarnings = []string{"Unknown setting export.mystery in " + file}
+			}
+			if !reflect.DeepEqual(warnings, expectedWarnings) {
+				t.Errorf("Incorrect warnings\nExpected=%q\nReceived=%q", expectedWarnings, warnings)
+			}
+		})
+	}
+}
+
+func TestLoad_Error(t *testing.T) {
+	dir := t.TempDir()
+	var tests = []struct {
+		testname, data, profile, errStr string
+	}{
+		{"FAIL_PARSE", "[logging\n", "", "failed to parse configuration file %s: toml: line 2: expected '.' or ']' to end table name, but got '\\n' instead"},
+		{"FAIL_PROFILE_TYPE", "profile = 1", "", "invalid value for profile in %s: expected a string"},
+		{"FAIL_PROFILE", "[profiles.fast.logging]\nlevel = \"debug\"", "slow", "profile \"slow\" not found in %s"},
+		{"FAIL_TYPE", "[filesystem]\ncache-size = \"big\"", "", "invalid value for filesystem.cache-size in %s: invalid size \"big\""},
+		{"FAIL_LIST", "[filesystem]\nrepositories = [1]", "", "invalid value for filesystem.repositories in %s: expected an array of strings"},
+		{"FAIL_SIZE", "[transfer]\nupload-limit = \"fast\"", "", "invalid value for transfer.upload-limit in %s: invalid size \"fast\""},
+		{"FAIL_NEGATIVE_SIZE", "[transfer]\nupload-limit = -1", "", "invalid value for transfer.upload-limit in %s: expected a size such as 10M"},
+		{
+			"FAIL_PROFILE_VALUE", "[profiles.fast.export]\nverify-decrypt = \"yes\"", "fast",
+			"invalid value for profiles.fast.export.verify-decrypt in %s: expected a boolean",
+		},
+	}
+
+	t.Setenv(EnvPath, "")
+	t.Setenv(EnvProfile, "")
+	for i, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			file := filepath.Join(dir, fmt.Sprintf("config%d.toml", i))
+			if err := os.WriteFile(file, []byte(tt.data), 0o600); err != nil {
+				t.Fatalf("Could not write configuration file: %s", err.Error())
+			}
+
+			errStr := fmt.Sprintf(tt.errStr, file)
+			if _, err := Load(file, tt.profile); err == nil {
+				t.Error("Function did not return error")
+			} else if err.Error() != errStr {
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+			}
+		})
+	}
+
+	// A file given explicitly must exist, and so must a profile that is requested
+	missing := filepath.Join(dir, "missing.toml")
+	if _, err := Load(missing, ""); err == nil {
+		t.Error("Function did not return error for missing file")
+	}
+	origDefaultPath := DefaultPath
+	defer func() { DefaultPath = origDefaultPath }()
+	DefaultPath = func() (string, error) {
+		return missing, nil
+	}
+	errStr := fmt.Sprintf("profile \"fast\" not found, configuration file %s does not exist", missing)
+	if _, err := Load("", "fast"); err == nil {
+		t.Error("Function did not return error for missing profile")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+}
+
+func TestConfigValues(t *testing.T) {
+	c := &Config{values: map[string]any{
+		"logging.level":              "debug",
+		"filesystem.cache-size":      int64(2048),
+		"filesystem.browse-archives": true,
+		"filesystem.repositories":    []string{"SD-Connect", "SD-Apply"},
+	}}
+
+	if value, ok := c.Value("filesystem.cache-size"); !ok || value != "2048" {
+		t.Errorf("Incorrect value. Expected=2048, received=%q", value)
+	}
+	if value, ok := c.Value("filesystem.browse-archives"); !ok || value != "true" {
+		t.Errorf("Incorrect value. Expected=true, received=%q", value)
+	}
+	if value, ok := c.Value("filesystem.repositories"); !ok || value != "SD-Connect,SD-Apply" {
+		t.Errorf("Incorrect value. Expected=SD-Connect,SD-Apply, received=%q", value)
+	}
+	if _, ok := c.Value("filesystem.mount"); ok {
+		t.Error("Setting without a value should not be found")
+	}
+	if level, ok := c.String("logging.level"); !ok || level != "debug" {
+		t.Errorf("Incorrect string. Expected=debug, received=%q", level)
+	}
+	if _, ok := c.Int("logging.level"); ok {
+		t.Error("String should not be returned as an integer")
+	}
+	if keys := c.Keys(); !reflect.DeepEqual(keys, []string{
+		"filesystem.browse-archives", "filesystem.cache-size", "filesystem.repositories", "logging.level",
+	}) {
+		t.Errorf("Incorrect keys: %v", keys)
+	}
+
+	// The zero value contains no settings
+	var empty Config
+	if _, ok := empty.Bool("filesystem.browse-archives"); ok {
+		t.Error("Empty configuration should not contain settings")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	if size, err := ParseSize(" 2k "); err != nil || size != 2<<10 {
+		t.Errorf("Function returned incorrect size. Expected=%d, received=%d (%v)", 2<<10, size, err)
+	}
+
+	errStr := "invalid size \"2K/s\""
+	if _, err := ParseSize("2K/s"); err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	var tests = []struct {
+		size     int64
+		expected string
+	}{
+		{0, "0"},
+		{1500, "1500"},
+		{2048, "2K"},
+		{10 << 20, "10M"},
+		{1 << 30, "1G"},
+	}
+
+	for _, tt := range tests {
+		if received := FormatSize(tt.size); received != tt.expected {
+			t.Errorf("Function returned incorrect string. Expected=%s, received=%s", tt.expected, received)
+		}
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"strconv"
 	"strings"
 	"syscall"
+	"time"
 	"unsafe"
 
 	"sda-filesystem/internal/api"
@@ -343,9 +344,18 @@ func ClearPath(path string) error {
 }
 
 func clearNode(node *C.node_t, pathNodes []string, meta map[string]api.Metadata) (C.off_t, C.time_t) {
+	if member, ok := fi.members[node.stat.st_ino]; ok {
+		// Members of archives keep their place, only the data cached for the archive is cleared
+		nodes := append([]string{member.bucket}, strings.Split(member.object, "/")...)
+		api.DeleteFileFromCache(api.SDConnect, nodes, member.archiveSize)
+		delete(fi.archiveHeaders, member.bucket+"/"+member.object)
+
+		return node.stat.st_size, node.last_modified.tv_sec
+	}
 	if node.children == nil {
 		api.DeleteFileFromCache(api.SDConnect, pathNodes, int64(node.stat.st_size))
 		delete(fi.headers, node.stat.st_ino)
+		delete(fi.checksums, node.stat.st_ino)
 		path := strings.Join(pathNodes, "/")
 		obj, ok := meta[path]
 		if ok {
@@ -383,6 +393,10 @@ func clearNode(node *C.node_t, pathNodes []string, meta map[string]api.Metadata)
 //
 //export CheckHeaderExistence
 func CheckHeaderExistence(node *C.node_t, cpath *C.cchar_t) {
+	if _, ok := fi.members[node.stat.st_ino]; ok {
+		return // The header of the archive is fetched when the member is read
+	}
+
 	path := C.GoString(cpath)
 	logs.Debugf("Checking existence of header for object %s", path)
 
@@ -475,6 +489,10 @@ func DownloadData(node *C.node_t, cpath *C.cchar_t, cbuffer *C.char, size C.size
 		return -1
 	}
 
+	if member, ok := fi.members[node.stat.st_ino]; ok {
+		return downloadMember(member, path, buffer, offset)
+	}
+
 	header := fi.headers[node.stat.st_ino]
 	if header.value == "" {
 		logs.Errorf("You do not have permission to access file %s: %s", path,
@@ -497,10 +515,94 @@ func DownloadData(node *C.node_t, cpath *C.cchar_t, cbuffer *C.char, size C.size
 	data, err := api.DownloadData(rep, pathNames, path, header.owner, header.fileID, header.value,
 		int64(offset), int64(offset)+int64(size), int64(node.offset), int64(node.stat.st_size))
 	if err != nil {
-		logs.Errorf("Retrieving data failed for %s: %w", path, err)
+		logs.WithSubsystem("filesystem").WithFields(logs.Fields{
+			logs.FieldRepository: rep.ForPath(), logs.FieldBucket: pathNames[0], logs.FieldObject: strings.Join(pathNames[1:], "/"),
+		}).Errorf("Retrieving data failed for %s: %w", path, err)
 
 		return -3
 	}
 
 	return C.int(copy(buffer, data))
 }
+
+// GetAttributes changes the modification time and permissions of the object represented by `node` to those of
+// the original file, if they were stored in the metadata of the object during export. Only SD Connect objects
+// can have them. The checksum in the metadata is also stored so that GetChecksum() does not need to fetch it again.
+// The metadata is fetched when the file is first opened, since getattr must not block the single-threaded filesystem.
+//
+//export GetAttributes
+func GetAttributes(node *C.node_t, cpath *C.cchar_t) {
+	pathNames := getNodePathNames(node)
+	if pathNames[1] != api.SDConnect.ForPath() || len(pathNames) < 5 {
+		return
+	}
+
+	path := C.GoString(cpath)
+	_, meta, err := api.GetObjectMetadata(api.SDConnect, pathNames[3], strings.Join(pathNames[4:], "/"))
+	if err != nil {
+		logs.Warningf("Failed to retrieve attributes for %s: %w", path, err)
+
+		return
+	}
+	if _, ok := fi.checksums[node.stat.st_ino]; !ok {
+		fi.checksums[node.stat.st_ino] = meta[api.MetaChecksum]
+	}
+
+	if modified, err := time.Parse(time.RFC3339Nano, meta[api.MetaModified]); err == nil {
+		node.last_modified.tv_sec = C.time_t(modified.Unix())
+		node.last_modified.tv_nsec = C.long(modified.Nanosecond())
+	}
+	if mode, err := strconv.ParseInt(meta[api.MetaMode], 8, 64); err == nil {
+		node.stat.st_mode = syscall.S_IFREG | C.mode_t(filePermissions(mode))
+	}
+}
+
+// filePermissions returns the permissions a file with the original permissions `mode` has in the filesystem.
+// Only the executable bit is preserved, since the owner of the original file is not known.
+func filePermissions(mode int64) int64 {
+	if mode&0111 != 0 {
+		return 0755
+	}
+
+	return 0644
+}
+
+// GetChecksum copies the SHA-256 checksum of the decrypted content of the object represented by `node` into `cbuffer`.
+// The checksum is stored in the metadata of SD Connect objects that have been exported with Data Gateway.
+// Function returns the length of the checksum, or, if the checksum could not be copied, a negative integer,
+// which will be interpreted in the C function calling GetChecksum(). If `size` is zero, only the length is returned.
+//
+//export GetChecksum
+func GetChecksum(node *C.node_t, cpath *C.cchar_t, cbuffer *C.char, size C.size_t) C.int {
+	checksum, ok := fi.checksums[node.stat.st_ino]
+	if !ok {
+		pathNames := getNodePathNames(node)
+		if pathNames[1] != api.SDConnect.ForPath() || len(pathNames) < 5 {
+			return -1
+		}
+
+		path := C.GoString(cpath)
+		_, meta, err := api.GetObjectMetadata(api.SDConnect, pathNames[3], strings.Join(pathNames[4:], "/"))
+		if err != nil {
+			logs.Errorf("Failed to retrieve checksum for %s: %w", path, err)
+
+			return -3
+		}
+
+		checksum = meta[api.MetaChecksum]
+		fi.checksums[node.stat.st_ino] = checksum
+	}
+
+	switch {
+	case checksum == "":
+		return -1
+	case size == 0:
+		return C.int(len(checksum))
+	case int(size) < len(checksum):
+		return -2
+	}
+
+	buffer := unsafe.Slice((*byte)(unsafe.Pointer(cbuffer)), C.int(size))
+
+	return C.int(copy(buffer, checksum))
+}
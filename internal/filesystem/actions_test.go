@@ -10,6 +10,7 @@ import (
 	"slices"
 	"strconv"
 	"strings"
+	"syscall"
 	"testing"
 	"time"
 	"unsafe"
@@ -964,3 +965,161 @@ func TestCheckHeaderExistence_TooSmall(t *testing.T) {
 		t.Errorf("Checking for header changed filesystem: %s", err.Error())
 	}
 }
+
+func TestGetChecksum(t *testing.T) {
+	fi.nodes = getTestFuse(t)
+	nodeSlice := unsafe.Slice(fi.nodes.nodes, fsSize)
+
+	origGetObjectMetadata := api.GetObjectMetadata
+	origChecksums := fi.checksums
+	defer func() {
+		api.GetObjectMetadata = origGetObjectMetadata
+		fi.checksums = origChecksums
+	}()
+
+	checksum := "a948904f2f0f479b8f8197694b30184b0d2ed1c1cd2a1ec0fb85d299a192a447"
+	calls := 0
+	api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+		calls++
+		if bucket != "bucket_2" {
+			t.Errorf("api.GetObjectMetadata() received incorrect bucket. Expected=bucket_2, received=%s", bucket)
+		}
+		if object != "?folder/test" {
+			t.Errorf("api.GetObjectMetadata() received incorrect object. Expected=?folder/test, received=%s", object)
+		}
+
+		return 0, map[string]string{api.MetaChecksum: checksum}, nil
+	}
+	fi.checksums = make(map[_Ctype_ino_t]string)
+
+	node := &nodeSlice[35]
+	if n := GetChecksum(node, node.name, nil, 0); int(n) != len(checksum) {
+		t.Errorf("Function returned incorrect length. Expected=%d, received=%d", len(checksum), n)
+	}
+
+	buffer := make([]byte, 10)
+	if n := GetChecksum(node, node.name, (*_Ctype_char)(unsafe.Pointer(&buffer[0])), _Ctype_size_t(len(buffer))); n != -2 {
+		t.Errorf("Function returned incorrect value for small buffer. Expected=-2, received=%d", n)
+	}
+
+	buffer = make([]byte, 100)
+	n := GetChecksum(node, node.name, (*_Ctype_char)(unsafe.Pointer(&buffer[0])), _Ctype_size_t(len(buffer)))
+	if int(n) != len(checksum) {
+		t.Errorf("Function returned incorrect length. Expected=%d, received=%d", len(checksum), n)
+	} else if string(buffer[:n]) != checksum {
+		t.Errorf("Function copied incorrect checksum\nExpected=%s\nReceived=%s", checksum, string(buffer[:n]))
+	}
+	if calls != 1 {
+		t.Errorf("api.GetObjectMetadata() should have been called once, was called %d times", calls)
+	}
+}
+
+func TestGetChecksum_NoChecksum(t *testing.T) {
+	fi.nodes = getTestFuse(t)
+	nodeSlice := unsafe.Slice(fi.nodes.nodes, fsSize)
+
+	origGetObjectMetadata := api.GetObjectMetadata
+	origChecksums := fi.checksums
+	defer func() {
+		api.GetObjectMetadata = origGetObjectMetadata
+		fi.checksums = origChecksums
+	}()
+
+	var tests = []struct {
+		testname string
+		nodeIdx  int
+		err      error
+		code     int
+	}{
+		{"FAIL_SD_APPLY", 8, nil, -1},
+		{"FAIL_NO_CHECKSUM", 35, nil, -1},
+		{"FAIL_REQUEST", 35, errExpected, -3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			fi.checksums = make(map[_Ctype_ino_t]string)
+			api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+				if tt.nodeIdx == 8 {
+					t.Error("api.GetObjectMetadata() should not have been called")
+				}
+
+				return 0, map[string]string{api.MetaModified: "2020-01-01T00:00:00Z"}, tt.err
+			}
+
+			node := &nodeSlice[tt.nodeIdx]
+			if n := GetChecksum(node, node.name, nil, 0); int(n) != tt.code {
+				t.Errorf("Function returned incorrect value. Expected=%d, received=%d", tt.code, n)
+			}
+		})
+	}
+}
+
+func TestGetAttributes(t *testing.T) {
+	origGetObjectMetadata := api.GetObjectMetadata
+	origChecksums := fi.checksums
+	defer func() {
+		api.GetObjectMetadata = origGetObjectMetadata
+		fi.checksums = origChecksums
+	}()
+
+	modified := time.Date(2023, 1, 2, 3, 4, 5, 6, time.UTC)
+	var tests = []struct {
+		testname string
+		nodeIdx  int
+		meta     map[string]string
+		err      error
+		mode     uint32
+		modified bool
+		checksum bool
+	}{
+		{
+			"OK_EXECUTABLE", 35,
+			map[string]string{api.MetaModified: modified.Format(time.RFC3339Nano), api.MetaMode: "0750", api.MetaChecksum: "abc"},
+			nil, syscall.S_IFREG | 0755, true, true,
+		},
+		{
+			"OK_REGULAR", 35,
+			map[string]string{api.MetaModified: modified.Format(time.RFC3339Nano), api.MetaMode: "0600"},
+			nil, syscall.S_IFREG | 0644, true, true,
+		},
+		{"OK_NO_METADATA", 35, map[string]string{}, nil, syscall.S_IFREG | 0644, false, true},
+		{"OK_INVALID_METADATA", 35, map[string]string{api.MetaModified: "yesterday", api.MetaMode: "rwx"}, nil, syscall.S_IFREG | 0644, false, true},
+		{"OK_SD_APPLY", 8, nil, nil, syscall.S_IFREG | 0644, false, false},
+		{"FAIL_REQUEST", 35, nil, errExpected, syscall.S_IFREG | 0644, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			fi.nodes = getTestFuse(t)
+			fi.checksums = make(map[_Ctype_ino_t]string)
+			node := &unsafe.Slice(fi.nodes.nodes, fsSize)[tt.nodeIdx]
+			origModified := node.last_modified
+
+			api.GetObjectMetadata = func(rep api.Repo, bucket, object string) (int64, map[string]string, error) {
+				if tt.nodeIdx == 8 {
+					t.Error("api.GetObjectMetadata() should not have been called")
+				}
+				if bucket != "bucket_2" || object != "?folder/test" {
+					t.Errorf("api.GetObjectMetadata() received incorrect object %s/%s", bucket, object)
+				}
+
+				return 0, tt.meta, tt.err
+			}
+
+			GetAttributes(node, node.name)
+			if uint32(node.stat.st_mode) != tt.mode {
+				t.Errorf("Node has incorrect mode. Expected=%o, received=%o", tt.mode, node.stat.st_mode)
+			}
+			switch {
+			case tt.modified && (int64(node.last_modified.tv_sec) != modified.Unix() || int64(node.last_modified.tv_nsec) != 6):
+				t.Errorf("Node has incorrect timestamp %+v, expected %v", node.last_modified, modified)
+			case !tt.modified && node.last_modified != origModified:
+				t.Errorf("Timestamp of node should not have changed, received %+v", node.last_modified)
+			}
+			if checksum, ok := fi.checksums[node.stat.st_ino]; ok != tt.checksum || checksum != tt.meta[api.MetaChecksum] {
+				t.Errorf("Checksum was stored incorrectly, received %q", checksum)
+			}
+		})
+	}
+}
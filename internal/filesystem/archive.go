@@ -0,0 +1,138 @@
+package filesystem
+
+/*
+#include <sys/stat.h>
+#include "helpers.h"
+*/
+import "C"
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/logs"
+)
+
+// archiveMember locates the content of a file inside an archive object
+type archiveMember struct {
+	bucket, object string
+	offset, size   int64
+	mode           int64 // Permissions of the original file
+	archiveSize    int64 // Decrypted size of the archive object
+	checksum       string
+}
+
+// SetBrowseArchives determines whether archive objects exported with `export -archive` are presented as directories.
+// Only uncompressed archives in the user's own SD Connect buckets can be browsed.
+func SetBrowseArchives(enabled bool) {
+	fi.browseArchives = enabled
+}
+
+// expandArchives replaces the archive objects in `meta` that have a member index with the files inside them.
+// The index objects of these archives are removed. Returned map contains the members by their full object paths.
+func expandArchives(bucket string, meta []api.Metadata) ([]api.Metadata, map[string]archiveMember) {
+	names := make(map[string]bool, len(meta))
+	for i := range meta {
+		names[meta[i].Name] = true
+	}
+
+	members := make(map[string]archiveMember)
+	indexes := make(map[string]bool)
+	expanded := make([]api.Metadata, 0, len(meta))
+	for i := range meta {
+		name := meta[i].Name
+		if !strings.HasSuffix(name, api.ArchiveSuffix) || !names[api.ArchiveIndexObject(name)] {
+			expanded = append(expanded, meta[i])
+
+			continue
+		}
+
+		index, err := api.GetArchiveIndex(bucket, name)
+		if err != nil {
+			logs.Warningf("Archive %s in bucket %s cannot be browsed: %w", name, bucket, err)
+			expanded = append(expanded, meta[i])
+
+			continue
+		}
+		logs.Debugf("Presenting archive %s in bucket %s as a directory", name, bucket)
+
+		dir := strings.TrimSuffix(name, ".c4gh")
+		archiveSize := int64(calculateDecryptedSize(C.off_t(meta[i].Size)))
+		for _, member := range index {
+			if !filepath.IsLocal(member.Name) {
+				logs.Warningf("Skipping member %q of archive %s with an invalid name", member.Name, name)
+
+				continue
+			}
+			path := dir + "/" + member.Name
+			expanded = append(expanded, api.Metadata{Name: path, Size: member.Size, LastModified: &member.Modified})
+			members[path] = archiveMember{
+				bucket:      bucket,
+				object:      name,
+				offset:      member.Offset,
+				size:        member.Size,
+				mode:        member.Mode,
+				archiveSize: archiveSize,
+				checksum:    member.Checksum,
+			}
+		}
+		indexes[api.ArchiveIndexObject(name)] = true
+	}
+
+	return slices.DeleteFunc(expanded, func(m api.Metadata) bool { return indexes[m.Name] }), members
+}
+
+// attachMembers links the file nodes under `node` to their location inside archive objects.
+// `path` is the path of `node` inside its bucket, formed from the original names of the nodes.
+func attachMembers(node *goNode, path string, members map[string]archiveMember) {
+	for _, chld := range node.children {
+		chldPath := chld.meta.Name
+		if path != "" {
+			chldPath = path + "/" + chldPath
+		}
+		if chld.children != nil {
+			attachMembers(chld, chldPath, members)
+		} else if member, ok := members[chldPath]; ok {
+			chld.member = &member
+		}
+	}
+}
+
+// downloadMember fills `buffer` with the content of an archive member, starting from `offset`
+func downloadMember(member archiveMember, path string, buffer []byte, offset C.off_t) C.int {
+	if int64(offset) >= member.size {
+		return 0
+	}
+
+	key := member.bucket + "/" + member.object
+	hdr, ok := fi.archiveHeaders[key]
+	if !ok {
+		var err error
+		hdr, err = api.GetFileHeader(api.SDConnect, member.bucket, member.object, "", "")
+		if err != nil {
+			logs.Errorf("Failed to retrieve header from Vault for archive %s: %w", key, err)
+
+			return -3
+		}
+		if hdr == "" {
+			logs.Errorf("Archive %s has no header", key)
+
+			return -2
+		}
+		fi.archiveHeaders[key] = hdr
+	}
+
+	nodes := append([]string{member.bucket}, strings.Split(member.object, "/")...)
+	start := member.offset + int64(offset)
+	end := member.offset + min(int64(offset)+int64(len(buffer)), member.size)
+	data, err := api.DownloadData(api.SDConnect, nodes, path, "", "", hdr, start, end, 0, member.archiveSize)
+	if err != nil {
+		logs.Errorf("Retrieving data failed for %s: %w", path, err)
+
+		return -3
+	}
+
+	return C.int(copy(buffer, data))
+}
@@ -0,0 +1,194 @@
+package filesystem
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"sda-filesystem/internal/api"
+)
+
+func TestSetBrowseArchives(t *testing.T) {
+	origBrowse := fi.browseArchives
+	defer func() { fi.browseArchives = origBrowse }()
+
+	SetBrowseArchives(true)
+	if !fi.browseArchives {
+		t.Error("Browsing archives was not enabled")
+	}
+	SetBrowseArchives(false)
+	if fi.browseArchives {
+		t.Error("Browsing archives was not disabled")
+	}
+}
+
+func TestExpandArchives(t *testing.T) {
+	origGetArchiveIndex := api.GetArchiveIndex
+	defer func() { api.GetArchiveIndex = origGetArchiveIndex }()
+
+	modified := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	api.GetArchiveIndex = func(bucket, object string) ([]api.ArchiveMember, error) {
+		if bucket != "bucket" {
+			t.Errorf("api.GetArchiveIndex() received incorrect bucket. Expected=bucket, received=%s", bucket)
+		}
+		switch object {
+		case "dir/data.tar.c4gh":
+			return []api.ArchiveMember{
+				{Name: "data/file.txt", Offset: 512, Size: 12, Modified: modified, Checksum: "abc"},
+				{Name: "data/sub/other.txt", Offset: 1536, Size: 600, Mode: 0755, Modified: modified},
+				{Name: "../escape.txt", Offset: 2560, Size: 3, Modified: modified},
+			}, nil
+		case "broken.tar.c4gh":
+			return nil, errExpected
+		}
+		t.Errorf("api.GetArchiveIndex() received unexpected object %s", object)
+
+		return nil, nil
+	}
+
+	meta := []api.Metadata{
+		{Name: "dir/data.tar.c4gh", Size: 65536 + 2*28},
+		{Name: "dir/data.tar.index.json.c4gh", Size: 300},
+		{Name: "broken.tar.c4gh", Size: 1000},
+		{Name: "broken.tar.index.json.c4gh", Size: 100},
+		{Name: "noindex.tar.c4gh", Size: 1000},
+		{Name: "packed.tar.zst.c4gh", Size: 1000},
+		{Name: "packed.tar.zst.index.json.c4gh", Size: 100},
+		{Name: "file.c4gh", Size: 10},
+	}
+
+	expanded, members := expandArchives("bucket", meta)
+	names := make([]string, len(expanded))
+	for i := range expanded {
+		names[i] = expanded[i].Name
+	}
+	expectedNames := []string{
+		"dir/data.tar/data/file.txt", "dir/data.tar/data/sub/other.txt",
+		"broken.tar.c4gh", "broken.tar.index.json.c4gh", "noindex.tar.c4gh",
+		"packed.tar.zst.c4gh", "packed.tar.zst.index.json.c4gh", "file.c4gh",
+	}
+	if !slices.Equal(names, expectedNames) {
+		t.Errorf("Function returned incorrect objects\nExpected=%v\nReceived=%v", expectedNames, names)
+	}
+	if expanded[1].Size != 600 || !expanded[1].LastModified.Equal(modified) {
+		t.Errorf("Member has incorrect metadata: size=%d, modified=%v", expanded[1].Size, expanded[1].LastModified)
+	}
+
+	expectedMembers := map[string]archiveMember{
+		"dir/data.tar/data/file.txt": {
+			bucket: "bucket", object: "dir/data.tar.c4gh", offset: 512, size: 12, archiveSize: 65536, checksum: "abc",
+		},
+		"dir/data.tar/data/sub/other.txt": {
+			bucket: "bucket", object: "dir/data.tar.c4gh", offset: 1536, size: 600, mode: 0755, archiveSize: 65536,
+		},
+	}
+	if !reflect.DeepEqual(members, expectedMembers) {
+		t.Errorf("Function returned incorrect members\nExpected=%+v\nReceived=%+v", expectedMembers, members)
+	}
+}
+
+func TestAttachMembers(t *testing.T) {
+	member1 := archiveMember{bucket: "bucket", object: "data.tar.c4gh", offset: 512, size: 10}
+	member2 := archiveMember{bucket: "bucket", object: "data.tar.c4gh", offset: 1536, size: 20}
+	members := map[string]archiveMember{
+		"data.tar/file.txt":     member1,
+		"data.tar/sub/file.txt": member2,
+	}
+
+	file1 := &goNode{meta: api.Metadata{Name: "file.txt"}}
+	file2 := &goNode{meta: api.Metadata{Name: "file.txt"}}
+	other := &goNode{meta: api.Metadata{Name: "other.c4gh"}}
+	root := &goNode{children: map[string]*goNode{
+		"data.tar": {meta: api.Metadata{Name: "data.tar"}, children: map[string]*goNode{
+			"file.txt": file1,
+			"sub":      {meta: api.Metadata{Name: "sub"}, children: map[string]*goNode{"file.txt": file2}},
+		}},
+		"other": other,
+	}}
+
+	attachMembers(root, "", members)
+	if file1.member == nil || *file1.member != member1 {
+		t.Errorf("Node data.tar/file.txt has incorrect member %+v", file1.member)
+	}
+	if file2.member == nil || *file2.member != member2 {
+		t.Errorf("Node data.tar/sub/file.txt has incorrect member %+v", file2.member)
+	}
+	if other.member != nil {
+		t.Errorf("Node other should not have a member, received %+v", other.member)
+	}
+}
+
+func TestDownloadMember(t *testing.T) {
+	origGetFileHeader := api.GetFileHeader
+	origDownloadData := api.DownloadData
+	origArchiveHeaders := fi.archiveHeaders
+	defer func() {
+		api.GetFileHeader = origGetFileHeader
+		api.DownloadData = origDownloadData
+		fi.archiveHeaders = origArchiveHeaders
+	}()
+
+	content := strings.Repeat("a", 1000) + "member content" + strings.Repeat("b", 1000)
+	member := archiveMember{bucket: "bucket", object: "dir/data.tar.c4gh", offset: 1000, size: 14, archiveSize: 2014}
+
+	headerCalls := 0
+	api.GetFileHeader = func(rep api.Repo, bucket, object, owner, id string) (string, error) {
+		headerCalls++
+		if rep != api.SDConnect || bucket != "bucket" || object != "dir/data.tar.c4gh" {
+			t.Errorf("api.GetFileHeader() received incorrect object %s/%s/%s", rep, bucket, object)
+		}
+
+		return "header", nil
+	}
+	api.DownloadData = func(rep api.Repo, nodes []string, path, owner, id, header string, start, end, oldOffset, fileSize int64) ([]byte, error) {
+		if !slices.Equal(nodes, []string{"bucket", "dir", "data.tar.c4gh"}) {
+			t.Errorf("api.DownloadData() received incorrect nodes %v", nodes)
+		}
+		if header != "header" || fileSize != 2014 || oldOffset != 0 {
+			t.Errorf("api.DownloadData() received incorrect header %s, file size %d or old offset %d", header, fileSize, oldOffset)
+		}
+
+		return []byte(content[start:end]), nil
+	}
+
+	var tests = []struct {
+		testname string
+		offset   int64
+		size     int
+		expected string
+	}{
+		{"OK_ALL", 0, 100, "member content"},
+		{"OK_PART", 7, 4, "cont"},
+		{"OK_END", 14, 10, ""},
+	}
+
+	fi.archiveHeaders = make(map[string]string)
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			buffer := make([]byte, tt.size)
+			n := downloadMember(member, "path", buffer, _Ctype_off_t(tt.offset))
+			if received := string(buffer[:n]); received != tt.expected {
+				t.Errorf("Function returned incorrect data. Expected=%s, received=%s", tt.expected, received)
+			}
+		})
+	}
+	if headerCalls != 1 {
+		t.Errorf("Header of archive should have been fetched once, was fetched %d times", headerCalls)
+	}
+
+	fi.archiveHeaders = make(map[string]string)
+	api.GetFileHeader = func(rep api.Repo, bucket, object, owner, id string) (string, error) {
+		return "", errExpected
+	}
+	if ret := downloadMember(member, "path", make([]byte, 10), 0); ret != -3 {
+		t.Errorf("Function returned incorrect value. Expected=-3, received=%d", ret)
+	}
+	api.GetFileHeader = func(rep api.Repo, bucket, object, owner, id string) (string, error) {
+		return "", nil
+	}
+	if ret := downloadMember(member, "path", make([]byte, 10), 0); ret != -2 {
+		t.Errorf("Function returned incorrect value. Expected=-2, received=%d", ret)
+	}
+}
@@ -23,6 +23,13 @@ int mount_filesystem(const char *mount, int debug) {
 
 const int MAX_READ = 1 << 20;
 
+// Extended attribute that holds the SHA-256 checksum of the decrypted file
+#define CHECKSUM_XATTR "user.sha256"
+
+#ifndef ENOATTR
+#define ENOATTR ENODATA
+#endif
+
 static void s3_destroy(void *private_data) {
     WaitForLock();
 
@@ -53,6 +60,11 @@ static int s3_open(const char *path, struct fuse_file_info *fi) {
     } else if (node->offset == -2) {
         return -ENOENT;
     }
+    // Fetched here and not in getattr(), which has to answer without waiting for the network
+    if (!node->attrs_fetched) {
+        node->attrs_fetched = 1;
+        GetAttributes(node, path);
+    }
 
 	return 0;
 }
@@ -121,7 +133,6 @@ static int s3_getattr(const char *path, struct stat *stbuf, struct fuse_file_inf
 	if (!node) {
 		return -ENOENT;
 	}
-
     *stbuf = node->stat;
 #if defined(__linux__)
     stbuf->st_atim = node->last_modified;
@@ -139,6 +150,58 @@ static int s3_getattr(const char *path, struct stat *stbuf, struct fuse_file_inf
     return 0;
 }
 
+static int s3_getxattr(const char *path, const char *name, char *value, size_t size) {
+    struct fuse_context *fc = fuse_get_context();
+    node_t *node = search_node((nodes_t *)fc->private_data, path);
+    if (!node) {
+        return -ENOENT;
+    }
+    if (strcmp(name, CHECKSUM_XATTR) != 0 || S_ISDIR(node->stat.st_mode)) {
+        return -ENOATTR;
+    }
+
+    int n_bytes = GetChecksum(node, path, value, size);
+    if (n_bytes == -1) {
+        return -ENOATTR;
+    } else if (n_bytes == -2) {
+        return -ERANGE;
+    } else if (n_bytes < -2) {
+        return -EIO;
+    }
+
+    return n_bytes;
+}
+
+static int s3_listxattr(const char *path, char *list, size_t size) {
+    struct fuse_context *fc = fuse_get_context();
+    node_t *node = search_node((nodes_t *)fc->private_data, path);
+    if (!node) {
+        return -ENOENT;
+    }
+    if (S_ISDIR(node->stat.st_mode) || GetChecksum(node, path, NULL, 0) < 0) {
+        return 0;
+    }
+
+    size_t len = strlen(CHECKSUM_XATTR) + 1;
+    if (size == 0) {
+        return len;
+    }
+    if (size < len) {
+        return -ERANGE;
+    }
+    memcpy(list, CHECKSUM_XATTR, len);
+
+    return len;
+}
+
+static int s3_setxattr(const char *path, const char *name, const char *value, size_t size, int flags) {
+    return -EROFS;
+}
+
+static int s3_removexattr(const char *path, const char *name) {
+    return -EROFS;
+}
+
 static int s3_write(const char *path, const char *buf, size_t size,
 		            off_t offset, struct fuse_file_info *fi) {
     return -EROFS;
@@ -195,6 +258,10 @@ static const struct fuse_operations operations = {
     .truncate   = s3_truncate,
     .mkdir      = s3_mkdir,
     .rmdir      = s3_rmdir,
+    .getxattr   = s3_getxattr,
+    .listxattr  = s3_listxattr,
+    .setxattr   = s3_setxattr,
+    .removexattr = s3_removexattr,
     .init       = s3_init,
 };
 
@@ -46,12 +46,16 @@ var fi = fuseInfo{}
 
 // fuseInfo stores variables relevant to the filesystem
 type fuseInfo struct {
-	mount   string
-	headers map[C.ino_t]header
-	nodes   *C.nodes_t
-	ready   chan<- any
-	guiFun  func(api.Repo, string, int)
-	mu      sync.RWMutex
+	mount          string
+	headers        map[C.ino_t]header
+	checksums      map[C.ino_t]string // Checksums fetched from object metadata, empty if object has none
+	members        map[C.ino_t]archiveMember
+	archiveHeaders map[string]string // Headers of archive objects whose members have been read
+	browseArchives bool
+	nodes          *C.nodes_t
+	ready          chan<- any
+	guiFun         func(api.Repo, string, int)
+	mu             sync.RWMutex
 }
 
 // bucketInfo is a packet of information sent through a channel to createObjects()
@@ -71,6 +75,7 @@ type header struct {
 type goNode struct {
 	meta     api.Metadata
 	children map[string]*goNode
+	member   *archiveMember // Set if the node is a file inside an archive object
 }
 
 // SetSignalBridge initializes the signal which informs Wails that program has paniced
@@ -235,6 +240,9 @@ func InitialiseFilesystem() {
 	// Construct the array of nodes for C
 	num, objs := numberOfNodes(root)
 	fi.headers = make(map[C.ino_t]header, objs)
+	fi.checksums = make(map[C.ino_t]string)
+	fi.members = make(map[C.ino_t]archiveMember)
+	fi.archiveHeaders = make(map[string]string)
 	fi.nodes.nodes = allocateNodeList(num)
 	fi.nodes.count = 1
 	nodeSlice := unsafe.Slice(fi.nodes.nodes, num)
@@ -300,6 +308,11 @@ func goNodeToC(node *goNode, name string) C.node_t {
 	if node.children == nil {
 		cNode.stat.st_mode = syscall.S_IFREG | 0644
 		cNode.stat.st_nlink = 1
+		if node.member != nil {
+			// Members already have the attributes of their files from the archive index
+			cNode.stat.st_mode = syscall.S_IFREG | C.mode_t(filePermissions(node.member.mode))
+			cNode.attrs_fetched = 1
+		}
 	} else {
 		cNode.stat.st_mode = syscall.S_IFDIR | 0744
 		cNode.stat.st_nlink = C.nlink_t(2 + len(node.children))
@@ -340,6 +353,11 @@ func addNodeChildrenToC(nodeSlice []C.node_t, prnt *goNode, prntIdx C.int64_t) (
 
 		if len(chld.children) > 0 {
 			size, modified = addNodeChildrenToC(nodeSlice, chld, i)
+		} else if chld.member != nil {
+			fi.members[ino] = *chld.member
+			if chld.member.checksum != "" {
+				fi.checksums[ino] = chld.member.checksum
+			}
 		} else if chld.meta.Owner != "" || chld.meta.ID != "" {
 			fi.headers[ino] = header{owner: chld.meta.Owner, fileID: chld.meta.ID}
 		}
@@ -396,7 +414,15 @@ var createObjects = func(_ int, jobs <-chan bucketInfo, wg *sync.WaitGroup) {
 			meta = append(meta, objects[i])
 		}
 
+		var members map[string]archiveMember
+		if fi.browseArchives && repository == api.SDConnect && node.meta.Owner == "" {
+			meta, members = expandArchives(node.meta.Name, meta)
+		}
+
 		createLevel(node, meta, path)
+		if len(members) > 0 {
+			attachMembers(node, "", members)
+		}
 
 		if fi.guiFun != nil {
 			fi.guiFun(repository, nodesSafe[2], 1)
@@ -501,7 +527,7 @@ func makeNode(siblings map[string]*goNode, meta api.Metadata, isDir bool, pathSa
 }
 
 func newGoNode(meta api.Metadata, isDir bool) *goNode {
-	node := goNode{meta, nil}
+	node := goNode{meta: meta}
 	if isDir {
 		node.children = make(map[string]*goNode)
 	}
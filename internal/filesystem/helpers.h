@@ -21,6 +21,8 @@ typedef struct Node {
 	// Value -2 indicates the object was removed from storage and should not be shown in the filesystem.
 	// A non-negative value represents an actual offset in the object.
 	int64_t offset;
+	// Whether the modification time and mode of the original file have been looked up from object metadata
+	int8_t attrs_fetched;
 } node_t;
 
 typedef struct Nodes {
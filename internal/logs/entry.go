@@ -0,0 +1,183 @@
+package logs
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Names of the fields attached to log entries. The same names are used in every subsystem,
+// so that e.g. all the logs concerning one object can be found with a single search.
+const (
+	FieldSubsystem  = "subsystem"
+	FieldRepository = "repository"
+	FieldBucket     = "bucket"
+	FieldObject     = "object"
+	FieldRequestID  = "request_id"
+	FieldDuration   = "duration" // In seconds
+)
+
+// Fields are attached to a log entry as key-value pairs
+type Fields map[string]any
+
+// Entry writes logs with fields attached to them. The fields are shown on the standard logger
+// and in the log file, but not in the GUI.
+type Entry struct {
+	fields logrus.Fields
+}
+
+// std is the entry without fields that is used by the package level functions
+var std = &Entry{}
+
+// WithFields returns an entry that attaches `fields` to its logs
+func WithFields(fields Fields) *Entry {
+	return std.WithFields(fields)
+}
+
+// WithSubsystem returns an entry that tells which part of Data Gateway wrote the log
+func WithSubsystem(name string) *Entry {
+	return std.WithFields(Fields{FieldSubsystem: name})
+}
+
+// WithFields returns a copy of the entry with `fields` added to it.
+// Durations are converted to seconds so that they can be compared across entries.
+func (e *Entry) WithFields(fields Fields) *Entry {
+	merged := make(logrus.Fields, len(e.fields)+len(fields))
+	maps.Copy(merged, e.fields)
+	for key, value := range fields {
+		if d, ok := value.(time.Duration); ok {
+			value = math.Round(d.Seconds()*1000) / 1000
+		}
+		merged[key] = value
+	}
+
+	return &Entry{fields: merged}
+}
+
+func (e *Entry) logger() *logrus.Entry {
+	fields := make(logrus.Fields, len(e.fields))
+	for key, value := range e.fields {
+		if str, ok := value.(string); ok {
+			value = Redact(str)
+		}
+		fields[key] = value
+	}
+
+	return log.WithFields(fields)
+}
+
+// Error logs a message at level "Error" either on the standard logger or in the GUI
+func (e *Entry) Error(err error) {
+	message := Redact(strings.ToUpper(err.Error()[:1]) + err.Error()[1:])
+	e.record(logrus.ErrorLevel, message)
+	if signal != nil {
+		stErr := redactAll(StructureError(err))
+		stErr[0] = strings.ToUpper(stErr[0][:1]) + stErr[0][1:]
+		signal(logrus.ErrorLevel.String(), stErr)
+	} else {
+		e.logger().Error(message)
+	}
+}
+
+// Errorf logs a message at level "Error" either on the standard logger or in the GUI
+func (e *Entry) Errorf(format string, args ...any) {
+	err := fmt.Errorf(format, args...)
+	message := Redact(err.Error())
+	e.record(logrus.ErrorLevel, message)
+	if signal != nil {
+		signal(logrus.ErrorLevel.String(), redactAll(StructureError(err)))
+	} else {
+		e.logger().Error(message)
+	}
+}
+
+// Warning logs a message at level "Warning" either on the standard logger or in the GUI
+func (e *Entry) Warning(err error) {
+	message := Redact(strings.ToUpper(err.Error()[:1]) + err.Error()[1:])
+	e.record(logrus.WarnLevel, message)
+	if signal != nil {
+		stErr := redactAll(StructureError(err))
+		stErr[0] = strings.ToUpper(stErr[0][:1]) + stErr[0][1:]
+		signal(logrus.WarnLevel.String(), stErr)
+	} else {
+		e.logger().Warning(message)
+	}
+}
+
+// Warningf logs a message at level "Warning" either on the standard logger or in the GUI
+func (e *Entry) Warningf(format string, args ...any) {
+	err := fmt.Errorf(format, args...)
+	message := Redact(err.Error())
+	e.record(logrus.WarnLevel, message)
+	if signal != nil {
+		signal(logrus.WarnLevel.String(), redactAll(StructureError(err)))
+	} else {
+		e.logger().Warning(message)
+	}
+}
+
+// Info logs a message at level "Info" either on the standard logger or in the GUI
+func (e *Entry) Info(args ...any) {
+	e.print(logrus.InfoLevel, fmt.Sprint(args...))
+}
+
+// Infof logs a message at level "Info" either on the standard logger or in the GUI
+func (e *Entry) Infof(format string, args ...any) {
+	e.print(logrus.InfoLevel, fmt.Sprintf(format, args...))
+}
+
+// Debug logs a message at level "Debug" either on the standard logger or in the GUI
+func (e *Entry) Debug(args ...any) {
+	e.print(logrus.DebugLevel, fmt.Sprint(args...))
+}
+
+// Debugf logs a message at level "Debug" either on the standard logger or in the GUI
+func (e *Entry) Debugf(format string, args ...any) {
+	e.print(logrus.DebugLevel, fmt.Sprintf(format, args...))
+}
+
+func (e *Entry) print(level logrus.Level, message string) {
+	// Info logs are always shown in the GUI
+	if !log.IsLevelEnabled(level) && (signal == nil || level == logrus.DebugLevel) {
+		return
+	}
+
+	message = Redact(message)
+	e.record(level, message)
+	if signal != nil {
+		signal(level.String(), []string{message})
+	} else {
+		e.logger().Log(level, message)
+	}
+}
+
+// redactAll redacts each of `strs` in place
+func redactAll(strs []string) []string {
+	for i := range strs {
+		strs[i] = Redact(strs[i])
+	}
+
+	return strs
+}
+
+// record writes the log to the log file, if one has been set with SetFile, and keeps it among the recent logs
+func (e *Entry) record(level logrus.Level, message string) {
+	if !log.IsLevelEnabled(level) {
+		return
+	}
+
+	entry := e.logger()
+	entry.Time, entry.Level, entry.Message = time.Now(), level, message
+	line, err := fileFormatter.Format(entry)
+	if err != nil {
+		return
+	}
+	recent.add(string(line))
+	if f := file.Load(); f != nil {
+		_, _ = f.Write(line)
+	}
+}
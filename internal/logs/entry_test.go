@@ -0,0 +1,97 @@
+package logs
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWithFields(t *testing.T) {
+	defer testHook.Reset()
+	signal = nil
+
+	entry := WithSubsystem("s3")
+	objectEntry := entry.WithFields(Fields{FieldBucket: "bucket", FieldObject: "dir/file.txt", FieldDuration: 1500 * time.Millisecond})
+
+	expected := logrus.Fields{FieldSubsystem: "s3"}
+	if !reflect.DeepEqual(entry.fields, expected) {
+		t.Errorf("Original entry was modified\nExpected=%v\nReceived=%v", expected, entry.fields)
+	}
+
+	objectEntry.Infof("Downloaded %d bytes", 10)
+	expected = logrus.Fields{FieldSubsystem: "s3", FieldBucket: "bucket", FieldObject: "dir/file.txt", FieldDuration: 1.5}
+	switch {
+	case len(testHook.Entries) != 1:
+		t.Errorf("Logger did not make the correct amount of entries. Expected=1, received=%d", len(testHook.Entries))
+	case testHook.LastEntry().Message != "Downloaded 10 bytes":
+		t.Errorf("Logger displayed incorrect message\nExpected=Downloaded 10 bytes\nReceived=%s", testHook.LastEntry().Message)
+	case !reflect.DeepEqual(testHook.LastEntry().Data, expected):
+		t.Errorf("Logger attached incorrect fields\nExpected=%v\nReceived=%v", expected, testHook.LastEntry().Data)
+	}
+}
+
+func TestEntry_Levels(t *testing.T) {
+	defer testHook.Reset()
+	signal = nil
+	entry := WithFields(Fields{FieldRequestID: "abc"})
+
+	var tests = []struct {
+		testname string
+		log      func()
+		level    logrus.Level
+		message  string
+	}{
+		{"ERROR", func() { entry.Error(errors.New("failed: oh no")) }, logrus.ErrorLevel, "Failed: oh no"},
+		{"ERRORF", func() { entry.Errorf("failed: %w", errors.New("oh no")) }, logrus.ErrorLevel, "failed: oh no"},
+		{"WARNING", func() { entry.Warning(errors.New("careful")) }, logrus.WarnLevel, "Careful"},
+		{"WARNINGF", func() { entry.Warningf("careful %d", 2) }, logrus.WarnLevel, "careful 2"},
+		{"INFO", func() { entry.Info("hello ", "there") }, logrus.InfoLevel, "hello there"},
+		{"DEBUG", func() { entry.Debugf("%s there", "hello") }, logrus.DebugLevel, "hello there"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			testHook.Reset()
+			tt.log()
+
+			switch {
+			case len(testHook.Entries) != 1:
+				t.Errorf("Logger did not make the correct amount of entries. Expected=1, received=%d", len(testHook.Entries))
+			case testHook.LastEntry().Level != tt.level:
+				t.Errorf("Logger logged at incorrect level. Expected=%s, received=%s", tt.level, testHook.LastEntry().Level)
+			case testHook.LastEntry().Message != tt.message:
+				t.Errorf("Logger displayed incorrect message\nExpected=%s\nReceived=%s", tt.message, testHook.LastEntry().Message)
+			case testHook.LastEntry().Data[FieldRequestID] != "abc":
+				t.Errorf("Logger did not attach fields, received=%v", testHook.LastEntry().Data)
+			}
+		})
+	}
+}
+
+func TestEntry_Signal(t *testing.T) {
+	defer func() {
+		signal = nil
+		testHook.Reset()
+	}()
+
+	var level string
+	var strs []string
+	signal = func(s1 string, s2 []string) {
+		level, strs = s1, s2
+	}
+
+	WithFields(Fields{FieldObject: "file.txt"}).Warningf("Object %s is odd: %w", "file.txt", errors.New("too large"))
+
+	expected := []string{"Object file.txt is odd", "too large"}
+	switch {
+	case len(testHook.Entries) != 0:
+		t.Error("Logger with signal should not have logged to stdout")
+	case level != logrus.WarnLevel.String():
+		t.Errorf("Logger with signal logged at incorrect level. Expected=%s, received=%s", logrus.WarnLevel.String(), level)
+	case !reflect.DeepEqual(strs, expected):
+		t.Errorf("Logger with signal gave incorrect message\nExpected=%v\nReceived=%v", expected, strs)
+	}
+}
@@ -0,0 +1,167 @@
+package logs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Defaults for the rotation of the log file
+const (
+	DefaultMaxSize = 10 << 20
+	DefaultBackups = 3
+)
+
+// minFileSize is the smallest size the log file can be limited to, so that it is not rotated after every few lines
+var minFileSize int64 = 1 << 20
+
+// file is the log file set with SetFile, or nil if logs are not written to a file
+var file atomic.Pointer[rotatingFile]
+
+var fileFormatter logrus.Formatter = &logrus.TextFormatter{FullTimestamp: true, DisableColors: true}
+
+var jsonFormatter = &logrus.JSONFormatter{FieldMap: logrus.FieldMap{logrus.FieldKeyMsg: "message"}}
+
+// SetFormat sets the format of the logs on the standard logger and in the log file.
+// Possible values are text and json. In the GUI, the format only affects the log file.
+func SetFormat(format string) error {
+	switch strings.ToLower(format) {
+	case "text":
+		log.SetFormatter(&logrus.TextFormatter{FullTimestamp: true, ForceColors: true})
+		fileFormatter = &logrus.TextFormatter{FullTimestamp: true, DisableColors: true}
+	case "json":
+		log.SetFormatter(jsonFormatter)
+		fileFormatter = jsonFormatter
+	default:
+		return fmt.Errorf("log format %q is not supported, possible values are {text,json}", format)
+	}
+
+	return nil
+}
+
+// SetFile writes the logs also to the file at `path`. When the file would grow larger than `maxSize` bytes,
+// it is renamed to `path`.1 and a new file is started. At most `backups` earlier files are kept.
+// An empty path stops writing logs to a file.
+func SetFile(path string, maxSize int64, backups int) error {
+	var f *rotatingFile
+	if path != "" {
+		if err := CheckMaxSize(maxSize); err != nil {
+			return err
+		}
+		if backups < 0 {
+			return fmt.Errorf("number of log file backups cannot be negative")
+		}
+
+		f = &rotatingFile{path: filepath.Clean(path), maxSize: maxSize, backups: backups}
+		if err := f.open(); err != nil {
+			return err
+		}
+	}
+
+	if old := file.Swap(f); old != nil {
+		return old.Close()
+	}
+
+	return nil
+}
+
+// CheckMaxSize checks that `size` bytes is large enough to be the maximum size of the log file
+func CheckMaxSize(size int64) error {
+	if size < minFileSize {
+		return fmt.Errorf("maximum size of log file must be at least %d MiB", minFileSize>>20)
+	}
+
+	return nil
+}
+
+// rotatingFile is a log file that is rotated once it reaches its maximum size
+type rotatingFile struct {
+	mu      sync.Mutex
+	path    string
+	maxSize int64
+	backups int
+	file    *os.File
+	size    int64
+}
+
+func (r *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory for log file: %w", err)
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	r.file, r.size = f, info.Size()
+
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return 0, fs.ErrClosed
+	}
+	if r.size > 0 && r.size+int64(len(p)) > r.maxSize {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+
+	return n, err
+}
+
+// rotate shifts the earlier files by one, so that the current file becomes `path`.1, and opens a new file
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("failed to close log file: %w", err)
+	}
+	r.file = nil
+
+	for i := r.backups; i > 0; i-- {
+		src := r.path
+		if i > 1 {
+			src = fmt.Sprintf("%s.%d", r.path, i-1)
+		}
+		if err := os.Rename(src, fmt.Sprintf("%s.%d", r.path, i)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+	if r.backups == 0 {
+		if err := os.Remove(r.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to rotate log file: %w", err)
+		}
+	}
+
+	return r.open()
+}
+
+func (r *rotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+
+	return err
+}
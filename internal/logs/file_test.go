@@ -0,0 +1,183 @@
+package logs
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSetFormat(t *testing.T) {
+	origFormatter := log.Formatter
+	defer func() {
+		log.SetFormatter(origFormatter)
+		_ = SetFormat("text")
+	}()
+
+	if err := SetFormat("JSON"); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if _, ok := log.Formatter.(*logrus.JSONFormatter); !ok {
+		t.Errorf("Standard logger has incorrect formatter %T", log.Formatter)
+	}
+	if _, ok := fileFormatter.(*logrus.JSONFormatter); !ok {
+		t.Errorf("Log file has incorrect formatter %T", fileFormatter)
+	}
+
+	if err := SetFormat("text"); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if _, ok := fileFormatter.(*logrus.TextFormatter); !ok {
+		t.Errorf("Log file has incorrect formatter %T", fileFormatter)
+	}
+
+	errStr := "log format \"xml\" is not supported, possible values are {text,json}"
+	if err := SetFormat("xml"); err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+}
+
+func TestSetFile(t *testing.T) {
+	defer func() {
+		_ = SetFile("", 0, 0)
+		_ = SetFormat("text")
+		signal = nil
+		testHook.Reset()
+	}()
+
+	path := filepath.Join(t.TempDir(), "logs", "gateway.log")
+	if err := SetFile(path, 1<<20, 2); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	if err := SetFormat("json"); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+
+	// Logs sent to the GUI are also written to the file
+	signal = func(string, []string) {}
+	WithSubsystem("airlock").WithFields(Fields{FieldBucket: "bucket"}).Infof("Uploaded %s", "file.txt")
+	signal = nil
+	Warningf("Something is %s", "off")
+	log.SetLevel(logrus.InfoLevel)
+	Debug("Not written")
+	log.SetLevel(logrus.DebugLevel)
+
+	if err := SetFile("", 0, 0); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	Info("Not written either")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read log file: %s", err.Error())
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Log file has incorrect number of lines. Expected=2, received=%d\n%s", len(lines), data)
+	}
+
+	var tests = []struct {
+		line   string
+		fields map[string]any
+	}{
+		{lines[0], map[string]any{"level": "info", "message": "Uploaded file.txt", FieldSubsystem: "airlock", FieldBucket: "bucket"}},
+		{lines[1], map[string]any{"level": "warning", "message": "Something is off"}},
+	}
+	for i, tt := range tests {
+		var received map[string]any
+		if err := json.Unmarshal([]byte(tt.line), &received); err != nil {
+			t.Fatalf("Line %d is not valid JSON: %s", i+1, err.Error())
+		}
+		if _, ok := received["time"]; !ok {
+			t.Errorf("Line %d does not have a timestamp", i+1)
+		}
+		delete(received, "time")
+		if len(received) != len(tt.fields) {
+			t.Errorf("Line %d has incorrect fields\nExpected=%v\nReceived=%v", i+1, tt.fields, received)
+		}
+		for key, value := range tt.fields {
+			if received[key] != value {
+				t.Errorf("Line %d has incorrect value for %s. Expected=%v, received=%v", i+1, key, value, received[key])
+			}
+		}
+	}
+}
+
+func TestSetFile_Rotate(t *testing.T) {
+	origMinFileSize := minFileSize
+	defer func() {
+		_ = SetFile("", 0, 0)
+		minFileSize = origMinFileSize
+	}()
+
+	minFileSize = 1
+
+	path := filepath.Join(t.TempDir(), "gateway.log")
+	if err := os.WriteFile(path, []byte(strings.Repeat("a", 90)+"\n"), 0600); err != nil {
+		t.Fatalf("Could not create log file: %s", err.Error())
+	}
+	if err := SetFile(path, 100, 2); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+
+	f := file.Load()
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err := f.Write([]byte(strings.Repeat(line, 10))); err != nil {
+			t.Fatalf("Writing to log file failed: %s", err.Error())
+		}
+	}
+
+	for name, content := range map[string]string{
+		"gateway.log":   strings.Repeat("third\n", 10),
+		"gateway.log.1": strings.Repeat("second\n", 10),
+		"gateway.log.2": strings.Repeat("first\n", 10),
+	} {
+		data, err := os.ReadFile(filepath.Join(filepath.Dir(path), name))
+		if err != nil {
+			t.Errorf("Could not read %s: %s", name, err.Error())
+		} else if string(data) != content {
+			t.Errorf("File %s has incorrect content\nExpected=%q\nReceived=%q", name, content, data)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); err == nil {
+		t.Error("Too many backups were kept")
+	}
+}
+
+func TestSetFile_Error(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0600); err != nil {
+		t.Fatalf("Could not create file: %s", err.Error())
+	}
+
+	var tests = []struct {
+		testname, path, errStr string
+		maxSize                int64
+		backups                int
+	}{
+		{"FAIL_SIZE", filepath.Join(dir, "gateway.log"), "maximum size of log file must be at least 1 MiB", 0, 1},
+		{"FAIL_SMALL_SIZE", filepath.Join(dir, "gateway.log"), "maximum size of log file must be at least 1 MiB", 1<<20 - 1, 1},
+		{"FAIL_BACKUPS", filepath.Join(dir, "gateway.log"), "number of log file backups cannot be negative", 1 << 20, -1},
+		{"FAIL_DIR", filepath.Join(blocker, "gateway.log"), "failed to create directory for log file: mkdir " + blocker + ": not a directory", 1 << 20, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.testname, func(t *testing.T) {
+			err := SetFile(tt.path, tt.maxSize, tt.backups)
+			if err == nil {
+				t.Error("Function did not return error")
+			} else if err.Error() != tt.errStr {
+				t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", tt.errStr, err.Error())
+			}
+			if file.Load() != nil {
+				t.Error("Log file should not have been set")
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package logs
 import (
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -42,6 +43,11 @@ func GetLevel() logrus.Level {
 	return log.GetLevel()
 }
 
+// SetOutput sets where the standard logger writes its logs
+func SetOutput(w io.Writer) {
+	log.SetOutput(w)
+}
+
 // Wrapper returns the outermost error in 'err' as a string along with the unwrapped error
 func Wrapper(err error) (string, error) {
 	unwrapped := errors.Unwrap(err)
@@ -66,95 +72,55 @@ var StructureError = func(err error) []string {
 
 // Error logs a message at level "Error" either on the standard logger or in the GUI
 var Error = func(err error) {
-	if signal != nil {
-		stErr := StructureError(err)
-		stErr[0] = strings.ToUpper(stErr[0][:1]) + stErr[0][1:]
-		signal(logrus.ErrorLevel.String(), stErr)
-	} else {
-		log.Error(strings.ToUpper(err.Error()[:1]) + err.Error()[1:])
-	}
+	std.Error(err)
 }
 
 // Errorf logs a message at level "Error" either on the standard logger or in the GUI
 var Errorf = func(format string, args ...any) {
-	err := fmt.Errorf(format, args...)
-	if signal != nil {
-		signal(logrus.ErrorLevel.String(), StructureError(err))
-	} else {
-		log.Error(err)
-	}
+	std.Errorf(format, args...)
 }
 
 // Warning logs a message at level "Warning" either on the standard logger or in the GUI
 func Warning(err error) {
-	if signal != nil {
-		stErr := StructureError(err)
-		stErr[0] = strings.ToUpper(stErr[0][:1]) + stErr[0][1:]
-		signal(logrus.WarnLevel.String(), stErr)
-	} else {
-		log.Warning(strings.ToUpper(err.Error()[:1]) + err.Error()[1:])
-	}
+	std.Warning(err)
 }
 
 // Warningf logs a message at level "Warning" either on the standard logger or in the GUI
 var Warningf = func(format string, args ...any) {
-	err := fmt.Errorf(format, args...)
-	if signal != nil {
-		signal(logrus.WarnLevel.String(), StructureError(err))
-	} else {
-		log.Warning(err.Error())
-	}
+	std.Warningf(format, args...)
 }
 
 // Info logs a message at level "Info" either on the standard logger or in the GUI
 func Info(args ...any) {
-	if signal != nil {
-		signal(logrus.InfoLevel.String(), []string{fmt.Sprint(args...)})
-	} else {
-		log.Info(args...)
-	}
+	std.Info(args...)
 }
 
 // Infof logs a message at level "Info" either on the standard logger or in the GUI
 func Infof(format string, args ...any) {
-	if signal != nil {
-		signal(logrus.InfoLevel.String(), []string{fmt.Sprintf(format, args...)})
-	} else {
-		log.Infof(format, args...)
-	}
+	std.Infof(format, args...)
 }
 
 // Debug logs a message at level "Debug" either on the standard logger or in the GUI
 func Debug(args ...any) {
-	if signal != nil {
-		if log.IsLevelEnabled(logrus.DebugLevel) {
-			signal(logrus.DebugLevel.String(), []string{fmt.Sprint(args...)})
-		}
-	} else {
-		log.Debug(args...)
-	}
+	std.Debug(args...)
 }
 
 // Debugf logs a message at level "Debug" either on the standard logger or in the GUI
 func Debugf(format string, args ...any) {
-	if signal != nil {
-		if log.IsLevelEnabled(logrus.DebugLevel) {
-			signal(logrus.DebugLevel.String(), []string{fmt.Sprintf(format, args...)})
-		}
-	} else {
-		log.Debugf(format, args...)
-	}
+	std.Debugf(format, args...)
 }
 
 // Fatal logs a message at level "Fatal" on the standard logger
 func Fatal(args ...any) {
-	err := fmt.Sprint(args...)
+	err := Redact(fmt.Sprint(args...))
+	std.record(logrus.FatalLevel, strings.ToUpper(err[:1])+err[1:])
 	log.Fatal(strings.ToUpper(err[:1]) + err[1:])
 }
 
 // Fatalf logs a message at level "Fatal" on the standard logger
 func Fatalf(format string, args ...any) {
-	err := fmt.Sprintf(format, args...)
+	err := Redact(fmt.Sprintf(format, args...))
+	std.record(logrus.FatalLevel, strings.ToUpper(err[:1])+err[1:])
 	log.Fatal(strings.ToUpper(err[:1]) + err[1:])
 }
 
@@ -82,6 +82,22 @@ func TestSetLevel(t *testing.T) {
 	}
 }
 
+func TestSetOutput(t *testing.T) {
+	origOut := log.Out
+	defer func() {
+		log.Out = origOut
+		testHook.Reset()
+	}()
+
+	var buf strings.Builder
+	SetOutput(&buf)
+	Info("written to buffer")
+
+	if !strings.Contains(buf.String(), "written to buffer") {
+		t.Errorf("Log was not written to the given output, received %q", buf.String())
+	}
+}
+
 func TestWrapper(t *testing.T) {
 	errs := []string{"Original problem", "Fix me", "Whaaat???", "Another error", "Error 1"}
 	fullError := fmt.Errorf("%s", errs[0])
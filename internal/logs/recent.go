@@ -0,0 +1,45 @@
+package logs
+
+import (
+	"strings"
+	"sync"
+)
+
+// recentSize is the number of logs kept in memory for support bundles
+const recentSize = 2000
+
+// ringBuffer keeps the latest logs, overwriting the oldest one once it is full
+type ringBuffer struct {
+	mu    sync.Mutex
+	lines []string
+	next  int
+	full  bool
+}
+
+var recent = &ringBuffer{lines: make([]string, recentSize)}
+
+func (b *ringBuffer) add(line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lines[b.next] = strings.TrimRight(line, "\n")
+	b.next = (b.next + 1) % len(b.lines)
+	b.full = b.full || b.next == 0
+}
+
+func (b *ringBuffer) all() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.full {
+		return append([]string{}, b.lines[:b.next]...)
+	}
+
+	return append(append([]string{}, b.lines[b.next:]...), b.lines[:b.next]...)
+}
+
+// Recent returns the latest logs, oldest first, in the same format as in the log file.
+// Secrets have already been redacted from them.
+func Recent() []string {
+	return recent.all()
+}
@@ -0,0 +1,59 @@
+package logs
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRingBuffer(t *testing.T) {
+	b := &ringBuffer{lines: make([]string, 3)}
+	if lines := b.all(); len(lines) != 0 {
+		t.Errorf("Empty buffer returned %v", lines)
+	}
+
+	b.add("first\n")
+	b.add("second\n")
+	if expected, lines := []string{"first", "second"}, b.all(); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Incorrect lines\nExpected=%v\nReceived=%v", expected, lines)
+	}
+
+	b.add("third\n")
+	b.add("fourth\n")
+	if expected, lines := []string{"second", "third", "fourth"}, b.all(); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Incorrect lines\nExpected=%v\nReceived=%v", expected, lines)
+	}
+}
+
+func TestRecent(t *testing.T) {
+	origRecent := recent
+	defer func() {
+		recent = origRecent
+		resetRedaction()
+		signal = nil
+		testHook.Reset()
+		log.SetLevel(logrus.DebugLevel)
+	}()
+
+	recent = &ringBuffer{lines: make([]string, 10)}
+	AddSecret("hunter22")
+
+	signal = func(string, []string) {}
+	WithSubsystem("api").Infof("Password is hunter22")
+	signal = nil
+	log.SetLevel(logrus.InfoLevel)
+	Debug("Not kept")
+	Warningf("Careful")
+
+	lines := Recent()
+	if len(lines) != 2 {
+		t.Fatalf("Incorrect number of recent logs. Expected=2, received=%d\n%v", len(lines), lines)
+	}
+	for i, expected := range []string{`level=info msg="Password is [REDACTED]" subsystem=api`, `level=warning msg=Careful`} {
+		if !strings.Contains(lines[i], expected) {
+			t.Errorf("Recent log %d is incorrect\nExpected to contain=%s\nReceived=%s", i, expected, lines[i])
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package logs
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// redacted replaces secrets in logs
+const redacted = "[REDACTED]"
+
+// minSecretLength is the length below which values given to AddSecret are ignored,
+// since redacting them would make the logs unreadable
+const minSecretLength = 4
+
+// redactRule replaces the matches of `re` with `repl`, which may refer to the submatches of `re`
+type redactRule struct {
+	re   *regexp.Regexp
+	repl string
+}
+
+// builtinRules scrub the secrets that Data Gateway knows to look like secrets
+var builtinRules = []redactRule{
+	// Authorization headers, e.g. "Authorization: Bearer <token>"
+	{regexp.MustCompile(`(?i)\b(bearer\s+)[A-Za-z0-9._~+/=-]+`), "${1}" + redacted},
+	// The password header of KrakenD
+	{regexp.MustCompile(`(?i)\b(csc-password["']?\s*[:=]\s*["'\[]?)[^\s"',;\]]+`), "${1}" + redacted},
+	// Values of variables and fields named like secrets, e.g. "SDS_ACCESS_TOKEN=<token>", `"secret_key":"<key>"` or
+	// "Encryption key: <key>". The value must be long enough to be a secret, so that e.g. the errors in
+	// "failed to get key: not found" are left as they are.
+	{regexp.MustCompile(`(?i)\b([\w-]*(?:password|secret|token|key)s?["']?\s*[:=]\s*["']?)[A-Za-z0-9+/=._~-]{16,}`), "${1}" + redacted},
+	// PEM encoded keys and certificates
+	{regexp.MustCompile(`-----BEGIN [A-Z0-9 ]+-----[\s\S]*?-----END [A-Z0-9 ]+-----`), redacted},
+}
+
+var redactMu sync.RWMutex
+
+// secrets are values that are redacted wherever they appear in logs
+var secrets []string
+
+// patterns are the regular expressions given with AddRedactPattern
+var patterns []*regexp.Regexp
+
+// AddSecret makes `value` to be redacted wherever it appears in logs, e.g. a token or a password
+func AddSecret(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+
+	redactMu.Lock()
+	defer redactMu.Unlock()
+
+	if slices.Contains(secrets, value) {
+		return
+	}
+	secrets = append(secrets, value)
+	// Longer secrets first, so that a secret that contains another one is redacted as a whole
+	slices.SortStableFunc(secrets, func(a, b string) int {
+		return len(b) - len(a)
+	})
+}
+
+// AddRedactPattern makes the matches of the regular expression `pattern` to be redacted from logs,
+// e.g. the names of objects in sensitive projects
+func AddRedactPattern(pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+	}
+
+	redactMu.Lock()
+	defer redactMu.Unlock()
+	patterns = append(patterns, re)
+
+	return nil
+}
+
+// Redact replaces tokens, passwords, keys and the matches of the patterns given with AddRedactPattern in `s`.
+// All logs go through Redact before they are written to the console, the GUI or the log file.
+func Redact(s string) string {
+	for _, rule := range builtinRules {
+		s = rule.re.ReplaceAllString(s, rule.repl)
+	}
+
+	redactMu.RLock()
+	defer redactMu.RUnlock()
+
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	for _, re := range patterns {
+		s = re.ReplaceAllString(s, redacted)
+	}
+
+	return s
+}
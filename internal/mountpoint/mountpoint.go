@@ -1,6 +1,7 @@
 package mountpoint
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -71,6 +72,36 @@ var CheckMountPoint = func(mount string) error {
 	return nil
 }
 
+// fuseRequirements lists the files and commands that FUSE needs on each supported operating system
+var fuseRequirements = map[string]struct {
+	files, commands []string
+}{
+	"linux":  {files: []string{"/dev/fuse"}, commands: []string{"fusermount3"}},
+	"darwin": {files: []string{"/Library/Filesystems/macfuse.fs"}, commands: []string{"diskutil"}},
+}
+
+// CheckFUSE verifies that FUSE is installed and the filesystem can be mounted and unmounted
+var CheckFUSE = func() error {
+	req, ok := fuseRequirements[runtime.GOOS]
+	if !ok {
+		return fmt.Errorf("FUSE is not supported on %s", runtime.GOOS)
+	}
+
+	var errs []error
+	for _, file := range req.files {
+		if _, err := os.Stat(file); err != nil {
+			errs = append(errs, fmt.Errorf("%s not found", file))
+		}
+	}
+	for _, command := range req.commands {
+		if _, err := exec.LookPath(command); err != nil {
+			errs = append(errs, fmt.Errorf("command %s not found", command))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 var Unmount = func(mount string) error {
 	logs.Debugf("Starting to unmount %s", mount)
 
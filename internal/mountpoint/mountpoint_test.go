@@ -202,3 +202,35 @@ func TestCheckMountPoint_Fail_Read(t *testing.T) {
 	Unmount = origUnmount
 	_ = Unmount(node)
 }
+
+func TestCheckFUSE(t *testing.T) {
+	origRequirements := fuseRequirements
+	defer func() { fuseRequirements = origRequirements }()
+
+	file := filepath.Join(t.TempDir(), "fuse")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatalf("Could not create file: %s", err.Error())
+	}
+	fuseRequirements = map[string]struct{ files, commands []string }{
+		runtime.GOOS: {files: []string{file}, commands: []string{"go"}},
+	}
+	if err := CheckFUSE(); err != nil {
+		t.Errorf("Function returned unexpected error: %s", err.Error())
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	fuseRequirements = map[string]struct{ files, commands []string }{
+		runtime.GOOS: {files: []string{missing}, commands: []string{"fusermount-not-installed"}},
+	}
+	errStr := missing + " not found\ncommand fusermount-not-installed not found"
+	if err := CheckFUSE(); err == nil {
+		t.Error("Function did not return error")
+	} else if err.Error() != errStr {
+		t.Errorf("Function returned incorrect error\nExpected=%s\nReceived=%s", errStr, err.Error())
+	}
+
+	fuseRequirements = nil
+	if err := CheckFUSE(); err == nil {
+		t.Error("Function should return error for unsupported operating system")
+	}
+}
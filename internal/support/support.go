@@ -0,0 +1,159 @@
+package support
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/cache"
+	"sda-filesystem/internal/config"
+	"sda-filesystem/internal/logs"
+)
+
+// Info describes the state of Data Gateway when the support bundle was created
+type Info struct {
+	Created           time.Time   `json:"created"`
+	Version           string      `json:"version"`
+	OS                string      `json:"os"`
+	Arch              string      `json:"arch"`
+	GoVersion         string      `json:"go_version"`
+	LogLevel          string      `json:"loglevel"`
+	Project           string      `json:"project,omitempty"`
+	ProjectType       string      `json:"project_type,omitempty"`
+	SDConnect         bool        `json:"sd_connect"`
+	S3Access          bool        `json:"s3_access"`
+	Repositories      []string    `json:"repositories"`
+	Cache             cache.Stats `json:"cache"`
+	Mount             string      `json:"mount,omitempty"`
+	Mounted           bool        `json:"mounted"`
+	CertificateExpiry *time.Time  `json:"certificate_expiry,omitempty"`
+	ConfigFile        string      `json:"config_file,omitempty"`
+	Profile           string      `json:"profile,omitempty"`
+	Problems          []string    `json:"problems,omitempty"` // Information that could not be collected
+}
+
+// Bundle is everything that is packaged into a support bundle. Secrets have been redacted from all of it.
+type Bundle struct {
+	Info          Info     `json:"info"`
+	Logs          []string `json:"logs"`
+	Configuration []byte   `json:"configuration,omitempty"` // configuration.json
+	Settings      []byte   `json:"settings,omitempty"`      // The configuration file
+}
+
+// readFile is a variable so that it can be mocked in tests
+var readFile = os.ReadFile
+
+// Collect gathers the support bundle from the current process. `settings` is the configuration file in use,
+// `mount` the mount point and `mounted` whether Data Gateway is mounted there. `files` contains the
+// files from the `certs` directory.
+var Collect = func(settings *config.Config, mount string, mounted bool, files api.FileReader) Bundle {
+	info := Info{
+		Created:      time.Now().UTC(),
+		Version:      api.GetVersion(),
+		OS:           runtime.GOOS,
+		Arch:         runtime.GOARCH,
+		GoVersion:    runtime.Version(),
+		LogLevel:     logs.GetLevel().String(),
+		Project:      api.GetProjectName(),
+		Repositories: []string{},
+		Cache:        api.GetCacheStats(),
+		Mount:        mount,
+		Mounted:      mounted,
+		ConfigFile:   settings.Path(),
+		Profile:      settings.Profile(),
+	}
+	problem := func(format string, args ...any) {
+		info.Problems = append(info.Problems, logs.Redact(fmt.Errorf(format, args...).Error()))
+	}
+
+	if info.Project != "" {
+		info.ProjectType = api.GetProjectType()
+		info.SDConnect = api.SDConnectEnabled()
+		info.S3Access = api.S3AccessEnabled()
+		for _, rep := range api.GetRepositories() {
+			info.Repositories = append(info.Repositories, rep.ForPath())
+		}
+	} else {
+		problem("profile has not been fetched")
+	}
+
+	if proxy, _, err := api.GetURLs(); err != nil {
+		problem("could not determine proxy: %w", err)
+	} else if expiry, err := api.CertificateExpiry(files, proxy); err != nil {
+		problem("could not read client certificate: %w", err)
+	} else {
+		info.CertificateExpiry = &expiry
+	}
+
+	bundle := Bundle{Logs: logs.Recent()}
+	configuration, err := api.GetConfiguration()
+	switch {
+	case err != nil:
+		problem("%w", err)
+	case configuration == nil:
+		problem("configuration.json has not been fetched")
+	default:
+		bundle.Configuration = configuration
+	}
+
+	if len(settings.Keys()) > 0 {
+		data, err := readFile(settings.Path())
+		if err != nil {
+			problem("could not read configuration file: %w", err)
+		} else {
+			bundle.Settings = []byte(logs.Redact(string(data)))
+		}
+	}
+	bundle.Info = info
+
+	return bundle
+}
+
+// FileName returns the name of a bundle created at `t`
+func FileName(t time.Time) string {
+	return "data-gateway-support-" + t.Format("20060102-150405") + ".zip"
+}
+
+// Write writes the bundle into `w` as a zip archive that contains info.json, logs.txt and,
+// if they are available, configuration.json and config.toml
+func (b Bundle) Write(w io.Writer) error {
+	info, err := json.MarshalIndent(b.Info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode information: %w", err)
+	}
+
+	files := []struct {
+		name string
+		data []byte
+	}{
+		{"info.json", info},
+		{"logs.txt", []byte(strings.Join(b.Logs, "\n") + "\n")},
+		{"configuration.json", b.Configuration},
+		{"config.toml", b.Settings},
+	}
+
+	zw := zip.NewWriter(w)
+	for _, file := range files {
+		if file.data == nil {
+			continue
+		}
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: file.name, Method: zip.Deflate, Modified: b.Info.Created})
+		if err != nil {
+			return fmt.Errorf("failed to add %s to support bundle: %w", file.name, err)
+		}
+		if _, err = fw.Write(file.data); err != nil {
+			return fmt.Errorf("failed to add %s to support bundle: %w", file.name, err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to finish support bundle: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package support
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"sda-filesystem/internal/api"
+	"sda-filesystem/internal/config"
+)
+
+var errExpected = errors.New("expected error for test")
+
+func TestCollect(t *testing.T) {
+	origGetProjectName := api.GetProjectName
+	origGetProjectType := api.GetProjectType
+	origSDConnectEnabled := api.SDConnectEnabled
+	origS3AccessEnabled := api.S3AccessEnabled
+	origGetRepositories := api.GetRepositories
+	origGetURLs := api.GetURLs
+	origCertificateExpiry := api.CertificateExpiry
+	origGetConfiguration := api.GetConfiguration
+	defer func() {
+		api.GetProjectName = origGetProjectName
+		api.GetProjectType = origGetProjectType
+		api.SDConnectEnabled = origSDConnectEnabled
+		api.S3AccessEnabled = origS3AccessEnabled
+		api.GetRepositories = origGetRepositories
+		api.GetURLs = origGetURLs
+		api.CertificateExpiry = origCertificateExpiry
+		api.GetConfiguration = origGetConfiguration
+	}()
+
+	file := filepath.Join(t.TempDir(), "config.toml")
+	data := "[logging]\nlevel = \"debug\"\nredact = [\"SDS_ACCESS_TOKEN=0123456789abcdef0123\"]\n"
+	if err := os.WriteFile(file, []byte(data), 0o600); err != nil {
+		t.Fatalf("Could not write configuration file: %s", err.Error())
+	}
+	settings, err := config.Load(file, "")
+	if err != nil {
+		t.Fatalf("Could not load configuration file: %s", err.Error())
+	}
+	expiry := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	api.GetProjectName = func() string {
+		return "project_2001"
+	}
+	api.GetProjectType = func() string {
+		return "findata"
+	}
+	api.SDConnectEnabled = func() bool {
+		return true
+	}
+	api.S3AccessEnabled = func() bool {
+		return false
+	}
+	api.GetRepositories = func() []api.Repo {
+		return []api.Repo{api.SDApply, api.SDConnect}
+	}
+	api.GetURLs = func() (string, string, error) {
+		return "https://proxy.example.com", "https://proxy.example.com/configuration.json", nil
+	}
+	api.CertificateExpiry = func(api.FileReader, string) (time.Time, error) {
+		return expiry, nil
+	}
+	api.GetConfiguration = func() ([]byte, error) {
+		return []byte(`{"findata_upload": true}`), nil
+	}
+
+	bundle := Collect(settings, "/home/user/Projects", true, nil)
+	info := bundle.Info
+	switch {
+	case info.Project != "project_2001" || info.ProjectType != "findata" || !info.SDConnect || info.S3Access:
+		t.Errorf("Bundle has incorrect profile: %+v", info)
+	case !reflect.DeepEqual(info.Repositories, []string{"SD-Apply", "SD-Connect"}):
+		t.Errorf("Bundle has incorrect repositories %v", info.Repositories)
+	case info.Mount != "/home/user/Projects" || !info.Mounted:
+		t.Errorf("Bundle has incorrect mount state: mount=%s, mounted=%t", info.Mount, info.Mounted)
+	case info.CertificateExpiry == nil || !info.CertificateExpiry.Equal(expiry):
+		t.Errorf("Bundle has incorrect certificate expiry %v", info.CertificateExpiry)
+	case info.ConfigFile != file:
+		t.Errorf("Bundle has incorrect configuration file %s", info.ConfigFile)
+	case len(info.Problems) != 0:
+		t.Errorf("Bundle has unexpected problems %v", info.Problems)
+	case string(bundle.Configuration) != `{"findata_upload": true}`:
+		t.Errorf("Bundle has incorrect configuration.json %s", bundle.Configuration)
+	}
+	expected := "[logging]\nlevel = \"debug\"\nredact = [\"SDS_ACCESS_TOKEN=[REDACTED]\"]\n"
+	if string(bundle.Settings) != expected {
+		t.Errorf("Bundle has incorrect configuration file content\nExpected=%q\nReceived=%q", expected, bundle.Settings)
+	}
+}
+
+func TestCollect_Problems(t *testing.T) {
+	origGetProjectName := api.GetProjectName
+	origGetURLs := api.GetURLs
+	origGetConfiguration := api.GetConfiguration
+	origReadFile := readFile
+	defer func() {
+		api.GetProjectName = origGetProjectName
+		api.GetURLs = origGetURLs
+		api.GetConfiguration = origGetConfiguration
+		readFile = origReadFile
+	}()
+
+	file := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(file, []byte("[logging]\nlevel = \"debug\"\n"), 0o600); err != nil {
+		t.Fatalf("Could not write configuration file: %s", err.Error())
+	}
+	settings, err := config.Load(file, "")
+	if err != nil {
+		t.Fatalf("Could not load configuration file: %s", err.Error())
+	}
+
+	api.GetProjectName = func() string {
+		return ""
+	}
+	api.GetURLs = func() (string, string, error) {
+		return "", "", errExpected
+	}
+	api.GetConfiguration = func() ([]byte, error) {
+		return nil, nil
+	}
+	readFile = func(string) ([]byte, error) {
+		return nil, errExpected
+	}
+
+	bundle := Collect(settings, "", false, nil)
+	expected := []string{
+		"profile has not been fetched",
+		"could not determine proxy: " + errExpected.Error(),
+		"configuration.json has not been fetched",
+		"could not read configuration file: " + errExpected.Error(),
+	}
+	if !reflect.DeepEqual(bundle.Info.Problems, expected) {
+		t.Errorf("Bundle has incorrect problems\nExpected=%v\nReceived=%v", expected, bundle.Info.Problems)
+	}
+	if bundle.Configuration != nil || bundle.Settings != nil || bundle.Info.CertificateExpiry != nil {
+		t.Errorf("Bundle should not contain information that could not be collected: %+v", bundle)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	bundle := Bundle{
+		Info:          Info{Version: "1.0.0", Repositories: []string{"SD-Apply"}},
+		Logs:          []string{"first", "second"},
+		Configuration: []byte(`{"findata_upload": false}`),
+	}
+
+	buf := &bytes.Buffer{}
+	if err := bundle.Write(buf); err != nil {
+		t.Fatalf("Function returned unexpected error: %s", err.Error())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Bundle is not a valid zip archive: %s", err.Error())
+	}
+
+	contents := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Could not open %s: %s", f.Name, err.Error())
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("Could not read %s: %s", f.Name, err.Error())
+		}
+		contents[f.Name] = string(data)
+	}
+
+	if len(contents) != 3 {
+		t.Errorf("Bundle has incorrect files %v", contents)
+	}
+	if contents["logs.txt"] != "first\nsecond\n" {
+		t.Errorf("Incorrect logs.txt\nExpected=%q\nReceived=%q", "first\nsecond\n", contents["logs.txt"])
+	}
+	if contents["configuration.json"] != `{"findata_upload": false}` {
+		t.Errorf("Incorrect configuration.json %s", contents["configuration.json"])
+	}
+	var info Info
+	if err := json.Unmarshal([]byte(contents["info.json"]), &info); err != nil {
+		t.Fatalf("info.json is not valid JSON: %s", err.Error())
+	}
+	if !reflect.DeepEqual(info, bundle.Info) {
+		t.Errorf("Incorrect info.json\nExpected=%+v\nReceived=%+v", bundle.Info, info)
+	}
+}
+
+func TestFileName(t *testing.T) {
+	name := FileName(time.Date(2026, 10, 18, 9, 5, 3, 0, time.UTC))
+	if name != "data-gateway-support-20261018-090503.zip" {
+		t.Errorf("Incorrect file name. Expected=data-gateway-support-20261018-090503.zip, received=%s", name)
+	}
+}